@@ -8,18 +8,23 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
-	"fmt"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/root-secure/Amass/amass/core"
 	"github.com/root-secure/Amass/amass/utils"
+	"github.com/miekg/dns"
 )
 
 const (
 	defaultTLSConnectTimeout = 3 * time.Second
 	defaultHandshakeDeadline = 5 * time.Second
+
+	// The most in-scope names used for SNI retries against a single address
+	maxServerNamesPerAddr = 10
 )
 
 // ActiveCertService is the AmassService that handles all active certificate activities
@@ -28,11 +33,23 @@ type ActiveCertService struct {
 	core.BaseService
 
 	maxPulls utils.Semaphore
+	filter   utils.StringFilter
+
+	sync.Mutex
+	// Names that resolved to each address and are candidates for SNI
+	addrNames map[string][]string
+	// Addresses that have already had certificates pulled
+	pulled map[string]struct{}
 }
 
 // NewActiveCertService returns he object initialized, but not yet started.
 func NewActiveCertService(config *core.Config, bus *core.EventBus) *ActiveCertService {
-	acs := &ActiveCertService{maxPulls: utils.NewSimpleSemaphore(100)}
+	acs := &ActiveCertService{
+		maxPulls:  utils.NewSimpleSemaphore(100),
+		filter:    config.NewStringFilter(),
+		addrNames: make(map[string][]string),
+		pulled:    make(map[string]struct{}),
+	}
 
 	acs.BaseService = *core.NewBaseService(acs, "Active Cert", config, bus)
 	return acs
@@ -44,11 +61,18 @@ func (acs *ActiveCertService) OnStart() error {
 
 	if acs.Config().Active {
 		acs.Bus().Subscribe(core.ActiveCertTopic, acs.SendAddrRequest)
+		acs.Bus().Subscribe(core.NameResolvedTopic, acs.nameResolved)
 		go acs.processRequests()
 	}
 	return nil
 }
 
+// OnStop implements the Service interface.
+func (acs *ActiveCertService) OnStop() error {
+	acs.filter.Close()
+	return nil
+}
+
 func (acs *ActiveCertService) processRequests() {
 	for {
 		select {
@@ -66,90 +90,190 @@ func (acs *ActiveCertService) processRequests() {
 	}
 }
 
+// nameResolved records the in-scope names that resolved to each address, so the
+// certificate pulls can be retried with the names provided via SNI.
+func (acs *ActiveCertService) nameResolved(req *core.DNSRequest) {
+	if !acs.Config().IsDomainInScope(req.Name) {
+		return
+	}
+
+	for _, rec := range req.Records {
+		if rec.Type != int(dns.TypeA) && rec.Type != int(dns.TypeAAAA) {
+			continue
+		}
+
+		addr := strings.TrimSpace(rec.Data)
+		if acs.addServerName(addr, req.Name) {
+			// The address was already pulled before this name was discovered
+			go acs.pullServerName(addr, req.Name)
+		}
+	}
+}
+
+// addServerName returns true when the address has already been pulled and the name was added.
+func (acs *ActiveCertService) addServerName(addr, name string) bool {
+	acs.Lock()
+	defer acs.Unlock()
+
+	names := acs.addrNames[addr]
+	if len(names) >= maxServerNamesPerAddr {
+		return false
+	}
+	for _, n := range names {
+		if n == name {
+			return false
+		}
+	}
+	acs.addrNames[addr] = append(names, name)
+
+	_, pulled := acs.pulled[addr]
+	return pulled
+}
+
+// markPulled returns the names currently known for the address, or false
+// if the address has already been pulled.
+func (acs *ActiveCertService) markPulled(addr string) ([]string, bool) {
+	acs.Lock()
+	defer acs.Unlock()
+
+	if _, found := acs.pulled[addr]; found {
+		return nil, false
+	}
+	acs.pulled[addr] = struct{}{}
+
+	names := make([]string, len(acs.addrNames[addr]))
+	copy(names, acs.addrNames[addr])
+	return names, true
+}
+
 func (acs *ActiveCertService) performRequest(req *core.AddrRequest) {
 	defer acs.maxPulls.Release(1)
 
+	names, ok := acs.markPulled(req.Address)
+	if !ok {
+		return
+	}
+
+	acs.SetActive()
+	acs.processCerts(PullCertificates(req.Address, acs.Config().Ports, ""))
+	for _, name := range names {
+		acs.pullWithServerName(req.Address, name)
+	}
+}
+
+func (acs *ActiveCertService) pullServerName(addr, name string) {
+	acs.maxPulls.Acquire(1)
+	defer acs.maxPulls.Release(1)
+
+	acs.pullWithServerName(addr, name)
+}
+
+func (acs *ActiveCertService) pullWithServerName(addr, name string) {
+	if acs.filter.Duplicate(addr + "," + name) {
+		return
+	}
+
 	acs.SetActive()
-	for _, r := range PullCertificateNames(req.Address, acs.Config().Ports) {
-		if domain := acs.Config().WhichDomain(r.Name); domain != "" {
-			r.Domain = domain
-			r.Source = acs.String()
-			acs.Bus().Publish(core.NewNameTopic, r)
+	acs.processCerts(PullCertificates(addr, acs.Config().Ports, name))
+}
+
+func (acs *ActiveCertService) processCerts(certs []*core.CertRequest) {
+	for _, cert := range certs {
+		cert.Tag = core.CERT
+		cert.Source = acs.String()
+		// Only provide each certificate seen on an address and port once
+		key := cert.Address + "," + strconv.Itoa(cert.Port) + "," + cert.Fingerprint
+		if !acs.filter.Duplicate(key) {
+			acs.Bus().Publish(core.NewCertTopic, cert)
+		}
+
+		for _, r := range reqFromNames(cert.Names) {
+			if domain := acs.Config().WhichDomain(r.Name); domain != "" {
+				r.Domain = domain
+				r.Source = acs.String()
+				acs.Bus().Publish(core.NewNameTopic, r)
+			}
 		}
 	}
 }
 
 // PullCertificateNames attempts to pull a cert from one or more ports on an IP.
+// PullCertificates provides the subject organization along with the names.
 func PullCertificateNames(addr string, ports []int) []*core.DNSRequest {
 	var requests []*core.DNSRequest
 
+	for _, cert := range PullCertificates(addr, ports, "") {
+		// Create the new requests from names found within the cert
+		requests = append(requests, reqFromNames(cert.Names)...)
+	}
+	return requests
+}
+
+// PullCertificates attempts to pull a cert from one or more ports on an IP. When serverName
+// is not empty, it is provided to the server via SNI. Ports belonging to plaintext protocols
+// that support STARTTLS are upgraded before the TLS handshake is performed.
+func PullCertificates(addr string, ports []int, serverName string) []*core.CertRequest {
+	var certs []*core.CertRequest
+
 	// Check hosts for certificates that contain subdomain names
 	for _, port := range ports {
-		cfg := &tls.Config{InsecureSkipVerify: true}
-		// Set the maximum time allowed for making the connection
-		ctx, cancel := context.WithTimeout(context.Background(), defaultTLSConnectTimeout)
-		defer cancel()
-		// Obtain the connection
-		d := net.Dialer{}
-		conn, err := d.DialContext(ctx, "tcp", addr+":"+strconv.Itoa(port))
+		cert, err := pullCertificate(addr, port, serverName)
 		if err != nil {
 			continue
 		}
-		defer conn.Close()
-
-		c := tls.Client(conn, cfg)
-		// Attempt to acquire the certificate chain
-		errChan := make(chan error, 2)
-		// This goroutine will break us out of the handshake
-		time.AfterFunc(defaultHandshakeDeadline, func() {
-			errChan <- errors.New("Handshake timeout")
-		})
-		// Be sure we do not wait too long in this attempt
-		c.SetDeadline(time.Now().Add(defaultHandshakeDeadline))
-		// The handshake is performed in the goroutine
-		go func() {
-			errChan <- c.Handshake()
-		}()
-		// The error channel returns handshake or timeout error
-		if err = <-errChan; err != nil {
-			continue
-		}
-		// Get the correct certificate in the chain
-		certChain := c.ConnectionState().PeerCertificates
-		cert := certChain[0]
-		// Create the new requests from names found within the cert
-		requests = append(requests, reqFromNames(namesFromCert(cert))...)
+
+		req := core.NewCertRequest(cert)
+		req.Address = addr
+		req.Port = port
+		req.ServerName = serverName
+		certs = append(certs, req)
 	}
-	return requests
+	return certs
 }
 
-func namesFromCert(cert *x509.Certificate) []string {
-	var cn string
-
-	for _, name := range cert.Subject.Names {
-		oid := name.Type
-		if len(oid) == 4 && oid[0] == 2 && oid[1] == 5 && oid[2] == 4 {
-			if oid[3] == 3 {
-				cn = fmt.Sprintf("%s", name.Value)
-				break
-			}
-		}
+func pullCertificate(addr string, port int, serverName string) (*x509.Certificate, error) {
+	cfg := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	}
+	// Set the maximum time allowed for making the connection
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTLSConnectTimeout)
+	defer cancel()
+	// Obtain the connection
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// Be sure we do not wait too long in this attempt
+	conn.SetDeadline(time.Now().Add(defaultHandshakeDeadline))
+	// Upgrade plaintext protocols before starting the handshake
+	if err := startTLS(conn, port, serverName); err != nil {
+		return nil, err
 	}
 
-	var subdomains []string
-	// Add the subject common name to the list of subdomain names
-	commonName := utils.RemoveAsteriskLabel(cn)
-	if commonName != "" {
-		subdomains = append(subdomains, commonName)
+	c := tls.Client(conn, cfg)
+	// Attempt to acquire the certificate chain
+	errChan := make(chan error, 2)
+	// This goroutine will break us out of the handshake
+	time.AfterFunc(defaultHandshakeDeadline, func() {
+		errChan <- errors.New("Handshake timeout")
+	})
+	// The handshake is performed in the goroutine
+	go func() {
+		errChan <- c.Handshake()
+	}()
+	// The error channel returns handshake or timeout error
+	if err = <-errChan; err != nil {
+		return nil, err
 	}
-	// Add the cert DNS names to the list of subdomain names
-	for _, name := range cert.DNSNames {
-		n := utils.RemoveAsteriskLabel(name)
-		if n != "" {
-			subdomains = utils.UniqueAppend(subdomains, n)
-		}
+	// Get the correct certificate in the chain
+	certChain := c.ConnectionState().PeerCertificates
+	if len(certChain) == 0 {
+		return nil, errors.New("No certificates were provided by the server")
 	}
-	return subdomains
+	return certChain[0], nil
 }
 
 func reqFromNames(subdomains []string) []*core.DNSRequest {
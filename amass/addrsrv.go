@@ -46,7 +46,7 @@ var (
 type AddressService struct {
 	core.BaseService
 
-	filter *utils.StringFilter
+	filter utils.StringFilter
 }
 
 func init() {
@@ -61,7 +61,7 @@ func init() {
 
 // NewAddressService returns he object initialized, but not yet started.
 func NewAddressService(config *core.Config, bus *core.EventBus) *AddressService {
-	as := &AddressService{filter: utils.NewStringFilter()}
+	as := &AddressService{filter: config.NewStringFilter()}
 
 	as.BaseService = *core.NewBaseService(as, "Address Service", config, bus)
 	return as
@@ -74,8 +74,16 @@ func (as *AddressService) OnStart() error {
 	as.Bus().Subscribe(core.NewAddrTopic, as.SendAddrRequest)
 	as.Bus().Subscribe(core.NewASNTopic, as.SendASNRequest)
 
+	LoadOfflineASNDatabase(as.Config())
 	// Put in requests for all the ASNs specified in the configuration
 	for _, asn := range as.Config().ASNs {
+		// The offline database is queried before the online sources
+		if db := offlineASNDatabase(); db != nil {
+			if req := db.LookupASN(asn); req != nil {
+				as.performASNRequest(req)
+				continue
+			}
+		}
 		as.Bus().Publish(core.IPToASNTopic, &core.ASNRequest{ASN: asn})
 	}
 	// Give the data sources some time to obtain the results
@@ -84,6 +92,12 @@ func (as *AddressService) OnStart() error {
 	return nil
 }
 
+// OnStop implements the Service interface
+func (as *AddressService) OnStop() error {
+	as.filter.Close()
+	return nil
+}
+
 func (as *AddressService) processRequests() {
 	for {
 		select {
@@ -139,7 +153,9 @@ func (as *AddressService) updateConfigWithNetblocks(req *core.ASNRequest) {
 		return
 	}
 
-	filter := utils.NewStringFilter()
+	filter := as.Config().NewStringFilter()
+	defer filter.Close()
+
 	for _, cidr := range as.Config().CIDRs {
 		filter.Duplicate(cidr.String())
 	}
@@ -233,6 +249,12 @@ func ipSearch(addr string) *core.ASNRequest {
 	if info := checkForReservedAddress(addr); info != nil {
 		return info
 	}
+	// Is the address announced according to the offline ASN database?
+	if db := offlineASNDatabase(); db != nil {
+		if info := db.LookupIP(addr); info != nil {
+			return info
+		}
+	}
 
 	netLock.Lock()
 	defer netLock.Unlock()
@@ -50,7 +50,7 @@ func (ac *alterationCache) update(word string) int {
 type AlterationService struct {
 	core.BaseService
 
-	filter   *utils.StringFilter
+	filter   utils.StringFilter
 	prefixes *alterationCache
 	suffixes *alterationCache
 }
@@ -58,7 +58,7 @@ type AlterationService struct {
 // NewAlterationService returns he object initialized, but not yet started.
 func NewAlterationService(config *core.Config, bus *core.EventBus) *AlterationService {
 	as := &AlterationService{
-		filter:   utils.NewStringFilter(),
+		filter:   config.NewStringFilter(),
 		prefixes: newAlterationCache(config.AltWordlist),
 		suffixes: newAlterationCache(config.AltWordlist),
 	}
@@ -78,6 +78,12 @@ func (as *AlterationService) OnStart() error {
 	return nil
 }
 
+// OnStop implements the Service interface.
+func (as *AlterationService) OnStop() error {
+	as.filter.Close()
+	return nil
+}
+
 // OnLowNumberOfNames implements the Service interface.
 func (as *AlterationService) OnLowNumberOfNames() error {
 loop:
@@ -350,7 +356,7 @@ func (as *AlterationService) substitutions(set []string) []string {
 // sendAlteredName checks that the provided name is valid before publishing it as a new name.
 func (as *AlterationService) sendAlteredName(name, domain string) {
 	name = strings.Trim(name, "-")
-	if name == "" {
+	if name == "" || !as.Config().InShard(name) {
 		return
 	}
 
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/handlers"
 	"github.com/root-secure/Amass/amass/utils"
 	"github.com/fatih/color"
 )
@@ -55,10 +56,7 @@ var (
 // TrustedTag returns true when the tag parameter is of a type that should be trusted even
 // facing DNS wildcards.
 func TrustedTag(tag string) bool {
-	if tag == core.DNS || tag == core.CERT || tag == core.ARCHIVE || tag == core.AXFR {
-		return true
-	}
-	return false
+	return core.TrustedTag(tag)
 }
 
 // ASNSummaryData stores information related to discovered ASs and netblocks.
@@ -150,6 +148,243 @@ func PrintEnumerationSummary(total int, tags map[string]int, asns map[int]*ASNSu
 	}
 }
 
+// PrintTakeoverFindings outputs the subdomain takeover findings as a separate section.
+func PrintTakeoverFindings(findings []*core.TakeoverFinding, demo bool) {
+	if len(findings) == 0 {
+		return
+	}
+
+	fmt.Fprintln(color.Error)
+	fmt.Fprintf(color.Error, "%s%s\n", yellow(strconv.Itoa(len(findings))),
+		green(" potential subdomain takeovers"))
+	for i := 0; i < 8; i++ {
+		b.Fprint(color.Error, "----------")
+	}
+	fmt.Fprintln(color.Error)
+
+	for _, f := range findings {
+		name, target := f.Name, f.Target
+		if demo {
+			name = censorDomain(name)
+			target = censorDomain(target)
+		}
+
+		provider := f.Provider
+		if provider == "" {
+			provider = "Unknown"
+		}
+		fmt.Fprintf(color.Error, "%s %s %s %s\n", green(name), blue("->"), yellow(target),
+			blue("["+provider+": "+f.Reason+"]"))
+		fmt.Fprintf(color.Error, "\t%s\n", f.Evidence)
+	}
+}
+
+// PrintFilterStats outputs the number of names held by the filter and the memory it was using.
+func PrintFilterStats(stats *FilterStats) {
+	if stats == nil || stats.Names == 0 {
+		return
+	}
+
+	fmt.Fprintln(color.Error)
+	fmt.Fprintf(color.Error, "%s%s%s%s%s\n", yellow(strconv.Itoa(stats.Names)),
+		green(" unique names filtered using approximately "),
+		yellow(strconv.FormatInt(stats.MemoryUsage/1024, 10)), green(" KB of memory"),
+		blue(" ("+stats.Mode+")"))
+}
+
+// PrintInsertStats outputs the throughput of the inserts performed by the graph database handler.
+func PrintInsertStats(stats *handlers.InsertStats) {
+	if stats == nil || stats.Batches == 0 && stats.Spooled == 0 && stats.Failed == 0 {
+		return
+	}
+
+	fmt.Fprintln(color.Error)
+	fmt.Fprintf(color.Error, "%s%s%s%s%s%s\n", yellow(strconv.Itoa(stats.Inserted)),
+		green(" data operations inserted in "), yellow(strconv.Itoa(stats.Batches)),
+		green(" batches ("), yellow(fmt.Sprintf("%.1f", stats.PerSecond())), green(" per second)"))
+	if stats.Retries > 0 {
+		fmt.Fprintf(color.Error, "%s%s\n", yellow(strconv.Itoa(stats.Retries)), green(" failed attempts were retried"))
+	}
+	if stats.Failed > 0 {
+		r.Fprintf(color.Error, "%d data operations were rejected by the database\n", stats.Failed)
+	}
+	if stats.Spooled > 0 {
+		r.Fprintf(color.Error, "%d data operations were kept in the spool file\n", stats.Spooled)
+	}
+}
+
+// PrintEmailPostures outputs the SPF, DMARC and DKIM analysis of each root domain.
+func PrintEmailPostures(postures []*core.EmailPosture, demo bool) {
+	for _, p := range postures {
+		domain := p.Domain
+		if demo {
+			domain = censorDomain(domain)
+		}
+
+		fmt.Fprintln(color.Error)
+		fmt.Fprintf(color.Error, "%s%s\n", green("Email posture of "), yellow(domain))
+		for i := 0; i < 8; i++ {
+			b.Fprint(color.Error, "----------")
+		}
+		fmt.Fprintln(color.Error)
+
+		spf := "none"
+		if p.SPF != "" {
+			spf = fmt.Sprintf("%s (%d DNS lookups)", p.SPF, p.SPFLookups)
+		}
+		dmarc := "none"
+		if p.DMARC != "" {
+			dmarc = p.DMARC
+		}
+		dkim := "none"
+		if len(p.DKIMSelectors) > 0 {
+			dkim = strings.Join(p.DKIMSelectors, ", ")
+		}
+		if demo {
+			spf = censorString(spf, 0, len(spf))
+			dmarc = censorString(dmarc, 0, len(dmarc))
+		}
+
+		fmt.Fprintf(color.Error, "%s%s\n", blue("SPF:     "), green(spf))
+		fmt.Fprintf(color.Error, "%s%s\n", blue("DMARC:   "), green(dmarc))
+		fmt.Fprintf(color.Error, "%s%s\n", blue("DKIM:    "), green(dkim))
+		for _, s := range p.Senders {
+			sender := s.Domain
+			if demo {
+				sender = censorDomain(sender)
+			}
+
+			fmt.Fprintf(color.Error, "%s%s %s\n", blue("Sender:  "), green(sender),
+				yellow(fmt.Sprintf("(%d netblocks)", len(s.CIDRs))))
+		}
+		for _, issue := range p.Issues {
+			fmt.Fprintf(color.Error, "%s%s\n", blue("Issue:   "), yellow(issue))
+		}
+	}
+}
+
+// PrintRDAPRecords outputs the registration data obtained via RDAP.
+func PrintRDAPRecords(recs []*core.RDAPRecord, demo bool) {
+	for _, rec := range recs {
+		query := rec.Query
+		registrant := rec.Registrant
+		email := rec.RegistrantEmail
+		if demo {
+			query = censorDomain(query)
+			registrant = censorString(registrant, 0, len(registrant))
+			email = censorString(email, 0, len(email))
+		}
+
+		fmt.Fprintln(color.Error)
+		fmt.Fprintf(color.Error, "%s%s\n", green("Registration of "), yellow(query))
+		for i := 0; i < 8; i++ {
+			b.Fprint(color.Error, "----------")
+		}
+		fmt.Fprintln(color.Error)
+
+		if email != "" {
+			registrant += " <" + email + ">"
+		}
+		var registered, expires string
+		if !rec.Registered.IsZero() {
+			registered = rec.Registered.Format("2006-01-02")
+		}
+		if !rec.Expires.IsZero() {
+			expires = rec.Expires.Format("2006-01-02")
+		}
+
+		line := func(label, value string) {
+			if value != "" {
+				fmt.Fprintf(color.Error, "%s%s\n", blue(label), green(value))
+			}
+		}
+		line("Handle:      ", rec.Handle)
+		line("Name:        ", rec.Name)
+		line("Registrant:  ", registrant)
+		line("Registrar:   ", rec.Registrar)
+		line("Abuse:       ", rec.AbuseEmail)
+		line("Country:     ", rec.Country)
+		line("Registered:  ", registered)
+		line("Expires:     ", expires)
+		line("Netblocks:   ", strings.Join(rec.CIDRs, ", "))
+		line("Nameservers: ", strings.Join(rec.Nameservers, ", "))
+	}
+}
+
+// PrintPivotCandidates outputs the root domains sharing infrastructure with the seed domains.
+func PrintPivotCandidates(candidates []*core.PivotCandidate, demo bool) {
+	if len(candidates) == 0 {
+		return
+	}
+
+	fmt.Fprintln(color.Error)
+	fmt.Fprintf(color.Error, "%s\n", green("Root domains sharing infrastructure (score: attributes shared)"))
+	for i := 0; i < 8; i++ {
+		b.Fprint(color.Error, "----------")
+	}
+	fmt.Fprintln(color.Error)
+
+	for _, c := range candidates {
+		domain := c.Domain
+		shared := strings.Join(c.Shared, ", ")
+		if demo {
+			domain = censorDomain(domain)
+			shared = censorString(shared, 0, len(shared))
+		}
+
+		fmt.Fprintf(color.Error, "%s %s %s\n", yellow(fmt.Sprintf("%-3d", c.Score)),
+			green(domain), blue(shared))
+	}
+}
+
+// PrintCertEvidence outputs the root domains found in certificates, grouped by the subject
+// organization, since root domains in certificates issued to the same organization are related.
+func PrintCertEvidence(evidence []*core.CertEvidence, demo bool) {
+	if len(evidence) == 0 {
+		return
+	}
+
+	fmt.Fprintln(color.Error)
+	fmt.Fprintf(color.Error, "%s\n", green("Root domains related by certificate subject organization"))
+	for i := 0; i < 8; i++ {
+		b.Fprint(color.Error, "----------")
+	}
+	fmt.Fprintln(color.Error)
+
+	var last string
+	for _, ev := range evidence {
+		org := ev.Organization
+		if ev.OrgUnit != "" {
+			org += " (" + ev.OrgUnit + ")"
+		}
+		if !strings.EqualFold(org, last) {
+			fmt.Fprintf(color.Error, "%s\n", yellow(org))
+			last = org
+		}
+
+		domain := ev.Domain
+		cn := ev.CommonName
+		if demo {
+			domain = censorDomain(domain)
+			cn = censorString(cn, 0, len(cn))
+		}
+
+		details := []string{"CN: " + cn}
+		if ev.Serial != "" {
+			details = append(details, "Serial: "+ev.Serial)
+		}
+		if ev.Fingerprint != "" {
+			details = append(details, "SHA256: "+ev.Fingerprint)
+		}
+		if !ev.NotAfter.IsZero() {
+			details = append(details, "Expires: "+ev.NotAfter.Format("2006-01-02"))
+		}
+		details = append(details, "Source: "+ev.Source)
+
+		fmt.Fprintf(color.Error, "    %s %s\n", green(domain), blue(strings.Join(details, ", ")))
+	}
+}
+
 // PrintBanner outputs the Amass banner the same for all tools.
 func PrintBanner() {
 	y := color.New(color.FgHiYellow)
@@ -225,6 +460,30 @@ func OutputLineParts(out *core.Output, src, addrs, demo bool) (source, name, ips
 	return
 }
 
+// AssetLineParts returns the parts of a line to be printed for a handlers.Asset.
+func AssetLineParts(a *handlers.Asset, demo bool) (asset string, addrs []string) {
+	asset = a.Asset
+	for _, h := range a.Addresses {
+		addrs = append(addrs, h.Address)
+	}
+	if !demo {
+		return
+	}
+
+	switch a.Type {
+	case handlers.AssetTypeName:
+		asset = censorDomain(asset)
+	case handlers.AssetTypeAddress:
+		asset = censorIP(asset)
+	case handlers.AssetTypeNetblock:
+		asset = censorNetBlock(asset)
+	}
+	for i, addr := range addrs {
+		addrs[i] = censorIP(addr)
+	}
+	return
+}
+
 // DesiredAddrTypes removes undesired address types from the AddressInfo slice.
 func DesiredAddrTypes(addrs []core.AddressInfo, ipv4, ipv6 bool) []core.AddressInfo {
 	if !ipv4 && !ipv6 {
@@ -0,0 +1,639 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils"
+	"github.com/boltdb/bolt"
+)
+
+const (
+	// DefaultASNDatabaseFile is the file name used for the offline ASN database
+	// when a path is not provided by the configuration.
+	DefaultASNDatabaseFile = "asn.db"
+
+	// The number of prefixes written to the database by each transaction during an import
+	asnImportBatchSize = 50000
+)
+
+var (
+	asnBucket      = []byte("asns")
+	range4Bucket   = []byte("ranges4")
+	range6Bucket   = []byte("ranges6")
+	asnMetaBucket  = []byte("meta")
+	asnUpdatedKey  = []byte("updated")
+	asnSourcesKey  = []byte("sources")
+	asnDBOpenLimit = 5 * time.Second
+
+	// The offline ASN database shared by the services of this process
+	offlineLock sync.Mutex
+	offlineDB   *ASNDatabase
+)
+
+// ASNDatabase is an offline IP-to-ASN dataset stored on disk. The address space is indexed as
+// ranges that do not overlap, where each range is keyed by its first address and holds the most
+// specific prefix announced for those addresses. The longest matching prefix is found with one
+// cursor seek to the range starting at or before the address, and the nested prefixes are split
+// into ranges when they are imported.
+type ASNDatabase struct {
+	sync.Mutex
+
+	Path string
+	db   *bolt.DB
+
+	// The AS records modified during an import, written when the import is flushed
+	pending map[int]*asnRecord
+	staged  map[string]struct{}
+	batch   []asnPrefix
+}
+
+type asnRecord struct {
+	ASN            int       `json:"asn"`
+	CC             string    `json:"cc,omitempty"`
+	Registry       string    `json:"registry,omitempty"`
+	AllocationDate time.Time `json:"allocation_date,omitempty"`
+	Description    string    `json:"description,omitempty"`
+	Netblocks      []string  `json:"netblocks,omitempty"`
+}
+
+type asnPrefix struct {
+	ipnet *net.IPNet
+	asn   int
+}
+
+// asnRange is the range of addresses where the prefix is the most specific prefix announced.
+type asnRange struct {
+	start, end net.IP
+	network    net.IP
+	depth      int
+	asn        int
+}
+
+// ASNDatabasePath returns the path of the offline ASN database selected by the configuration.
+func ASNDatabasePath(config *core.Config) string {
+	if config.ASNDatabase != "" {
+		return config.ASNDatabase
+	}
+
+	dir := core.OutputDirectory(config.Dir)
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, DefaultASNDatabaseFile)
+}
+
+// NewASNDatabase opens the database at the path provided, and creates it when missing.
+func NewASNDatabase(path string) (*ASNDatabase, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("Failed to create the ASN database directory: %v", err)
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: asnDBOpenLimit})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open the ASN database: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{asnBucket, range4Bucket, range6Bucket, asnMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Failed to initialize the ASN database: %v", err)
+	}
+
+	return &ASNDatabase{
+		Path:    path,
+		db:      db,
+		pending: make(map[int]*asnRecord),
+		staged:  make(map[string]struct{}),
+	}, nil
+}
+
+// OpenASNDatabase opens an existing database at the path provided for lookups.
+func OpenASNDatabase(path string) (*ASNDatabase, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("The ASN database does not exist: %s", path)
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{
+		Timeout:  asnDBOpenLimit,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open the ASN database: %v", err)
+	}
+	return &ASNDatabase{Path: path, db: db}, nil
+}
+
+// Close releases the database file.
+func (adb *ASNDatabase) Close() error {
+	return adb.db.Close()
+}
+
+// Updated returns the time of the most recent import and the datasets imported.
+func (adb *ASNDatabase) Updated() (time.Time, []string) {
+	var updated time.Time
+	var sources []string
+
+	adb.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(asnMetaBucket)
+		if b == nil {
+			return nil
+		}
+
+		updated, _ = time.Parse(time.RFC3339, string(b.Get(asnUpdatedKey)))
+		if v := b.Get(asnSourcesKey); v != nil {
+			json.Unmarshal(v, &sources)
+		}
+		return nil
+	})
+	return updated, sources
+}
+
+// LookupIP returns the most specific prefix containing the address and the AS announcing it.
+func (adb *ASNDatabase) LookupIP(addr string) *core.ASNRequest {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+
+	bucket, bits := range6Bucket, 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bucket, bits = ip4, range4Bucket, 32
+	} else {
+		ip = ip.To16()
+	}
+
+	var result *core.ASNRequest
+	adb.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+
+		r := floorRange(b.Cursor(), ip)
+		if r == nil || bytes.Compare(r.end, ip) < 0 {
+			return nil
+		}
+
+		ipnet := &net.IPNet{IP: r.network, Mask: net.CIDRMask(r.depth, bits)}
+		result = &core.ASNRequest{
+			Address: addr,
+			ASN:     r.asn,
+			Prefix:  ipnet.String(),
+		}
+		if rec := getASNRecord(tx, result.ASN); rec != nil {
+			result.CC = rec.CC
+			result.Registry = rec.Registry
+			result.AllocationDate = rec.AllocationDate
+			result.Description = rec.Description
+		}
+		return nil
+	})
+	return result
+}
+
+// LookupASN returns the AS record, including all the netblocks announced by the AS.
+func (adb *ASNDatabase) LookupASN(asn int) *core.ASNRequest {
+	var result *core.ASNRequest
+
+	adb.db.View(func(tx *bolt.Tx) error {
+		if rec := getASNRecord(tx, asn); rec != nil {
+			result = rec.request()
+		}
+		return nil
+	})
+	return result
+}
+
+// ASNsByName returns the AS records with descriptions that contain the string provided.
+func (adb *ASNDatabase) ASNsByName(s string) []*core.ASNRequest {
+	var results []*core.ASNRequest
+
+	s = strings.ToLower(s)
+	adb.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(asnBucket)
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			var rec asnRecord
+
+			if err := json.Unmarshal(v, &rec); err == nil &&
+				strings.Contains(strings.ToLower(rec.Description), s) {
+				results = append(results, rec.request())
+			}
+			return nil
+		})
+	})
+	return results
+}
+
+// AddPrefix stages the netblock announced by the AS for insertion into the database.
+func (adb *ASNDatabase) AddPrefix(ipnet *net.IPNet, asn int) error {
+	adb.Lock()
+	defer adb.Unlock()
+
+	// Route dumps provide the same prefix once for each peer
+	key := ipnet.String()
+	if _, found := adb.staged[key]; found {
+		return nil
+	}
+	adb.staged[key] = struct{}{}
+
+	rec := adb.pendingRecord(asn)
+	rec.Netblocks = append(rec.Netblocks, key)
+	adb.batch = append(adb.batch, asnPrefix{ipnet: ipnet, asn: asn})
+	if len(adb.batch) >= asnImportBatchSize {
+		return adb.writeBatch()
+	}
+	return nil
+}
+
+// AddASNInfo stages details of the AS for insertion into the database. Empty values
+// do not replace the details already known.
+func (adb *ASNDatabase) AddASNInfo(req *core.ASNRequest) {
+	adb.Lock()
+	defer adb.Unlock()
+
+	rec := adb.pendingRecord(req.ASN)
+	if req.CC != "" {
+		rec.CC = req.CC
+	}
+	if req.Registry != "" {
+		rec.Registry = req.Registry
+	}
+	if !req.AllocationDate.IsZero() {
+		rec.AllocationDate = req.AllocationDate
+	}
+	if req.Description != "" {
+		rec.Description = req.Description
+	}
+}
+
+// Flush writes all the staged prefixes and AS records to the database, and records the dataset name.
+func (adb *ASNDatabase) Flush(dataset string) error {
+	adb.Lock()
+	defer adb.Unlock()
+
+	if err := adb.writeBatch(); err != nil {
+		return err
+	}
+
+	err := adb.db.Update(func(tx *bolt.Tx) error {
+		for asn, rec := range adb.pending {
+			// Merge with the details provided by earlier imports
+			cur := getASNRecord(tx, asn)
+			if cur == nil {
+				cur = &asnRecord{ASN: asn}
+			}
+			rec.merge(cur)
+
+			v, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(asnBucket).Put(asnKey(asn), v); err != nil {
+				return err
+			}
+		}
+
+		meta := tx.Bucket(asnMetaBucket)
+		var sources []string
+		if v := meta.Get(asnSourcesKey); v != nil {
+			json.Unmarshal(v, &sources)
+		}
+		if dataset != "" {
+			sources = utils.UniqueAppend(sources, dataset)
+		}
+
+		v, err := json.Marshal(sources)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(asnSourcesKey, v); err != nil {
+			return err
+		}
+		return meta.Put(asnUpdatedKey, []byte(time.Now().Format(time.RFC3339)))
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to write the AS records: %v", err)
+	}
+
+	adb.pending = make(map[int]*asnRecord)
+	adb.staged = make(map[string]struct{})
+	return nil
+}
+
+func (adb *ASNDatabase) pendingRecord(asn int) *asnRecord {
+	rec, found := adb.pending[asn]
+	if !found {
+		rec = &asnRecord{ASN: asn}
+		adb.pending[asn] = rec
+	}
+	return rec
+}
+
+func (adb *ASNDatabase) writeBatch() error {
+	if len(adb.batch) == 0 {
+		return nil
+	}
+
+	err := adb.db.Update(func(tx *bolt.Tx) error {
+		for _, p := range adb.batch {
+			bucket, bits := range6Bucket, 128
+			ip := p.ipnet.IP.To16()
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bucket, bits = ip4, range4Bucket, 32
+			}
+
+			depth, size := p.ipnet.Mask.Size()
+			// IPv4 prefixes can be provided with the mask of the IPv4-mapped address
+			if bits == 32 && size == 128 {
+				depth -= 96
+			}
+			if err := insertPrefix(tx.Bucket(bucket), ip, depth, bits, p.asn); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to write the prefixes: %v", err)
+	}
+
+	adb.batch = adb.batch[:0]
+	return nil
+}
+
+func (rec *asnRecord) merge(cur *asnRecord) {
+	if rec.CC == "" {
+		rec.CC = cur.CC
+	}
+	if rec.Registry == "" {
+		rec.Registry = cur.Registry
+	}
+	if rec.AllocationDate.IsZero() {
+		rec.AllocationDate = cur.AllocationDate
+	}
+	if rec.Description == "" {
+		rec.Description = cur.Description
+	}
+	// The netblock lists of large ASes are too long for utils.UniqueAppend
+	seen := make(map[string]struct{}, len(cur.Netblocks)+len(rec.Netblocks))
+	netblocks := make([]string, 0, len(cur.Netblocks)+len(rec.Netblocks))
+	for _, list := range [][]string{cur.Netblocks, rec.Netblocks} {
+		for _, n := range list {
+			if _, found := seen[n]; !found {
+				seen[n] = struct{}{}
+				netblocks = append(netblocks, n)
+			}
+		}
+	}
+	rec.Netblocks = netblocks
+}
+
+func (rec *asnRecord) request() *core.ASNRequest {
+	req := &core.ASNRequest{
+		ASN:            rec.ASN,
+		CC:             rec.CC,
+		Registry:       rec.Registry,
+		AllocationDate: rec.AllocationDate,
+		Description:    rec.Description,
+		Netblocks:      rec.Netblocks,
+		Tag:            core.EXTERNAL,
+		Source:         "ASN Database",
+	}
+	if len(rec.Netblocks) > 0 {
+		req.Prefix = rec.Netblocks[0]
+	}
+	return req
+}
+
+func getASNRecord(tx *bolt.Tx, asn int) *asnRecord {
+	b := tx.Bucket(asnBucket)
+	if b == nil {
+		return nil
+	}
+
+	v := b.Get(asnKey(asn))
+	if v == nil {
+		return nil
+	}
+
+	var rec asnRecord
+	if err := json.Unmarshal(v, &rec); err != nil {
+		return nil
+	}
+	return &rec
+}
+
+// insertPrefix adds the prefix to the ranges in the bucket. The addresses of the prefix that
+// belong to a more specific prefix keep their range, and the remaining addresses of the ranges
+// overlapped are assigned to the prefix.
+func insertPrefix(b *bolt.Bucket, ip net.IP, depth, bits, asn int) error {
+	network := ip.Mask(net.CIDRMask(depth, bits))
+	end := make(net.IP, len(network))
+	for i, mask := range net.CIDRMask(depth, bits) {
+		end[i] = network[i] | ^mask
+	}
+	p := &asnRange{start: network, end: end, network: network, depth: depth, asn: asn}
+
+	// Collect the ranges that overlap the prefix
+	var overlap []*asnRange
+	c := b.Cursor()
+	if r := floorRange(c, p.start); r != nil &&
+		bytes.Compare(r.start, p.start) < 0 && bytes.Compare(r.end, p.start) >= 0 {
+		overlap = append(overlap, r)
+	}
+	for k, v := c.Seek(p.start); k != nil && bytes.Compare(k, p.end) <= 0; k, v = c.Next() {
+		if r := parseRange(k, v); r != nil {
+			overlap = append(overlap, r)
+		}
+	}
+
+	var pieces []*asnRange
+	next := p.start
+	covered := false
+	for _, r := range overlap {
+		lo, hi := r.start, r.end
+		if bytes.Compare(lo, p.start) < 0 {
+			pieces = append(pieces, r.clip(r.start, addIP(p.start, -1)))
+			lo = p.start
+		}
+		if bytes.Compare(lo, next) > 0 {
+			pieces = append(pieces, p.clip(next, addIP(lo, -1)))
+		}
+
+		var tail *asnRange
+		if bytes.Compare(hi, p.end) > 0 {
+			tail = r.clip(addIP(p.end, 1), r.end)
+			hi = p.end
+		}
+		if r.depth > p.depth {
+			pieces = append(pieces, r.clip(lo, hi))
+		} else {
+			pieces = append(pieces, p.clip(lo, hi))
+		}
+		if tail != nil {
+			pieces = append(pieces, tail)
+		}
+
+		if bytes.Equal(hi, p.end) {
+			covered = true
+			break
+		}
+		next = addIP(hi, 1)
+	}
+	if !covered {
+		pieces = append(pieces, p.clip(next, p.end))
+	}
+
+	for _, r := range overlap {
+		if err := b.Delete(r.start); err != nil {
+			return err
+		}
+	}
+
+	var last *asnRange
+	for _, r := range pieces {
+		// Adjacent pieces of the same prefix are stored as one range
+		if last != nil && last.depth == r.depth && last.asn == r.asn && last.network.Equal(r.network) {
+			last.end = r.end
+		} else {
+			if last != nil {
+				if err := b.Put(last.start, last.value()); err != nil {
+					return err
+				}
+			}
+			last = r
+		}
+	}
+	return b.Put(last.start, last.value())
+}
+
+// floorRange returns the range with the greatest first address that is not after the address.
+func floorRange(c *bolt.Cursor, ip net.IP) *asnRange {
+	k, v := c.Seek(ip)
+	if k == nil {
+		k, v = c.Last()
+	} else if !bytes.Equal(k, ip) {
+		k, v = c.Prev()
+	}
+	if k == nil {
+		return nil
+	}
+	return parseRange(k, v)
+}
+
+// parseRange decodes the range stored with the first address as the key, and the last
+// address, prefix length, network address and ASN as the value.
+func parseRange(k, v []byte) *asnRange {
+	n := len(k)
+	if len(v) != 2*n+5 {
+		return nil
+	}
+
+	// The values are copied, since bolt only provides them during the transaction
+	return &asnRange{
+		start:   append(net.IP(nil), k...),
+		end:     append(net.IP(nil), v[:n]...),
+		depth:   int(v[n]),
+		network: append(net.IP(nil), v[n+1:2*n+1]...),
+		asn:     int(binary.BigEndian.Uint32(v[2*n+1:])),
+	}
+}
+
+func (r *asnRange) value() []byte {
+	v := append(append([]byte{}, r.end...), byte(r.depth))
+	v = append(v, r.network...)
+	return append(v, asnKey(r.asn)...)
+}
+
+// clip returns a copy of the range that only covers the addresses from lo to hi.
+func (r *asnRange) clip(lo, hi net.IP) *asnRange {
+	c := *r
+	c.start, c.end = lo, hi
+	return &c
+}
+
+// addIP returns a copy of the address incremented by delta, which is either 1 or -1.
+func addIP(ip net.IP, delta int) net.IP {
+	res := append(net.IP(nil), ip...)
+
+	for i := len(res) - 1; i >= 0; i-- {
+		if delta > 0 {
+			res[i]++
+			if res[i] != 0 {
+				break
+			}
+		} else {
+			res[i]--
+			if res[i] != 0xff {
+				break
+			}
+		}
+	}
+	return res
+}
+
+func asnKey(asn int) []byte {
+	key := make([]byte, 4)
+
+	binary.BigEndian.PutUint32(key, uint32(asn))
+	return key
+}
+
+// LoadOfflineASNDatabase makes the offline ASN database selected by the configuration
+// available to the address lookups of this process. False is returned when no database exists.
+func LoadOfflineASNDatabase(config *core.Config) bool {
+	offlineLock.Lock()
+	defer offlineLock.Unlock()
+
+	if offlineDB != nil {
+		return true
+	}
+
+	path := ASNDatabasePath(config)
+	if path == "" {
+		return false
+	}
+
+	db, err := OpenASNDatabase(path)
+	if err != nil {
+		// The database is optional unless a path was provided
+		if config.ASNDatabase != "" {
+			config.Log.Printf("%v", err)
+		}
+		return false
+	}
+
+	offlineDB = db
+	return true
+}
+
+func offlineASNDatabase() *ASNDatabase {
+	offlineLock.Lock()
+	defer offlineLock.Unlock()
+
+	return offlineDB
+}
@@ -0,0 +1,198 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestASNDatabaseImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asndb")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	adb, err := NewASNDatabase(filepath.Join(dir, DefaultASNDatabaseFile))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer adb.Close()
+
+	iptoasn := "192.0.2.0\t192.0.2.255\t64496\tUS\tEXAMPLE-NET\n" +
+		"198.51.100.0\t198.51.100.255\t0\tNone\tNot routed\n"
+	if n, err := adb.Import(strings.NewReader(iptoasn), "", "ip2asn-v4.tsv"); err != nil || n != 1 {
+		t.Fatalf("The iptoasn import returned %d prefixes and the error: %v", n, err)
+	}
+
+	bgpdump := "TABLE_DUMP2|1546300800|B|198.18.0.1|64511|192.0.2.128/25|64511 64497|IGP\n" +
+		"TABLE_DUMP2|1546300800|B|198.18.0.1|64511|2001:db8::/32|64511 64498|IGP\n"
+	if n, err := adb.Import(strings.NewReader(bgpdump), "", "rib.txt"); err != nil || n != 2 {
+		t.Fatalf("The bgpdump import returned %d prefixes and the error: %v", n, err)
+	}
+
+	asnlist := "64497,EXAMPLE-CDN - Example CDN Inc., US\n64498,EXAMPLE-V6\n"
+	if _, err := adb.Import(strings.NewReader(asnlist), ASNFormatASNList, "asnlist.txt"); err != nil {
+		t.Fatalf("The asnlist import failed: %v", err)
+	}
+
+	// The longest matching prefix must be selected
+	tests := map[string]int{
+		"192.0.2.1":      64496,
+		"192.0.2.200":    64497,
+		"2001:db8::1":    64498,
+		"198.51.100.1":   0,
+		"203.0.113.1":    0,
+		"2001:db9::1234": 0,
+	}
+	for addr, expected := range tests {
+		var asn int
+
+		if req := adb.LookupIP(addr); req != nil {
+			asn = req.ASN
+		}
+		if asn != expected {
+			t.Errorf("%s was found in AS%d, expected AS%d", addr, asn, expected)
+		}
+	}
+
+	if req := adb.LookupIP("192.0.2.200"); req == nil || req.Prefix != "192.0.2.128/25" ||
+		req.Description != "EXAMPLE-CDN - Example CDN Inc." || req.CC != "US" {
+		t.Errorf("The AS information for 192.0.2.200 was not complete: %+v", req)
+	}
+	if req := adb.LookupASN(64496); req == nil || len(req.Netblocks) != 1 || req.Netblocks[0] != "192.0.2.0/24" {
+		t.Errorf("The netblocks of AS64496 were not returned: %+v", req)
+	}
+	if reqs := adb.ASNsByName("example cdn"); len(reqs) != 1 || reqs[0].ASN != 64497 {
+		t.Errorf("The description search returned %d records", len(reqs))
+	}
+	if _, sources := adb.Updated(); len(sources) != 3 {
+		t.Errorf("The database recorded the datasets %v", sources)
+	}
+}
+
+func TestASNDatabaseImportRIR(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asndb")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	adb, err := NewASNDatabase(filepath.Join(dir, DefaultASNDatabaseFile))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer adb.Close()
+
+	// The second organization holds two ASNs, so its blocks cannot be attributed
+	rir := "2|arin|20190101|6|19830101|20190101|-0500\n" +
+		"arin|*|asn|*|2|summary\n" +
+		"arin|US|asn|64496|1|20100101|allocated|org-single\n" +
+		"arin|US|ipv4|192.0.2.0|256|20100101|allocated|org-single\n" +
+		"arin|US|asn|64500|1|20120101|assigned|org-multi\n" +
+		"arin|US|asn|64501|1|20130101|assigned|org-multi\n" +
+		"arin|US|ipv4|198.51.100.0|256|20120101|allocated|org-multi\n" +
+		"arin|US|ipv6|2001:db8::|32|20120101|allocated|org-multi\n"
+	n, err := adb.Import(strings.NewReader(rir), ASNFormatRIR, "delegated-arin-extended-latest")
+	if err != nil || n != 1 {
+		t.Fatalf("The RIR import returned %d prefixes and the error: %v", n, err)
+	}
+
+	if req := adb.LookupIP("192.0.2.1"); req == nil || req.ASN != 64496 || req.Registry != "arin" {
+		t.Errorf("The block of the organization with one ASN was not attributed: %+v", req)
+	}
+	for _, addr := range []string{"198.51.100.1", "2001:db8::1"} {
+		if req := adb.LookupIP(addr); req != nil {
+			t.Errorf("The block of the organization with two ASNs was attributed to AS%d", req.ASN)
+		}
+	}
+	if req := adb.LookupASN(64501); req == nil || req.CC != "US" {
+		t.Errorf("The AS information of AS64501 was not imported: %+v", req)
+	}
+}
+
+func TestASNDatabaseNestedPrefixes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asndb")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	adb, err := NewASNDatabase(filepath.Join(dir, DefaultASNDatabaseFile))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer adb.Close()
+
+	// The prefixes are imported by separate datasets, with the nested prefixes before
+	// and after the prefixes containing them
+	datasets := [][]string{
+		{"192.0.2.64/26|64501", "10.1.0.0/16|64502", "255.255.255.255/32|64503", "2001:db8:1::/48|64504"},
+		{"192.0.2.0/24|64496", "10.0.0.0/8|64497", "0.0.0.0/0|64498", "2001:db8::/32|64499"},
+		{"192.0.2.96/27|64505", "192.0.2.0/25|64506", "10.1.2.0/24|64507", "192.0.2.0/24|64508"},
+	}
+	for i, prefixes := range datasets {
+		for _, p := range prefixes {
+			parts := strings.Split(p, "|")
+			_, ipnet, _ := net.ParseCIDR(parts[0])
+			asn, _ := strconv.Atoi(parts[1])
+
+			if err := adb.AddPrefix(ipnet, asn); err != nil {
+				t.Fatalf("Failed to add the prefix %s: %v", parts[0], err)
+			}
+		}
+		if err := adb.Flush("dataset" + strconv.Itoa(i)); err != nil {
+			t.Fatalf("Failed to flush the dataset: %v", err)
+		}
+	}
+
+	tests := map[string]string{
+		"192.0.2.1":       "192.0.2.0/25|64506",
+		"192.0.2.64":      "192.0.2.64/26|64501",
+		"192.0.2.100":     "192.0.2.96/27|64505",
+		"192.0.2.127":     "192.0.2.96/27|64505",
+		"192.0.2.128":     "192.0.2.0/24|64508",
+		"192.0.2.255":     "192.0.2.0/24|64508",
+		"192.0.3.0":       "0.0.0.0/0|64498",
+		"10.1.2.3":        "10.1.2.0/24|64507",
+		"10.1.3.0":        "10.1.0.0/16|64502",
+		"10.2.0.0":        "10.0.0.0/8|64497",
+		"0.0.0.0":         "0.0.0.0/0|64498",
+		"255.255.255.254": "0.0.0.0/0|64498",
+		"255.255.255.255": "255.255.255.255/32|64503",
+		"2001:db8:1::1":   "2001:db8:1::/48|64504",
+		"2001:db8:2::1":   "2001:db8::/32|64499",
+		"2001:db7::1":     "",
+	}
+	for addr, expected := range tests {
+		var got string
+
+		if req := adb.LookupIP(addr); req != nil {
+			got = req.Prefix + "|" + strconv.Itoa(req.ASN)
+		}
+		if got != expected {
+			t.Errorf("%s was found in %s, expected %s", addr, got, expected)
+		}
+	}
+}
+
+func TestRangeToCIDRs(t *testing.T) {
+	cidrs := rangeToCIDRs(net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.8"))
+
+	expected := []string{"192.0.2.1/32", "192.0.2.2/31", "192.0.2.4/30", "192.0.2.8/32"}
+	if len(cidrs) != len(expected) {
+		t.Fatalf("rangeToCIDRs returned %v, expected %v", cidrs, expected)
+	}
+	for i, cidr := range cidrs {
+		if cidr.String() != expected[i] {
+			t.Errorf("CIDR %d was %s, expected %s", i, cidr.String(), expected[i])
+		}
+	}
+}
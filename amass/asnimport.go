@@ -0,0 +1,526 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+)
+
+// The IP-to-ASN dataset formats accepted by ASNDatabase.Import.
+const (
+	// Tab separated ranges, as provided by iptoasn.com
+	ASNFormatIPtoASN = "iptoasn"
+
+	// Pipe separated RIR statistics exchange (delegation) files
+	ASNFormatRIR = "rir"
+
+	// MRT TABLE_DUMP_V2 routing table (RIB) dumps
+	ASNFormatMRT = "mrt"
+
+	// MRT routing table dumps converted to text by 'bgpdump -m'
+	ASNFormatBGPDump = "bgpdump"
+
+	// The ASN and description pairs of the Amass asnlist.txt wordlist
+	ASNFormatASNList = "asnlist"
+)
+
+// The MRT record types and subtypes used by the importer (RFC 6396)
+const (
+	mrtTableDumpV2     = 13
+	mrtRIBIPv4Unicast  = 2
+	mrtRIBIPv6Unicast  = 4
+	mrtHeaderLen       = 12
+	bgpAttrASPath      = 2
+	bgpAttrExtendedLen = 0x10
+	bgpASSequence      = 2
+)
+
+var asnListRE = regexp.MustCompile(`^[0-9]+,`)
+
+// Import loads the IP-to-ASN dataset read from r into the database. The format is
+// detected when not provided, and gzip or bzip2 compressed input is accepted.
+// The number of prefixes imported is returned.
+func (adb *ASNDatabase) Import(r io.Reader, format, dataset string) (int, error) {
+	br, err := decompressReader(bufio.NewReaderSize(r, 64*1024))
+	if err != nil {
+		return 0, err
+	}
+
+	if format == "" {
+		format, err = detectASNFormat(br)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var count int
+	switch strings.ToLower(format) {
+	case ASNFormatIPtoASN:
+		count, err = adb.importIPtoASN(br)
+	case ASNFormatRIR:
+		count, err = adb.importRIR(br)
+	case ASNFormatMRT:
+		count, err = adb.importMRT(br)
+	case ASNFormatBGPDump:
+		count, err = adb.importBGPDump(br)
+	case ASNFormatASNList:
+		err = adb.importASNList(br)
+	default:
+		return 0, fmt.Errorf("%s is not a supported ASN dataset format", format)
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, adb.Flush(dataset)
+}
+
+func decompressReader(br *bufio.Reader) (*bufio.Reader, error) {
+	magic, _ := br.Peek(3)
+
+	if len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decompress the ASN dataset: %v", err)
+		}
+		return bufio.NewReaderSize(gz, 64*1024), nil
+	}
+	if bytes.Equal(magic, []byte("BZh")) {
+		return bufio.NewReaderSize(bzip2.NewReader(br), 64*1024), nil
+	}
+	return br, nil
+}
+
+func detectASNFormat(br *bufio.Reader) (string, error) {
+	head, _ := br.Peek(4096)
+	if len(head) == 0 {
+		return "", errors.New("The ASN dataset is empty")
+	}
+	// MRT records start with a binary header providing the record type
+	if len(head) >= mrtHeaderLen && binary.BigEndian.Uint16(head[4:6]) == mrtTableDumpV2 {
+		return ASNFormatMRT, nil
+	}
+
+	for _, line := range strings.Split(string(head), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "TABLE_DUMP"):
+			return ASNFormatBGPDump, nil
+		case strings.Contains(line, "|"):
+			return ASNFormatRIR, nil
+		case len(strings.Split(line, "\t")) >= 3:
+			return ASNFormatIPtoASN, nil
+		case asnListRE.MatchString(line):
+			return ASNFormatASNList, nil
+		}
+		break
+	}
+	return "", errors.New("The format of the ASN dataset could not be detected")
+}
+
+// importIPtoASN handles lines with the format: range_start range_end AS_number country_code AS_description
+func (adb *ASNDatabase) importIPtoASN(r io.Reader) (int, error) {
+	var count int
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		parts := strings.Split(strings.TrimSpace(scanner.Text()), "\t")
+		if len(parts) < 3 {
+			continue
+		}
+
+		asn, err := strconv.Atoi(parts[2])
+		// AS number zero identifies address ranges that are not routed
+		if err != nil || asn == 0 {
+			continue
+		}
+
+		info := &core.ASNRequest{ASN: asn}
+		if len(parts) > 3 && parts[3] != "None" {
+			info.CC = parts[3]
+		}
+		if len(parts) > 4 && parts[4] != "Not routed" {
+			info.Description = parts[4]
+		}
+		adb.AddASNInfo(info)
+
+		start, end := net.ParseIP(parts[0]), net.ParseIP(parts[1])
+		if start == nil || end == nil {
+			continue
+		}
+		for _, ipnet := range rangeToCIDRs(start, end) {
+			if err := adb.AddPrefix(ipnet, asn); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, scanner.Err()
+}
+
+// importRIR handles lines with the format: registry|cc|type|start|value|date|status[|opaque-id]
+// The address blocks are associated with ASNs using the opaque-id of the extended format, which
+// identifies the organization holding the resources. Since the delegations do not provide the AS
+// announcing each block, blocks held by organizations with more than one ASN are not imported.
+func (adb *ASNDatabase) importRIR(r io.Reader) (int, error) {
+	type delegation struct {
+		start, end net.IP
+		prefix     *net.IPNet
+		opaque     string
+	}
+
+	var count int
+	var blocks []delegation
+	holders := make(map[string][]int)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, "|")
+		// Skip the version and summary lines
+		if len(parts) < 7 || parts[1] == "*" {
+			continue
+		}
+		if status := parts[6]; status != "allocated" && status != "assigned" {
+			continue
+		}
+
+		var opaque string
+		if len(parts) > 7 {
+			opaque = parts[7]
+		}
+
+		switch parts[2] {
+		case "asn":
+			first, err1 := strconv.Atoi(parts[3])
+			num, err2 := strconv.Atoi(parts[4])
+			if err1 != nil || err2 != nil {
+				continue
+			}
+
+			date, _ := time.Parse("20060102", parts[5])
+			for asn := first; asn < first+num; asn++ {
+				adb.AddASNInfo(&core.ASNRequest{
+					ASN:            asn,
+					CC:             parts[1],
+					Registry:       parts[0],
+					AllocationDate: date,
+				})
+			}
+			if opaque != "" {
+				for asn := first; asn < first+num; asn++ {
+					holders[opaque] = append(holders[opaque], asn)
+				}
+			}
+		case "ipv4":
+			start := net.ParseIP(parts[3])
+			num, err := strconv.ParseInt(parts[4], 10, 64)
+			if start == nil || err != nil || num < 1 {
+				continue
+			}
+
+			end := new(big.Int).Add(new(big.Int).SetBytes(start.To4()), big.NewInt(num-1))
+			blocks = append(blocks, delegation{
+				start:  start,
+				end:    bigToIP(end, 32),
+				opaque: opaque,
+			})
+		case "ipv6":
+			if _, ipnet, err := net.ParseCIDR(parts[3] + "/" + parts[4]); err == nil {
+				blocks = append(blocks, delegation{prefix: ipnet, opaque: opaque})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+
+	for _, b := range blocks {
+		// The block cannot be attributed to one of the ASNs held by the organization
+		asns := holders[b.opaque]
+		if b.opaque == "" || len(asns) != 1 {
+			continue
+		}
+		asn := asns[0]
+
+		cidrs := []*net.IPNet{b.prefix}
+		if b.prefix == nil {
+			cidrs = rangeToCIDRs(b.start, b.end)
+		}
+		for _, ipnet := range cidrs {
+			if err := adb.AddPrefix(ipnet, asn); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// importMRT handles the RIB entries of MRT TABLE_DUMP_V2 routing table dumps.
+func (adb *ASNDatabase) importMRT(r io.Reader) (int, error) {
+	var count int
+
+	header := make([]byte, mrtHeaderLen)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			break
+		} else if err != nil {
+			return count, fmt.Errorf("Failed to read the MRT record header: %v", err)
+		}
+
+		rtype := binary.BigEndian.Uint16(header[4:6])
+		subtype := binary.BigEndian.Uint16(header[6:8])
+		body := make([]byte, binary.BigEndian.Uint32(header[8:12]))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return count, fmt.Errorf("Failed to read the MRT record: %v", err)
+		}
+
+		if rtype != mrtTableDumpV2 {
+			continue
+		}
+
+		var bits int
+		switch subtype {
+		case mrtRIBIPv4Unicast:
+			bits = 32
+		case mrtRIBIPv6Unicast:
+			bits = 128
+		default:
+			continue
+		}
+
+		ipnet, asn, err := parseMRTRIB(body, bits)
+		if err != nil || asn == 0 {
+			continue
+		}
+		if err := adb.AddPrefix(ipnet, asn); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// parseMRTRIB returns the prefix of the RIB entry and the origin AS of the first route.
+func parseMRTRIB(body []byte, bits int) (*net.IPNet, int, error) {
+	errShort := errors.New("The MRT RIB entry is truncated")
+
+	// Skip the sequence number
+	if len(body) < 5 {
+		return nil, 0, errShort
+	}
+	plen := int(body[4])
+	if plen > bits {
+		return nil, 0, errors.New("The MRT RIB entry has an invalid prefix length")
+	}
+
+	nbytes := (plen + 7) / 8
+	body = body[5:]
+	if len(body) < nbytes+2 {
+		return nil, 0, errShort
+	}
+
+	ip := make(net.IP, bits/8)
+	copy(ip, body[:nbytes])
+	ipnet := &net.IPNet{IP: ip, Mask: net.CIDRMask(plen, bits)}
+	ipnet.IP = ipnet.IP.Mask(ipnet.Mask)
+
+	entries := int(binary.BigEndian.Uint16(body[nbytes : nbytes+2]))
+	body = body[nbytes+2:]
+	for i := 0; i < entries; i++ {
+		// Skip the peer index and originated time
+		if len(body) < 8 {
+			return nil, 0, errShort
+		}
+		alen := int(binary.BigEndian.Uint16(body[6:8]))
+		if len(body) < 8+alen {
+			return nil, 0, errShort
+		}
+
+		if asn := originFromAttrs(body[8 : 8+alen]); asn != 0 {
+			return ipnet, asn, nil
+		}
+		body = body[8+alen:]
+	}
+	return ipnet, 0, nil
+}
+
+// originFromAttrs returns the last AS of the AS_PATH attribute found in the BGP path attributes.
+func originFromAttrs(attrs []byte) int {
+	for len(attrs) >= 3 {
+		flags, atype := attrs[0], attrs[1]
+
+		var alen, hlen int
+		if flags&bgpAttrExtendedLen != 0 {
+			if len(attrs) < 4 {
+				return 0
+			}
+			alen, hlen = int(binary.BigEndian.Uint16(attrs[2:4])), 4
+		} else {
+			alen, hlen = int(attrs[2]), 3
+		}
+		if len(attrs) < hlen+alen {
+			return 0
+		}
+
+		if atype == bgpAttrASPath {
+			return originFromASPath(attrs[hlen : hlen+alen])
+		}
+		attrs = attrs[hlen+alen:]
+	}
+	return 0
+}
+
+func originFromASPath(path []byte) int {
+	var origin int
+
+	// TABLE_DUMP_V2 always uses four octet AS numbers
+	for len(path) >= 2 {
+		stype, num := path[0], int(path[1])
+		if len(path) < 2+num*4 {
+			break
+		}
+
+		if stype == bgpASSequence && num > 0 {
+			origin = int(binary.BigEndian.Uint32(path[2+(num-1)*4:]))
+		}
+		path = path[2+num*4:]
+	}
+	return origin
+}
+
+// importBGPDump handles lines with the format: TABLE_DUMP2|time|B|peer_ip|peer_as|prefix|as_path|...
+func (adb *ASNDatabase) importBGPDump(r io.Reader) (int, error) {
+	var count int
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), "|")
+		if len(parts) < 7 {
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(parts[5])
+		if err != nil {
+			continue
+		}
+
+		path := strings.Fields(parts[6])
+		if len(path) == 0 {
+			continue
+		}
+		// An AS_SET at the end of the path does not identify a single origin
+		asn, err := strconv.Atoi(path[len(path)-1])
+		if err != nil || asn == 0 {
+			continue
+		}
+
+		if err := adb.AddPrefix(ipnet, asn); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, scanner.Err()
+}
+
+// importASNList handles lines with the format: AS_number,AS_description[, country_code]
+func (adb *ASNDatabase) importASNList(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(scanner.Text()), ",", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		asn, err := strconv.Atoi(parts[0])
+		if err != nil || asn == 0 {
+			continue
+		}
+
+		info := &core.ASNRequest{
+			ASN:         asn,
+			Description: strings.TrimSpace(parts[1]),
+		}
+		if idx := strings.LastIndex(info.Description, ","); idx != -1 {
+			if cc := strings.TrimSpace(info.Description[idx+1:]); len(cc) == 2 {
+				info.CC = cc
+				info.Description = strings.TrimSpace(info.Description[:idx])
+			}
+		}
+		adb.AddASNInfo(info)
+	}
+	return scanner.Err()
+}
+
+// rangeToCIDRs returns the fewest CIDRs that cover the address range provided.
+func rangeToCIDRs(start, end net.IP) []*net.IPNet {
+	bits := 128
+	if s4, e4 := start.To4(), end.To4(); s4 != nil && e4 != nil {
+		start, end, bits = s4, e4, 32
+	} else {
+		start, end = start.To16(), end.To16()
+	}
+
+	one := big.NewInt(1)
+	s := new(big.Int).SetBytes(start)
+	e := new(big.Int).SetBytes(end)
+
+	var cidrs []*net.IPNet
+	for s.Cmp(e) <= 0 {
+		// Find the largest block aligned on s that does not pass the end of the range
+		plen := bits
+		for plen > 0 {
+			hostmask := new(big.Int).Sub(new(big.Int).Lsh(one, uint(bits-plen+1)), one)
+			if new(big.Int).And(s, hostmask).Sign() != 0 ||
+				new(big.Int).Add(s, hostmask).Cmp(e) > 0 {
+				break
+			}
+			plen--
+		}
+
+		cidrs = append(cidrs, &net.IPNet{
+			IP:   bigToIP(s, bits),
+			Mask: net.CIDRMask(plen, bits),
+		})
+		s.Add(s, new(big.Int).Lsh(one, uint(bits-plen)))
+	}
+	return cidrs
+}
+
+func bigToIP(n *big.Int, bits int) net.IP {
+	ip := make(net.IP, bits/8)
+	b := n.Bytes()
+
+	if len(b) > len(ip) {
+		b = b[len(b)-len(ip):]
+	}
+	copy(ip[len(ip)-len(b):], b)
+	return ip
+}
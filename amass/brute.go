@@ -30,12 +30,12 @@ type BruteForceService struct {
 	totalNames int
 	curIdx     int
 
-	filter *utils.StringFilter
+	filter utils.StringFilter
 }
 
 // NewBruteForceService returns he object initialized, but not yet started.
 func NewBruteForceService(config *core.Config, bus *core.EventBus) *BruteForceService {
-	bfs := &BruteForceService{filter: utils.NewStringFilter()}
+	bfs := &BruteForceService{filter: config.NewStringFilter()}
 
 	bfs.BaseService = *core.NewBaseService(bfs, "Brute Forcing", config, bus)
 	return bfs
@@ -81,6 +81,7 @@ func (bfs *BruteForceService) OnLowNumberOfNames() error {
 // OnStop implements the Service interface.
 func (bfs *BruteForceService) OnStop() error {
 	bfs.metrics.Stop()
+	bfs.filter.Close()
 	return nil
 }
 
@@ -159,10 +160,15 @@ func (bfs *BruteForceService) performBruteForcing(subdomain, domain string) {
 			if idx >= len(bfs.Config().Wordlist) {
 				return
 			}
-			bfs.Config().SemMaxDNSQueries.Acquire(1)
 			word := strings.ToLower(bfs.Config().Wordlist[idx])
-			go bfs.bruteForceResolution(word, subdomain, domain)
 			idx++
+			// Skip the names that belong to another shard of the enumeration
+			if !bfs.Config().InShard(word + "." + subdomain) {
+				bfs.decTotalNames()
+				continue
+			}
+			bfs.Config().SemMaxDNSQueries.Acquire(1)
+			go bfs.bruteForceResolution(word, subdomain, domain)
 		}
 	}
 }
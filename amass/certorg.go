@@ -0,0 +1,204 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/handlers"
+	"github.com/root-secure/Amass/amass/sources"
+	"github.com/root-secure/Amass/amass/utils"
+)
+
+// OrganizationCertificates discovers the root domains in certificates issued to the organization.
+// Certificates are obtained from the graph and the data sources able to search by the subject
+// organization. Each root domain is provided with the certificates that link it to the organization.
+func (ic *IntelCollection) OrganizationCertificates(org string) error {
+	defer close(ic.Output)
+
+	org = strings.TrimSpace(org)
+	if org == "" {
+		return errors.New("No organization was provided")
+	} else if err := ic.Config.CheckSettings(); err != nil {
+		return err
+	}
+
+	var certs []*core.CertRequest
+	// The local graph databases, including the in-memory graph, can be searched for certificates
+	if g, ok := ic.Graph.(interface {
+		CertificatesByOrganization(org string) []*handlers.DataOptsParams
+	}); ok {
+		for _, data := range g.CertificatesByOrganization(org) {
+			certs = append(certs, certFromDataOpts(data))
+		}
+	}
+	certs = append(certs, ic.sourceCertificates(org)...)
+
+	filter := ic.Config.NewStringFilter()
+	defer filter.Close()
+	for _, cert := range certs {
+		// The data sources can return certificates with similar organizations
+		if !certIssuedTo(cert, org) {
+			continue
+		}
+
+		for _, ev := range ic.addCertEvidence(cert) {
+			if !filter.Duplicate(ev.Domain) {
+				ic.Output <- &core.Output{
+					Name:   ev.Domain,
+					Domain: ev.Domain,
+					Tag:    core.CERT,
+					Source: ev.Source,
+					Method: core.IntelCertOrg,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (ic *IntelCollection) sourceCertificates(org string) []*core.CertRequest {
+	var certs []*core.CertRequest
+
+	srcs := sources.GetAllSources(ic.Config, ic.Bus)
+	// Select the data sources desired by the user
+	if len(ic.Config.DisabledDataSources) > 0 {
+		srcs = ic.Config.ExcludeDisabledDataSources(srcs)
+	}
+
+	for _, src := range srcs {
+		ol, ok := src.(core.OrganizationLookup)
+		if !ok {
+			continue
+		}
+		select {
+		case <-ic.Done:
+			return certs
+		default:
+		}
+		if err := src.Start(); err != nil {
+			src.Stop()
+			continue
+		}
+
+		results, err := ol.OrganizationLookup(org)
+		if err != nil {
+			ic.Config.Log.Printf("%v", err)
+		}
+		certs = append(certs, results...)
+		src.Stop()
+	}
+	return certs
+}
+
+// addCertEvidence records the root domains found in a certificate with a subject organization,
+// and returns the evidence that was not already known.
+func (ic *IntelCollection) addCertEvidence(cert *core.CertRequest) []*core.CertEvidence {
+	org := strings.Join(cert.Organization, ", ")
+	if org == "" {
+		return nil
+	}
+
+	names := cert.Names
+	if cert.CommonName != "" {
+		names = append([]string{cert.CommonName}, names...)
+	}
+
+	var domains []string
+	for _, name := range names {
+		name = strings.ToLower(utils.RemoveAsteriskLabel(strings.TrimSpace(name)))
+		if name == "" || net.ParseIP(name) != nil {
+			continue
+		}
+		if d := strings.ToLower(strings.TrimSpace(ic.rootDomain(name))); d != "" {
+			domains = utils.UniqueAppend(domains, d)
+		}
+	}
+
+	ic.certLock.Lock()
+	defer ic.certLock.Unlock()
+
+	var added []*core.CertEvidence
+	for _, d := range domains {
+		key := d + "," + org + "," + cert.Fingerprint + "," + cert.Serial
+		if _, found := ic.certKeys[key]; found {
+			continue
+		}
+		ic.certKeys[key] = struct{}{}
+
+		ev := &core.CertEvidence{
+			Domain:       d,
+			Organization: org,
+			OrgUnit:      strings.Join(cert.OrgUnit, ", "),
+			CommonName:   cert.CommonName,
+			Issuer:       cert.Issuer,
+			NotAfter:     cert.NotAfter,
+			Serial:       cert.Serial,
+			Fingerprint:  cert.Fingerprint,
+			Source:       cert.Source,
+		}
+		ic.certEvidence = append(ic.certEvidence, ev)
+		added = append(added, ev)
+	}
+	return added
+}
+
+// CertEvidence returns the root domains found in certificates with a subject organization,
+// sorted by the organization, so root domains issued to the same organization are adjacent.
+func (ic *IntelCollection) CertEvidence() []*core.CertEvidence {
+	ic.certLock.Lock()
+	defer ic.certLock.Unlock()
+
+	evidence := make([]*core.CertEvidence, len(ic.certEvidence))
+	copy(evidence, ic.certEvidence)
+
+	sort.SliceStable(evidence, func(i, j int) bool {
+		oi, oj := strings.ToLower(evidence[i].Organization), strings.ToLower(evidence[j].Organization)
+		if oi != oj {
+			return oi < oj
+		}
+		return evidence[i].Domain < evidence[j].Domain
+	})
+	return evidence
+}
+
+func certIssuedTo(cert *core.CertRequest, org string) bool {
+	org = strings.ToLower(org)
+
+	for _, list := range [][]string{cert.Organization, cert.OrgUnit} {
+		for _, o := range list {
+			if strings.Contains(strings.ToLower(o), org) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func certFromDataOpts(data *handlers.DataOptsParams) *core.CertRequest {
+	cert := &core.CertRequest{
+		CommonName:  data.Name,
+		Names:       data.Names,
+		Issuer:      data.Issuer,
+		Serial:      data.Serial,
+		Fingerprint: data.Fingerprint,
+		Tag:         data.Tag,
+		Source:      data.Source,
+	}
+
+	if data.Organization != "" {
+		cert.Organization = []string{data.Organization}
+	}
+	if data.OrgUnit != "" {
+		cert.OrgUnit = []string{data.OrgUnit}
+	}
+	cert.NotBefore, _ = time.Parse(time.RFC3339, data.NotBefore)
+	cert.NotAfter, _ = time.Parse(time.RFC3339, data.NotAfter)
+	return cert
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/root-secure/Amass/amass/handlers"
+)
+
+func TestOrganizationCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certorg")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	graph := handlers.NewGraph(dir)
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	for _, opt := range []*handlers.DataOptsParams{
+		{Type: handlers.OptDomain, Domain: "example.com"},
+		{Type: handlers.OptCertificate, Name: "www.example.com", Names: []string{"www.example.com",
+			"shop.example-store.net"}, Organization: "Example Holdings, Inc.", OrgUnit: "IT",
+			Serial: "0a1b", Fingerprint: "aaaa", NotAfter: "2030-01-01T00:00:00Z", Source: "Active Cert"},
+		{Type: handlers.OptCertificate, Name: "mail.example.org", Names: []string{"mail.example.org"},
+			Organization: "Example Holdings, Inc.", Fingerprint: "bbbb", Source: "Censys"},
+		{Type: handlers.OptCertificate, Name: "other.com", Names: []string{"other.com"},
+			Organization: "Other Corporation", Fingerprint: "cccc", Source: "Censys"},
+	} {
+		opt.UUID = "enum1"
+		opt.Timestamp = "2019-01-01T00:00:00Z"
+		if err := graph.Insert(opt); err != nil {
+			t.Fatalf("Failed to insert the data operation: %v", err)
+		}
+	}
+
+	ic := NewIntelCollection()
+	ic.Config = setupConfig("example.com")
+	// Keep the data sources from searching for the organization over the network
+	ic.Config.DisabledDataSources = []string{"Censys", "Crtsh"}
+	ic.Graph = graph
+	ic.rootDomain = func(name string) string {
+		labels := strings.Split(name, ".")
+		return strings.Join(labels[len(labels)-2:], ".")
+	}
+
+	go ic.OrganizationCertificates("example holdings")
+	var domains []string
+	for out := range ic.Output {
+		domains = append(domains, out.Domain)
+	}
+	if len(domains) != 3 {
+		t.Errorf("The organization search returned %v", domains)
+	}
+
+	evidence := ic.CertEvidence()
+	if len(evidence) != 3 {
+		t.Fatalf("The organization search provided %d pieces of evidence, expected 3", len(evidence))
+	}
+	for i, domain := range []string{"example-store.net", "example.com", "example.org"} {
+		if ev := evidence[i]; ev.Domain != domain || ev.Organization != "Example Holdings, Inc." {
+			t.Errorf("Evidence %d was %+v, expected %s", i, ev, domain)
+		}
+	}
+	if ev := evidence[1]; ev.Fingerprint != "aaaa" || ev.OrgUnit != "IT" ||
+		ev.NotAfter.Year() != 2030 || ev.Source != "Active Cert" {
+		t.Errorf("The certificate evidence was not complete: %+v", ev)
+	}
+}
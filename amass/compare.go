@@ -0,0 +1,259 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/handlers"
+)
+
+// enumAssets holds the unique assets found by an enumeration.
+type enumAssets struct {
+	names     map[string]*handlers.QueryResult
+	addrs     map[string]struct{}
+	netblocks map[string]struct{}
+	asns      map[string]struct{}
+	cnames    map[string]struct{}
+}
+
+func newEnumAssets() *enumAssets {
+	return &enumAssets{
+		names:     make(map[string]*handlers.QueryResult),
+		addrs:     make(map[string]struct{}),
+		netblocks: make(map[string]struct{}),
+		asns:      make(map[string]struct{}),
+		cnames:    make(map[string]struct{}),
+	}
+}
+
+// add includes the assets of the result, and returns false when the name was already added.
+// The result is only kept when keep is true, since the other assets are held as strings.
+func (a *enumAssets) add(result *handlers.QueryResult, keep bool) bool {
+	name := strings.ToLower(result.Name)
+	if _, found := a.names[name]; found {
+		return false
+	}
+	a.names[name] = nil
+	if keep {
+		a.names[name] = result
+	}
+
+	for _, target := range result.CNAMETargets {
+		a.cnames[strings.ToLower(target)] = struct{}{}
+	}
+	for _, addr := range result.Addresses {
+		if addr.Address != nil {
+			a.addrs[addr.Address.String()] = struct{}{}
+		}
+		if cidr := netblockString(addr); cidr != "" {
+			a.netblocks[cidr] = struct{}{}
+		}
+		if addr.ASN != 0 {
+			a.asns[strconv.Itoa(addr.ASN)] = struct{}{}
+		}
+	}
+	return true
+}
+
+func (a *enumAssets) stats() core.TrackStats {
+	return core.TrackStats{
+		Names:        len(a.names),
+		Addresses:    len(a.addrs),
+		Netblocks:    len(a.netblocks),
+		ASNs:         len(a.asns),
+		CNAMETargets: len(a.cnames),
+	}
+}
+
+// CompareEnumerations returns the complete differences between the DNS names found by the later
+// and the earlier enumerations, and calls fn with each name that was added, removed or changed.
+// A name has changed when it resolves to different addresses or has different CNAME targets,
+// and the changed names are classified by ClassifyMovement using the signatures provided.
+func CompareEnumerations(later, earlier []*handlers.QueryResult, enum, prev string,
+	sigs []*TakeoverSignature, fn func(*core.TrackEvent)) *core.TrackComparison {
+	return CompareEnumerationStream(func(next func(*handlers.QueryResult) bool) {
+		for _, result := range later {
+			if !next(result) {
+				return
+			}
+		}
+	}, earlier, enum, prev, sigs, fn)
+}
+
+// CompareEnumerationStream performs the comparison of CompareEnumerations while the names found by
+// the later enumeration are streamed, so only the results of the earlier enumeration are held.
+// The added and changed names are provided to fn during the stream, followed by the removed names.
+func CompareEnumerationStream(later func(func(*handlers.QueryResult) bool), earlier []*handlers.QueryResult,
+	enum, prev string, sigs []*TakeoverSignature, fn func(*core.TrackEvent)) *core.TrackComparison {
+	old := newEnumAssets()
+	for _, result := range earlier {
+		old.add(result, true)
+	}
+
+	comp := &core.TrackComparison{
+		Type:         core.TrackSummary,
+		Enum:         enum,
+		PreviousEnum: prev,
+	}
+
+	cur := newEnumAssets()
+	later(func(result *handlers.QueryResult) bool {
+		if !cur.add(result, false) {
+			return true
+		}
+
+		o, found := old.names[strings.ToLower(result.Name)]
+		if !found {
+			fn(&core.TrackEvent{
+				Type:         core.TrackAdded,
+				Name:         result.Name,
+				Domain:       result.Domain,
+				Addresses:    resultAddresses(result),
+				CNAMETargets: result.CNAMETargets,
+				Enum:         enum,
+				PreviousEnum: prev,
+			})
+			return true
+		}
+
+		addrs, prevAddrs := resultAddresses(result), resultAddresses(o)
+		if sameStrings(addrs, prevAddrs) && sameStrings(result.CNAMETargets, o.CNAMETargets) {
+			comp.NamesUnchanged++
+			return true
+		}
+
+		comp.NamesChanged++
+		e := &core.TrackEvent{
+			Type:              core.TrackChanged,
+			Name:              result.Name,
+			Domain:            result.Domain,
+			Addresses:         addrs,
+			PreviousAddresses: prevAddrs,
+			CNAMETargets:      result.CNAMETargets,
+			PreviousCNAMEs:    o.CNAMETargets,
+			Enum:              enum,
+			PreviousEnum:      prev,
+		}
+		ClassifyMovement(e, result.Addresses, o.Addresses, sigs)
+		fn(e)
+		return true
+	})
+
+	seen := make(map[string]struct{})
+	for _, result := range earlier {
+		name := strings.ToLower(result.Name)
+		if _, found := cur.names[name]; found {
+			continue
+		}
+		if _, found := seen[name]; found {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		fn(&core.TrackEvent{
+			Type:              core.TrackRemoved,
+			Name:              result.Name,
+			Domain:            result.Domain,
+			PreviousAddresses: resultAddresses(result),
+			PreviousCNAMEs:    result.CNAMETargets,
+			Enum:              enum,
+			PreviousEnum:      prev,
+		})
+	}
+
+	comp.Names = diffNames(cur.names, old.names)
+	comp.Addresses = diffSets(cur.addrs, old.addrs)
+	comp.Netblocks = diffSets(cur.netblocks, old.netblocks)
+	comp.ASNs = diffSets(cur.asns, old.asns)
+	comp.CNAMETargets = diffSets(cur.cnames, old.cnames)
+	comp.Stats = cur.stats()
+	comp.PreviousStats = old.stats()
+	sortNumeric(comp.ASNs.Added)
+	sortNumeric(comp.ASNs.Removed)
+	return comp
+}
+
+func netblockString(addr core.AddressInfo) string {
+	if addr.Netblock != nil {
+		return addr.Netblock.String()
+	}
+	return addr.CIDRStr
+}
+
+func resultAddresses(result *handlers.QueryResult) []string {
+	var addrs []string
+
+	for _, addr := range result.Addresses {
+		if addr.Address != nil {
+			addrs = append(addrs, addr.Address.String())
+		}
+	}
+	return addrs
+}
+
+// sameStrings returns true when both lists hold the same values, regardless of order.
+func sameStrings(a, b []string) bool {
+	set := make(map[string]struct{})
+	for _, s := range a {
+		set[strings.ToLower(s)] = struct{}{}
+	}
+
+	other := make(map[string]struct{})
+	for _, s := range b {
+		s = strings.ToLower(s)
+		if _, found := set[s]; !found {
+			return false
+		}
+		other[s] = struct{}{}
+	}
+	return len(set) == len(other)
+}
+
+func diffNames(cur, old map[string]*handlers.QueryResult) core.TrackSetDiff {
+	c := make(map[string]struct{})
+	for name := range cur {
+		c[name] = struct{}{}
+	}
+
+	o := make(map[string]struct{})
+	for name := range old {
+		o[name] = struct{}{}
+	}
+	return diffSets(c, o)
+}
+
+// diffSets returns the sorted values only found in cur or old.
+func diffSets(cur, old map[string]struct{}) core.TrackSetDiff {
+	diff := core.TrackSetDiff{
+		Added:   []string{},
+		Removed: []string{},
+	}
+
+	for v := range cur {
+		if _, found := old[v]; !found {
+			diff.Added = append(diff.Added, v)
+		}
+	}
+	for v := range old {
+		if _, found := cur[v]; !found {
+			diff.Removed = append(diff.Removed, v)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+func sortNumeric(list []string) {
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(list[i])
+		b, _ := strconv.Atoi(list[j])
+		return a < b
+	})
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/handlers"
+)
+
+func compareTestResult(name string, cnames []string, addrs ...core.AddressInfo) *handlers.QueryResult {
+	return &handlers.QueryResult{
+		Name:         name,
+		Domain:       "example.com",
+		CNAMETargets: cnames,
+		Addresses:    addrs,
+	}
+}
+
+func compareTestAddr(addr, cidr string, asn int) core.AddressInfo {
+	_, ipnet, _ := net.ParseCIDR(cidr)
+	return core.AddressInfo{
+		Address:  net.ParseIP(addr),
+		Netblock: ipnet,
+		CIDRStr:  cidr,
+		ASN:      asn,
+	}
+}
+
+func TestCompareEnumerations(t *testing.T) {
+	earlier := []*handlers.QueryResult{
+		compareTestResult("www.example.com", nil, compareTestAddr("192.0.2.10", "192.0.2.0/24", 64500)),
+		compareTestResult("mail.example.com", nil, compareTestAddr("192.0.2.20", "192.0.2.0/24", 64500)),
+		compareTestResult("old.example.com", nil, compareTestAddr("198.51.100.5", "198.51.100.0/24", 64501)),
+		compareTestResult("static.example.com", []string{"static.cdn-a.net"},
+			compareTestAddr("203.0.113.7", "203.0.113.0/24", 13335)),
+	}
+	later := []*handlers.QueryResult{
+		compareTestResult("www.example.com", nil, compareTestAddr("192.0.2.10", "192.0.2.0/24", 64500)),
+		compareTestResult("mail.example.com", nil, compareTestAddr("203.0.113.9", "203.0.113.0/24", 13335)),
+		compareTestResult("api.example.com", nil, compareTestAddr("192.0.2.30", "192.0.2.0/24", 64500)),
+		compareTestResult("static.example.com", []string{"static.cdn-b.net"},
+			compareTestAddr("203.0.113.7", "203.0.113.0/24", 13335)),
+	}
+
+	events := make(map[string]*core.TrackEvent)
+	comp := CompareEnumerations(later, earlier, "enum-2", "enum-1", nil, func(e *core.TrackEvent) {
+		events[e.Name] = e
+	})
+
+	expected := map[string]string{
+		"mail.example.com":   core.TrackChanged,
+		"api.example.com":    core.TrackAdded,
+		"old.example.com":    core.TrackRemoved,
+		"static.example.com": core.TrackChanged,
+	}
+	if len(events) != len(expected) {
+		t.Errorf("Returned %d events", len(events))
+	}
+	for name, typ := range expected {
+		if e, found := events[name]; !found || e.Type != typ || e.Enum != "enum-2" || e.PreviousEnum != "enum-1" {
+			t.Errorf("Unexpected event for %s: %+v", name, e)
+		}
+	}
+	if e := events["static.example.com"]; e != nil && (e.PreviousCNAMEs[0] != "static.cdn-a.net" ||
+		e.CNAMETargets[0] != "static.cdn-b.net") {
+		t.Errorf("The CNAME targets were not provided: %+v", e)
+	}
+
+	if e := events["mail.example.com"]; e != nil && !reflect.DeepEqual(e.Categories, []string{core.TrackASNMove}) {
+		t.Errorf("The movement was not classified: %v", e.Categories)
+	}
+
+	if comp.Type != core.TrackSummary || comp.NamesChanged != 2 || comp.NamesUnchanged != 1 {
+		t.Errorf("Unexpected comparison: %+v", comp)
+	}
+	diffs := []struct {
+		label    string
+		diff     core.TrackSetDiff
+		expected core.TrackSetDiff
+	}{
+		{"names", comp.Names, core.TrackSetDiff{Added: []string{"api.example.com"},
+			Removed: []string{"old.example.com"}}},
+		{"addresses", comp.Addresses, core.TrackSetDiff{Added: []string{"192.0.2.30", "203.0.113.9"},
+			Removed: []string{"192.0.2.20", "198.51.100.5"}}},
+		{"netblocks", comp.Netblocks, core.TrackSetDiff{Added: []string{},
+			Removed: []string{"198.51.100.0/24"}}},
+		{"ASNs", comp.ASNs, core.TrackSetDiff{Added: []string{}, Removed: []string{"64501"}}},
+		{"CNAME targets", comp.CNAMETargets, core.TrackSetDiff{Added: []string{"static.cdn-b.net"},
+			Removed: []string{"static.cdn-a.net"}}},
+	}
+	for _, d := range diffs {
+		if !reflect.DeepEqual(d.diff, d.expected) {
+			t.Errorf("Unexpected %s difference: %+v", d.label, d.diff)
+		}
+	}
+
+	prev := core.TrackStats{Names: 4, Addresses: 4, Netblocks: 3, ASNs: 3, CNAMETargets: 1}
+	if comp.PreviousStats != prev {
+		t.Errorf("Unexpected previous stats: %+v", comp.PreviousStats)
+	}
+	cur := core.TrackStats{Names: 4, Addresses: 4, Netblocks: 2, ASNs: 2, CNAMETargets: 1}
+	if comp.Stats != cur {
+		t.Errorf("Unexpected stats: %+v", comp.Stats)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"compress/gzip"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	homedir "github.com/mitchellh/go-homedir"
 	"io"
 	"log"
@@ -19,6 +20,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/root-secure/Amass/amass/utils"
 	"github.com/go-ini/ini"
@@ -33,6 +35,12 @@ const (
 	defaultAltWordlistURL  = "https://raw.githubusercontent.com/root-secure/Amass/master/wordlists/alterations.txt"
 )
 
+// The filtering modes used by services to identify names already seen.
+const (
+	FilterExact         = "exact"
+	FilterProbabilistic = "probabilistic"
+)
+
 // Config passes along Amass configuration settings and options.
 type Config struct {
 	sync.Mutex
@@ -54,6 +62,33 @@ type Config struct {
 	GremlinUser string
 	GremlinPass string
 
+	// The settings for the batched inserts performed by the Gremlin Server handler
+	GremlinBatchSize     int
+	GremlinFlushInterval time.Duration
+	GremlinMaxRetries    int
+	GremlinSpoolFile     string
+
+	// The settings for connecting with a SQL database (sqlite3 or postgres)
+	SQLDriver     string
+	SQLDataSource string
+
+	// The settings for keeping the graph in memory, with an optional data operations snapshot file
+	MemoryGraph         bool
+	MemoryGraphSnapshot string
+
+	// The notifiers alerted when the track subcommand discovers changes
+	NotifyWebhooks     []string
+	NotifyExec         string
+	NotifySMTPServer   string
+	NotifySMTPUsername string
+	NotifySMTPPassword string
+	NotifyEmailFrom    string
+	NotifyEmailTo      []string
+
+	// The retention policy applied to the graph database after each enumeration
+	RetentionKeepLast int
+	RetentionKeepDays int
+
 	// The maximum number of concurrent DNS queries
 	MaxDNSQueries int `ini:"maximum_dns_queries"`
 
@@ -94,6 +129,12 @@ type Config struct {
 	EditDistance   int
 	AltWordlist    []string
 
+	// The slice of the generated name space processed by this host (zero-based)
+	ShardIndex int
+
+	// The total number of hosts the generated name space is split across
+	ShardCount int
+
 	// Only access the data sources for names and return results?
 	Passive bool
 
@@ -103,9 +144,39 @@ type Config struct {
 	// Determines if unresolved DNS names will be output by the enumeration
 	IncludeUnresolvable bool `ini:"include_unresolvable"`
 
+	// Determines if discovered names will be probed using HTTP and HTTPS
+	HTTPProbe bool `ini:"http_probe"`
+
+	// Determines if CNAME records will be checked for subdomain takeover opportunities
+	Takeovers bool `ini:"takeover_checks"`
+
+	// The path to the file providing the signatures of unclaimed third-party services
+	TakeoverSignatures string `ini:"takeover_signatures"`
+
+	// The path to the offline IP-to-ASN database created by 'amass asn -import'
+	ASNDatabase string `ini:"asn_database"`
+
+	// Determines if RDAP registration data is obtained for domains, ASNs and netblocks
+	RDAP bool `ini:"rdap"`
+
+	// Determines if the SPF, DMARC and DKIM configuration of root domains will be analyzed
+	EmailPosture bool
+
+	// The DKIM selectors checked for each root domain during the email posture analysis
+	DKIMSelectors []string
+
 	// A blacklist of subdomain names that will not be investigated
 	Blacklist []string
 
+	// Selects between exact and probabilistic filtering of names already seen
+	FilterMode string
+
+	// The number of names held in memory by an exact filter before it spills to disk
+	FilterMaxEntries int
+
+	// The number of requests held in memory by each service queue and the event bus before they spill to disk
+	QueueMaxInMemory int
+
 	// A list of data sources that should not be utilized
 	DisabledDataSources []string
 
@@ -141,6 +212,21 @@ func (c *Config) CheckSettings() error {
 	if c.Passive && c.Active {
 		return errors.New("Active enumeration cannot be performed without DNS resolution")
 	}
+	if c.Passive && c.HTTPProbe {
+		return errors.New("HTTP probing cannot be performed without DNS resolution")
+	}
+	if c.Passive && c.Takeovers {
+		return errors.New("Subdomain takeover checks cannot be performed without DNS resolution")
+	}
+	if c.Passive && c.EmailPosture {
+		return errors.New("Email posture analysis cannot be performed without DNS resolution")
+	}
+	if c.ShardCount > 1 && (c.ShardIndex < 0 || c.ShardIndex >= c.ShardCount) {
+		return fmt.Errorf("Shard index %d is outside the range of %d shards", c.ShardIndex+1, c.ShardCount)
+	}
+	if c.FilterMode != "" && c.FilterMode != FilterExact && c.FilterMode != FilterProbabilistic {
+		return fmt.Errorf("%s is not a valid filtering mode", c.FilterMode)
+	}
 	if c.MaxDNSQueries <= 0 {
 		c.MaxDNSQueries = 1000
 	}
@@ -267,6 +353,51 @@ func (c *Config) IsAddressInScope(addr string) bool {
 	return false
 }
 
+// InShard returns true if the generated DNS name in the parameter belongs to the
+// slice of the name space assigned to this host. All names are in the shard when
+// sharding has not been configured.
+func (c *Config) InShard(name string) bool {
+	if c.ShardCount <= 1 {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(name))))
+	return int(h.Sum32()%uint32(c.ShardCount)) == c.ShardIndex
+}
+
+// ParseShard parses a shard specification in the form 'i/n', where i is the
+// one-based index of the shard and n is the total number of shards. The
+// returned index is zero-based.
+func ParseShard(spec string) (int, int, error) {
+	parts := strings.Split(strings.TrimSpace(spec), "/")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%s is not a valid shard specification (format: i/n)", spec)
+	}
+
+	idx, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s is not a valid shard index", parts[0])
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || count < 1 {
+		return 0, 0, fmt.Errorf("%s is not a valid number of shards", parts[1])
+	}
+	if idx < 1 || idx > count {
+		return 0, 0, fmt.Errorf("Shard index %d is outside the range of %d shards", idx, count)
+	}
+	return idx - 1, count, nil
+}
+
+// NewStringFilter returns a filter of names already seen that implements the filtering
+// mode selected in the configuration. Exact filtering is used by default.
+func (c *Config) NewStringFilter() utils.StringFilter {
+	if c.FilterMode == FilterProbabilistic {
+		return utils.NewProbabilisticFilter()
+	}
+	return utils.NewExactFilter(c.Dir, c.FilterMaxEntries)
+}
+
 // Blacklisted returns true is the name in the parameter ends with a subdomain name in the config blacklist.
 func (c *Config) Blacklisted(name string) bool {
 	var resp bool
@@ -360,6 +491,115 @@ func (c *Config) loadNetworkSettings(cfg *ini.File) error {
 	return nil
 }
 
+func (c *Config) loadFilteringSettings(cfg *ini.File) error {
+	if filtering, err := cfg.GetSection("filtering"); err == nil {
+		c.FilterMode = strings.ToLower(filtering.Key("mode").MustString(FilterExact))
+		if c.FilterMode != FilterExact && c.FilterMode != FilterProbabilistic {
+			return fmt.Errorf("The filtering mode must be %s or %s", FilterExact, FilterProbabilistic)
+		}
+		c.FilterMaxEntries = filtering.Key("maximum_memory_entries").MustInt(0)
+	}
+	return nil
+}
+
+func (c *Config) loadQueueSettings(cfg *ini.File) error {
+	if queues, err := cfg.GetSection("queues"); err == nil {
+		c.QueueMaxInMemory = queues.Key("maximum_in_memory").MustInt(0)
+		if c.QueueMaxInMemory < 0 {
+			return errors.New("The queues maximum_in_memory setting cannot be negative")
+		}
+	}
+	return nil
+}
+
+func (c *Config) loadEmailPostureSettings(cfg *ini.File) error {
+	if posture, err := cfg.GetSection("email_posture"); err == nil {
+		c.EmailPosture = posture.Key("enabled").MustBool(true)
+
+		if c.EmailPosture {
+			c.DKIMSelectors = utils.UniqueAppend(c.DKIMSelectors,
+				posture.Key("dkim_selector").ValueWithShadows()...)
+			if posture.HasKey("dkim_selectors_file") {
+				path := posture.Key("dkim_selectors_file").String()
+
+				list, err := GetListFromFile(path)
+				if err != nil {
+					return fmt.Errorf("Unable to load the file in the email_posture dkim_selectors_file setting: %s: %v", path, err)
+				}
+				c.DKIMSelectors = utils.UniqueAppend(c.DKIMSelectors, list...)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) loadNotificationSettings(cfg *ini.File) error {
+	if notify, err := cfg.GetSection("notifications"); err == nil {
+		if notify.HasKey("webhook") {
+			c.NotifyWebhooks = utils.UniqueAppend(c.NotifyWebhooks, notify.Key("webhook").ValueWithShadows()...)
+		}
+		c.NotifyExec = notify.Key("exec").String()
+
+		c.NotifySMTPServer = notify.Key("smtp_server").String()
+		c.NotifySMTPUsername = notify.Key("smtp_username").String()
+		c.NotifySMTPPassword = notify.Key("smtp_password").String()
+		c.NotifyEmailFrom = notify.Key("email_from").String()
+		if notify.HasKey("email_to") {
+			c.NotifyEmailTo = utils.UniqueAppend(c.NotifyEmailTo, notify.Key("email_to").ValueWithShadows()...)
+		}
+		if c.NotifySMTPServer != "" && (c.NotifyEmailFrom == "" || len(c.NotifyEmailTo) == 0) {
+			return errors.New("The notifications smtp_server setting requires the email_from and email_to settings")
+		}
+	}
+	return nil
+}
+
+func (c *Config) loadSQLSettings(cfg *ini.File) error {
+	if sql, err := cfg.GetSection("sql"); err == nil {
+		c.SQLDriver = strings.ToLower(sql.Key("driver").String())
+		if c.SQLDriver != "sqlite3" && c.SQLDriver != "postgres" {
+			return errors.New("The sql driver must be sqlite3 or postgres")
+		}
+		c.SQLDataSource = sql.Key("data_source").String()
+	}
+	return nil
+}
+
+func (c *Config) loadGremlinSettings(cfg *ini.File) error {
+	// Load up all the Gremlin Server settings
+	if gremlin, err := cfg.GetSection("gremlin"); err == nil {
+		c.GremlinURL = gremlin.Key("url").String()
+		c.GremlinUser = gremlin.Key("username").String()
+		c.GremlinPass = gremlin.Key("password").String()
+
+		c.GremlinBatchSize = gremlin.Key("batch_size").MustInt(0)
+		if c.GremlinBatchSize < 0 {
+			return errors.New("The gremlin batch_size setting cannot be negative")
+		}
+
+		interval := gremlin.Key("flush_interval").MustInt(0)
+		if interval < 0 {
+			return errors.New("The gremlin flush_interval setting cannot be negative")
+		}
+		c.GremlinFlushInterval = time.Duration(interval) * time.Second
+
+		c.GremlinMaxRetries = gremlin.Key("max_retries").MustInt(0)
+		c.GremlinSpoolFile = gremlin.Key("spool_file").String()
+	}
+	return nil
+}
+
+func (c *Config) loadRetentionSettings(cfg *ini.File) error {
+	if retention, err := cfg.GetSection("retention"); err == nil {
+		c.RetentionKeepLast = retention.Key("keep_last").MustInt(0)
+		c.RetentionKeepDays = retention.Key("keep_days").MustInt(0)
+		if c.RetentionKeepLast < 0 || c.RetentionKeepDays < 0 {
+			return errors.New("The retention settings cannot be negative")
+		}
+	}
+	return nil
+}
+
 func (c *Config) loadBruteForceSettings(cfg *ini.File) error {
 	if bruteforce, err := cfg.GetSection("bruteforce"); err == nil {
 		c.BruteForcing = bruteforce.Key("enabled").MustBool(true)
@@ -429,6 +669,15 @@ func (c *Config) LoadSettings(path string) error {
 			c.Active = true
 		}
 	}
+	// Check if this host is only responsible for a slice of the generated names
+	if cfg.Section(ini.DEFAULT_SECTION).HasKey("shard") {
+		spec := cfg.Section(ini.DEFAULT_SECTION).Key("shard").String()
+
+		c.ShardIndex, c.ShardCount, err = ParseShard(spec)
+		if err != nil {
+			return err
+		}
+	}
 	// Load up all the DNS domain names
 	if domains, err := cfg.GetSection("domains"); err == nil {
 		for _, domain := range domains.Key("domain").ValueWithShadows() {
@@ -445,11 +694,26 @@ func (c *Config) LoadSettings(path string) error {
 		c.DisabledDataSources = utils.UniqueAppend(
 			c.DisabledDataSources, disabled.Key("data_source").ValueWithShadows()...)
 	}
-	// Load up all the Gremlin Server settings
-	if gremlin, err := cfg.GetSection("gremlin"); err == nil {
-		c.GremlinURL = gremlin.Key("url").String()
-		c.GremlinUser = gremlin.Key("username").String()
-		c.GremlinPass = gremlin.Key("password").String()
+	if err := c.loadGremlinSettings(cfg); err != nil {
+		return err
+	}
+
+	if err := c.loadSQLSettings(cfg); err != nil {
+		return err
+	}
+
+	if err := c.loadRetentionSettings(cfg); err != nil {
+		return err
+	}
+
+	if err := c.loadNotificationSettings(cfg); err != nil {
+		return err
+	}
+
+	// Load up the in-memory graph settings
+	if memory, err := cfg.GetSection("memory"); err == nil {
+		c.MemoryGraph = memory.Key("enabled").MustBool(false)
+		c.MemoryGraphSnapshot = memory.Key("snapshot_file").String()
 	}
 
 	if err := c.loadNetworkSettings(cfg); err != nil {
@@ -464,6 +728,18 @@ func (c *Config) LoadSettings(path string) error {
 		return err
 	}
 
+	if err := c.loadFilteringSettings(cfg); err != nil {
+		return err
+	}
+
+	if err := c.loadQueueSettings(cfg); err != nil {
+		return err
+	}
+
+	if err := c.loadEmailPostureSettings(cfg); err != nil {
+		return err
+	}
+
 	// Load up all API key information from data source sections
 	nonAPISections := map[string]struct{}{
 		"alterations":           struct{}{},
@@ -473,7 +749,14 @@ func (c *Config) LoadSettings(path string) error {
 		"resolvers":             struct{}{},
 		"blacklisted":           struct{}{},
 		"disabled_data_sources": struct{}{},
+		"email_posture":         struct{}{},
+		"filtering":             struct{}{},
 		"gremlin":               struct{}{},
+		"memory":                struct{}{},
+		"notifications":         struct{}{},
+		"queues":                struct{}{},
+		"retention":             struct{}{},
+		"sql":                   struct{}{},
 	}
 
 	for _, section := range cfg.Sections() {
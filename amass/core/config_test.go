@@ -3,6 +3,64 @@
 
 package core
 
+import (
+	"testing"
+)
+
+func TestParseShard(t *testing.T) {
+	tests := []struct {
+		spec  string
+		idx   int
+		count int
+		err   bool
+	}{
+		{"1/4", 0, 4, false},
+		{"4/4", 3, 4, false},
+		{" 2 / 3 ", 1, 3, false},
+		{"0/4", 0, 0, true},
+		{"5/4", 0, 0, true},
+		{"1/0", 0, 0, true},
+		{"1", 0, 0, true},
+		{"a/b", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		idx, count, err := ParseShard(tt.spec)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseShard(%q) did not return an error", tt.spec)
+			}
+			continue
+		}
+		if err != nil || idx != tt.idx || count != tt.count {
+			t.Errorf("ParseShard(%q) returned %d, %d, %v", tt.spec, idx, count, err)
+		}
+	}
+}
+
+func TestInShard(t *testing.T) {
+	names := []string{"www.owasp.org", "mail.owasp.org", "dev.owasp.org", "test1.owasp.org", "api.owasp.org"}
+
+	for _, name := range names {
+		var matches int
+
+		for i := 0; i < 3; i++ {
+			c := &Config{ShardIndex: i, ShardCount: 3}
+
+			if c.InShard(name) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			t.Errorf("%s was found in %d shards instead of one", name, matches)
+		}
+	}
+
+	if c := new(Config); !c.InShard("www.owasp.org") {
+		t.Errorf("Names must be in scope when sharding has not been configured")
+	}
+}
+
 /*
 func TestExcludeDisabledDataSources(t *testing.T) {
 	e := NewEnumeration()
@@ -4,6 +4,11 @@
 package core
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"net"
 	"reflect"
 	"sync"
@@ -22,6 +27,7 @@ const (
 	CERT     = "cert"
 	DNS      = "dns"
 	EXTERNAL = "ext"
+	HTTP     = "http"
 	SCRAPE   = "scrape"
 )
 
@@ -34,6 +40,11 @@ const (
 	NameResolvedTopic = "amass:resolved"
 	ReverseSweepTopic = "amass:sweep"
 	ActiveCertTopic   = "amass:activecert"
+	NewCertTopic      = "amass:newcert"
+	HTTPProbeTopic    = "amass:httpprobe"
+	TakeoverTopic     = "amass:takeover"
+	EmailPostureTopic = "amass:emailposture"
+	RDAPTopic         = "amass:rdap"
 	OutputTopic       = "amass:output"
 	IPToASNTopic      = "amass:iptoasn"
 	NewASNTopic       = "amass:asn"
@@ -41,6 +52,27 @@ const (
 	NewWhoisTopic     = "amass:whoisinfo"
 )
 
+// TrustedTag returns true when the tag parameter is of a type that should be trusted even
+// facing DNS wildcards.
+func TrustedTag(tag string) bool {
+	if tag == DNS || tag == CERT || tag == ARCHIVE || tag == AXFR {
+		return true
+	}
+	return false
+}
+
+// RequestPriority returns the queue priority class for a request carrying the tag parameter.
+// User provided names and names from trusted sources are handled before generated guesses.
+func RequestPriority(tag string) int {
+	switch {
+	case tag == EXTERNAL || TrustedTag(tag):
+		return utils.QueuePriorityHigh
+	case tag == BRUTE || tag == ALT:
+		return utils.QueuePriorityLow
+	}
+	return utils.QueuePriorityNormal
+}
+
 // DNSAnswer is the type used by Amass to represent a DNS record.
 type DNSAnswer struct {
 	Name string `json:"name"`
@@ -66,6 +98,121 @@ type AddrRequest struct {
 	Source  string
 }
 
+// CertRequest handles the certificate details obtained from a network address.
+type CertRequest struct {
+	Address      string
+	Port         int
+	ServerName   string
+	CommonName   string
+	Names        []string
+	Organization []string
+	OrgUnit      []string
+	Issuer       string
+	NotBefore    time.Time
+	NotAfter     time.Time
+	Serial       string
+	Fingerprint  string
+	Tag          string
+	Source       string
+}
+
+// NewCertRequest returns a CertRequest populated with the details of the certificate,
+// including the subject organization and the names the certificate was issued for.
+func NewCertRequest(cert *x509.Certificate) *CertRequest {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	return &CertRequest{
+		CommonName:   cert.Subject.CommonName,
+		Names:        namesFromCert(cert),
+		Organization: cert.Subject.Organization,
+		OrgUnit:      cert.Subject.OrganizationalUnit,
+		Issuer:       cert.Issuer.String(),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		Serial:       cert.SerialNumber.Text(16),
+		Fingerprint:  hex.EncodeToString(fingerprint[:]),
+	}
+}
+
+func namesFromCert(cert *x509.Certificate) []string {
+	var cn string
+
+	for _, name := range cert.Subject.Names {
+		oid := name.Type
+		if len(oid) == 4 && oid[0] == 2 && oid[1] == 5 && oid[2] == 4 {
+			if oid[3] == 3 {
+				cn = fmt.Sprintf("%s", name.Value)
+				break
+			}
+		}
+	}
+
+	var subdomains []string
+	// Add the subject common name to the list of subdomain names
+	commonName := utils.RemoveAsteriskLabel(cn)
+	if commonName != "" {
+		subdomains = append(subdomains, commonName)
+	}
+	// Add the cert DNS names to the list of subdomain names
+	for _, name := range cert.DNSNames {
+		n := utils.RemoveAsteriskLabel(name)
+		if n != "" {
+			subdomains = utils.UniqueAppend(subdomains, n)
+		}
+	}
+	return subdomains
+}
+
+// HTTPProbe contains the details of a web server response obtained for a DNS name.
+type HTTPProbe struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Name       string    `json:"name"`
+	Domain     string    `json:"domain"`
+	URL        string    `json:"url"`
+	StatusCode int       `json:"status"`
+	Title      string    `json:"title"`
+	Server     string    `json:"server"`
+	Redirects  []string  `json:"redirects"`
+	Tag        string    `json:"tag"`
+	Source     string    `json:"source"`
+}
+
+// TakeoverFinding describes a CNAME record that appears vulnerable to a subdomain takeover.
+type TakeoverFinding struct {
+	Timestamp time.Time `json:"timestamp"`
+	Name      string    `json:"name"`
+	Domain    string    `json:"domain"`
+	Target    string    `json:"target"`
+	Provider  string    `json:"provider"`
+	Reason    string    `json:"reason"`
+	Evidence  string    `json:"evidence"`
+	Tag       string    `json:"tag"`
+	Source    string    `json:"source"`
+}
+
+// EmailSender describes a third-party service authorized by SPF to send email for a domain.
+type EmailSender struct {
+	Domain string   `json:"domain"`
+	CIDRs  []string `json:"cidrs,omitempty"`
+}
+
+// EmailPosture describes the SPF, DMARC and DKIM configuration of a root domain.
+type EmailPosture struct {
+	Timestamp     time.Time      `json:"timestamp"`
+	Domain        string         `json:"domain"`
+	SPF           string         `json:"spf,omitempty"`
+	SPFLookups    int            `json:"spf_lookups"`
+	SPFAll        string         `json:"spf_all,omitempty"`
+	CIDRs         []string       `json:"cidrs,omitempty"`
+	Senders       []*EmailSender `json:"senders,omitempty"`
+	DMARC         string         `json:"dmarc,omitempty"`
+	DMARCPolicy   string         `json:"dmarc_policy,omitempty"`
+	DKIMSelectors []string       `json:"dkim_selectors,omitempty"`
+	Issues        []string       `json:"issues,omitempty"`
+	Tag           string         `json:"tag"`
+	Source        string         `json:"source"`
+}
+
 // ASNRequest handles all autonomous system information needed by Amass.
 type ASNRequest struct {
 	Address        string
@@ -90,6 +237,166 @@ type WhoisRequest struct {
 	Source     string
 }
 
+// RDAPRecord contains the registration data obtained via RDAP for an IP network,
+// autonomous system or domain name.
+type RDAPRecord struct {
+	Timestamp       time.Time `json:"timestamp"`
+	Class           string    `json:"class"`
+	Query           string    `json:"query"`
+	Handle          string    `json:"handle,omitempty"`
+	Name            string    `json:"name,omitempty"`
+	Domain          string    `json:"domain,omitempty"`
+	ASN             int       `json:"asn,omitempty"`
+	CIDRs           []string  `json:"cidrs,omitempty"`
+	Country         string    `json:"country,omitempty"`
+	Registrant      string    `json:"registrant,omitempty"`
+	RegistrantEmail string    `json:"registrant_email,omitempty"`
+	Registrar       string    `json:"registrar,omitempty"`
+	AbuseEmail      string    `json:"abuse_email,omitempty"`
+	Registered      time.Time `json:"registered,omitempty"`
+	Expires         time.Time `json:"expires,omitempty"`
+	Updated         time.Time `json:"updated,omitempty"`
+	Nameservers     []string  `json:"nameservers,omitempty"`
+	URL             string    `json:"url"`
+	Tag             string    `json:"tag"`
+	Source          string    `json:"source"`
+}
+
+// PivotCandidate is a root domain sharing nameservers, mail servers or SOA contacts
+// with the seed domains. The score is the number of attributes shared.
+type PivotCandidate struct {
+	Domain  string   `json:"domain"`
+	Score   int      `json:"score"`
+	Shared  []string `json:"shared"`
+	Sources []string `json:"sources"`
+}
+
+// CertEvidence is a certificate linking a root domain to the organization in the subject.
+type CertEvidence struct {
+	Domain       string    `json:"domain"`
+	Organization string    `json:"organization"`
+	OrgUnit      string    `json:"org_unit,omitempty"`
+	CommonName   string    `json:"common_name"`
+	Issuer       string    `json:"issuer,omitempty"`
+	NotAfter     time.Time `json:"not_after"`
+	Serial       string    `json:"serial,omitempty"`
+	Fingerprint  string    `json:"fingerprint,omitempty"`
+	Source       string    `json:"source"`
+}
+
+// These strings identify the changes to DNS names discovered by the track subcommand.
+const (
+	TrackAdded   = "added"
+	TrackRemoved = "removed"
+	TrackChanged = "changed"
+)
+
+// The categories of infrastructure movement identified for the changed DNS names.
+const (
+	TrackASNMove          = "asn_move"
+	TrackNetblockMove     = "netblock_move"
+	TrackCNAMETarget      = "cname_target"
+	TrackStartedResolving = "started_resolving"
+	TrackStoppedResolving = "stopped_resolving"
+	TrackIPv6Added        = "ipv6_added"
+)
+
+// TrackCategories provides the movement categories in the order they are reported.
+var TrackCategories = []string{
+	TrackASNMove,
+	TrackNetblockMove,
+	TrackCNAMETarget,
+	TrackStartedResolving,
+	TrackStoppedResolving,
+	TrackIPv6Added,
+}
+
+// TrackCategoryLabels provides a short description of each movement category.
+var TrackCategoryLabels = map[string]string{
+	TrackASNMove:          "ASN or provider move",
+	TrackNetblockMove:     "Netblock move",
+	TrackCNAMETarget:      "CNAME provider change",
+	TrackStartedResolving: "Started resolving",
+	TrackStoppedResolving: "Stopped resolving",
+	TrackIPv6Added:        "IPv6 added",
+}
+
+// TrackEvent describes a DNS name that was added, removed or resolved to different addresses
+// between two enumerations. The timestamps provide the end of each enumeration. Changed names
+// include the categories of infrastructure movement, and the providers when the ASN changed.
+type TrackEvent struct {
+	Type              string    `json:"type"`
+	Name              string    `json:"name"`
+	Domain            string    `json:"domain"`
+	Addresses         []string  `json:"addresses,omitempty"`
+	PreviousAddresses []string  `json:"previous_addresses,omitempty"`
+	CNAMETargets      []string  `json:"cname_targets,omitempty"`
+	PreviousCNAMEs    []string  `json:"previous_cname_targets,omitempty"`
+	Categories        []string  `json:"categories,omitempty"`
+	Providers         []string  `json:"providers,omitempty"`
+	PreviousProviders []string  `json:"previous_providers,omitempty"`
+	Enum              string    `json:"enum"`
+	Timestamp         time.Time `json:"timestamp"`
+	PreviousEnum      string    `json:"previous_enum"`
+	PreviousTimestamp time.Time `json:"previous_timestamp"`
+	Annotation        string    `json:"annotation,omitempty"`
+}
+
+// TrackReport contains the changes discovered by the track subcommand for the domains.
+type TrackReport struct {
+	Timestamp  time.Time        `json:"timestamp"`
+	Domains    []string         `json:"domains"`
+	Events     []*TrackEvent    `json:"events"`
+	Comparison *TrackComparison `json:"comparison,omitempty"`
+	Movements  *TrackMovements  `json:"movements,omitempty"`
+}
+
+// TrackMovementSummary is the type of the movement counts when written along with the track events.
+const TrackMovementSummary = "movements"
+
+// TrackMovements provides the number of changed DNS names in each movement category.
+type TrackMovements struct {
+	Type       string         `json:"type"`
+	Categories map[string]int `json:"categories"`
+}
+
+// TrackSummary is the type of the comparison when written along with the track events.
+const TrackSummary = "summary"
+
+// TrackSetDiff provides the assets only found by the later or the earlier enumeration.
+type TrackSetDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// TrackStats provides the number of unique assets found by an enumeration.
+type TrackStats struct {
+	Names        int `json:"names"`
+	Addresses    int `json:"addresses"`
+	Netblocks    int `json:"netblocks"`
+	ASNs         int `json:"asns"`
+	CNAMETargets int `json:"cname_targets"`
+}
+
+// TrackComparison describes the complete differences between two enumerations, along with
+// summary statistics. The changes to individual DNS names are provided as TrackEvents.
+type TrackComparison struct {
+	Type              string       `json:"type"`
+	Enum              string       `json:"enum"`
+	Timestamp         time.Time    `json:"timestamp"`
+	PreviousEnum      string       `json:"previous_enum"`
+	PreviousTimestamp time.Time    `json:"previous_timestamp"`
+	Names             TrackSetDiff `json:"names"`
+	Addresses         TrackSetDiff `json:"addresses"`
+	Netblocks         TrackSetDiff `json:"netblocks"`
+	ASNs              TrackSetDiff `json:"asns"`
+	CNAMETargets      TrackSetDiff `json:"cname_targets"`
+	NamesChanged      int          `json:"names_changed"`
+	NamesUnchanged    int          `json:"names_unchanged"`
+	Stats             TrackStats   `json:"stats"`
+	PreviousStats     TrackStats   `json:"previous_stats"`
+}
+
 // Output contains all the output data for an enumerated DNS name.
 type Output struct {
 	Timestamp time.Time
@@ -98,8 +405,18 @@ type Output struct {
 	Addresses []AddressInfo `json:"addresses"`
 	Tag       string        `json:"tag"`
 	Source    string        `json:"source"`
+	Method    string        `json:"method,omitempty"`
 }
 
+// The methods used by the intelligence collection to discover root domains.
+const (
+	IntelReverseDNS = "reverse_dns"
+	IntelCert       = "cert"
+	IntelWhois      = "whois"
+	IntelPivot      = "pivot"
+	IntelCertOrg    = "cert_org"
+)
+
 // AddressInfo stores all network addressing info for the Output type.
 type AddressInfo struct {
 	Address     net.IP     `json:"ip"`
@@ -114,6 +431,80 @@ type pubReq struct {
 	Args  []reflect.Value
 }
 
+// busArgTypes are the event arguments that can be moved to disk by the EventBus queue, which
+// include the requests carried by the high volume topics. Events with other arguments, such as
+// the enumeration output, remain in memory.
+var busArgTypes = map[string]reflect.Type{
+	"dns":    reflect.TypeOf(&DNSRequest{}),
+	"addr":   reflect.TypeOf(&AddrRequest{}),
+	"asn":    reflect.TypeOf(&ASNRequest{}),
+	"whois":  reflect.TypeOf(&WhoisRequest{}),
+	"string": reflect.TypeOf(""),
+	"int":    reflect.TypeOf(0),
+}
+
+type busArg struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type busEvent struct {
+	Topic string   `json:"topic"`
+	Args  []busArg `json:"args"`
+}
+
+// busCodec implements the utils.QueueCodec interface for the events waiting to be published.
+type busCodec struct{}
+
+// Encode implements the utils.QueueCodec interface.
+func (bc *busCodec) Encode(data interface{}) ([]byte, error) {
+	p := data.(*pubReq)
+
+	event := &busEvent{Topic: p.Topic}
+	for _, arg := range p.Args {
+		var name string
+		for n, t := range busArgTypes {
+			if arg.IsValid() && arg.Type() == t {
+				name = n
+				break
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("The %s event cannot be moved to disk", p.Topic)
+		}
+
+		value, err := json.Marshal(arg.Interface())
+		if err != nil {
+			return nil, err
+		}
+		event.Args = append(event.Args, busArg{Type: name, Value: value})
+	}
+	return json.Marshal(event)
+}
+
+// Decode implements the utils.QueueCodec interface.
+func (bc *busCodec) Decode(b []byte) (interface{}, error) {
+	var event busEvent
+	if err := json.Unmarshal(b, &event); err != nil {
+		return nil, err
+	}
+
+	p := &pubReq{Topic: event.Topic}
+	for _, arg := range event.Args {
+		t, found := busArgTypes[arg.Type]
+		if !found {
+			return nil, fmt.Errorf("Unknown event argument type: %s", arg.Type)
+		}
+
+		value := reflect.New(t)
+		if err := json.Unmarshal(arg.Value, value.Interface()); err != nil {
+			return nil, err
+		}
+		p.Args = append(p.Args, value.Elem())
+	}
+	return p, nil
+}
+
 // EventBus handles sending and receiving events across Amass.
 type EventBus struct {
 	sync.Mutex
@@ -179,10 +570,19 @@ func (eb *EventBus) Publish(topic string, args ...interface{}) {
 		passedArgs = append(passedArgs, reflect.ValueOf(arg))
 	}
 
-	eb.queue.Append(&pubReq{
+	priority := utils.QueuePriorityNormal
+	// Events carrying DNS names are prioritized by the source of the name
+	for _, arg := range args {
+		if req, ok := arg.(*DNSRequest); ok && req != nil {
+			priority = RequestPriority(req.Tag)
+			break
+		}
+	}
+
+	eb.queue.AppendPriority(&pubReq{
 		Topic: topic,
 		Args:  passedArgs,
-	})
+	}, priority)
 }
 
 func (eb *EventBus) processRequests() {
@@ -219,6 +619,12 @@ func (eb *EventBus) processRequests() {
 	}
 }
 
+// SpillQueue moves the events waiting to be published to a temporary file in dir once more
+// than maxInMemory are held in memory. A maxInMemory value of zero keeps all events in memory.
+func (eb *EventBus) SpillQueue(dir string, maxInMemory int) {
+	eb.queue.EnableSpill(dir, maxInMemory, new(busCodec))
+}
+
 func (eb *EventBus) executeCallbacks(callbacks, args []reflect.Value) {
 	defer eb.max.Release(1)
 
@@ -230,4 +636,5 @@ func (eb *EventBus) executeCallbacks(callbacks, args []reflect.Value) {
 // Stop prevents any additional requests from being sent.
 func (eb *EventBus) Stop() {
 	close(eb.done)
+	eb.queue.Close()
 }
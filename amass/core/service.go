@@ -4,6 +4,7 @@
 package core
 
 import (
+	"encoding/json"
 	"errors"
 	"sync"
 	"time"
@@ -30,6 +31,19 @@ type ServiceStats struct {
 	AddrsRemaining   int
 }
 
+// QueueDepth provides the number of requests waiting in each queue of an Amass service.
+type QueueDepth struct {
+	DNS   int `json:"dns"`
+	Addr  int `json:"addr"`
+	ASN   int `json:"asn"`
+	Whois int `json:"whois"`
+}
+
+// Total returns the number of requests waiting in all the queues.
+func (q QueueDepth) Total() int {
+	return q.DNS + q.Addr + q.ASN + q.Whois
+}
+
 // Service is the object type for a service running within the Amass enumeration architecture.
 type Service interface {
 	// Start the service
@@ -72,6 +86,9 @@ type Service interface {
 	WhoisRequestChan() <-chan *WhoisRequest
 	WhoisRequestLen() int
 
+	// Returns the number of requests waiting in each queue
+	QueueDepth() QueueDepth
+
 	IsActive() bool
 	SetActive()
 
@@ -95,6 +112,19 @@ type Service interface {
 	Stats() *ServiceStats
 }
 
+// ReverseLookup is implemented by the data sources able to return the domain names
+// served by a nameserver (qtype NS) or using a mail server (qtype MX).
+type ReverseLookup interface {
+	// Returns nil without an error when the qtype is not supported by the source
+	ReverseLookup(qtype, host string) ([]string, error)
+}
+
+// OrganizationLookup is implemented by the data sources able to search certificates
+// by the subject organization.
+type OrganizationLookup interface {
+	OrganizationLookup(org string) ([]*CertRequest, error)
+}
+
 // BaseService provides common mechanisms to all Amass services in the enumeration architecture.
 // It is used to compose a type that completely meets the AmassService interface.
 type BaseService struct {
@@ -125,6 +155,46 @@ type BaseService struct {
 	bus *EventBus
 }
 
+// requestCodec implements the utils.QueueCodec interface for the request types.
+type requestCodec struct {
+	newRequest func() interface{}
+}
+
+// Encode implements the utils.QueueCodec interface.
+func (rc *requestCodec) Encode(data interface{}) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+// Decode implements the utils.QueueCodec interface.
+func (rc *requestCodec) Decode(b []byte) (interface{}, error) {
+	req := rc.newRequest()
+
+	err := json.Unmarshal(b, req)
+	return req, err
+}
+
+// spillRequestQueues limits the requests held in memory by the queues, using the configuration
+// loaded once the service was created.
+func (bas *BaseService) spillRequestQueues() {
+	if bas.config == nil || bas.config.QueueMaxInMemory <= 0 {
+		return
+	}
+
+	dir, max := bas.config.Dir, bas.config.QueueMaxInMemory
+	bas.dnsQueue.EnableSpill(dir, max, &requestCodec{newRequest: func() interface{} {
+		return new(DNSRequest)
+	}})
+	bas.addrQueue.EnableSpill(dir, max, &requestCodec{newRequest: func() interface{} {
+		return new(AddrRequest)
+	}})
+	bas.asnQueue.EnableSpill(dir, max, &requestCodec{newRequest: func() interface{} {
+		return new(ASNRequest)
+	}})
+	bas.whoisQueue.EnableSpill(dir, max, &requestCodec{newRequest: func() interface{} {
+		return new(WhoisRequest)
+	}})
+}
+
 // NewBaseService returns an initialized BaseService object.
 func NewBaseService(srv Service, name string, config *Config, bus *EventBus) *BaseService {
 	return &BaseService{
@@ -155,6 +225,8 @@ func (bas *BaseService) Start() error {
 		return errors.New(bas.name + " has been stopped")
 	}
 	bas.started = true
+	// Services are created before the configuration file has been loaded
+	bas.spillRequestQueues()
 	go bas.processDNSRequests()
 	go bas.processAddrRequests()
 	go bas.processASNRequests()
@@ -207,6 +279,11 @@ func (bas *BaseService) Stop() error {
 	err := bas.service.OnStop()
 	bas.stopped = true
 	close(bas.quit)
+
+	bas.dnsQueue.Close()
+	bas.addrQueue.Close()
+	bas.asnQueue.Close()
+	bas.whoisQueue.Close()
 	return err
 }
 
@@ -230,8 +307,9 @@ func (bas *BaseService) OnLowNumberOfNames() error {
 }
 
 // SendDNSRequest adds the request provided by the parameter to the service request channel.
+// Names from users and trusted sources are handled before generated names.
 func (bas *BaseService) SendDNSRequest(req *DNSRequest) {
-	bas.dnsQueue.Append(req)
+	bas.dnsQueue.AppendPriority(req, RequestPriority(req.Tag))
 }
 
 // DNSRequestChan returns the channel that provides new service requests.
@@ -270,7 +348,7 @@ func (bas *BaseService) DNSRequestLen() int {
 
 // SendAddrRequest adds the request provided by the parameter to the service request channel.
 func (bas *BaseService) SendAddrRequest(req *AddrRequest) {
-	bas.addrQueue.Append(req)
+	bas.addrQueue.AppendPriority(req, RequestPriority(req.Tag))
 }
 
 // AddrRequestChan returns the channel that provides new service requests.
@@ -385,6 +463,16 @@ func (bas *BaseService) WhoisRequestLen() int {
 	return bas.whoisQueue.Len()
 }
 
+// QueueDepth returns the number of requests waiting in each queue of the service.
+func (bas *BaseService) QueueDepth() QueueDepth {
+	return QueueDepth{
+		DNS:   bas.DNSRequestLen(),
+		Addr:  bas.AddrRequestLen(),
+		ASN:   bas.ASNRequestLen(),
+		Whois: bas.WhoisRequestLen(),
+	}
+}
+
 // IsActive returns true if SetActive has been called for the service within the last 3 seconds.
 func (bas *BaseService) IsActive() bool {
 	bas.activeLock.Lock()
@@ -0,0 +1,95 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type queueTestService struct {
+	BaseService
+}
+
+func TestQueueDepth(t *testing.T) {
+	srv := new(queueTestService)
+	srv.BaseService = *NewBaseService(srv, "Queue Test", nil, nil)
+
+	// The requests wait in the queues, since the service has not been started
+	for i := 0; i < 3; i++ {
+		srv.SendDNSRequest(&DNSRequest{Name: "www.example.com", Tag: DNS})
+	}
+	srv.SendAddrRequest(&AddrRequest{Address: "192.0.2.1", Tag: DNS})
+	srv.SendWhoisRequest(&WhoisRequest{Domain: "example.com"})
+
+	expected := QueueDepth{DNS: 3, Addr: 1, Whois: 1}
+	if q := srv.QueueDepth(); q != expected {
+		t.Errorf("Returned the queue depths %+v", q)
+	}
+	if total := srv.QueueDepth().Total(); total != 5 {
+		t.Errorf("Returned the total queue depth %d", total)
+	}
+}
+
+func TestServiceQueueSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "amass_queue_test")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{Dir: dir}
+	srv := new(queueTestService)
+	srv.BaseService = *NewBaseService(srv, "Queue Test", config, nil)
+	defer srv.dnsQueue.Close()
+
+	// The configuration file is loaded after the service has been created
+	config.QueueMaxInMemory = 2
+	srv.spillRequestQueues()
+
+	for i := 0; i < 5; i++ {
+		srv.SendDNSRequest(&DNSRequest{Name: "www.example.com", Tag: DNS})
+	}
+	if n := srv.dnsQueue.LenOnDisk(); n != 3 {
+		t.Errorf("The queue held %d requests on disk, expected 3", n)
+	}
+}
+
+func TestEventBusCodec(t *testing.T) {
+	codec := new(busCodec)
+
+	req := &DNSRequest{Name: "www.example.com", Domain: "example.com", Tag: DNS, Source: "Test"}
+	p := &pubReq{
+		Topic: NewNameTopic,
+		Args:  []reflect.Value{reflect.ValueOf(req), reflect.ValueOf(2)},
+	}
+
+	b, err := codec.Encode(p)
+	if err != nil {
+		t.Fatalf("Failed to encode the event: %v", err)
+	}
+	data, err := codec.Decode(b)
+	if err != nil {
+		t.Fatalf("Failed to decode the event: %v", err)
+	}
+
+	event := data.(*pubReq)
+	if event.Topic != NewNameTopic || len(event.Args) != 2 {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+	if r, ok := event.Args[0].Interface().(*DNSRequest); !ok || !reflect.DeepEqual(r, req) {
+		t.Errorf("The request was not restored: %+v", event.Args[0].Interface())
+	}
+	if n, ok := event.Args[1].Interface().(int); !ok || n != 2 {
+		t.Errorf("The argument was not restored: %v", event.Args[1].Interface())
+	}
+
+	// The enumeration output is kept in memory
+	out := &pubReq{Topic: OutputTopic, Args: []reflect.Value{reflect.ValueOf(&Output{Name: "www.example.com"})}}
+	if _, err := codec.Encode(out); err == nil {
+		t.Errorf("The output event was encoded")
+	}
+}
@@ -20,12 +20,12 @@ type DataManagerService struct {
 	core.BaseService
 
 	Handlers     []handlers.DataHandler
-	domainFilter *utils.StringFilter
+	domainFilter utils.StringFilter
 }
 
 // NewDataManagerService returns he object initialized, but not yet started.
 func NewDataManagerService(config *core.Config, bus *core.EventBus) *DataManagerService {
-	dms := &DataManagerService{domainFilter: utils.NewStringFilter()}
+	dms := &DataManagerService{domainFilter: config.NewStringFilter()}
 
 	dms.BaseService = *core.NewBaseService(dms, "Data Manager", config, bus)
 	return dms
@@ -36,10 +36,21 @@ func (dms *DataManagerService) OnStart() error {
 	dms.BaseService.OnStart()
 
 	dms.Bus().Subscribe(core.NameResolvedTopic, dms.SendDNSRequest)
+	dms.Bus().Subscribe(core.NewCertTopic, dms.insertCertificate)
+	dms.Bus().Subscribe(core.HTTPProbeTopic, dms.insertHTTPProbe)
+	dms.Bus().Subscribe(core.TakeoverTopic, dms.insertTakeover)
+	dms.Bus().Subscribe(core.EmailPostureTopic, dms.insertEmailPosture)
+	dms.Bus().Subscribe(core.RDAPTopic, dms.insertRDAP)
 	go dms.processRequests()
 	return nil
 }
 
+// OnStop implements the Service interface
+func (dms *DataManagerService) OnStop() error {
+	dms.domainFilter.Close()
+	return nil
+}
+
 // AddDataHandler provides the Data Manager with another DataHandler.
 func (dms *DataManagerService) AddDataHandler(handler handlers.DataHandler) {
 	dms.Handlers = append(dms.Handlers, handler)
@@ -364,14 +375,58 @@ func (dms *DataManagerService) insertTXT(req *core.DNSRequest, recidx int) {
 	if !dms.Config().IsDomainInScope(req.Name) {
 		return
 	}
-	dms.findNamesAndAddresses(req.Records[recidx].Data, req.Domain)
+
+	data := req.Records[recidx].Data
+	if terms, err := ParseSPF(data); err == nil {
+		dms.findSPFNamesAndAddresses(terms, req.Domain)
+		return
+	}
+	dms.findNamesAndAddresses(data, req.Domain)
 }
 
 func (dms *DataManagerService) insertSPF(req *core.DNSRequest, recidx int) {
 	if !dms.Config().IsDomainInScope(req.Name) {
 		return
 	}
-	dms.findNamesAndAddresses(req.Records[recidx].Data, req.Domain)
+
+	data := req.Records[recidx].Data
+	if terms, err := ParseSPF(data); err == nil {
+		dms.findSPFNamesAndAddresses(terms, req.Domain)
+		return
+	}
+	dms.findNamesAndAddresses(data, req.Domain)
+}
+
+func (dms *DataManagerService) findSPFNamesAndAddresses(terms []*SPFTerm, domain string) {
+	for _, term := range terms {
+		switch term.Name {
+		case "ip4", "ip6":
+			cidr := SPFNetblock(term.Name, term.Value)
+			if cidr == "" {
+				continue
+			}
+
+			addr := strings.SplitN(cidr, "/", 2)[0]
+			dms.Bus().Publish(core.NewAddrTopic, &core.AddrRequest{
+				Address: addr,
+				Domain:  domain,
+				Tag:     core.DNS,
+				Source:  "Forward DNS",
+			})
+		case "a", "mx", "include", "exists", "redirect":
+			name := strings.SplitN(term.Value, "/", 2)[0]
+			if name == "" || !dms.Config().IsDomainInScope(name) {
+				continue
+			}
+
+			dms.Bus().Publish(core.NewNameTopic, &core.DNSRequest{
+				Name:   name,
+				Domain: strings.ToLower(dms.Config().WhichDomain(name)),
+				Tag:    core.DNS,
+				Source: "Forward DNS",
+			})
+		}
+	}
 }
 
 func (dms *DataManagerService) findNamesAndAddresses(data, domain string) {
@@ -425,3 +480,154 @@ func (dms *DataManagerService) insertInfrastructure(addr string) {
 		}
 	}
 }
+
+func (dms *DataManagerService) insertCertificate(req *core.CertRequest) {
+	dms.SetActive()
+	for _, handler := range dms.Handlers {
+		err := handler.Insert(&handlers.DataOptsParams{
+			UUID:         dms.Config().UUID.String(),
+			Timestamp:    time.Now().Format(time.RFC3339),
+			Type:         handlers.OptCertificate,
+			Name:         req.CommonName,
+			Names:        req.Names,
+			Address:      req.Address,
+			Port:         req.Port,
+			ServerName:   req.ServerName,
+			Organization: strings.Join(req.Organization, ", "),
+			OrgUnit:      strings.Join(req.OrgUnit, ", "),
+			Issuer:       req.Issuer,
+			NotBefore:    req.NotBefore.Format(time.RFC3339),
+			NotAfter:     req.NotAfter.Format(time.RFC3339),
+			Serial:       req.Serial,
+			Fingerprint:  req.Fingerprint,
+			Tag:          req.Tag,
+			Source:       req.Source,
+		})
+		if err != nil {
+			dms.Config().Log.Printf("%s: %s failed to insert certificate: %v", dms.String(), handler, err)
+		}
+	}
+}
+
+func (dms *DataManagerService) insertHTTPProbe(probe *core.HTTPProbe) {
+	dms.SetActive()
+	for _, handler := range dms.Handlers {
+		err := handler.Insert(&handlers.DataOptsParams{
+			UUID:       dms.Config().UUID.String(),
+			Timestamp:  probe.Timestamp.Format(time.RFC3339),
+			Type:       handlers.OptHTTP,
+			Name:       probe.Name,
+			Domain:     probe.Domain,
+			URL:        probe.URL,
+			StatusCode: probe.StatusCode,
+			Title:      probe.Title,
+			Server:     probe.Server,
+			Redirects:  probe.Redirects,
+			Tag:        probe.Tag,
+			Source:     probe.Source,
+		})
+		if err != nil {
+			dms.Config().Log.Printf("%s: %s failed to insert HTTP probe: %v", dms.String(), handler, err)
+		}
+	}
+}
+
+func (dms *DataManagerService) insertTakeover(finding *core.TakeoverFinding) {
+	dms.SetActive()
+	for _, handler := range dms.Handlers {
+		err := handler.Insert(&handlers.DataOptsParams{
+			UUID:       dms.Config().UUID.String(),
+			Timestamp:  finding.Timestamp.Format(time.RFC3339),
+			Type:       handlers.OptTakeover,
+			Name:       finding.Name,
+			Domain:     finding.Domain,
+			TargetName: finding.Target,
+			Provider:   finding.Provider,
+			Reason:     finding.Reason,
+			Evidence:   finding.Evidence,
+			Tag:        finding.Tag,
+			Source:     finding.Source,
+		})
+		if err != nil {
+			dms.Config().Log.Printf("%s: %s failed to insert takeover finding: %v", dms.String(), handler, err)
+		}
+	}
+}
+
+func (dms *DataManagerService) insertEmailPosture(posture *core.EmailPosture) {
+	dms.SetActive()
+	for _, handler := range dms.Handlers {
+		err := handler.Insert(&handlers.DataOptsParams{
+			UUID:          dms.Config().UUID.String(),
+			Timestamp:     posture.Timestamp.Format(time.RFC3339),
+			Type:          handlers.OptEmailPosture,
+			Domain:        posture.Domain,
+			SPF:           posture.SPF,
+			SPFLookups:    posture.SPFLookups,
+			SPFAll:        posture.SPFAll,
+			CIDRs:         posture.CIDRs,
+			DMARC:         posture.DMARC,
+			DMARCPolicy:   posture.DMARCPolicy,
+			DKIMSelectors: posture.DKIMSelectors,
+			Issues:        posture.Issues,
+			Tag:           posture.Tag,
+			Source:        posture.Source,
+		})
+		if err != nil {
+			dms.Config().Log.Printf("%s: %s failed to insert email posture: %v", dms.String(), handler, err)
+		}
+
+		for _, sender := range posture.Senders {
+			err := handler.Insert(&handlers.DataOptsParams{
+				UUID:      dms.Config().UUID.String(),
+				Timestamp: posture.Timestamp.Format(time.RFC3339),
+				Type:      handlers.OptEmailSender,
+				Name:      sender.Domain,
+				Domain:    posture.Domain,
+				CIDRs:     sender.CIDRs,
+				Tag:       posture.Tag,
+				Source:    posture.Source,
+			})
+			if err != nil {
+				dms.Config().Log.Printf("%s: %s failed to insert email sender: %v", dms.String(), handler, err)
+			}
+		}
+	}
+}
+
+func (dms *DataManagerService) insertRDAP(rec *core.RDAPRecord) {
+	var registered, expires string
+	if !rec.Registered.IsZero() {
+		registered = rec.Registered.Format(time.RFC3339)
+	}
+	if !rec.Expires.IsZero() {
+		expires = rec.Expires.Format(time.RFC3339)
+	}
+
+	dms.SetActive()
+	for _, handler := range dms.Handlers {
+		err := handler.Insert(&handlers.DataOptsParams{
+			UUID:         dms.Config().UUID.String(),
+			Timestamp:    rec.Timestamp.Format(time.RFC3339),
+			Type:         handlers.OptRDAP,
+			Name:         rec.Name,
+			Domain:       rec.Domain,
+			ASN:          rec.ASN,
+			CIDRs:        rec.CIDRs,
+			Handle:       rec.Handle,
+			Country:      rec.Country,
+			Registrant:   rec.Registrant,
+			Registrar:    rec.Registrar,
+			AbuseContact: rec.AbuseEmail,
+			Registered:   registered,
+			Expires:      expires,
+			Nameservers:  rec.Nameservers,
+			URL:          rec.URL,
+			Tag:          rec.Tag,
+			Source:       rec.Source,
+		})
+		if err != nil {
+			dms.Config().Log.Printf("%s: %s failed to insert RDAP data: %v", dms.String(), handler, err)
+		}
+	}
+}
@@ -40,13 +40,13 @@ type DNSService struct {
 	totalLock  sync.RWMutex
 	totalNames int
 
-	filter        *utils.StringFilter
+	filter        utils.StringFilter
 	cidrBlacklist []*net.IPNet
 }
 
 // NewDNSService returns he object initialized, but not yet started.
 func NewDNSService(config *core.Config, bus *core.EventBus) *DNSService {
-	ds := &DNSService{filter: utils.NewStringFilter()}
+	ds := &DNSService{filter: config.NewStringFilter()}
 
 	for _, n := range badSubnets {
 		if _, ipnet, err := net.ParseCIDR(n); err == nil {
@@ -75,6 +75,7 @@ func (ds *DNSService) OnStart() error {
 // OnStop implements the Service interface.
 func (ds *DNSService) OnStop() error {
 	ds.metrics.Stop()
+	ds.filter.Close()
 	return nil
 }
 
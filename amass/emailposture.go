@@ -0,0 +1,489 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils"
+	"github.com/miekg/dns"
+)
+
+const (
+	// The most DNS lookups an SPF evaluation can require (RFC 7208, section 4.6.4)
+	maxSPFLookups = 10
+
+	// The SPF includes are no longer followed after this many lookups
+	maxSPFLookupsFollowed = 50
+
+	// DKIM keys shorter than this are considered weak
+	minDKIMKeyBits = 1024
+)
+
+// The DKIM selectors checked when none are provided by the configuration.
+var defaultDKIMSelectors = []string{
+	"default",
+	"dkim",
+	"google",
+	"k1",
+	"k2",
+	"mail",
+	"mandrill",
+	"mx",
+	"s1",
+	"s2",
+	"selector1",
+	"selector2",
+	"smtp",
+	"zendesk1",
+	"zendesk2",
+}
+
+// SPFTerm is a single mechanism or modifier found in an SPF record.
+type SPFTerm struct {
+	Qualifier string
+	Name      string
+	Value     string
+}
+
+// ParseSPF returns the terms of the SPF record provided. An error is
+// returned when the text is not an SPF version 1 record.
+func ParseSPF(record string) ([]*SPFTerm, error) {
+	fields := strings.Fields(strings.ToLower(record))
+	if len(fields) == 0 || fields[0] != "v=spf1" {
+		return nil, fmt.Errorf("Not an SPF record: %s", record)
+	}
+
+	var terms []*SPFTerm
+	for _, field := range fields[1:] {
+		term := new(SPFTerm)
+
+		if strings.ContainsAny(field[:1], "+-~?") {
+			term.Qualifier = field[:1]
+			field = field[1:]
+		}
+		// Modifiers use an equal sign, while mechanisms use a colon
+		if idx := strings.IndexAny(field, ":="); idx != -1 {
+			term.Name, term.Value = field[:idx], field[idx+1:]
+		} else if idx := strings.Index(field, "/"); idx != -1 {
+			// Mechanisms such as a/24 only provide the CIDR length
+			term.Name, term.Value = field[:idx], field[idx:]
+		} else {
+			term.Name = field
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// SPFNetblock returns the ip4 or ip6 mechanism value provided in CIDR notation.
+func SPFNetblock(mechanism, value string) string {
+	if !strings.Contains(value, "/") {
+		if mechanism == "ip6" {
+			value += "/128"
+		} else {
+			value += "/32"
+		}
+	}
+
+	if _, ipnet, err := net.ParseCIDR(value); err == nil {
+		return ipnet.String()
+	}
+	return ""
+}
+
+// EmailPostureService is the Service that analyzes the SPF, DMARC and DKIM
+// records of the root domains and identifies the third-party email senders.
+type EmailPostureService struct {
+	core.BaseService
+
+	maxChecks utils.Semaphore
+	filter    utils.StringFilter
+	selectors []string
+
+	// Returns the TXT record data for the name provided
+	lookupTXT func(name string) ([]string, error)
+}
+
+// NewEmailPostureService returns he object initialized, but not yet started.
+func NewEmailPostureService(config *core.Config, bus *core.EventBus) *EmailPostureService {
+	eps := &EmailPostureService{
+		maxChecks: utils.NewSimpleSemaphore(10),
+		filter:    config.NewStringFilter(),
+		lookupTXT: resolveTXT,
+	}
+
+	for _, sel := range config.DKIMSelectors {
+		if sel = strings.TrimSpace(sel); sel != "" {
+			eps.selectors = append(eps.selectors, sel)
+		}
+	}
+	if len(eps.selectors) == 0 {
+		eps.selectors = defaultDKIMSelectors
+	}
+
+	eps.BaseService = *core.NewBaseService(eps, "Email Posture", config, bus)
+	return eps
+}
+
+// OnStart implements the Service interface
+func (eps *EmailPostureService) OnStart() error {
+	eps.BaseService.OnStart()
+
+	eps.Bus().Subscribe(core.NameResolvedTopic, eps.nameResolved)
+	go eps.processRequests()
+	return nil
+}
+
+// OnStop implements the Service interface.
+func (eps *EmailPostureService) OnStop() error {
+	eps.filter.Close()
+	return nil
+}
+
+func (eps *EmailPostureService) nameResolved(req *core.DNSRequest) {
+	domain := strings.ToLower(req.Domain)
+
+	// Each root domain is analyzed once, after the first name is resolved
+	if domain == "" || eps.filter.Duplicate(domain) {
+		return
+	}
+
+	eps.SendDNSRequest(&core.DNSRequest{
+		Name:   domain,
+		Domain: domain,
+	})
+}
+
+func (eps *EmailPostureService) processRequests() {
+	for {
+		select {
+		case <-eps.PauseChan():
+			<-eps.ResumeChan()
+		case <-eps.Quit():
+			return
+		case req := <-eps.DNSRequestChan():
+			eps.maxChecks.Acquire(1)
+			go eps.checkDomain(req.Domain)
+		case <-eps.AddrRequestChan():
+		case <-eps.ASNRequestChan():
+		case <-eps.WhoisRequestChan():
+		}
+	}
+}
+
+func (eps *EmailPostureService) checkDomain(domain string) {
+	defer eps.maxChecks.Release(1)
+
+	eps.SetActive()
+	posture := eps.analyze(domain)
+	eps.Bus().Publish(core.EmailPostureTopic, posture)
+}
+
+func (eps *EmailPostureService) analyze(domain string) *core.EmailPosture {
+	posture := &core.EmailPosture{
+		Timestamp: time.Now(),
+		Domain:    domain,
+		Tag:       core.DNS,
+		Source:    eps.String(),
+	}
+
+	eps.checkSPF(posture)
+	eps.SetActive()
+	eps.checkDMARC(posture)
+	eps.SetActive()
+	eps.checkDKIM(posture)
+	return posture
+}
+
+// spfEvaluation keeps the state of an SPF record evaluation across includes and redirects.
+type spfEvaluation struct {
+	posture *core.EmailPosture
+	visited map[string]struct{}
+	senders map[string]*core.EmailSender
+	order   []string
+}
+
+func (eps *EmailPostureService) checkSPF(posture *core.EmailPosture) {
+	eval := &spfEvaluation{
+		posture: posture,
+		visited: make(map[string]struct{}),
+		senders: make(map[string]*core.EmailSender),
+	}
+
+	record := eps.spfRecord(posture.Domain, posture)
+	if record == "" {
+		posture.Issues = append(posture.Issues, "No SPF record was found")
+		return
+	}
+
+	posture.SPF = record
+	eps.walkSPF(posture.Domain, record, "", true, eval)
+	for _, name := range eval.order {
+		posture.Senders = append(posture.Senders, eval.senders[name])
+	}
+
+	if posture.SPFLookups > maxSPFLookups {
+		posture.Issues = append(posture.Issues, fmt.Sprintf(
+			"The SPF record requires %d DNS lookups, exceeding the limit of %d", posture.SPFLookups, maxSPFLookups))
+	}
+	switch posture.SPFAll {
+	case "":
+		posture.Issues = append(posture.Issues, "The SPF record does not end with an all mechanism")
+	case "+all":
+		posture.Issues = append(posture.Issues, "The SPF record permits any host to send email (+all)")
+	case "?all":
+		posture.Issues = append(posture.Issues, "The SPF record provides a neutral result for other hosts (?all)")
+	}
+}
+
+func (eps *EmailPostureService) spfRecord(name string, posture *core.EmailPosture) string {
+	records, err := eps.lookupTXT(name)
+	if err != nil {
+		return ""
+	}
+
+	var spf []string
+	for _, rec := range records {
+		if fields := strings.Fields(strings.ToLower(rec)); len(fields) > 0 && fields[0] == "v=spf1" {
+			spf = append(spf, strings.TrimSpace(rec))
+		}
+	}
+	if len(spf) == 0 {
+		return ""
+	}
+	if len(spf) > 1 {
+		posture.Issues = append(posture.Issues, "Multiple SPF records were found for "+name)
+	}
+	return spf[0]
+}
+
+// walkSPF follows the includes and redirects of the record, while counting the DNS
+// lookups required. The sender is the out of scope domain responsible for the record.
+func (eps *EmailPostureService) walkSPF(name, record, sender string, top bool, eval *spfEvaluation) {
+	eval.visited[name] = struct{}{}
+
+	terms, err := ParseSPF(record)
+	if err != nil {
+		return
+	}
+
+	var redirect string
+	var foundAll bool
+	for _, term := range terms {
+		switch term.Name {
+		case "all":
+			foundAll = true
+			if top {
+				qualifier := term.Qualifier
+				if qualifier == "" {
+					qualifier = "+"
+				}
+				eval.posture.SPFAll = qualifier + "all"
+			}
+		case "ip4", "ip6":
+			if cidr := SPFNetblock(term.Name, term.Value); cidr != "" {
+				eval.addNetblock(sender, cidr)
+			}
+		case "a", "mx", "exists":
+			eval.posture.SPFLookups++
+		case "ptr":
+			eval.posture.SPFLookups++
+			if top {
+				eval.posture.Issues = append(eval.posture.Issues, "The SPF record uses the deprecated ptr mechanism")
+			}
+		case "include":
+			eval.posture.SPFLookups++
+			eps.followSPF(term.Value, sender, false, eval)
+		case "redirect":
+			redirect = term.Value
+		}
+	}
+	// The redirect modifier is ignored when an all mechanism is present
+	if redirect != "" && !foundAll {
+		eval.posture.SPFLookups++
+		eps.followSPF(redirect, sender, top, eval)
+	}
+}
+
+func (eps *EmailPostureService) followSPF(target, sender string, top bool, eval *spfEvaluation) {
+	target = strings.ToLower(core.RemoveLastDot(target))
+	// Macros cannot be expanded without the details of a message
+	if target == "" || strings.Contains(target, "%") {
+		return
+	}
+	if _, found := eval.visited[target]; found || eval.posture.SPFLookups > maxSPFLookupsFollowed {
+		return
+	}
+
+	if sender == "" {
+		if eps.Config().IsDomainInScope(target) {
+			eps.newName(target, eval.posture.Domain)
+		} else {
+			sender = target
+			eval.addSender(sender)
+		}
+	}
+
+	eps.SetActive()
+	if record := eps.spfRecord(target, eval.posture); record != "" {
+		eps.walkSPF(target, record, sender, top, eval)
+	}
+}
+
+func (eps *EmailPostureService) newName(name, domain string) {
+	eps.Bus().Publish(core.NewNameTopic, &core.DNSRequest{
+		Name:   name,
+		Domain: domain,
+		Tag:    core.DNS,
+		Source: eps.String(),
+	})
+}
+
+func (eval *spfEvaluation) addSender(name string) *core.EmailSender {
+	if s, found := eval.senders[name]; found {
+		return s
+	}
+
+	s := &core.EmailSender{Domain: name}
+	eval.senders[name] = s
+	eval.order = append(eval.order, name)
+	return s
+}
+
+func (eval *spfEvaluation) addNetblock(sender, cidr string) {
+	if sender == "" {
+		eval.posture.CIDRs = utils.UniqueAppend(eval.posture.CIDRs, cidr)
+		return
+	}
+
+	s := eval.addSender(sender)
+	s.CIDRs = utils.UniqueAppend(s.CIDRs, cidr)
+}
+
+func (eps *EmailPostureService) checkDMARC(posture *core.EmailPosture) {
+	records, err := eps.lookupTXT("_dmarc." + posture.Domain)
+	if err == nil {
+		for _, rec := range records {
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(rec)), "v=dmarc1") {
+				posture.DMARC = strings.TrimSpace(rec)
+				break
+			}
+		}
+	}
+	if posture.DMARC == "" {
+		posture.Issues = append(posture.Issues, "No DMARC record was found")
+		return
+	}
+
+	tags := parseTagList(posture.DMARC)
+	posture.DMARCPolicy = strings.ToLower(tags["p"])
+	switch posture.DMARCPolicy {
+	case "":
+		posture.Issues = append(posture.Issues, "The DMARC record does not provide a policy")
+	case "none":
+		posture.Issues = append(posture.Issues, "The DMARC policy only monitors email (p=none)")
+	}
+	if pct, found := tags["pct"]; found {
+		if n, err := strconv.Atoi(pct); err == nil && n < 100 {
+			posture.Issues = append(posture.Issues,
+				fmt.Sprintf("The DMARC policy is only applied to %d%% of email", n))
+		}
+	}
+	if tags["rua"] == "" {
+		posture.Issues = append(posture.Issues, "The DMARC record does not request aggregate reports (rua)")
+	}
+}
+
+func (eps *EmailPostureService) checkDKIM(posture *core.EmailPosture) {
+	for _, sel := range eps.selectors {
+		eps.SetActive()
+
+		records, err := eps.lookupTXT(sel + "._domainkey." + posture.Domain)
+		if err != nil {
+			continue
+		}
+
+		for _, rec := range records {
+			tags := parseTagList(rec)
+
+			key, found := tags["p"]
+			if !found {
+				continue
+			}
+
+			posture.DKIMSelectors = append(posture.DKIMSelectors, sel)
+			if key == "" {
+				posture.Issues = append(posture.Issues, "The DKIM key for selector "+sel+" has been revoked")
+			} else if bits := dkimKeyBits(strings.ToLower(tags["k"]), key); bits > 0 && bits < minDKIMKeyBits {
+				posture.Issues = append(posture.Issues,
+					fmt.Sprintf("The DKIM key for selector %s is only %d bits", sel, bits))
+			}
+			break
+		}
+	}
+	if len(posture.DKIMSelectors) == 0 {
+		posture.Issues = append(posture.Issues, "No DKIM keys were found for the selectors checked")
+	}
+}
+
+// dkimKeyBits returns the size of an RSA public key from a DKIM record, or zero.
+func dkimKeyBits(keytype, key string) int {
+	if keytype != "" && keytype != "rsa" {
+		return 0
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(key), ""))
+	if err != nil {
+		return 0
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return 0
+	}
+	if rsakey, ok := pub.(*rsa.PublicKey); ok {
+		return rsakey.N.BitLen()
+	}
+	return 0
+}
+
+// parseTagList returns the tags and values of a DMARC or DKIM record.
+func parseTagList(record string) map[string]string {
+	tags := make(map[string]string)
+
+	for _, pair := range strings.Split(record, ";") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		tag := strings.ToLower(strings.TrimSpace(parts[0]))
+		tags[tag] = strings.TrimSpace(parts[1])
+	}
+	return tags
+}
+
+func resolveTXT(name string) ([]string, error) {
+	answers, err := core.Resolve(name, "TXT", core.PriorityHigh)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []string
+	for _, a := range answers {
+		if uint16(a.Type) == dns.TypeTXT {
+			records = append(records, strings.TrimSpace(a.Data))
+		}
+	}
+	return records, nil
+}
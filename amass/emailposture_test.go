@@ -0,0 +1,120 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+)
+
+func TestParseSPF(t *testing.T) {
+	terms, err := ParseSPF("v=spf1 ip4:192.0.2.0/24 -ip6:2001:db8::1 a/24 include:_spf.google.com redirect=_spf.owasp.org ~all")
+	if err != nil {
+		t.Fatalf("ParseSPF returned an error: %v", err)
+	}
+
+	expected := []SPFTerm{
+		{Name: "ip4", Value: "192.0.2.0/24"},
+		{Qualifier: "-", Name: "ip6", Value: "2001:db8::1"},
+		{Name: "a", Value: "/24"},
+		{Name: "include", Value: "_spf.google.com"},
+		{Name: "redirect", Value: "_spf.owasp.org"},
+		{Qualifier: "~", Name: "all"},
+	}
+	if len(terms) != len(expected) {
+		t.Fatalf("ParseSPF returned %d terms, expected %d", len(terms), len(expected))
+	}
+	for i, term := range terms {
+		if *term != expected[i] {
+			t.Errorf("Term %d was %+v, expected %+v", i, *term, expected[i])
+		}
+	}
+
+	if cidr := SPFNetblock("ip6", "2001:db8::1"); cidr != "2001:db8::1/128" {
+		t.Errorf("SPFNetblock returned %s for an ip6 address", cidr)
+	}
+	if _, err := ParseSPF("google-site-verification=abc"); err == nil {
+		t.Errorf("ParseSPF did not return an error for a non-SPF record")
+	}
+}
+
+func TestEmailPosture(t *testing.T) {
+	records := map[string][]string{
+		"owasp.org": {
+			"google-site-verification=abc",
+			"v=spf1 ip4:192.0.2.0/24 include:_spf.owasp.org include:_spf.google.com ?all",
+		},
+		"_spf.owasp.org":        {"v=spf1 ip4:198.51.100.7 a mx -all"},
+		"_spf.google.com":       {"v=spf1 include:_netblocks.google.com include:_netblocks2.google.com ~all"},
+		"_netblocks.google.com": {"v=spf1 ip4:203.0.113.0/24 ip6:2001:db8::/32 a mx ptr exists:x.google.com ~all"},
+		// A loop back to a record already evaluated
+		"_netblocks2.google.com":         {"v=spf1 include:_spf.google.com a mx ~all"},
+		"_dmarc.owasp.org":               {"v=DMARC1; p=none; pct=50"},
+		"selector1._domainkey.owasp.org": {"v=DKIM1; k=rsa; p="},
+	}
+
+	config := setupConfig("owasp.org")
+	config.DKIMSelectors = []string{"selector1", "selector2"}
+	bus, out := setupEventBus(core.NewNameTopic)
+	defer bus.Stop()
+
+	eps := NewEmailPostureService(config, bus)
+	defer eps.filter.Close()
+	eps.lookupTXT = func(name string) ([]string, error) {
+		if recs, found := records[name]; found {
+			return recs, nil
+		}
+		return nil, errors.New("NXDOMAIN")
+	}
+
+	p := eps.analyze("owasp.org")
+	if p.SPFAll != "?all" {
+		t.Errorf("The SPF all mechanism was %s, expected ?all", p.SPFAll)
+	}
+	// Four includes, plus the a, mx, exists and ptr mechanisms that were reached
+	if p.SPFLookups != 13 {
+		t.Errorf("The SPF evaluation required %d lookups, expected 13", p.SPFLookups)
+	}
+	if len(p.CIDRs) != 2 || p.CIDRs[0] != "192.0.2.0/24" || p.CIDRs[1] != "198.51.100.7/32" {
+		t.Errorf("The SPF netblocks of the domain were %v", p.CIDRs)
+	}
+	if len(p.Senders) != 1 || p.Senders[0].Domain != "_spf.google.com" || len(p.Senders[0].CIDRs) != 2 {
+		t.Fatalf("The third-party senders were not identified correctly: %v", p.Senders)
+	}
+	if p.DMARCPolicy != "none" {
+		t.Errorf("The DMARC policy was %s, expected none", p.DMARCPolicy)
+	}
+	if len(p.DKIMSelectors) != 1 || p.DKIMSelectors[0] != "selector1" {
+		t.Errorf("The DKIM selectors found were %v", p.DKIMSelectors)
+	}
+
+	for _, issue := range []string{"exceeding the limit", "neutral",
+		"p=none", "50%", "aggregate reports", "revoked"} {
+		var found bool
+
+		for _, i := range p.Issues {
+			if strings.Contains(i, issue) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("The posture issues did not mention %q: %v", issue, p.Issues)
+		}
+	}
+
+	// The in scope include was published as a new name
+	select {
+	case req := <-out:
+		if req.Name != "_spf.owasp.org" {
+			t.Errorf("%s was published as a new name", req.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("The in scope SPF include was not published")
+	}
+}
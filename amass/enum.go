@@ -5,8 +5,11 @@ package amass
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -37,14 +40,22 @@ type Enumeration struct {
 
 	dataSources []core.Service
 	bruteSrv    core.Service
+	services    []core.Service
 
 	// Pause/Resume channels for halting the enumeration
 	pause  chan struct{}
 	resume chan struct{}
 
-	filter      *utils.StringFilter
+	filter      utils.StringFilter
 	outputQueue *utils.Queue
 
+	resultsLock sync.Mutex
+	httpProbes  []*core.HTTPProbe
+	takeovers   []*core.TakeoverFinding
+	postures    []*core.EmailPosture
+	insertStats *handlers.InsertStats
+	filterStats *FilterStats
+
 	metricsLock       sync.RWMutex
 	dnsQueriesPerSec  int
 	dnsNamesRemaining int
@@ -52,6 +63,13 @@ type Enumeration struct {
 	domainIdx int
 }
 
+// FilterStats provides the size of the filter of names already seen by the enumeration.
+type FilterStats struct {
+	Mode        string
+	Names       int
+	MemoryUsage int64
+}
+
 // NewEnumeration returns an initialized Enumeration that has not been started yet.
 func NewEnumeration() *Enumeration {
 	e := &Enumeration{
@@ -72,7 +90,6 @@ func NewEnumeration() *Enumeration {
 		Done:        make(chan struct{}, 2),
 		pause:       make(chan struct{}, 2),
 		resume:      make(chan struct{}, 2),
-		filter:      utils.NewStringFilter(),
 		outputQueue: utils.NewQueue(),
 	}
 	e.dataSources = sources.GetAllSources(e.Config, e.Bus)
@@ -89,16 +106,33 @@ func (e *Enumeration) Start() error {
 		return err
 	}
 
+	e.filter = e.Config.NewStringFilter()
+	defer e.closeFilter()
+	// The event bus was created before the configuration was loaded
+	e.Bus.SpillQueue(e.Config.Dir, e.Config.QueueMaxInMemory)
+	defer e.Bus.Stop()
+
 	// Setup the correct graph database handler
 	err := e.setupGraph()
 	if err != nil {
 		return err
 	}
-	defer e.Graph.Close()
+	defer e.closeGraph()
+	// The retention policy is applied once the enumeration has completed
+	defer e.applyRetention()
 
 	e.Bus.Subscribe(core.OutputTopic, e.sendOutput)
 	defer e.Bus.Unsubscribe(core.OutputTopic, e.sendOutput)
 
+	e.Bus.Subscribe(core.HTTPProbeTopic, e.addHTTPProbe)
+	defer e.Bus.Unsubscribe(core.HTTPProbeTopic, e.addHTTPProbe)
+
+	e.Bus.Subscribe(core.TakeoverTopic, e.addTakeover)
+	defer e.Bus.Unsubscribe(core.TakeoverTopic, e.addTakeover)
+
+	e.Bus.Subscribe(core.EmailPostureTopic, e.addEmailPosture)
+	defer e.Bus.Unsubscribe(core.EmailPostureTopic, e.addEmailPosture)
+
 	// Select the data sources desired by the user
 	if len(e.Config.DisabledDataSources) > 0 {
 		e.dataSources = e.Config.ExcludeDisabledDataSources(e.dataSources)
@@ -125,6 +159,10 @@ func (e *Enumeration) Start() error {
 	}
 	services = append(services, e.dataSources...)
 
+	e.metricsLock.Lock()
+	e.services = services
+	e.metricsLock.Unlock()
+
 	// Use all previously discovered names that are in scope
 	go e.submitKnownNames()
 	go e.submitProvidedNames()
@@ -154,6 +192,7 @@ loop:
 				e.Config.Log.Printf("Average DNS queries performed: %d/sec, DNS names remaining: %d",
 					e.DNSQueriesPerSec(), e.DNSNamesRemaining())
 			}
+			e.logQueueDepths()
 		case <-t.C:
 			e.periodicChecks(services)
 		}
@@ -193,6 +232,38 @@ func (e *Enumeration) periodicChecks(services []core.Service) {
 	e.releaseDomainName()
 }
 
+// QueueDepths returns the number of requests waiting in the queues of each service used
+// by the enumeration, keyed by the service name.
+func (e *Enumeration) QueueDepths() map[string]core.QueueDepth {
+	e.metricsLock.RLock()
+	defer e.metricsLock.RUnlock()
+
+	depths := make(map[string]core.QueueDepth)
+	for _, srv := range e.services {
+		depths[srv.String()] = srv.QueueDepth()
+	}
+	return depths
+}
+
+// logQueueDepths writes the number of requests waiting in each service queue to the log.
+func (e *Enumeration) logQueueDepths() {
+	depths := e.QueueDepths()
+
+	var names []string
+	for name, q := range depths {
+		if q.Total() > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		q := depths[name]
+		e.Config.Log.Printf("Queue depths for %s: DNS: %d, Address: %d, ASN: %d, Whois: %d",
+			name, q.DNS, q.Addr, q.ASN, q.Whois)
+	}
+}
+
 func (e *Enumeration) releaseDomainName() {
 	domains := e.Config.Domains()
 
@@ -276,18 +347,67 @@ func (e *Enumeration) requiredServices() []core.Service {
 		services = append(services, e.bruteSrv,
 			NewMarkovService(e.Config, e.Bus), NewAlterationService(e.Config, e.Bus))
 	}
+
+	if e.Config.HTTPProbe {
+		services = append(services, NewHTTPProbeService(e.Config, e.Bus))
+	}
+	if e.Config.Takeovers {
+		services = append(services, NewTakeoverService(e.Config, e.Bus))
+	}
+	if e.Config.EmailPosture {
+		services = append(services, NewEmailPostureService(e.Config, e.Bus))
+	}
+	if e.Config.RDAP {
+		services = append(services, NewRDAPService(e.Config, e.Bus))
+	}
 	return services
 }
 
 // Select the graph that will store the enumeration findings.
 func (e *Enumeration) setupGraph() error {
 	if e.Config.GremlinURL != "" {
-		gremlin := handlers.NewGremlin(e.Config.GremlinURL,
-			e.Config.GremlinUser, e.Config.GremlinPass, e.Config.Log)
+		spool := e.Config.GremlinSpoolFile
+		if spool == "" {
+			spool = filepath.Join(core.OutputDirectory(e.Config.Dir), handlers.DefaultGremlinSpoolFile)
+		}
+
+		gremlin, err := handlers.NewGremlin(e.Config.GremlinURL,
+			e.Config.GremlinUser, e.Config.GremlinPass, &handlers.BatchSettings{
+				Size:          e.Config.GremlinBatchSize,
+				FlushInterval: e.Config.GremlinFlushInterval,
+				MaxRetries:    e.Config.GremlinMaxRetries,
+				SpoolFile:     spool,
+			}, e.Config.Log)
+		if err != nil {
+			return err
+		}
 		e.Graph = gremlin
 		return nil
 	}
 
+	if e.Config.SQLDriver != "" {
+		source := e.Config.SQLDataSource
+		if source == "" && e.Config.SQLDriver == handlers.SQLDriverSQLite {
+			source = filepath.Join(core.OutputDirectory(e.Config.Dir), handlers.DefaultSQLiteFile)
+		}
+
+		db, err := handlers.NewSQL(e.Config.SQLDriver, source, e.Config.Log)
+		if err != nil {
+			return fmt.Errorf("Failed to connect with the SQL database: %v", err)
+		}
+		e.Graph = db
+		return nil
+	}
+
+	if e.Config.MemoryGraph {
+		graph, err := handlers.NewMemoryGraph(e.Config.MemoryGraphSnapshot, e.Config.Log)
+		if err != nil {
+			return err
+		}
+		e.Graph = graph
+		return nil
+	}
+
 	graph := handlers.NewGraph(e.Config.Dir)
 	if graph == nil {
 		return errors.New("Failed to create the graph")
@@ -296,6 +416,90 @@ func (e *Enumeration) setupGraph() error {
 	return nil
 }
 
+// Close the graph and keep the insert throughput of the handlers that batch their inserts.
+func (e *Enumeration) closeGraph() {
+	e.Graph.Close()
+
+	reporter, ok := e.Graph.(handlers.InsertStatsReporter)
+	if !ok {
+		return
+	}
+
+	stats := reporter.InsertStats()
+	e.Config.Log.Printf("%s: Inserted %d data operations in %d batches (%.1f per second), %d retries",
+		e.Graph.String(), stats.Inserted, stats.Batches, stats.PerSecond(), stats.Retries)
+	if stats.Failed > 0 {
+		e.Config.Log.Printf("%s: %d data operations were rejected and set aside", e.Graph.String(), stats.Failed)
+	}
+	if stats.Spooled > 0 {
+		e.Config.Log.Printf("%s: %d data operations were kept in the spool file", e.Graph.String(), stats.Spooled)
+	}
+
+	e.resultsLock.Lock()
+	e.insertStats = &stats
+	e.resultsLock.Unlock()
+}
+
+// Close the filter of names already seen and keep the amount of memory it was using.
+func (e *Enumeration) closeFilter() {
+	mode := e.Config.FilterMode
+	if mode == "" {
+		mode = core.FilterExact
+	}
+
+	stats := &FilterStats{
+		Mode:        mode,
+		Names:       e.filter.Len(),
+		MemoryUsage: e.filter.MemoryUsage(),
+	}
+	e.filter.Close()
+
+	e.Config.Log.Printf("Name filter (%s): %d unique names using approximately %d KB of memory",
+		stats.Mode, stats.Names, stats.MemoryUsage/1024)
+
+	e.resultsLock.Lock()
+	e.filterStats = stats
+	e.resultsLock.Unlock()
+}
+
+// FilterStats returns the size of the filter of names already seen, or nil when the
+// enumeration has not completed.
+func (e *Enumeration) FilterStats() *FilterStats {
+	e.resultsLock.Lock()
+	defer e.resultsLock.Unlock()
+
+	return e.filterStats
+}
+
+// InsertStats returns the insert throughput of the graph database handler, or nil when
+// the handler does not batch its inserts or the enumeration has not completed.
+func (e *Enumeration) InsertStats() *handlers.InsertStats {
+	e.resultsLock.Lock()
+	defer e.resultsLock.Unlock()
+
+	return e.insertStats
+}
+
+// Delete the enumerations in the graph that have expired according to the retention policy.
+func (e *Enumeration) applyRetention() {
+	policy := &handlers.RetentionPolicy{
+		KeepLast: e.Config.RetentionKeepLast,
+		KeepDays: e.Config.RetentionKeepDays,
+		Exclude:  []string{e.Config.UUID.String()},
+	}
+	if !policy.Enabled() {
+		return
+	}
+
+	deleted, err := handlers.ApplyRetention(e.Graph, policy, e.Config.Domains())
+	if err != nil {
+		e.Config.Log.Printf("Failed to apply the retention policy: %v", err)
+	}
+	if len(deleted) > 0 {
+		e.Config.Log.Printf("The retention policy removed %d enumerations from the graph", len(deleted))
+	}
+}
+
 // DNSQueriesPerSec returns the number of DNS queries the enumeration has performed per second.
 func (e *Enumeration) DNSQueriesPerSec() int {
 	e.metricsLock.RLock()
@@ -413,6 +617,57 @@ func (e *Enumeration) sendOutput(o *core.Output) {
 	}
 }
 
+func (e *Enumeration) addHTTPProbe(probe *core.HTTPProbe) {
+	e.resultsLock.Lock()
+	defer e.resultsLock.Unlock()
+
+	e.httpProbes = append(e.httpProbes, probe)
+}
+
+// HTTPProbes returns the web server responses obtained by the enumeration.
+func (e *Enumeration) HTTPProbes() []*core.HTTPProbe {
+	e.resultsLock.Lock()
+	defer e.resultsLock.Unlock()
+
+	probes := make([]*core.HTTPProbe, len(e.httpProbes))
+	copy(probes, e.httpProbes)
+	return probes
+}
+
+func (e *Enumeration) addTakeover(finding *core.TakeoverFinding) {
+	e.resultsLock.Lock()
+	defer e.resultsLock.Unlock()
+
+	e.takeovers = append(e.takeovers, finding)
+}
+
+// Takeovers returns the subdomain takeover findings of the enumeration.
+func (e *Enumeration) Takeovers() []*core.TakeoverFinding {
+	e.resultsLock.Lock()
+	defer e.resultsLock.Unlock()
+
+	findings := make([]*core.TakeoverFinding, len(e.takeovers))
+	copy(findings, e.takeovers)
+	return findings
+}
+
+func (e *Enumeration) addEmailPosture(posture *core.EmailPosture) {
+	e.resultsLock.Lock()
+	defer e.resultsLock.Unlock()
+
+	e.postures = append(e.postures, posture)
+}
+
+// EmailPostures returns the SPF, DMARC and DKIM analysis of each root domain.
+func (e *Enumeration) EmailPostures() []*core.EmailPosture {
+	e.resultsLock.Lock()
+	defer e.resultsLock.Unlock()
+
+	postures := make([]*core.EmailPosture, len(e.postures))
+	copy(postures, e.postures)
+	return postures
+}
+
 // Pause temporarily halts the enumeration.
 func (e *Enumeration) Pause() {
 	e.pause <- struct{}{}
@@ -0,0 +1,28 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"testing"
+
+	"github.com/root-secure/Amass/amass/core"
+)
+
+type enumTestService struct {
+	core.BaseService
+}
+
+func TestEnumerationQueueDepths(t *testing.T) {
+	e := NewEnumeration()
+
+	srv := new(enumTestService)
+	srv.BaseService = *core.NewBaseService(srv, "Queue Test", nil, e.Bus)
+	srv.SendASNRequest(&core.ASNRequest{ASN: 64500})
+	e.services = []core.Service{srv}
+
+	depths := e.QueueDepths()
+	if q, found := depths["Queue Test"]; !found || q.ASN != 1 || q.Total() != 1 {
+		t.Errorf("Returned the queue depths %+v", depths)
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// The label of the quads holding the annotations, which are not part of an enumeration.
+const graphAnnotationContext = "amass_annotations"
+
+// Criticalities are the values accepted for the criticality of an annotated asset.
+var Criticalities = []string{"low", "medium", "high", "critical"}
+
+// Annotation is the triage metadata attached to a DNS name, address or netblock.
+// Annotations apply to the asset in every enumeration, including future enumerations.
+type Annotation struct {
+	Asset          string    `json:"asset"`
+	Owner          string    `json:"owner,omitempty"`
+	Criticality    string    `json:"criticality,omitempty"`
+	FalsePositive  bool      `json:"false_positive,omitempty"`
+	Decommissioned bool      `json:"decommissioned,omitempty"`
+	Notes          string    `json:"notes,omitempty"`
+	Updated        time.Time `json:"updated"`
+}
+
+// Empty returns true when the annotation does not hold any metadata.
+func (a *Annotation) Empty() bool {
+	return a.Owner == "" && a.Criticality == "" && !a.FalsePositive && !a.Decommissioned && a.Notes == ""
+}
+
+// Labels returns the short descriptions of the metadata, without the notes.
+func (a *Annotation) Labels() []string {
+	var labels []string
+
+	if a.Owner != "" {
+		labels = append(labels, "owner: "+a.Owner)
+	}
+	if a.Criticality != "" {
+		labels = append(labels, "criticality: "+a.Criticality)
+	}
+	if a.FalsePositive {
+		labels = append(labels, "false positive")
+	}
+	if a.Decommissioned {
+		labels = append(labels, "decommissioned")
+	}
+	return labels
+}
+
+// String returns a description of all the metadata held by the annotation.
+func (a *Annotation) String() string {
+	labels := a.Labels()
+	if a.Notes != "" {
+		labels = append(labels, "notes: "+a.Notes)
+	}
+	return strings.Join(labels, ", ")
+}
+
+// ValidCriticality returns true when the criticality is empty or one of the accepted values.
+func ValidCriticality(c string) bool {
+	if c == "" {
+		return true
+	}
+	for _, v := range Criticalities {
+		if c == v {
+			return true
+		}
+	}
+	return false
+}
+
+// annotationDataOpts returns the data operation storing the annotation.
+func annotationDataOpts(a *Annotation) *DataOptsParams {
+	updated := a.Updated
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	return &DataOptsParams{
+		Timestamp:      updated.UTC().Format(time.RFC3339),
+		Type:           OptAnnotation,
+		Name:           strings.ToLower(a.Asset),
+		Owner:          a.Owner,
+		Criticality:    a.Criticality,
+		FalsePositive:  a.FalsePositive,
+		Decommissioned: a.Decommissioned,
+		Notes:          a.Notes,
+	}
+}
+
+// annotationFromDataOpts returns the annotation stored by the data operation.
+func annotationFromDataOpts(data *DataOptsParams) *Annotation {
+	return &Annotation{
+		Asset:          strings.ToLower(data.Name),
+		Owner:          data.Owner,
+		Criticality:    data.Criticality,
+		FalsePositive:  data.FalsePositive,
+		Decommissioned: data.Decommissioned,
+		Notes:          data.Notes,
+		Updated:        parseAssetTimestamp(data.Timestamp),
+	}
+}
+
+// ParseAnnotations decodes the annotations in CSV format provided via a Reader. The first row
+// names the columns, which include asset and any of owner, criticality, false_positive,
+// decommissioned and notes. Each row provides the complete annotation for the asset.
+func ParseAnnotations(r io.Reader) ([]*Annotation, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.Replace(strings.ToLower(strings.TrimSpace(name)), "-", "_", -1)
+		columns[name] = i
+	}
+	if _, found := columns["asset"]; !found {
+		return nil, errors.New("The annotations file does not have an asset column")
+	}
+
+	var annotations []*Annotation
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, found := columns[name]; found && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		a := &Annotation{
+			Asset:       strings.ToLower(field("asset")),
+			Owner:       field("owner"),
+			Criticality: strings.ToLower(field("criticality")),
+			Notes:       field("notes"),
+		}
+		if a.Asset == "" {
+			continue
+		}
+		if !ValidCriticality(a.Criticality) {
+			return nil, fmt.Errorf("Line %d: The criticality must be one of %s",
+				line, strings.Join(Criticalities, ", "))
+		}
+		if a.FalsePositive, err = parseAnnotationBool(field("false_positive")); err != nil {
+			return nil, fmt.Errorf("Line %d: false_positive %v", line, err)
+		}
+		if a.Decommissioned, err = parseAnnotationBool(field("decommissioned")); err != nil {
+			return nil, fmt.Errorf("Line %d: decommissioned %v", line, err)
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, nil
+}
+
+func parseAnnotationBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", "no", "n":
+		return false, nil
+	case "yes", "y", "x":
+		return true, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("is not a boolean value: %s", value)
+	}
+	return b, nil
+}
+
+func (g *Graph) insertAnnotation(data *DataOptsParams) error {
+	a := annotationFromDataOpts(data)
+	if a.Asset == "" {
+		return errors.New("Graph: The annotation does not identify an asset")
+	}
+
+	var quads []quad.Quad
+	if !a.Empty() {
+		add := func(pred, value string) {
+			if value != "" {
+				quads = append(quads, quad.Make(a.Asset, pred, value, graphAnnotationContext))
+			}
+		}
+
+		add("owner", a.Owner)
+		add("criticality", a.Criticality)
+		if a.FalsePositive {
+			add("false_positive", "true")
+		}
+		if a.Decommissioned {
+			add("decommissioned", "true")
+		}
+		add("notes", a.Notes)
+		add("updated", data.Timestamp)
+	}
+
+	// The annotation replaces the one previously stored for the asset. The quads are removed
+	// in a separate transaction, since the store releases the values no longer referenced
+	// after a transaction, even when the same transaction references them again
+	t := cayley.NewTransaction()
+	for _, q := range g.subjectQuads(a.Asset, graphAnnotationContext) {
+		t.RemoveQuad(q)
+	}
+	if err := g.store.ApplyTransaction(t); err != nil {
+		return err
+	}
+
+	t = cayley.NewTransaction()
+	for _, q := range quads {
+		t.AddQuad(q)
+	}
+	return g.store.ApplyTransaction(t)
+}
+
+// subjectQuads returns the quads with the subject and label.
+// The caller is expected to hold the lock on the graph.
+func (g *Graph) subjectQuads(subject, label string) []quad.Quad {
+	ref := g.store.ValueOf(quad.String(subject))
+	if ref == nil {
+		return nil
+	}
+
+	var quads []quad.Quad
+	it := g.store.QuadIterator(quad.Subject, ref)
+	defer it.Close()
+
+	ctx := context.TODO()
+	for it.Next(ctx) {
+		q := g.store.Quad(it.Result())
+		if quad.ToString(q.Label) == label {
+			quads = append(quads, q)
+		}
+	}
+	return quads
+}
+
+// Annotate implements the Amass DataHandler interface.
+func (g *Graph) Annotate(a *Annotation) error {
+	return g.Insert(annotationDataOpts(a))
+}
+
+// Annotations implements the Amass DataHandler interface.
+func (g *Graph) Annotations() map[string]*Annotation {
+	g.Lock()
+	defer g.Unlock()
+
+	annotations := make(map[string]*Annotation)
+	for _, q := range g.labelQuads(graphAnnotationContext) {
+		asset := quad.ToString(q.Subject)
+		value := quad.ToString(q.Object)
+
+		a, found := annotations[asset]
+		if !found {
+			a = &Annotation{Asset: asset}
+			annotations[asset] = a
+		}
+
+		switch quad.ToString(q.Predicate) {
+		case "owner":
+			a.Owner = value
+		case "criticality":
+			a.Criticality = value
+		case "false_positive":
+			a.FalsePositive = value == "true"
+		case "decommissioned":
+			a.Decommissioned = value == "true"
+		case "notes":
+			a.Notes = value
+		case "updated":
+			a.Updated = parseAssetTimestamp(value)
+		}
+	}
+	return annotations
+}
+
+// mergeAnnotations brings the annotations in the src graph into this graph. The most
+// recently updated annotation is kept for each asset.
+func (g *Graph) mergeAnnotations(src *Graph) (int, error) {
+	existing := g.Annotations()
+
+	var merged int
+	for asset, a := range src.Annotations() {
+		if cur, found := existing[asset]; found && !a.Updated.After(cur.Updated) {
+			continue
+		}
+
+		if err := g.Annotate(a); err != nil {
+			return merged, err
+		}
+		merged++
+	}
+	return merged, nil
+}
+
+func (s *SQL) insertAnnotation(data *DataOptsParams) error {
+	a := annotationFromDataOpts(data)
+	if a.Asset == "" {
+		return errors.New("SQL: The annotation does not identify an asset")
+	}
+
+	if a.Empty() {
+		_, err := s.db.Exec(s.db.Rebind("DELETE FROM annotations WHERE asset = ?"), a.Asset)
+		return err
+	}
+
+	_, err := s.db.Exec(s.db.Rebind("INSERT INTO annotations (asset, owner, criticality, "+
+		"false_positive, decommissioned, notes, updated) VALUES (?, ?, ?, ?, ?, ?, ?) "+
+		"ON CONFLICT (asset) DO UPDATE SET owner = excluded.owner, criticality = excluded.criticality, "+
+		"false_positive = excluded.false_positive, decommissioned = excluded.decommissioned, "+
+		"notes = excluded.notes, updated = excluded.updated"), a.Asset, a.Owner, a.Criticality,
+		sqlBool(a.FalsePositive), sqlBool(a.Decommissioned), a.Notes, data.Timestamp)
+	return err
+}
+
+func sqlBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Annotate implements the Amass DataHandler interface.
+func (s *SQL) Annotate(a *Annotation) error {
+	return s.Insert(annotationDataOpts(a))
+}
+
+// Annotations implements the Amass DataHandler interface.
+func (s *SQL) Annotations() map[string]*Annotation {
+	s.Lock()
+	defer s.Unlock()
+
+	var rows []struct {
+		Asset          string `db:"asset"`
+		Owner          string `db:"owner"`
+		Criticality    string `db:"criticality"`
+		FalsePositive  int    `db:"false_positive"`
+		Decommissioned int    `db:"decommissioned"`
+		Notes          string `db:"notes"`
+		Updated        string `db:"updated"`
+	}
+	if err := s.db.Select(&rows, "SELECT asset, owner, criticality, false_positive, "+
+		"decommissioned, notes, updated FROM annotations"); err != nil {
+		return nil
+	}
+
+	annotations := make(map[string]*Annotation)
+	for _, row := range rows {
+		annotations[row.Asset] = &Annotation{
+			Asset:          row.Asset,
+			Owner:          row.Owner,
+			Criticality:    row.Criticality,
+			FalsePositive:  row.FalsePositive != 0,
+			Decommissioned: row.Decommissioned != 0,
+			Notes:          row.Notes,
+			Updated:        parseAssetTimestamp(row.Updated),
+		}
+	}
+	return annotations
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnnotations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "annotations")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	graph := NewGraph(filepath.Join(dir, "graph"))
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	db, err := NewSQL(SQLDriverSQLite, filepath.Join(dir, DefaultSQLiteFile), nil)
+	if err != nil {
+		t.Fatalf("Failed to create the SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	updated := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, handler := range []DataHandler{graph, db} {
+		if err := DataOptsDriver(mergeTestOpts(
+			"3d9a2f1c-6b7e-4d5a-8c9b-0a1b2c3d4e5f", "2019-01-01T00:00:00Z", "dns"), handler); err != nil {
+			t.Fatalf("%s: Failed to insert the data operations: %v", handler, err)
+		}
+
+		err := handler.Annotate(&Annotation{
+			Asset:       "WWW.example.com",
+			Owner:       "web-team",
+			Criticality: "high",
+			Notes:       "Public website",
+			Updated:     updated,
+		})
+		if err != nil {
+			t.Fatalf("%s: Failed to annotate the asset: %v", handler, err)
+		}
+		handler.Annotate(&Annotation{Asset: "192.0.2.0/24", Decommissioned: true, Updated: updated})
+
+		// Annotations are not enumerations
+		if enums := handler.EnumerationList(); len(enums) != 1 {
+			t.Errorf("%s: The enumeration list was %v", handler, enums)
+		}
+
+		annotations := handler.Annotations()
+		a, found := annotations["www.example.com"]
+		if !found || a.Owner != "web-team" || a.Criticality != "high" ||
+			a.Notes != "Public website" || !a.Updated.Equal(updated) {
+			t.Fatalf("%s: Unexpected annotation: %+v", handler, a)
+		}
+		if a := annotations["192.0.2.0/24"]; a == nil || !a.Decommissioned || a.FalsePositive {
+			t.Errorf("%s: Unexpected netblock annotation: %+v", handler, a)
+		}
+
+		// The annotation is replaced, and removed once it is empty
+		if err := handler.Annotate(&Annotation{Asset: "www.example.com", FalsePositive: true}); err != nil {
+			t.Fatalf("%s: Failed to replace the annotation: %v", handler, err)
+		}
+		if a := handler.Annotations()["www.example.com"]; a == nil || a.Owner != "" || !a.FalsePositive {
+			t.Errorf("%s: The annotation was not replaced: %+v", handler, a)
+		}
+		handler.Annotate(&Annotation{Asset: "www.example.com"})
+		if _, found := handler.Annotations()["www.example.com"]; found {
+			t.Errorf("%s: The empty annotation was not removed", handler)
+		}
+	}
+}
+
+func TestAnnotationsMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "annotations")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	snapshot := filepath.Join(dir, "snapshot.json")
+	mem, err := NewMemoryGraph(snapshot, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the in-memory graph: %v", err)
+	}
+	mem.Annotate(&Annotation{Asset: "www.example.com", Owner: "web-team",
+		Updated: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)})
+	mem.Close()
+
+	// The annotations are kept by the snapshot as data operations
+	f, err := os.Open(snapshot)
+	if err != nil {
+		t.Fatalf("Failed to open the snapshot: %v", err)
+	}
+	opts, err := ParseDataOpts(f)
+	f.Close()
+	if err != nil || len(opts) != 1 || opts[0].Type != OptAnnotation {
+		t.Fatalf("The snapshot did not hold the annotation: %v", opts)
+	}
+
+	graph := NewGraph(filepath.Join(dir, "graph"))
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	// The most recently updated annotation is kept
+	graph.Annotate(&Annotation{Asset: "www.example.com", Owner: "ops-team",
+		Updated: time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)})
+	stats, err := MergeDataOpts(graph, opts, false)
+	if err != nil {
+		t.Fatalf("Failed to merge the data operations: %v", err)
+	}
+	if stats.Annotations != 1 || len(stats.Merged) != 0 {
+		t.Errorf("Unexpected merge stats: %+v", stats)
+	}
+	if a := graph.Annotations()["www.example.com"]; a == nil || a.Owner != "web-team" {
+		t.Errorf("The merged annotation was %+v", a)
+	}
+}
+
+func TestParseAnnotations(t *testing.T) {
+	input := `Asset,Owner,Criticality,False-Positive,Decommissioned,Notes
+www.example.com,web-team,High,,,"Public website, behind the CDN"
+old.example.com,,,no,yes,
+192.0.2.0/24,network,low`
+
+	annotations, err := ParseAnnotations(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Failed to parse the annotations: %v", err)
+	}
+	if len(annotations) != 3 {
+		t.Fatalf("Parsed %d annotations", len(annotations))
+	}
+
+	if a := annotations[0]; a.Owner != "web-team" || a.Criticality != "high" ||
+		a.Notes != "Public website, behind the CDN" {
+		t.Errorf("Unexpected annotation: %+v", a)
+	}
+	if a := annotations[1]; a.FalsePositive || !a.Decommissioned {
+		t.Errorf("Unexpected annotation: %+v", a)
+	}
+	if a := annotations[2]; a.Asset != "192.0.2.0/24" || a.Owner != "network" {
+		t.Errorf("Unexpected annotation: %+v", a)
+	}
+
+	if _, err := ParseAnnotations(strings.NewReader("asset,criticality\nwww.example.com,urgent")); err == nil {
+		t.Errorf("An invalid criticality was accepted")
+	}
+	if _, err := ParseAnnotations(strings.NewReader("name,owner\nwww.example.com,web-team")); err == nil {
+		t.Errorf("A file without the asset column was accepted")
+	}
+}
+
+func TestGremlinAnnotationScript(t *testing.T) {
+	g := &Gremlin{batch: &gremlinBatch{bindings: make(map[string]string)}}
+
+	a := &Annotation{Asset: "WWW.example.com", Owner: "web team", FalsePositive: true}
+	if err := g.insertData(annotationDataOpts(a)); err != nil {
+		t.Fatalf("Failed to add the annotation to the batch: %v", err)
+	}
+	// The previous annotation is removed before the new vertex is added
+	if len(g.batch.statements) != 2 || !strings.Contains(g.batch.statements[0], "drop()") ||
+		!strings.Contains(g.batch.statements[1], "addV('annotation')") {
+		t.Fatalf("Unexpected statements: %v", g.batch.statements)
+	}
+	if g.batch.bindings["b1_asset"] != "www.example.com" || g.batch.bindings["b1_false_positive"] != "true" {
+		t.Errorf("Unexpected bindings: %v", g.batch.bindings)
+	}
+
+	// Clearing the annotation only removes the vertex
+	g.batch = &gremlinBatch{bindings: make(map[string]string)}
+	if err := g.insertData(annotationDataOpts(&Annotation{Asset: "www.example.com"})); err != nil {
+		t.Fatalf("Failed to add the annotation to the batch: %v", err)
+	}
+	if len(g.batch.statements) != 1 {
+		t.Errorf("Unexpected statements: %v", g.batch.statements)
+	}
+}
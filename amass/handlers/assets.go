@@ -0,0 +1,563 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/jmoiron/sqlx"
+)
+
+// The label of the quads holding the asset inventory, which is kept apart from the enumerations
+// so it is not affected when enumerations are deleted.
+const graphAssetContext = "amass_assets"
+
+// These strings identify the types of assets kept by the asset inventory.
+const (
+	AssetTypeName     = "name"
+	AssetTypeAddress  = "address"
+	AssetTypeNetblock = "netblock"
+)
+
+// Asset is a DNS name, address or netblock consolidated across all the enumerations.
+type Asset struct {
+	Asset        string           `json:"asset"`
+	Type         string           `json:"type"`
+	FirstSeen    time.Time        `json:"first_seen"`
+	LastSeen     time.Time        `json:"last_seen"`
+	Enumerations []string         `json:"enumerations"`
+	Addresses    []AddressHistory `json:"addresses,omitempty"`
+	Annotation   *Annotation      `json:"annotation,omitempty"`
+}
+
+// AddressHistory reports when a DNS name resolved to the address.
+type AddressHistory struct {
+	Address   string    `json:"address"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// AssetInventory is implemented by the handlers that maintain the asset inventory
+// alongside the data collected by each enumeration.
+type AssetInventory interface {
+	// Returns all the assets, sorted by type and value. The enumerations stored
+	// before the inventory was maintained are added the first time it is requested.
+	Assets() []*Asset
+}
+
+// assetSighting is an observation of an asset made by a data operation.
+type assetSighting struct {
+	Asset   string
+	Type    string
+	Address string
+	UUID    string
+	Seen    time.Time
+}
+
+// assetSightings returns the assets observed by the data operation.
+func assetSightings(data *DataOptsParams) []*assetSighting {
+	seen, err := time.Parse(time.RFC3339, data.Timestamp)
+	if err != nil {
+		seen = time.Now()
+	}
+
+	var sightings []*assetSighting
+	add := func(asset, t, addr string) {
+		if asset == "" {
+			return
+		}
+
+		sightings = append(sightings, &assetSighting{
+			Asset:   strings.ToLower(asset),
+			Type:    t,
+			Address: addr,
+			UUID:    data.UUID,
+			Seen:    seen,
+		})
+	}
+
+	switch data.Type {
+	case OptDomain:
+		add(data.Domain, AssetTypeName, "")
+	case OptSubdomain:
+		add(data.Name, AssetTypeName, "")
+	case OptCNAME, OptNS, OptMX:
+		add(data.Name, AssetTypeName, "")
+		add(data.TargetName, AssetTypeName, "")
+	case OptPTR:
+		// The reverse DNS name is not an asset, but the name it points to is
+		add(data.TargetName, AssetTypeName, "")
+	case OptSRV:
+		add(data.Service, AssetTypeName, "")
+		add(data.TargetName, AssetTypeName, "")
+	case OptA, OptAAAA:
+		add(data.Name, AssetTypeName, data.Address)
+		add(data.Address, AssetTypeAddress, "")
+	case OptInfrastructure:
+		add(data.Address, AssetTypeAddress, "")
+		add(data.CIDR, AssetTypeNetblock, "")
+	}
+	return sightings
+}
+
+// assetTimestamp formats the time for storage, so the values can be compared as strings.
+func assetTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func parseAssetTimestamp(ts string) time.Time {
+	t, _ := time.Parse(time.RFC3339, ts)
+	return t
+}
+
+// sortAssets orders the assets by type and value, and the details of each asset by time.
+func sortAssets(assets []*Asset) {
+	order := map[string]int{AssetTypeName: 0, AssetTypeAddress: 1, AssetTypeNetblock: 2}
+
+	for _, a := range assets {
+		sort.Strings(a.Enumerations)
+		sort.Slice(a.Addresses, func(i, j int) bool {
+			if !a.Addresses[i].FirstSeen.Equal(a.Addresses[j].FirstSeen) {
+				return a.Addresses[i].FirstSeen.Before(a.Addresses[j].FirstSeen)
+			}
+			return a.Addresses[i].Address < a.Addresses[j].Address
+		})
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		if assets[i].Type != assets[j].Type {
+			return order[assets[i].Type] < order[assets[j].Type]
+		}
+		return assets[i].Asset < assets[j].Asset
+	})
+}
+
+// FilterAssets returns the assets related to the domains. The addresses are kept when a name
+// within the domains resolved to them, and the netblocks are kept when they contain one of
+// those addresses. All the assets are returned when no domains are provided.
+func FilterAssets(assets []*Asset, domains []string) []*Asset {
+	if len(domains) == 0 {
+		return assets
+	}
+
+	addrs := make(map[string]struct{})
+	var names, others []*Asset
+	for _, a := range assets {
+		if a.Type != AssetTypeName {
+			others = append(others, a)
+			continue
+		}
+		if !assetNameInScope(a.Asset, domains) {
+			continue
+		}
+
+		names = append(names, a)
+		for _, h := range a.Addresses {
+			addrs[h.Address] = struct{}{}
+		}
+	}
+
+	var ips []net.IP
+	for addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	filtered := names
+	for _, a := range others {
+		switch a.Type {
+		case AssetTypeAddress:
+			if _, found := addrs[a.Asset]; found {
+				filtered = append(filtered, a)
+			}
+		case AssetTypeNetblock:
+			_, cidr, err := net.ParseCIDR(a.Asset)
+			if err != nil {
+				continue
+			}
+
+			for _, ip := range ips {
+				if cidr.Contains(ip) {
+					filtered = append(filtered, a)
+					break
+				}
+			}
+		}
+	}
+	return filtered
+}
+
+func assetNameInScope(name string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(d)
+
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateAssets adds the assets observed by the data operation to the inventory.
+// The caller is expected to hold the lock on the graph.
+func (g *Graph) updateAssets(data *DataOptsParams) error {
+	for _, sighting := range assetSightings(data) {
+		if err := g.addAssetSighting(sighting); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Graph) addAssetSighting(s *assetSighting) error {
+	t := cayley.NewTransaction()
+
+	if g.propertyValue(quad.String(s.Asset), "asset_type", graphAssetContext) == "" {
+		t.AddQuad(quad.Make(s.Asset, "asset_type", s.Type, graphAssetContext))
+	}
+	g.assetSeen(t, s.Asset, s.Seen)
+
+	if q := quad.Make(s.Asset, "seen_in", s.UUID, graphAssetContext); !g.hasQuad(q) {
+		t.AddQuad(q)
+	}
+	if s.Address != "" {
+		if q := quad.Make(s.Asset, "had_address", s.Address, graphAssetContext); !g.hasQuad(q) {
+			t.AddQuad(q)
+		}
+		// The address history is kept by a node for the name and address pair
+		g.assetSeen(t, s.Asset+" "+s.Address, s.Seen)
+	}
+	return g.store.ApplyTransaction(t)
+}
+
+// assetSeen updates the first seen and last seen properties of the node with the time.
+func (g *Graph) assetSeen(t *graph.Transaction, node string, seen time.Time) {
+	ts := assetTimestamp(seen)
+
+	if first := g.propertyValue(quad.String(node), "first_seen", graphAssetContext); first == "" || ts < first {
+		if first != "" {
+			t.RemoveQuad(quad.Make(node, "first_seen", first, graphAssetContext))
+		}
+		t.AddQuad(quad.Make(node, "first_seen", ts, graphAssetContext))
+	}
+	if last := g.propertyValue(quad.String(node), "last_seen", graphAssetContext); last == "" || ts > last {
+		if last != "" {
+			t.RemoveQuad(quad.Make(node, "last_seen", last, graphAssetContext))
+		}
+		t.AddQuad(quad.Make(node, "last_seen", ts, graphAssetContext))
+	}
+}
+
+// indexAssets adds the assets found in an enumeration to the inventory.
+// The caller is expected to hold the lock on the graph.
+func (g *Graph) indexAssets(uuid string) error {
+	types := make(map[string]string)
+	timestamps := make(map[string]string)
+	var resolved []quad.Quad
+
+	for _, q := range g.labelQuads(uuid) {
+		subject := quad.ToString(q.Subject)
+
+		switch quad.ToString(q.Predicate) {
+		case "type":
+			types[subject] = quad.ToString(q.Object)
+		case "timestamp":
+			timestamps[subject] = quad.ToString(q.Object)
+		case "a_to", "aaaa_to":
+			resolved = append(resolved, q)
+		}
+	}
+
+	var opts []*DataOptsParams
+	for node, t := range types {
+		data := &DataOptsParams{UUID: uuid, Timestamp: timestamps[node]}
+
+		switch t {
+		case "domain", "subdomain", "ns", "mx":
+			data.Type = OptSubdomain
+			data.Name = node
+		case "address":
+			// Addresses are reported by infrastructure data operations without a netblock
+			data.Type = OptInfrastructure
+			data.Address = node
+		case "netblock":
+			data.Type = OptInfrastructure
+			data.CIDR = node
+		default:
+			continue
+		}
+		opts = append(opts, data)
+	}
+	for _, q := range resolved {
+		addr := quad.ToString(q.Object)
+
+		opts = append(opts, &DataOptsParams{
+			UUID:      uuid,
+			Timestamp: timestamps[addr],
+			Type:      OptA,
+			Name:      quad.ToString(q.Subject),
+			Address:   addr,
+		})
+	}
+
+	for _, data := range opts {
+		if err := g.updateAssets(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Assets implements the AssetInventory interface.
+func (g *Graph) Assets() []*Asset {
+	enums := g.EnumerationList()
+
+	g.Lock()
+	defer g.Unlock()
+
+	assets := make(map[string]*Asset)
+	history := make(map[string]*AddressHistory)
+	indexed := make(map[string]struct{})
+	getAsset := func(name string) *Asset {
+		a, found := assets[name]
+		if !found {
+			a = &Asset{Asset: name}
+			assets[name] = a
+		}
+		return a
+	}
+	getHistory := func(node string) *AddressHistory {
+		h, found := history[node]
+		if !found {
+			h = new(AddressHistory)
+			history[node] = h
+		}
+		return h
+	}
+
+	load := func() {
+		for _, q := range g.labelQuads(graphAssetContext) {
+			subject := quad.ToString(q.Subject)
+			object := quad.ToString(q.Object)
+
+			switch quad.ToString(q.Predicate) {
+			case "asset_type":
+				getAsset(subject).Type = object
+			case "seen_in":
+				getAsset(subject).Enumerations = append(getAsset(subject).Enumerations, object)
+				indexed[object] = struct{}{}
+			case "had_address":
+				getHistory(subject + " " + object).Address = object
+			case "first_seen":
+				if strings.Contains(subject, " ") {
+					getHistory(subject).FirstSeen = parseAssetTimestamp(object)
+				} else {
+					getAsset(subject).FirstSeen = parseAssetTimestamp(object)
+				}
+			case "last_seen":
+				if strings.Contains(subject, " ") {
+					getHistory(subject).LastSeen = parseAssetTimestamp(object)
+				} else {
+					getAsset(subject).LastSeen = parseAssetTimestamp(object)
+				}
+			}
+		}
+	}
+
+	load()
+	// Add the enumerations stored before the inventory was maintained
+	var missing bool
+	for _, uuid := range enums {
+		if _, found := indexed[uuid]; !found {
+			g.indexAssets(uuid)
+			missing = true
+		}
+	}
+	if missing {
+		assets = make(map[string]*Asset)
+		history = make(map[string]*AddressHistory)
+		load()
+	}
+
+	for node, h := range history {
+		if a, found := assets[node[:strings.Index(node, " ")]]; found && h.Address != "" {
+			a.Addresses = append(a.Addresses, *h)
+		}
+	}
+
+	var results []*Asset
+	for _, a := range assets {
+		if a.Type != "" {
+			results = append(results, a)
+		}
+	}
+	sortAssets(results)
+	return results
+}
+
+// updateAssets adds the assets observed by the data operation to the inventory.
+func (s *SQL) updateAssets(tx *sqlx.Tx, data *DataOptsParams) error {
+	for _, sighting := range assetSightings(data) {
+		ts := assetTimestamp(sighting.Seen)
+
+		_, err := s.exec(tx, "INSERT INTO assets (asset, type, first_seen, last_seen) VALUES (?, ?, ?, ?) "+
+			"ON CONFLICT (asset) DO UPDATE SET "+
+			"first_seen = CASE WHEN excluded.first_seen < assets.first_seen "+
+			"THEN excluded.first_seen ELSE assets.first_seen END, "+
+			"last_seen = CASE WHEN excluded.last_seen > assets.last_seen "+
+			"THEN excluded.last_seen ELSE assets.last_seen END",
+			sighting.Asset, sighting.Type, ts, ts)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.exec(tx, "INSERT INTO asset_enumerations (asset, enum_uuid) VALUES (?, ?) "+
+			"ON CONFLICT DO NOTHING", sighting.Asset, sighting.UUID)
+		if err != nil {
+			return err
+		}
+
+		if sighting.Address == "" {
+			continue
+		}
+		_, err = s.exec(tx, "INSERT INTO asset_addresses (name, addr, first_seen, last_seen) VALUES (?, ?, ?, ?) "+
+			"ON CONFLICT (name, addr) DO UPDATE SET "+
+			"first_seen = CASE WHEN excluded.first_seen < asset_addresses.first_seen "+
+			"THEN excluded.first_seen ELSE asset_addresses.first_seen END, "+
+			"last_seen = CASE WHEN excluded.last_seen > asset_addresses.last_seen "+
+			"THEN excluded.last_seen ELSE asset_addresses.last_seen END",
+			sighting.Asset, sighting.Address, ts, ts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexAssets adds the assets found in an enumeration to the inventory.
+func (s *SQL) indexAssets(uuid string) error {
+	var opts []*DataOptsParams
+
+	var names []struct {
+		Name      string `db:"name"`
+		Timestamp string `db:"timestamp"`
+	}
+	s.db.Select(&names, s.db.Rebind("SELECT name, timestamp FROM names WHERE enum_uuid = ? "+
+		"AND type IN ('domain', 'subdomain', 'ns', 'mx')"), uuid)
+	for _, n := range names {
+		opts = append(opts, &DataOptsParams{
+			UUID: uuid, Timestamp: n.Timestamp, Type: OptSubdomain, Name: n.Name})
+	}
+
+	var addrs []struct {
+		Name      string `db:"name"`
+		Addr      string `db:"addr"`
+		Timestamp string `db:"timestamp"`
+	}
+	s.db.Select(&addrs, s.db.Rebind("SELECT COALESCE(r.from_node, '') AS name, a.addr, a.timestamp "+
+		"FROM addresses a LEFT JOIN relations r ON r.enum_uuid = a.enum_uuid AND r.to_node = a.addr "+
+		"AND r.relation IN ('a_to', 'aaaa_to') WHERE a.enum_uuid = ?"), uuid)
+	for _, a := range addrs {
+		data := &DataOptsParams{UUID: uuid, Timestamp: a.Timestamp, Type: OptInfrastructure, Address: a.Addr}
+		if a.Name != "" {
+			data.Type = OptA
+			data.Name = a.Name
+		}
+		opts = append(opts, data)
+	}
+
+	var netblocks []struct {
+		CIDR      string `db:"cidr"`
+		Timestamp string `db:"timestamp"`
+	}
+	s.db.Select(&netblocks, s.db.Rebind("SELECT cidr, timestamp FROM netblocks WHERE enum_uuid = ?"), uuid)
+	for _, n := range netblocks {
+		opts = append(opts, &DataOptsParams{
+			UUID: uuid, Timestamp: n.Timestamp, Type: OptInfrastructure, CIDR: n.CIDR})
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, data := range opts {
+		if err := s.updateAssets(tx, data); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Assets implements the AssetInventory interface.
+func (s *SQL) Assets() []*Asset {
+	s.Lock()
+	defer s.Unlock()
+
+	// Add the enumerations stored before the inventory was maintained
+	for _, uuid := range s.queryStrings("SELECT uuid FROM enumerations WHERE uuid NOT IN " +
+		"(SELECT DISTINCT enum_uuid FROM asset_enumerations)") {
+		s.indexAssets(uuid)
+	}
+
+	var rows []struct {
+		Asset     string `db:"asset"`
+		Type      string `db:"type"`
+		FirstSeen string `db:"first_seen"`
+		LastSeen  string `db:"last_seen"`
+	}
+	if err := s.db.Select(&rows, "SELECT asset, type, first_seen, last_seen FROM assets"); err != nil {
+		return nil
+	}
+
+	assets := make(map[string]*Asset)
+	var results []*Asset
+	for _, row := range rows {
+		a := &Asset{
+			Asset:     row.Asset,
+			Type:      row.Type,
+			FirstSeen: parseAssetTimestamp(row.FirstSeen),
+			LastSeen:  parseAssetTimestamp(row.LastSeen),
+		}
+		assets[a.Asset] = a
+		results = append(results, a)
+	}
+
+	var enums []struct {
+		Asset string `db:"asset"`
+		UUID  string `db:"enum_uuid"`
+	}
+	s.db.Select(&enums, "SELECT asset, enum_uuid FROM asset_enumerations")
+	for _, e := range enums {
+		if a, found := assets[e.Asset]; found {
+			a.Enumerations = append(a.Enumerations, e.UUID)
+		}
+	}
+
+	var history []struct {
+		Name      string `db:"name"`
+		Addr      string `db:"addr"`
+		FirstSeen string `db:"first_seen"`
+		LastSeen  string `db:"last_seen"`
+	}
+	s.db.Select(&history, "SELECT name, addr, first_seen, last_seen FROM asset_addresses")
+	for _, h := range history {
+		if a, found := assets[h.Name]; found {
+			a.Addresses = append(a.Addresses, AddressHistory{
+				Address:   h.Addr,
+				FirstSeen: parseAssetTimestamp(h.FirstSeen),
+				LastSeen:  parseAssetTimestamp(h.LastSeen),
+			})
+		}
+	}
+
+	sortAssets(results)
+	return results
+}
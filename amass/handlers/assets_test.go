@@ -0,0 +1,144 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func assetTestOpts() []DataOptsParams {
+	first := "0f2c6a1e-3b5d-4c8e-9a7f-1d2e3f4a5b6c"
+	opts := mergeTestOpts(first, "2019-01-01T00:00:00Z", "dns")
+
+	// The name resolves to a different address during the second enumeration
+	second := "7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d"
+	for _, opt := range mergeTestOpts(second, "2019-02-01T00:00:00Z", "dns") {
+		if opt.Address != "" {
+			opt.Address = "192.0.2.11"
+		}
+		opts = append(opts, opt)
+	}
+	return opts
+}
+
+func findAsset(assets []*Asset, name string) *Asset {
+	for _, a := range assets {
+		if a.Asset == name {
+			return a
+		}
+	}
+	return nil
+}
+
+func TestAssetInventory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	graph := NewGraph(filepath.Join(dir, "graph"))
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	db, err := NewSQL(SQLDriverSQLite, filepath.Join(dir, DefaultSQLiteFile), nil)
+	if err != nil {
+		t.Fatalf("Failed to create the SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	jan := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)
+	for _, handler := range []interface {
+		DataHandler
+		AssetInventory
+	}{graph, db} {
+		if err := DataOptsDriver(assetTestOpts(), handler); err != nil {
+			t.Fatalf("%s: Failed to insert the data operations: %v", handler, err)
+		}
+
+		assets := handler.Assets()
+		if len(assets) != 5 {
+			t.Fatalf("%s: Returned %d assets", handler, len(assets))
+		}
+		if a := assets[0]; a.Type != AssetTypeName || assets[len(assets)-1].Type != AssetTypeNetblock {
+			t.Errorf("%s: The assets were not sorted by type", handler)
+		}
+
+		www := findAsset(assets, "www.example.com")
+		if www == nil {
+			t.Fatalf("%s: The name was not in the asset inventory", handler)
+		}
+		if !www.FirstSeen.Equal(jan) || !www.LastSeen.Equal(feb) || len(www.Enumerations) != 2 {
+			t.Errorf("%s: Unexpected name asset: %+v", handler, www)
+		}
+		if len(www.Addresses) != 2 || www.Addresses[0].Address != "192.0.2.10" ||
+			!www.Addresses[0].LastSeen.Equal(jan) || !www.Addresses[1].FirstSeen.Equal(feb) {
+			t.Errorf("%s: Unexpected address history: %+v", handler, www.Addresses)
+		}
+
+		if a := findAsset(assets, "192.0.2.10"); a == nil || !a.LastSeen.Equal(jan) || len(a.Enumerations) != 1 {
+			t.Errorf("%s: Unexpected address asset: %+v", handler, a)
+		}
+		if a := findAsset(assets, "192.0.2.0/24"); a == nil || !a.LastSeen.Equal(feb) {
+			t.Errorf("%s: Unexpected netblock asset: %+v", handler, a)
+		}
+
+		if n := len(FilterAssets(assets, []string{"example.com"})); n != 5 {
+			t.Errorf("%s: %d assets were related to the domain", handler, n)
+		}
+		if n := len(FilterAssets(assets, []string{"example.org"})); n != 0 {
+			t.Errorf("%s: %d assets were related to an unrelated domain", handler, n)
+		}
+	}
+}
+
+func TestAssetInventoryIndexing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	graph := NewGraph(filepath.Join(dir, "graph"))
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	db, err := NewSQL(SQLDriverSQLite, filepath.Join(dir, DefaultSQLiteFile), nil)
+	if err != nil {
+		t.Fatalf("Failed to create the SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	opts := assetTestOpts()
+	if err := DataOptsDriver(opts, graph); err != nil {
+		t.Fatalf("Failed to insert the data operations: %v", err)
+	}
+	if err := DataOptsDriver(opts, db); err != nil {
+		t.Fatalf("Failed to insert the data operations: %v", err)
+	}
+
+	// Remove the inventory, as with databases created before it was maintained
+	graph.DeleteEnumeration(graphAssetContext)
+	for _, table := range []string{"assets", "asset_enumerations", "asset_addresses"} {
+		if _, err := db.db.Exec("DELETE FROM " + table); err != nil {
+			t.Fatalf("Failed to empty the %s table: %v", table, err)
+		}
+	}
+
+	for _, handler := range []AssetInventory{graph, db} {
+		www := findAsset(handler.Assets(), "www.example.com")
+		if www == nil || len(www.Enumerations) != 2 || len(www.Addresses) != 2 {
+			t.Errorf("%v: The enumerations were not indexed: %+v", handler, www)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Default settings for the batched inserts performed by the remote graph database handlers.
+const (
+	DefaultBatchSize          = 100
+	DefaultBatchFlushInterval = time.Second
+	DefaultBatchMaxRetries    = 5
+)
+
+// The longest delay between the attempts to write a batch.
+const maxBatchBackoff = 30 * time.Second
+
+// The number of written data operations, as a multiple of the batch size, kept in the
+// spool file before it is rewritten with only the pending data operations.
+const spoolCompactBatches = 10
+
+// permanentError is returned by a flush function when writing the batch again cannot succeed.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// permanent marks the error as a failure that retries will not fix, such as a rejected query.
+// Errors that have not been marked are considered transient and the batch is retried.
+func permanent(err error) error {
+	if err == nil || isPermanent(err) {
+		return err
+	}
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	_, ok := err.(*permanentError)
+	return ok
+}
+
+// BatchSettings configures the batched inserts performed by the remote graph database handlers.
+type BatchSettings struct {
+	// The number of data operations written in each transaction
+	Size int
+
+	// The longest time data operations wait before being written
+	FlushInterval time.Duration
+
+	// The number of times a batch is retried, with backoff, before it is deferred.
+	// A negative value disables the retries
+	MaxRetries int
+
+	// The write-ahead spool file keeping the data operations that have not been written yet.
+	// Data operations left in the file are written the next time the handler is created, and
+	// data operations that fail permanently are set aside in the file with a .failed extension
+	SpoolFile string
+}
+
+// InsertStats reports the throughput of the inserts performed by a handler.
+type InsertStats struct {
+	// The number of data operations written to the database
+	Inserted int
+
+	// The number of batches written, and the number of failed attempts
+	Batches int
+	Retries int
+
+	// The number of data operations left in the spool file
+	Spooled int
+
+	// The number of data operations set aside after failing permanently
+	Failed int
+
+	// The time spent writing the batches to the database
+	Elapsed time.Duration
+}
+
+// PerSecond returns the number of data operations written to the database each second.
+func (s InsertStats) PerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Inserted) / s.Elapsed.Seconds()
+}
+
+// InsertStatsReporter is implemented by the handlers that batch their inserts.
+type InsertStatsReporter interface {
+	InsertStats() InsertStats
+}
+
+// batchWriter collects data operations and writes them in batches using the flush function,
+// which is expected to write the entire batch in one transaction.
+type batchWriter struct {
+	sync.Mutex
+	name     string
+	settings BatchSettings
+	flush    func([]*DataOptsParams) error
+	log      *log.Logger
+
+	// Serializes the writes to the database
+	writing sync.Mutex
+
+	pending []*DataOptsParams
+	spool   *os.File
+	stats   InsertStats
+	full    chan struct{}
+	done    chan struct{}
+	closed  chan struct{}
+
+	// The number of written data operations still held in the spool file
+	acked int
+}
+
+func newBatchWriter(name string, settings *BatchSettings,
+	flush func([]*DataOptsParams) error, l *log.Logger) (*batchWriter, error) {
+	b := &batchWriter{
+		name:   name,
+		flush:  flush,
+		log:    l,
+		full:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+
+	if settings != nil {
+		b.settings = *settings
+	}
+	if b.settings.Size <= 0 {
+		b.settings.Size = DefaultBatchSize
+	}
+	if b.settings.FlushInterval <= 0 {
+		b.settings.FlushInterval = DefaultBatchFlushInterval
+	}
+	if b.settings.MaxRetries < 0 {
+		b.settings.MaxRetries = 0
+	} else if b.settings.MaxRetries == 0 {
+		b.settings.MaxRetries = DefaultBatchMaxRetries
+	}
+
+	if b.settings.SpoolFile != "" {
+		if err := b.openSpool(); err != nil {
+			return nil, err
+		}
+	}
+
+	go b.processBatches()
+	return b, nil
+}
+
+// openSpool opens the spool file and queues the data operations left by a previous run.
+func (b *batchWriter) openSpool() error {
+	path := b.settings.SpoolFile
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("%s: Failed to open the spool file: %v", b.name, err)
+	}
+
+	dec := json.NewDecoder(f)
+	for {
+		opt := new(DataOptsParams)
+
+		if err := dec.Decode(opt); err == io.EOF {
+			break
+		} else if err != nil {
+			// A partial line is left when the process ends while writing to the spool file
+			b.logf("%s: The spool file %s was damaged, and the remaining data operations were skipped", b.name, path)
+			break
+		}
+		b.pending = append(b.pending, opt)
+	}
+	if len(b.pending) > 0 {
+		b.logf("%s: Writing %d data operations left in the spool file", b.name, len(b.pending))
+	}
+
+	b.spool = f
+	return nil
+}
+
+// Insert adds the data operation to the spool file and the next batch.
+func (b *batchWriter) Insert(data *DataOptsParams) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.spool != nil {
+		line, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if _, err := b.spool.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("%s: Failed to write to the spool file: %v", b.name, err)
+		}
+	}
+
+	// The caller may reuse the data operation before the batch is written
+	opt := *data
+	b.pending = append(b.pending, &opt)
+	if len(b.pending) >= b.settings.Size {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush writes all the pending data operations before returning. Each batch is attempted
+// once, so callers are not blocked by the retries while the database is unavailable.
+func (b *batchWriter) Flush() error {
+	return b.flushPending(0)
+}
+
+func (b *batchWriter) flushPending(retries int) error {
+	for {
+		b.Lock()
+		remaining := len(b.pending)
+		b.Unlock()
+
+		if remaining == 0 {
+			return nil
+		}
+		if err := b.writeBatch(retries); err != nil {
+			return err
+		}
+	}
+}
+
+// Stats returns the throughput of the inserts performed.
+func (b *batchWriter) Stats() InsertStats {
+	b.Lock()
+	defer b.Unlock()
+
+	stats := b.stats
+	stats.Spooled = len(b.pending)
+	return stats
+}
+
+// Close writes the pending data operations and stops the writer. Data operations
+// that could not be written are kept in the spool file.
+func (b *batchWriter) Close() {
+	close(b.done)
+	<-b.closed
+
+	if err := b.flushPending(b.settings.MaxRetries); err != nil {
+		b.logf("%s: %d data operations were kept in the spool file: %v", b.name, b.Stats().Spooled, err)
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	if b.spool != nil {
+		b.spool.Close()
+		b.spool = nil
+	}
+}
+
+func (b *batchWriter) processBatches() {
+	t := time.NewTicker(b.settings.FlushInterval)
+	defer t.Stop()
+	defer close(b.closed)
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-b.full:
+		case <-t.C:
+		}
+
+		// Failed batches remain pending and are attempted again during the next interval
+		if err := b.writeBatch(b.settings.MaxRetries); err != nil {
+			b.logf("%s: Deferred a batch of data operations: %v", b.name, err)
+		}
+	}
+}
+
+// writeBatch writes the next batch of pending data operations, and retries with backoff
+// when the write fails with a transient error. The batch remains pending when all attempts
+// fail. When the batch fails permanently, the data operations are written one at a time and
+// those rejected by the database are set aside, so they do not block the following batches.
+func (b *batchWriter) writeBatch(retries int) error {
+	b.writing.Lock()
+	defer b.writing.Unlock()
+
+	b.Lock()
+	n := len(b.pending)
+	if n > b.settings.Size {
+		n = b.settings.Size
+	}
+	batch := b.pending[:n:n]
+	b.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var err error
+	backoff := 500 * time.Millisecond
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			b.Lock()
+			b.stats.Retries++
+			b.Unlock()
+
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxBatchBackoff {
+				backoff = maxBatchBackoff
+			}
+		}
+
+		start := time.Now()
+		if err = b.flush(batch); err == nil {
+			b.written(len(batch), time.Since(start))
+			return nil
+		} else if isPermanent(err) {
+			return b.writeEach(batch)
+		}
+	}
+	return err
+}
+
+// writeEach writes the data operations of a batch that failed permanently one at a time.
+func (b *batchWriter) writeEach(batch []*DataOptsParams) error {
+	for _, data := range batch {
+		start := time.Now()
+
+		err := b.flush([]*DataOptsParams{data})
+		if err == nil {
+			b.written(1, time.Since(start))
+			continue
+		} else if !isPermanent(err) {
+			return err
+		}
+
+		b.deadLetter(data, err)
+	}
+	return nil
+}
+
+// deadLetter removes the data operation that failed permanently from the pending data
+// operations, and keeps it in the failed file next to the spool file.
+func (b *batchWriter) deadLetter(data *DataOptsParams, err error) {
+	b.logf("%s: Set aside the %s data operation for %s: %v", b.name, data.Type, data.Name, err)
+
+	if path := b.settings.SpoolFile; path != "" {
+		if line, e := json.Marshal(data); e == nil {
+			if f, e := os.OpenFile(path+".failed", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); e == nil {
+				f.Write(append(line, '\n'))
+				f.Close()
+			}
+		}
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.pending = b.pending[1:]
+	b.stats.Failed++
+	b.acknowledge(1)
+}
+
+func (b *batchWriter) written(n int, elapsed time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.pending = b.pending[n:]
+	b.stats.Inserted += n
+	b.stats.Batches++
+	b.stats.Elapsed += elapsed
+	b.acknowledge(n)
+}
+
+// acknowledge removes data operations that no longer need to be written from the spool file.
+// The file is emptied once all the data operations have been written, and is rewritten with
+// the pending data operations after many have been written, so it does not grow under steady load.
+func (b *batchWriter) acknowledge(n int) {
+	if b.spool == nil {
+		return
+	}
+
+	b.acked += n
+	if len(b.pending) == 0 {
+		if err := b.spool.Truncate(0); err == nil {
+			b.spool.Seek(0, 0)
+			b.acked = 0
+		}
+		return
+	}
+
+	if b.acked < spoolCompactBatches*b.settings.Size || b.acked < len(b.pending) {
+		return
+	}
+	if err := b.compactSpool(); err != nil {
+		b.logf("%s: Failed to compact the spool file: %v", b.name, err)
+		return
+	}
+	b.acked = 0
+}
+
+// compactSpool replaces the spool file with one holding only the pending data operations.
+func (b *batchWriter) compactSpool() error {
+	path := b.settings.SpoolFile
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	for _, data := range b.pending {
+		if err := enc.Encode(data); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	spool, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	b.spool.Close()
+	b.spool = spool
+	return nil
+}
+
+func (b *batchWriter) logf(format string, v ...interface{}) {
+	if b.log != nil {
+		b.log.Printf(format, v...)
+	}
+}
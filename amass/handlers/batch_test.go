@@ -0,0 +1,329 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testBatchStore struct {
+	sync.Mutex
+	batches  [][]string
+	failures int
+	rejected map[string]bool
+}
+
+func (s *testBatchStore) flush(batch []*DataOptsParams) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("The database is not available")
+	}
+	for _, data := range batch {
+		if s.rejected[data.Name] {
+			return permanent(errors.New("The query was rejected"))
+		}
+	}
+
+	var names []string
+	for _, data := range batch {
+		names = append(names, data.Name)
+	}
+	s.batches = append(s.batches, names)
+	return nil
+}
+
+func (s *testBatchStore) written() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	var names []string
+	for _, batch := range s.batches {
+		names = append(names, batch...)
+	}
+	return names
+}
+
+func TestBatchWriter(t *testing.T) {
+	store := new(testBatchStore)
+	writer, err := newBatchWriter("Test", &BatchSettings{
+		Size:          2,
+		FlushInterval: time.Hour,
+	}, store.flush, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the batch writer: %v", err)
+	}
+
+	// The data operation is copied, so callers can reuse it
+	data := new(DataOptsParams)
+	for _, name := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		data.Name = name
+		if err := writer.Insert(data); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	writer.Close()
+
+	if got := strings.Join(store.written(), ","); got != "a.example.com,b.example.com,c.example.com" {
+		t.Errorf("The data operations written were %s", got)
+	}
+	for _, batch := range store.batches {
+		if len(batch) > 2 {
+			t.Errorf("A batch of %d data operations exceeded the batch size", len(batch))
+		}
+	}
+
+	stats := writer.Stats()
+	if stats.Inserted != 3 || stats.Batches != 2 || stats.Spooled != 0 {
+		t.Errorf("Unexpected insert stats: %+v", stats)
+	}
+}
+
+func TestBatchWriterRetry(t *testing.T) {
+	store := &testBatchStore{failures: 1}
+	writer, err := newBatchWriter("Test", &BatchSettings{
+		Size:          10,
+		FlushInterval: time.Hour,
+		MaxRetries:    2,
+	}, store.flush, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the batch writer: %v", err)
+	}
+
+	writer.Insert(&DataOptsParams{Name: "www.example.com"})
+	writer.Close()
+
+	stats := writer.Stats()
+	if stats.Inserted != 1 || stats.Retries != 1 {
+		t.Errorf("Unexpected insert stats: %+v", stats)
+	}
+}
+
+func TestBatchWriterFlushFailure(t *testing.T) {
+	store := &testBatchStore{failures: 100}
+	writer, err := newBatchWriter("Test", &BatchSettings{
+		Size:          10,
+		FlushInterval: time.Hour,
+		MaxRetries:    5,
+	}, store.flush, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the batch writer: %v", err)
+	}
+
+	// Flush returns the error without waiting for the retries
+	writer.Insert(&DataOptsParams{Name: "www.example.com"})
+	if err := writer.Flush(); err == nil {
+		t.Errorf("Flush did not return the error")
+	}
+	if stats := writer.Stats(); stats.Retries != 0 || stats.Spooled != 1 {
+		t.Errorf("Unexpected insert stats: %+v", stats)
+	}
+
+	store.Lock()
+	store.failures = 0
+	store.Unlock()
+	writer.Close()
+}
+
+func TestBatchWriterPermanentFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &testBatchStore{rejected: map[string]bool{"b.example.com": true}}
+	settings := &BatchSettings{
+		Size:          10,
+		FlushInterval: time.Hour,
+		MaxRetries:    5,
+		SpoolFile:     filepath.Join(dir, "test.spool"),
+	}
+	writer, err := newBatchWriter("Test", settings, store.flush, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the batch writer: %v", err)
+	}
+
+	for _, name := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		writer.Insert(&DataOptsParams{Name: name})
+	}
+	if err := writer.Flush(); err != nil {
+		t.Errorf("The rejected data operation blocked the batch: %v", err)
+	}
+	writer.Close()
+
+	if got := strings.Join(store.written(), ","); got != "a.example.com,c.example.com" {
+		t.Errorf("The data operations written were %s", got)
+	}
+	if stats := writer.Stats(); stats.Inserted != 2 || stats.Failed != 1 || stats.Retries != 0 || stats.Spooled != 0 {
+		t.Errorf("Unexpected insert stats: %+v", stats)
+	}
+	if failed, err := ioutil.ReadFile(settings.SpoolFile + ".failed"); err != nil ||
+		!strings.Contains(string(failed), "b.example.com") {
+		t.Errorf("The rejected data operation was not set aside")
+	}
+}
+
+func TestGremlinBatchInvalidData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The scripts are built by the handler, and recorded in place of the server
+	g := new(Gremlin)
+	store := new(testBatchStore)
+	flush := func(batch []*DataOptsParams) error {
+		if _, err := g.buildBatch(batch); err != nil {
+			return err
+		}
+		return store.flush(batch)
+	}
+
+	settings := &BatchSettings{
+		Size:          10,
+		FlushInterval: time.Hour,
+		MaxRetries:    5,
+		SpoolFile:     filepath.Join(dir, "test.spool"),
+	}
+	writer, err := newBatchWriter("Test", settings, flush, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the batch writer: %v", err)
+	}
+
+	// The registration data does not identify a domain, ASN or netblock
+	writer.Insert(&DataOptsParams{Type: OptRDAP, Name: "invalid.example.com"})
+	for _, name := range []string{"example.com", "example.org"} {
+		writer.Insert(&DataOptsParams{Type: OptDomain, Name: name, Domain: name})
+	}
+	if err := writer.Flush(); err != nil {
+		t.Errorf("The invalid data operation blocked the batch: %v", err)
+	}
+	writer.Close()
+
+	if got := strings.Join(store.written(), ","); got != "example.com,example.org" {
+		t.Errorf("The data operations written were %s", got)
+	}
+	if stats := writer.Stats(); stats.Inserted != 2 || stats.Failed != 1 || stats.Retries != 0 || stats.Spooled != 0 {
+		t.Errorf("Unexpected insert stats: %+v", stats)
+	}
+	if failed, err := ioutil.ReadFile(settings.SpoolFile + ".failed"); err != nil ||
+		!strings.Contains(string(failed), "invalid.example.com") {
+		t.Errorf("The invalid data operation was not set aside")
+	}
+}
+
+func TestBatchWriterSpoolCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := new(testBatchStore)
+	settings := &BatchSettings{
+		Size:          1,
+		FlushInterval: time.Hour,
+		SpoolFile:     filepath.Join(dir, "test.spool"),
+	}
+	writer, err := newBatchWriter("Test", settings, store.flush, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the batch writer: %v", err)
+	}
+	defer writer.Close()
+
+	// Data operations keep arriving, so the pending data operations never reach zero
+	writer.Insert(&DataOptsParams{Name: "0.example.com"})
+	for i := 1; i <= 2*spoolCompactBatches; i++ {
+		writer.Insert(&DataOptsParams{Name: strconv.Itoa(i) + ".example.com"})
+		if err := writer.writeBatch(0); err != nil {
+			t.Fatalf("Failed to write the batch: %v", err)
+		}
+	}
+
+	// Only the pending data operation and those written since the compaction remain
+	spool, err := ioutil.ReadFile(settings.SpoolFile)
+	if err != nil {
+		t.Fatalf("Failed to read the spool file: %v", err)
+	}
+	if lines := strings.Count(string(spool), "\n"); lines > spoolCompactBatches+1 {
+		t.Errorf("The spool file held %d data operations", lines)
+	}
+	if !strings.Contains(string(spool), "\"20.example.com\"") {
+		t.Errorf("The pending data operation was removed from the spool file")
+	}
+}
+
+func TestBatchWriterSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	settings := &BatchSettings{
+		Size:          10,
+		FlushInterval: time.Hour,
+		MaxRetries:    -1,
+		SpoolFile:     filepath.Join(dir, "test.spool"),
+	}
+
+	// The database is down for the entire run
+	down := &testBatchStore{failures: 100}
+	writer, err := newBatchWriter("Test", settings, down.flush, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the batch writer: %v", err)
+	}
+	writer.Insert(&DataOptsParams{Name: "a.example.com"})
+	writer.Insert(&DataOptsParams{Name: "b.example.com"})
+	writer.Close()
+
+	if stats := writer.Stats(); stats.Inserted != 0 || stats.Spooled != 2 {
+		t.Fatalf("Unexpected insert stats: %+v", stats)
+	}
+
+	// The next run writes the data operations left in the spool file
+	store := new(testBatchStore)
+	writer, err = newBatchWriter("Test", settings, store.flush, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the batch writer: %v", err)
+	}
+	writer.Insert(&DataOptsParams{Name: "c.example.com"})
+	writer.Close()
+
+	if got := strings.Join(store.written(), ","); got != "a.example.com,b.example.com,c.example.com" {
+		t.Errorf("The data operations written were %s", got)
+	}
+	if info, err := os.Stat(settings.SpoolFile); err != nil || info.Size() != 0 {
+		t.Errorf("The spool file was not emptied after the data operations were written")
+	}
+}
+
+func TestGremlinBatchScript(t *testing.T) {
+	batch := &gremlinBatch{bindings: make(map[string]string)}
+
+	batch.add("g.V().has('name', name)", map[string]string{"name": "a.example.com"})
+	batch.add("g.V().has('name', name)", map[string]string{"name": "b.example.com"})
+
+	if len(batch.statements) != 2 {
+		t.Fatalf("The script had %d statements", len(batch.statements))
+	}
+	if s := batch.statements[1]; s != "{ name -> g.V().has('name', name).iterate() }.call(b1_name)" {
+		t.Errorf("Unexpected statement: %s", s)
+	}
+	if batch.bindings["b0_name"] != "a.example.com" || batch.bindings["b1_name"] != "b.example.com" {
+		t.Errorf("Unexpected bindings: %v", batch.bindings)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// The number of bytes copied into the compacted bolt file before each commit.
+const compactTxMaxSize = 64 * 1024 * 1024
+
+// compactBolt rewrites the bolt file at path into a new file, so the pages freed by deleted
+// data are returned to the file system, and then replaces the original file with the copy.
+func compactBolt(path string) error {
+	src, err := bolt.Open(path, 0444, &bolt.Options{ReadOnly: true, Timeout: 10 * time.Second})
+	if err != nil {
+		return fmt.Errorf("Failed to open %s: %v", path, err)
+	}
+	defer src.Close()
+
+	finfo, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".compact"
+	os.Remove(tmp)
+	dst, err := bolt.Open(tmp, finfo.Mode(), &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return fmt.Errorf("Failed to create %s: %v", tmp, err)
+	}
+
+	if err := copyBolt(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("Failed to compact %s: %v", path, err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	src.Close()
+	return os.Rename(tmp, path)
+}
+
+// copyBolt copies all the buckets and keys from src to dst, committing the
+// destination transaction periodically to limit the memory required.
+func copyBolt(dst, src *bolt.DB) error {
+	tx, err := dst.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer func() { tx.Rollback() }()
+
+	var size int64
+	err = src.View(func(stx *bolt.Tx) error {
+		return walkBolt(stx, func(keys [][]byte, k, v []byte, seq uint64) error {
+			// Commit the transaction once it has grown large enough
+			if sz := int64(len(k) + len(v)); size+sz > compactTxMaxSize {
+				if err := tx.Commit(); err != nil {
+					return err
+				}
+				if tx, err = dst.Begin(true); err != nil {
+					return err
+				}
+				size = 0
+			} else {
+				size += sz
+			}
+
+			// Root level buckets are created on the transaction
+			if len(keys) == 0 {
+				b, err := tx.CreateBucket(k)
+				if err != nil {
+					return err
+				}
+				return b.SetSequence(seq)
+			}
+
+			// Find the parent bucket of the key
+			b := tx.Bucket(keys[0])
+			for _, key := range keys[1:] {
+				b = b.Bucket(key)
+			}
+			if b == nil {
+				return fmt.Errorf("Failed to find the bucket for key %q", k)
+			}
+			// Keys are written in order, so the pages can be filled
+			b.FillPercent = 1.0
+
+			// A nil value identifies a nested bucket
+			if v == nil {
+				nb, err := b.CreateBucket(k)
+				if err != nil {
+					return err
+				}
+				return nb.SetSequence(seq)
+			}
+			return b.Put(k, v)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type walkBoltFunc func(keys [][]byte, k, v []byte, seq uint64) error
+
+// walkBolt calls fn for every bucket and key within the transaction, providing the
+// path of bucket names that hold the key.
+func walkBolt(tx *bolt.Tx, fn walkBoltFunc) error {
+	return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		return walkBoltBucket(b, nil, name, nil, b.Sequence(), fn)
+	})
+}
+
+func walkBoltBucket(b *bolt.Bucket, keys [][]byte, k, v []byte, seq uint64, fn walkBoltFunc) error {
+	if err := fn(keys, k, v, seq); err != nil {
+		return err
+	}
+	// Only buckets have nested keys
+	if v != nil {
+		return nil
+	}
+
+	keys = append(keys, k)
+	return b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			nb := b.Bucket(k)
+			return walkBoltBucket(nb, keys, k, nil, nb.Sequence(), fn)
+		}
+		return walkBoltBucket(b, keys, k, v, b.Sequence(), fn)
+	})
+}
@@ -5,6 +5,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"time"
 
@@ -57,6 +58,31 @@ func (d *DataOptsHandler) GetOutput(uuid string, marked bool) []*core.Output {
 	return nil
 }
 
+// StreamOutput implements the Amass DataHandler interface.
+func (d *DataOptsHandler) StreamOutput(uuid string, marked bool, fn func(*core.Output) bool) error {
+	return errors.New("DataOptsHandler: Reading the output is not supported")
+}
+
+// Query implements the Amass DataHandler interface.
+func (d *DataOptsHandler) Query(uuid string, filter *QueryFilter) ([]*QueryResult, error) {
+	return nil, errors.New("DataOptsHandler: Queries are not supported")
+}
+
+// StreamQuery implements the Amass DataHandler interface.
+func (d *DataOptsHandler) StreamQuery(uuid string, filter *QueryFilter, fn func(*QueryResult) bool) error {
+	return errors.New("DataOptsHandler: Queries are not supported")
+}
+
+// DeleteEnumeration implements the Amass DataHandler interface.
+func (d *DataOptsHandler) DeleteEnumeration(uuid string) error {
+	return nil
+}
+
+// Compact implements the Amass DataHandler interface.
+func (d *DataOptsHandler) Compact() error {
+	return nil
+}
+
 // MarkAsRead implements the Amass DataHandler interface.
 func (d *DataOptsHandler) MarkAsRead(data *DataOptsParams) error {
 	return nil
@@ -70,4 +96,19 @@ func (d *DataOptsHandler) IsCNAMENode(data *DataOptsParams) bool {
 // VizData returns the current state of the Graph as viz package Nodes and Edges.
 func (d *DataOptsHandler) VizData(uuid string) ([]viz.Node, []viz.Edge) {
 	return []viz.Node{}, []viz.Edge{}
+}
+
+// StreamVizData implements the Amass DataHandler interface.
+func (d *DataOptsHandler) StreamVizData(uuid string, nodes func(viz.Node) bool, edges func(viz.Edge) bool) error {
+	return errors.New("DataOptsHandler: Reading the graph is not supported")
+}
+
+// Annotate implements the Amass DataHandler interface.
+func (d *DataOptsHandler) Annotate(a *Annotation) error {
+	return d.Insert(annotationDataOpts(a))
+}
+
+// Annotations implements the Amass DataHandler interface.
+func (d *DataOptsHandler) Annotations() map[string]*Annotation {
+	return nil
 }
\ No newline at end of file
@@ -10,7 +10,9 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,6 +26,12 @@ import (
 	homedir "github.com/mitchellh/go-homedir"
 )
 
+// The number of quads removed in each transaction when an enumeration is deleted.
+const graphDeleteBatchSize = 10000
+
+// The number of subdomain names read from the graph for each page of StreamOutput
+const graphStreamPageSize = 100
+
 // Graph is the object for managing a network infrastructure link graph.
 type Graph struct {
 	sync.Mutex
@@ -64,6 +72,15 @@ func NewGraph(path string) *Graph {
 	}
 }
 
+// newMemoryGraph returns a Graph that is kept in memory and never written to disk.
+func newMemoryGraph() *Graph {
+	store, err := cayley.NewMemoryGraph()
+	if err != nil {
+		return nil
+	}
+	return &Graph{store: store}
+}
+
 func isNewFile(path string) bool {
 	finfo, err := os.Stat(path)
 	if os.IsNotExist(err) {
@@ -109,6 +126,27 @@ func (g *Graph) propertyValue(node quad.Value, pname, uuid string) string {
 	return result
 }
 
+func (g *Graph) propertyValues(node quad.Value, pname, uuid string) []string {
+	if quad.ToString(node) == "" || pname == "" || uuid == "" {
+		return nil
+	}
+
+	p := cayley.StartPath(g.store, node).LabelContext(quad.String(uuid)).Out(quad.String(pname))
+	it, _ := p.BuildIterator().Optimize()
+	defer it.Close()
+
+	var results []string
+	ctx := context.TODO()
+	for it.Next(ctx) {
+		token := it.Result()
+		value := g.store.NameOf(token)
+		if v := quad.NativeOf(value).(string); v != "" {
+			results = utils.UniqueAppend(results, v)
+		}
+	}
+	return results
+}
+
 func (g *Graph) dumpGraph() string {
 	var result string
 
@@ -163,6 +201,26 @@ func (g *Graph) Insert(data *DataOptsParams) error {
 		err = g.insertMX(data)
 	case OptInfrastructure:
 		err = g.insertInfrastructure(data)
+	case OptCertificate:
+		err = g.insertCertificate(data)
+	case OptHTTP:
+		err = g.insertHTTP(data)
+	case OptTakeover:
+		err = g.insertTakeover(data)
+	case OptEmailPosture:
+		err = g.insertEmailPosture(data)
+	case OptEmailSender:
+		err = g.insertEmailSender(data)
+	case OptRDAP:
+		err = g.insertRDAP(data)
+	case OptIntel:
+		err = g.insertIntel(data)
+	case OptAnnotation:
+		err = g.insertAnnotation(data)
+	}
+
+	if err == nil {
+		err = g.updateAssets(data)
 	}
 	return err
 }
@@ -435,6 +493,250 @@ func (g *Graph) insertInfrastructure(data *DataOptsParams) error {
 	return nil
 }
 
+func (g *Graph) insertCertificate(data *DataOptsParams) error {
+	if data.Fingerprint == "" {
+		return errors.New("Graph: insertCertificate: no fingerprint provided")
+	}
+	// Check if the certificate has not been inserted
+	if val := g.propertyValue(quad.String(data.Fingerprint), "type", data.UUID); val == "" {
+		t := cayley.NewTransaction()
+		t.AddQuad(quad.Make(data.Fingerprint, "type", "certificate", data.UUID))
+		t.AddQuad(quad.Make(data.Fingerprint, "timestamp", data.Timestamp, data.UUID))
+		t.AddQuad(quad.Make(data.Fingerprint, "tag", data.Tag, data.UUID))
+		t.AddQuad(quad.Make(data.Fingerprint, "source", data.Source, data.UUID))
+		t.AddQuad(quad.Make(data.Fingerprint, "common_name", data.Name, data.UUID))
+		t.AddQuad(quad.Make(data.Fingerprint, "organization", data.Organization, data.UUID))
+		t.AddQuad(quad.Make(data.Fingerprint, "org_unit", data.OrgUnit, data.UUID))
+		t.AddQuad(quad.Make(data.Fingerprint, "issuer", data.Issuer, data.UUID))
+		t.AddQuad(quad.Make(data.Fingerprint, "not_before", data.NotBefore, data.UUID))
+		t.AddQuad(quad.Make(data.Fingerprint, "not_after", data.NotAfter, data.UUID))
+		t.AddQuad(quad.Make(data.Fingerprint, "serial", data.Serial, data.UUID))
+		for _, name := range data.Names {
+			t.AddQuad(quad.Make(data.Fingerprint, "cert_name", name, data.UUID))
+		}
+		g.store.ApplyTransaction(t)
+	}
+
+	if data.Address == "" {
+		return nil
+	}
+	// Check if the address has already been inserted
+	if val := g.propertyValue(quad.String(data.Address), "type", data.UUID); val == "" {
+		t := cayley.NewTransaction()
+		t.AddQuad(quad.Make(data.Address, "type", "address", data.UUID))
+		t.AddQuad(quad.Make(data.Address, "timestamp", data.Timestamp, data.UUID))
+		g.store.ApplyTransaction(t)
+	}
+	// Create the edge between the address and the certificate
+	g.store.AddQuad(quad.Make(data.Address, "has_cert", data.Fingerprint, data.UUID))
+	if data.Port != 0 {
+		g.store.AddQuad(quad.Make(data.Fingerprint, "port", strconv.Itoa(data.Port), data.UUID))
+	}
+	if data.ServerName != "" {
+		g.store.AddQuad(quad.Make(data.Fingerprint, "server_name", data.ServerName, data.UUID))
+	}
+	return nil
+}
+
+func (g *Graph) insertHTTP(data *DataOptsParams) error {
+	if data.URL == "" {
+		return errors.New("Graph: insertHTTP: no URL provided")
+	}
+	if err := g.insertSubdomain(data); err != nil {
+		return err
+	}
+	// Check if the URL has not been inserted
+	if val := g.propertyValue(quad.String(data.URL), "type", data.UUID); val == "" {
+		t := cayley.NewTransaction()
+		t.AddQuad(quad.Make(data.URL, "type", "url", data.UUID))
+		t.AddQuad(quad.Make(data.URL, "timestamp", data.Timestamp, data.UUID))
+		t.AddQuad(quad.Make(data.URL, "tag", data.Tag, data.UUID))
+		t.AddQuad(quad.Make(data.URL, "source", data.Source, data.UUID))
+		t.AddQuad(quad.Make(data.URL, "status", strconv.Itoa(data.StatusCode), data.UUID))
+		t.AddQuad(quad.Make(data.URL, "title", data.Title, data.UUID))
+		t.AddQuad(quad.Make(data.URL, "server", data.Server, data.UUID))
+		// URLs cannot contain spaces, so the chain is kept in order as a single value
+		t.AddQuad(quad.Make(data.URL, "redirects", strings.Join(data.Redirects, " "), data.UUID))
+		g.store.ApplyTransaction(t)
+	}
+	// Create the edge between the DNS name and the URL
+	g.store.AddQuad(quad.Make(data.Name, "has_url", data.URL, data.UUID))
+	return nil
+}
+
+func (g *Graph) insertTakeover(data *DataOptsParams) error {
+	if err := g.insertSubdomain(data); err != nil {
+		return err
+	}
+	// Check if the finding has already been recorded for the DNS name
+	if val := g.propertyValue(quad.String(data.Name), "takeover", data.UUID); val != "" {
+		return nil
+	}
+
+	t := cayley.NewTransaction()
+	t.AddQuad(quad.Make(data.Name, "takeover", data.Reason, data.UUID))
+	t.AddQuad(quad.Make(data.Name, "takeover_target", data.TargetName, data.UUID))
+	t.AddQuad(quad.Make(data.Name, "takeover_provider", data.Provider, data.UUID))
+	t.AddQuad(quad.Make(data.Name, "takeover_evidence", data.Evidence, data.UUID))
+	t.AddQuad(quad.Make(data.Name, "takeover_timestamp", data.Timestamp, data.UUID))
+	g.store.ApplyTransaction(t)
+	return nil
+}
+
+func (g *Graph) insertEmailPosture(data *DataOptsParams) error {
+	if err := g.insertDomain(data); err != nil {
+		return err
+	}
+	// Check if the posture has already been recorded for the domain
+	if val := g.propertyValue(quad.String(data.Domain), "posture_timestamp", data.UUID); val != "" {
+		return nil
+	}
+
+	t := cayley.NewTransaction()
+	t.AddQuad(quad.Make(data.Domain, "posture_timestamp", data.Timestamp, data.UUID))
+	t.AddQuad(quad.Make(data.Domain, "spf_record", data.SPF, data.UUID))
+	t.AddQuad(quad.Make(data.Domain, "spf_lookups", strconv.Itoa(data.SPFLookups), data.UUID))
+	t.AddQuad(quad.Make(data.Domain, "spf_all", data.SPFAll, data.UUID))
+	t.AddQuad(quad.Make(data.Domain, "dmarc_record", data.DMARC, data.UUID))
+	t.AddQuad(quad.Make(data.Domain, "dmarc_policy", data.DMARCPolicy, data.UUID))
+	for _, sel := range data.DKIMSelectors {
+		t.AddQuad(quad.Make(data.Domain, "dkim_selector", sel, data.UUID))
+	}
+	for _, issue := range data.Issues {
+		t.AddQuad(quad.Make(data.Domain, "posture_issue", issue, data.UUID))
+	}
+	g.store.ApplyTransaction(t)
+
+	for _, cidr := range data.CIDRs {
+		g.insertSPFNetblock(data.Domain, cidr, data)
+	}
+	return nil
+}
+
+func (g *Graph) insertEmailSender(data *DataOptsParams) error {
+	if data.Name == "" {
+		return errors.New("Graph: insertEmailSender: no sender name provided")
+	}
+	if err := g.insertDomain(data); err != nil {
+		return err
+	}
+	// Check if the sender has not been inserted
+	if val := g.propertyValue(quad.String(data.Name), "type", data.UUID); val == "" {
+		t := cayley.NewTransaction()
+		t.AddQuad(quad.Make(data.Name, "type", "sender", data.UUID))
+		t.AddQuad(quad.Make(data.Name, "timestamp", data.Timestamp, data.UUID))
+		t.AddQuad(quad.Make(data.Name, "tag", data.Tag, data.UUID))
+		t.AddQuad(quad.Make(data.Name, "source", data.Source, data.UUID))
+		g.store.ApplyTransaction(t)
+	}
+	// Create the edge between the domain and the third-party sender
+	g.store.AddQuad(quad.Make(data.Domain, "spf_sender", data.Name, data.UUID))
+
+	for _, cidr := range data.CIDRs {
+		g.insertSPFNetblock(data.Name, cidr, data)
+	}
+	return nil
+}
+
+func (g *Graph) insertSPFNetblock(from, cidr string, data *DataOptsParams) {
+	// Check if the netblock has not been inserted
+	if val := g.propertyValue(quad.String(cidr), "type", data.UUID); val == "" {
+		t := cayley.NewTransaction()
+		t.AddQuad(quad.Make(cidr, "type", "netblock", data.UUID))
+		t.AddQuad(quad.Make(cidr, "timestamp", data.Timestamp, data.UUID))
+		g.store.ApplyTransaction(t)
+	}
+	// Create the edge between the domain or sender and the authorized netblock
+	g.store.AddQuad(quad.Make(from, "spf_authorizes", cidr, data.UUID))
+}
+
+func (g *Graph) insertRDAP(data *DataOptsParams) error {
+	// Identify the nodes receiving the registration data
+	var nodes []string
+	switch {
+	case len(data.CIDRs) > 0:
+		for _, cidr := range data.CIDRs {
+			if val := g.propertyValue(quad.String(cidr), "type", data.UUID); val == "" {
+				t := cayley.NewTransaction()
+				t.AddQuad(quad.Make(cidr, "type", "netblock", data.UUID))
+				t.AddQuad(quad.Make(cidr, "timestamp", data.Timestamp, data.UUID))
+				g.store.ApplyTransaction(t)
+			}
+			nodes = append(nodes, cidr)
+		}
+	case data.ASN != 0:
+		asn := strconv.Itoa(data.ASN)
+		if val := g.propertyValue(quad.String(asn), "type", data.UUID); val == "" {
+			t := cayley.NewTransaction()
+			t.AddQuad(quad.Make(asn, "type", "as", data.UUID))
+			t.AddQuad(quad.Make(asn, "timestamp", data.Timestamp, data.UUID))
+			t.AddQuad(quad.Make(asn, "description", data.Name, data.UUID))
+			g.store.ApplyTransaction(t)
+		}
+		nodes = append(nodes, asn)
+	case data.Domain != "":
+		if err := g.insertDomain(data); err != nil {
+			return err
+		}
+		nodes = append(nodes, data.Domain)
+	default:
+		return errors.New("Graph: insertRDAP: no domain, ASN or netblock provided")
+	}
+
+	for _, node := range nodes {
+		// Check if the registration data has already been recorded for the node
+		if val := g.propertyValue(quad.String(node), "rdap_timestamp", data.UUID); val != "" {
+			continue
+		}
+
+		t := cayley.NewTransaction()
+		t.AddQuad(quad.Make(node, "rdap_timestamp", data.Timestamp, data.UUID))
+		t.AddQuad(quad.Make(node, "rdap_url", data.URL, data.UUID))
+		t.AddQuad(quad.Make(node, "rdap_handle", data.Handle, data.UUID))
+		t.AddQuad(quad.Make(node, "rdap_name", data.Name, data.UUID))
+		t.AddQuad(quad.Make(node, "rdap_country", data.Country, data.UUID))
+		t.AddQuad(quad.Make(node, "registrant", data.Registrant, data.UUID))
+		t.AddQuad(quad.Make(node, "registrar", data.Registrar, data.UUID))
+		t.AddQuad(quad.Make(node, "abuse_contact", data.AbuseContact, data.UUID))
+		t.AddQuad(quad.Make(node, "registered", data.Registered, data.UUID))
+		t.AddQuad(quad.Make(node, "expires", data.Expires, data.UUID))
+		for _, ns := range data.Nameservers {
+			t.AddQuad(quad.Make(node, "rdap_nameserver", ns, data.UUID))
+		}
+		g.store.ApplyTransaction(t)
+	}
+	return nil
+}
+
+func (g *Graph) insertIntel(data *DataOptsParams) error {
+	if err := g.insertDomain(data); err != nil {
+		return err
+	}
+	// Identify the enumeration as an intelligence collection
+	g.store.AddQuad(quad.Make(data.Domain, "enum_type", EnumTypeIntel, data.UUID))
+	if data.Method != "" {
+		g.store.AddQuad(quad.Make(data.Domain, "intel_method", data.Method, data.UUID))
+	}
+
+	if data.Address == "" {
+		return nil
+	}
+	// Check if the address has already been inserted
+	if val := g.propertyValue(quad.String(data.Address), "type", data.UUID); val == "" {
+		t := cayley.NewTransaction()
+		t.AddQuad(quad.Make(data.Address, "type", "address", data.UUID))
+		t.AddQuad(quad.Make(data.Address, "timestamp", data.Timestamp, data.UUID))
+		g.store.ApplyTransaction(t)
+	}
+	// Create the edge between the domain and the address it was discovered from
+	g.store.AddQuad(quad.Make(data.Domain, "discovered_from", data.Address, data.UUID))
+
+	if data.CIDR == "" || data.ASN == 0 {
+		return nil
+	}
+	return g.insertInfrastructure(data)
+}
+
 // EnumerationList returns a list of enumeration IDs found in the data.
 func (g *Graph) EnumerationList() []string {
 	g.Lock()
@@ -451,13 +753,30 @@ func (g *Graph) EnumerationList() []string {
 		value := g.store.NameOf(token)
 		label := quad.NativeOf(value).(string)
 
-		if label != "" {
+		// The asset inventory and annotations are not enumerations
+		if label != "" && label != graphAssetContext && label != graphAnnotationContext {
 			ids = utils.UniqueAppend(ids, label)
 		}
 	}
 	return ids
 }
 
+// EnumerationType returns EnumTypeIntel when the data was collected by the intel subcommand.
+func (g *Graph) EnumerationType(uuid string) string {
+	g.Lock()
+	defer g.Unlock()
+
+	p := cayley.StartPath(g.store).LabelContext(
+		quad.String(uuid)).Has(quad.String("enum_type"), quad.String(EnumTypeIntel))
+	it, _ := p.BuildIterator().Optimize()
+	defer it.Close()
+
+	if it.Next(context.TODO()) {
+		return EnumTypeIntel
+	}
+	return EnumTypeEnum
+}
+
 // EnumerationDomains returns the domains that were involved in the provided enumeration.
 func (g *Graph) EnumerationDomains(uuid string) []string {
 	g.Lock()
@@ -520,35 +839,75 @@ func (g *Graph) EnumerationDateRange(uuid string) (time.Time, time.Time) {
 
 // GetOutput returns new findings within the enumeration Graph.
 func (g *Graph) GetOutput(uuid string, marked bool) []*core.Output {
-	g.Lock()
-	defer g.Unlock()
+	var results []*core.Output
 
-	p := cayley.StartPath(g.store).LabelContext(
-		quad.String(uuid)).Has(quad.String("type"), quad.String("domain"))
-	it, _ := p.BuildIterator().Optimize()
+	g.StreamOutput(uuid, marked, func(o *core.Output) bool {
+		results = append(results, o)
+		return true
+	})
+	return results
+}
+
+// StreamOutput implements the Amass DataHandler interface.
+// The names are read from the graph in pages, and the graph is not locked while fn handles
+// each page. The stream holds a read transaction on the database, so fn can read from the
+// Graph, but must not write to it.
+func (g *Graph) StreamOutput(uuid string, marked bool, fn func(*core.Output) bool) error {
+	for _, domain := range g.EnumerationDomains(uuid) {
+		if !g.streamDomainOutput(domain, uuid, marked, fn) {
+			break
+		}
+	}
+	return nil
+}
+
+// streamDomainOutput provides the output for the domain name, followed by the names related to
+// the domain, and returns false when the stream was cancelled by fn.
+func (g *Graph) streamDomainOutput(domain, uuid string, marked bool, fn func(*core.Output) bool) bool {
+	g.Lock()
+	it := g.subdomainIterator(domain, uuid, marked)
+	g.Unlock()
 	defer it.Close()
 
 	ctx := context.TODO()
-	var results []*core.Output
-	for it.Next(ctx) {
-		token := it.Result()
-		value := g.store.NameOf(token)
-		domain := quad.NativeOf(value).(string)
-
-		names := g.getSubdomainNames(domain, uuid, marked)
+	names := []string{domain}
+	for len(names) > 0 {
+		g.Lock()
+		var page []*core.Output
 		for _, name := range names {
 			if o := g.buildOutput(name, uuid); o != nil {
 				o.Domain = domain
-				results = append(results, o)
+				page = append(page, o)
+			}
+		}
+
+		// Obtain the names provided by the next page
+		names = nil
+		for len(names) < graphStreamPageSize && it.Next(ctx) {
+			token := it.Result()
+			value := g.store.NameOf(token)
+			sub := quad.NativeOf(value).(string)
+
+			// Check for a SRV name
+			if srv := g.propertyValue(quad.String(sub), "srv_to", uuid); srv != "" {
+				names = append(names, srv)
+			}
+			// Grab all the CNAMEs chained to this subdomain name
+			names = append(names, g.getCNAMEs(sub, uuid)...)
+		}
+		g.Unlock()
+
+		for _, o := range page {
+			if !fn(o) {
+				return false
 			}
 		}
 	}
-	return results
+	return true
 }
 
-func (g *Graph) getSubdomainNames(domain, uuid string, marked bool) []string {
-	names := []string{domain}
-
+// subdomainIterator returns the iterator over the DNS names related to the domain name.
+func (g *Graph) subdomainIterator(domain, uuid string, marked bool) graph.Iterator {
 	d := quad.String(domain)
 	u := quad.String(uuid)
 	root := quad.String("root_of")
@@ -566,24 +925,7 @@ func (g *Graph) getSubdomainNames(domain, uuid string, marked bool) []string {
 		p = p.Except(read)
 	}
 	it, _ := p.BuildIterator().Optimize()
-	defer it.Close()
-
-	ctx := context.TODO()
-	for it.Next(ctx) {
-		token := it.Result()
-		value := g.store.NameOf(token)
-		sub := quad.NativeOf(value).(string)
-
-		// Check for a SRV name
-		if srv := g.propertyValue(quad.String(sub), "srv_to", uuid); srv != "" {
-			names = append(names, srv)
-		}
-		// Grab all the CNAMEs chained to this subdomain name
-		if n := g.getCNAMEs(sub, uuid); len(n) > 0 {
-			names = append(names, n...)
-		}
-	}
-	return names
+	return it
 }
 
 func (g *Graph) getCNAMEs(sub, uuid string) []string {
@@ -658,6 +1000,14 @@ func (g *Graph) buildOutput(sub, uuid string) *core.Output {
 		}
 	}
 
+	// Get the addresses that led the intelligence collection to the name
+	output.Method = g.propertyValue(qsub, "intel_method", uuid)
+	for _, addr := range g.propertyValues(qsub, "discovered_from", uuid) {
+		if i := g.buildAddrInfo(addr, uuid); i != nil {
+			output.Addresses = append(output.Addresses, *i)
+		}
+	}
+
 	if len(output.Addresses) == 0 {
 		return nil
 	}
@@ -713,6 +1063,221 @@ func (g *Graph) buildAddrInfo(addr, uuid string) *core.AddressInfo {
 	return ainfo
 }
 
+// Query returns the DNS names within the enumeration that match the filter.
+func (g *Graph) Query(uuid string, filter *QueryFilter) ([]*QueryResult, error) {
+	var results []*QueryResult
+
+	err := g.StreamQuery(uuid, filter, func(r *QueryResult) bool {
+		results = append(results, r)
+		return true
+	})
+	return results, err
+}
+
+// StreamQuery implements the Amass DataHandler interface, and provides the names in order.
+// The graph is locked until the stream completes, so fn must not call the Graph methods.
+func (g *Graph) StreamQuery(uuid string, filter *QueryFilter, fn func(*QueryResult) bool) error {
+	g.Lock()
+	defer g.Unlock()
+
+	t := quad.String("type")
+	p := cayley.StartPath(g.store).LabelContext(quad.String(uuid)).Has(t, quad.String("domain"),
+		quad.String("subdomain"), quad.String("ns"), quad.String("mx"), quad.String("ptr"))
+	it, _ := p.BuildIterator().Optimize()
+	defer it.Close()
+
+	var names []string
+	ctx := context.TODO()
+	for it.Next(ctx) {
+		token := it.Result()
+		value := g.store.NameOf(token)
+		if name := quad.NativeOf(value).(string); name != "" {
+			names = utils.UniqueAppend(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		if r := g.buildQueryResult(name, uuid); r != nil && filter.Match(r) && !fn(r) {
+			break
+		}
+	}
+	return nil
+}
+
+func (g *Graph) buildQueryResult(name, uuid string) *QueryResult {
+	node := quad.String(name)
+	ts, err := time.Parse(time.RFC3339, g.propertyValue(node, "timestamp", uuid))
+	if err != nil {
+		return nil
+	}
+	result := &QueryResult{
+		UUID:      uuid,
+		Timestamp: ts,
+		Name:      name,
+		Domain:    name,
+		Tag:       g.propertyValue(node, "tag", uuid),
+		Source:    g.propertyValue(node, "source", uuid),
+	}
+
+	u := quad.String(uuid)
+	if g.propertyValue(node, "type", uuid) != "domain" {
+		p := cayley.StartPath(g.store, node).LabelContext(u).In(quad.String("root_of"))
+		p.Iterate(nil).EachValue(nil, func(val quad.Value) {
+			result.Domain = quad.ToString(val)
+		})
+	}
+	// Identify the DNS records held by the name
+	p := cayley.StartPath(g.store, node).LabelContext(u).OutPredicates().Unique()
+	p.Iterate(nil).EachValue(nil, func(val quad.Value) {
+		if t, ok := recordPredicates[quad.ToString(val)]; ok {
+			result.RecordTypes = append(result.RecordTypes, t)
+		}
+	})
+	sort.Strings(result.RecordTypes)
+	// Traverse CNAME and SRV records
+	target := name
+	for i := 0; i < 10; i++ {
+		next := g.propertyValue(quad.String(target), "cname_to", uuid)
+		if next != "" {
+			result.CNAMETargets = append(result.CNAMETargets, next)
+		} else if next = g.propertyValue(quad.String(target), "srv_to", uuid); next == "" {
+			break
+		}
+		target = next
+	}
+
+	var addrs []string
+	addrs = append(addrs, g.propertyValues(quad.String(target), "a_to", uuid)...)
+	addrs = append(addrs, g.propertyValues(quad.String(target), "aaaa_to", uuid)...)
+	addrs = append(addrs, g.propertyValues(node, "discovered_from", uuid)...)
+	for _, addr := range addrs {
+		if i := g.buildAddrInfo(addr, uuid); i != nil {
+			result.Addresses = append(result.Addresses, *i)
+		} else if ip := net.ParseIP(addr); ip != nil {
+			result.Addresses = append(result.Addresses, core.AddressInfo{Address: ip})
+		}
+	}
+	return result
+}
+
+// CertificatesByOrganization returns the certificates from all enumerations that have
+// a subject organization or organizational unit containing the provided string.
+func (g *Graph) CertificatesByOrganization(org string) []*DataOptsParams {
+	uuids := g.EnumerationList()
+
+	g.Lock()
+	defer g.Unlock()
+
+	org = strings.ToLower(strings.TrimSpace(org))
+	if org == "" {
+		return nil
+	}
+
+	var certs []*DataOptsParams
+	for _, uuid := range uuids {
+		p := cayley.StartPath(g.store).LabelContext(
+			quad.String(uuid)).Has(quad.String("type"), quad.String("certificate"))
+		it, _ := p.BuildIterator().Optimize()
+
+		var fingerprints []string
+		ctx := context.TODO()
+		for it.Next(ctx) {
+			token := it.Result()
+			value := g.store.NameOf(token)
+			if fp := quad.NativeOf(value).(string); fp != "" {
+				fingerprints = utils.UniqueAppend(fingerprints, fp)
+			}
+		}
+		it.Close()
+
+		for _, fp := range fingerprints {
+			node := quad.String(fp)
+			o := g.propertyValue(node, "organization", uuid)
+			ou := g.propertyValue(node, "org_unit", uuid)
+			if !strings.Contains(strings.ToLower(o), org) && !strings.Contains(strings.ToLower(ou), org) {
+				continue
+			}
+
+			certs = append(certs, &DataOptsParams{
+				UUID:         uuid,
+				Timestamp:    g.propertyValue(node, "timestamp", uuid),
+				Type:         OptCertificate,
+				Name:         g.propertyValue(node, "common_name", uuid),
+				Names:        g.propertyValues(node, "cert_name", uuid),
+				Organization: o,
+				OrgUnit:      ou,
+				Issuer:       g.propertyValue(node, "issuer", uuid),
+				NotBefore:    g.propertyValue(node, "not_before", uuid),
+				NotAfter:     g.propertyValue(node, "not_after", uuid),
+				Serial:       g.propertyValue(node, "serial", uuid),
+				Fingerprint:  fp,
+				Tag:          g.propertyValue(node, "tag", uuid),
+				Source:       g.propertyValue(node, "source", uuid),
+			})
+		}
+	}
+	return certs
+}
+
+// DeleteEnumeration removes all the data collected by the provided enumeration from the graph.
+func (g *Graph) DeleteEnumeration(uuid string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	label := g.store.ValueOf(quad.String(uuid))
+	if label == nil {
+		return nil
+	}
+
+	var quads []quad.Quad
+	it := g.store.QuadIterator(quad.Label, label)
+	ctx := context.TODO()
+	for it.Next(ctx) {
+		quads = append(quads, g.store.Quad(it.Result()))
+	}
+	it.Close()
+
+	// Remove the quads in batches to keep the bolt transactions small
+	for len(quads) > 0 {
+		n := len(quads)
+		if n > graphDeleteBatchSize {
+			n = graphDeleteBatchSize
+		}
+
+		t := cayley.NewTransaction()
+		for _, q := range quads[:n] {
+			t.RemoveQuad(q)
+		}
+		if err := g.store.ApplyTransaction(t); err != nil {
+			return fmt.Errorf("Graph: Failed to delete enumeration %s: %v", uuid, err)
+		}
+		quads = quads[n:]
+	}
+	return nil
+}
+
+// Compact rewrites the bolt file to release the space left behind by deleted enumerations.
+func (g *Graph) Compact() error {
+	g.Lock()
+	defer g.Unlock()
+
+	// Graphs kept in memory do not have a bolt file
+	if g.path == "" {
+		return nil
+	}
+
+	g.store.Close()
+	err := compactBolt(filepath.Join(g.path, "indexes.bolt"))
+
+	store, serr := cayley.NewGraph("bolt", g.path, nil)
+	if serr != nil {
+		return fmt.Errorf("Graph: Failed to reopen the bolt file: %v", serr)
+	}
+	g.store = store
+	return err
+}
+
 // MarkAsRead implements the Amass DataHandler interface.
 func (g *Graph) MarkAsRead(data *DataOptsParams) error {
 	g.Lock()
@@ -737,108 +1302,183 @@ func (g *Graph) IsCNAMENode(data *DataOptsParams) bool {
 
 // VizData returns the current state of the Graph as viz package Nodes and Edges.
 func (g *Graph) VizData(uuid string) ([]viz.Node, []viz.Edge) {
-	g.Lock()
-	defer g.Unlock()
-
-	var idx int
 	var nodes []viz.Node
+	var edges []viz.Edge
+
+	g.StreamVizData(uuid, func(n viz.Node) bool {
+		nodes = append(nodes, n)
+		return true
+	}, func(e viz.Edge) bool {
+		edges = append(edges, e)
+		return true
+	})
+	return nodes, edges
+}
+
+// StreamVizData implements the Amass DataHandler interface.
+// The nodes and edges are read from the graph in pages, and the graph is not locked while the
+// functions handle each page. The stream holds a read transaction on the database, so the
+// functions can read from the Graph, but must not write to it.
+func (g *Graph) StreamVizData(uuid string, nodes func(viz.Node) bool, edges func(viz.Edge) bool) error {
+	var labels []string
 	u := quad.String(uuid)
 	rnodes := make(map[string]int)
+
+	g.Lock()
 	p := cayley.StartPath(g.store).LabelContext(u).Has(quad.String("type")).Unique()
 	it, _ := p.BuildIterator().Optimize()
+	g.Unlock()
+	defer it.Close()
+
+	ctx := context.TODO()
+	for {
+		g.Lock()
+		var page []viz.Node
+		for len(page) < graphStreamPageSize && it.Next(ctx) {
+			token := it.Result()
+			value := g.store.NameOf(token)
+			name := quad.NativeOf(value).(string)
+			if name == "" {
+				continue
+			}
+
+			node := g.vizNode(name, uuid)
+			node.ID = len(labels)
+			rnodes[name] = node.ID
+			labels = append(labels, name)
+			page = append(page, node)
+		}
+		g.Unlock()
+
+		if len(page) == 0 {
+			break
+		}
+		for _, n := range page {
+			if !nodes(n) {
+				return nil
+			}
+		}
+	}
+
+	for start := 0; start < len(labels); start += graphStreamPageSize {
+		g.Lock()
+		var page []viz.Edge
+		for id := start; id < len(labels) && id < start+graphStreamPageSize; id++ {
+			page = append(page, g.vizEdges(id, labels[id], uuid, rnodes)...)
+		}
+		g.Unlock()
+
+		for _, e := range page {
+			if !edges(e) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// vizNode returns the viz node for the named graph node, without the node identifier.
+func (g *Graph) vizNode(name, uuid string) viz.Node {
+	node := quad.String(name)
+
+	var source string
+	t := g.propertyValue(node, "type", uuid)
+	title := t + ": " + name
+
+	switch t {
+	case "subdomain":
+		source = g.propertyValue(node, "source", uuid)
+	case "domain":
+		source = g.propertyValue(node, "source", uuid)
+		if reg := g.propertyValue(node, "registrant", uuid); reg != "" {
+			title = title + ", Registrant: " + reg
+		}
+	case "ns":
+		source = g.propertyValue(node, "source", uuid)
+	case "mx":
+		source = g.propertyValue(node, "source", uuid)
+	case "as":
+		title = title + ", Desc: " + g.propertyValue(node, "description", uuid)
+		if reg := g.propertyValue(node, "registrant", uuid); reg != "" {
+			title = title + ", Registrant: " + reg
+		}
+	case "certificate":
+		source = g.propertyValue(node, "source", uuid)
+		title = title + ", Issuer: " + g.propertyValue(node, "issuer", uuid)
+		if org := g.propertyValue(node, "organization", uuid); org != "" {
+			title = title + ", Organization: " + org
+		}
+	case "url":
+		source = g.propertyValue(node, "source", uuid)
+		title = title + ", Status: " + g.propertyValue(node, "status", uuid)
+	case "sender":
+		source = g.propertyValue(node, "source", uuid)
+	}
+
+	return viz.Node{
+		Type:   t,
+		Label:  name,
+		Title:  title,
+		Source: source,
+	}
+}
+
+// vizEdges returns the viz edges for the graph edges leaving the node identified by id.
+func (g *Graph) vizEdges(id int, label, uuid string, rnodes map[string]int) []viz.Edge {
+	var edges []viz.Edge
+	u := quad.String(uuid)
+
+	// Obtain all the predicates for this node
+	var predicates []quad.Value
+	p := cayley.StartPath(g.store, quad.String(label)).LabelContext(u).OutPredicates().Unique()
+	it, _ := p.BuildIterator().Optimize()
 	defer it.Close()
 
 	ctx := context.TODO()
 	for it.Next(ctx) {
 		token := it.Result()
 		value := g.store.NameOf(token)
-		name := quad.NativeOf(value).(string)
-		if name == "" {
+		pred := quad.NativeOf(value).(string)
+		if pred == "" {
 			continue
 		}
-		node := quad.String(name)
-
-		var source string
-		t := g.propertyValue(node, "type", uuid)
-		title := t + ": " + name
-
-		switch t {
-		case "subdomain":
-			source = g.propertyValue(node, "source", uuid)
-		case "domain":
-			source = g.propertyValue(node, "source", uuid)
-		case "ns":
-			source = g.propertyValue(node, "source", uuid)
-		case "mx":
-			source = g.propertyValue(node, "source", uuid)
-		case "as":
-			title = title + ", Desc: " + g.propertyValue(node, "description", uuid)
-		}
-
-		rnodes[name] = idx
-		nodes = append(nodes, viz.Node{
-			ID:     idx,
-			Type:   t,
-			Label:  name,
-			Title:  title,
-			Source: source,
-		})
-		idx++
-	}
 
-	var edges []viz.Edge
-	for _, n := range nodes {
-		// Obtain all the predicates for this node
-		var predicates []quad.Value
-		p = cayley.StartPath(g.store, quad.String(n.Label)).LabelContext(u).OutPredicates().Unique()
-		it, _ := p.BuildIterator().Optimize()
-		defer it.Close()
+		predicates = append(predicates, quad.String(pred))
+	}
+	// Create viz edges for graph edges leaving the node
+	for _, predicate := range predicates {
+		path := cayley.StartPath(g.store, quad.String(label)).LabelContext(u).Out(predicate)
+		it, _ := path.BuildIterator().Optimize()
 
-		ctx := context.TODO()
 		for it.Next(ctx) {
 			token := it.Result()
 			value := g.store.NameOf(token)
-			pred := quad.NativeOf(value).(string)
-			if pred == "" {
+			vstr := quad.NativeOf(value).(string)
+			if vstr == "" {
 				continue
 			}
 
-			predicates = append(predicates, quad.String(pred))
-		}
-		// Create viz edges for graph edges leaving the node
-		for _, predicate := range predicates {
-			path := cayley.StartPath(g.store, quad.String(n.Label)).LabelContext(u).Out(predicate)
-			it, _ := path.BuildIterator().Optimize()
-			defer it.Close()
-
-			ctx := context.TODO()
-			for it.Next(ctx) {
-				token := it.Result()
-				value := g.store.NameOf(token)
-				vstr := quad.NativeOf(value).(string)
-				if vstr == "" {
-					continue
-				}
-
-				var to string
-				pstr := quad.ToString(predicate)
-				if pstr == "root_of" || pstr == "cname_to" || pstr == "a_to" ||
-					pstr == "aaaa_to" || pstr == "ptr_to" || pstr == "service_for" ||
-					pstr == "srv_to" || pstr == "ns_to" || pstr == "mx_to" ||
-					pstr == "contains" || pstr == "has_prefix" {
-					to = vstr
-				}
-				if to == "" {
-					continue
-				}
-
-				edges = append(edges, viz.Edge{
-					From:  n.ID,
-					To:    rnodes[to],
-					Title: pstr,
-				})
+			var to string
+			pstr := quad.ToString(predicate)
+			if pstr == "root_of" || pstr == "cname_to" || pstr == "a_to" ||
+				pstr == "aaaa_to" || pstr == "ptr_to" || pstr == "service_for" ||
+				pstr == "srv_to" || pstr == "ns_to" || pstr == "mx_to" ||
+				pstr == "contains" || pstr == "has_prefix" || pstr == "has_cert" ||
+				pstr == "has_url" || pstr == "spf_sender" || pstr == "spf_authorizes" ||
+				pstr == "discovered_from" {
+				to = vstr
 			}
+			if to == "" {
+				continue
+			}
+
+			edges = append(edges, viz.Edge{
+				From:  id,
+				To:    rnodes[to],
+				Title: pstr,
+			})
 		}
+		it.Close()
 	}
-	return nodes, edges
+	return edges
 }
@@ -5,9 +5,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/root-secure/Amass/amass/core"
@@ -19,8 +22,14 @@ import (
 const (
 	// GremlinMaxConnections defines the limited number of concurrent connections to the Gremlin Server.
 	GremlinMaxConnections int = 25
+
+	// DefaultGremlinSpoolFile is the name of the spool file kept in the output directory.
+	DefaultGremlinSpoolFile = "gremlin.spool"
 )
 
+// The number of vertices each traversal starts from while the graph is streamed.
+const gremlinStreamPageSize = 500
+
 // Gremlin is the client object for a Gremlin/TinkerPop graph database connection.
 type Gremlin struct {
 	Log      *log.Logger
@@ -28,14 +37,17 @@ type Gremlin struct {
 	username string
 	password string
 	pool     *gremgo.Pool
-	requests *utils.Queue
+	writer   *batchWriter
 	avail    utils.Semaphore
-	done     chan struct{}
+
+	// The script being built for the batch currently written
+	batch *gremlinBatch
 }
 
 // NewGremlin returns a client object that implements the Amass DataHandler interface.
 // The url param typically looks like the following: ws://localhost:8182
-func NewGremlin(url, user, pass string, l *log.Logger) *Gremlin {
+// Data operations are written in batches, and default settings are used when batch is nil.
+func NewGremlin(url, user, pass string, batch *BatchSettings, l *log.Logger) (*Gremlin, error) {
 	g := &Gremlin{
 		Log:      l,
 		URL:      url,
@@ -45,13 +57,16 @@ func NewGremlin(url, user, pass string, l *log.Logger) *Gremlin {
 			MaxActive:   GremlinMaxConnections,
 			IdleTimeout: 5 * time.Second,
 		},
-		requests: utils.NewQueue(),
-		avail:    utils.NewSimpleSemaphore(GremlinMaxConnections),
-		done:     make(chan struct{}, 2),
+		avail: utils.NewSimpleSemaphore(GremlinMaxConnections),
 	}
 	g.pool.Dial = g.getClient
-	go g.processInsertRequests()
-	return g
+
+	writer, err := newBatchWriter("Gremlin", batch, g.insertBatch, l)
+	if err != nil {
+		return nil, err
+	}
+	g.writer = writer
+	return g, nil
 }
 
 func (g *Gremlin) getClient() (*gremgo.Client, error) {
@@ -77,7 +92,7 @@ func (g *Gremlin) getClient() (*gremgo.Client, error) {
 
 // Close implements the Amass DataHandler interface.
 func (g *Gremlin) Close() {
-	g.done <- struct{}{}
+	g.writer.Close()
 }
 
 // String returns a description for the Gremlin client object.
@@ -85,25 +100,111 @@ func (g *Gremlin) String() string {
 	return "Gremlin TinkerPop Handler"
 }
 
-type gremlinRequest struct {
-	Params *DataOptsParams
-	Err    chan error
+// InsertStats returns the throughput of the inserts performed.
+func (g *Gremlin) InsertStats() InsertStats {
+	return g.writer.Stats()
 }
 
 // Insert implements the Amass DataHandler interface.
+// The data operation is written to the database with the next batch.
 func (g *Gremlin) Insert(data *DataOptsParams) error {
-	e := make(chan error)
-	g.requests.Append(&gremlinRequest{
-		Params: data,
-		Err:    e,
-	})
-	return <-e
+	return g.writer.Insert(data)
 }
 
-func (g *Gremlin) insertData(data *DataOptsParams) error {
+// gremlinBatch builds a single script containing the traversals for a batch of data operations,
+// so the Gremlin Server executes the entire batch within one transaction.
+type gremlinBatch struct {
+	statements []string
+	bindings   map[string]string
+}
+
+func (b *gremlinBatch) add(query string, bindings map[string]string) {
+	idx := len(b.statements)
+
+	var params, args []string
+	for k, v := range bindings {
+		name := "b" + strconv.Itoa(idx) + "_" + k
+
+		params = append(params, k)
+		args = append(args, name)
+		b.bindings[name] = v
+	}
+	// The closure parameters keep the binding names used by the traversal
+	b.statements = append(b.statements, "{ "+strings.Join(params, ", ")+" -> "+
+		query+".iterate() }.call("+strings.Join(args, ", ")+")")
+}
+
+func (g *Gremlin) insertBatch(batch []*DataOptsParams) error {
+	script, err := g.buildBatch(batch)
+	if err != nil || len(script.statements) == 0 {
+		return err
+	}
+
+	g.avail.Acquire(1)
+	defer g.avail.Release(1)
+
+	conn, err := g.pool.Get()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Client.Execute(strings.Join(script.statements, "\n"), script.bindings, map[string]string{})
+	return gremlinBatchError(err)
+}
+
+// buildBatch builds the script for the batch of data operations without contacting the server.
+// The traversals are only collected at this point, so any error is caused by a data operation
+// that cannot be written, and is marked as permanent.
+func (g *Gremlin) buildBatch(batch []*DataOptsParams) (*gremlinBatch, error) {
+	g.batch = &gremlinBatch{bindings: make(map[string]string)}
+	defer func() { g.batch = nil }()
+
+	script := g.batch
+	for _, data := range batch {
+		if err := g.insertData(data); err != nil {
+			return nil, permanent(err)
+		}
+	}
+	return script, nil
+}
+
+// gremlinBatchError marks the failures caused by the script as permanent, since the
+// server rejects the same script each time it is sent.
+func gremlinBatchError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch err.Error() {
+	case "MALFORMED REQUEST", "INVALID REQUEST ARGUMENTS",
+		"SCRIPT EVALUATION ERROR", "SERVER SERIALIZATION ERROR":
+		return permanent(err)
+	}
+	return err
+}
+
+// execute adds the traversal to the batch being built, or runs it when no batch is being written.
+func (g *Gremlin) execute(query string, bindings, rebindings map[string]string) error {
+	if g.batch != nil {
+		g.batch.add(query, bindings)
+		return nil
+	}
+
 	g.avail.Acquire(1)
 	defer g.avail.Release(1)
 
+	conn, err := g.pool.Get()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Client.Execute(query, bindings, rebindings)
+	return err
+}
+
+func (g *Gremlin) insertData(data *DataOptsParams) error {
 	var err error
 	switch data.Type {
 	case OptDomain:
@@ -124,34 +225,26 @@ func (g *Gremlin) insertData(data *DataOptsParams) error {
 		err = g.insertMX(data)
 	case OptInfrastructure:
 		err = g.insertInfrastructure(data)
+	case OptCertificate:
+		err = g.insertCertificate(data)
+	case OptHTTP:
+		err = g.insertHTTP(data)
+	case OptTakeover:
+		err = g.insertTakeover(data)
+	case OptEmailPosture:
+		err = g.insertEmailPosture(data)
+	case OptEmailSender:
+		err = g.insertEmailSender(data)
+	case OptRDAP:
+		err = g.insertRDAP(data)
+	case OptIntel:
+		err = g.insertIntel(data)
+	case OptAnnotation:
+		err = g.insertAnnotation(data)
 	}
 	return err
 }
 
-func (g *Gremlin) processInsertRequests() {
-	curIdx := 0
-	maxIdx := 7
-	delays := []int{10, 25, 50, 75, 100, 150, 250, 500}
-	for {
-		select {
-		case <-g.done:
-			return
-		default:
-			element, ok := g.requests.Next()
-			if !ok {
-				time.Sleep(time.Duration(delays[curIdx]) * time.Millisecond)
-				if curIdx < maxIdx {
-					curIdx++
-				}
-				continue
-			}
-			curIdx = 0
-			req := element.(*gremlinRequest)
-			req.Err <- g.insertData(req.Params)
-		}
-	}
-}
-
 func (g *Gremlin) insertDomain(data *DataOptsParams) error {
 	bindings := map[string]string{
 		"uuid":      data.UUID,
@@ -161,13 +254,7 @@ func (g *Gremlin) insertDomain(data *DataOptsParams) error {
 		"source":    data.Source,
 	}
 
-	conn, err := g.pool.Get()
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	_, err = conn.Client.Execute(
+	err := g.execute(
 		// Does this domain vertex already exist in the graph?
 		"g.V().hasLabel('domain').has('name', domain).has('enum', uuid).fold().coalesce(unfold(),"+
 			// Add the new domain vertex to the graph
@@ -199,13 +286,7 @@ func (g *Gremlin) insertSub(label string, data *DataOptsParams) error {
 	}
 
 	if data.Name != data.Domain {
-		conn, err := g.pool.Get()
-		if err != nil {
-			return err
-		}
-		defer conn.Close()
-
-		_, err = conn.Client.Execute(
+		err := g.execute(
 			// Does this subdomain name related vertex already exist in the graph?
 			"g.V().hasLabel(nodelabel).has('name', name).has('enum', uuid).fold().coalesce(unfold(),"+
 				// Find the appropriate domain vertex in the graph
@@ -253,13 +334,7 @@ func (g *Gremlin) insertCNAME(data *DataOptsParams) error {
 		return err
 	}
 
-	conn, err := g.pool.Get()
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	_, err = conn.Client.Execute(
+	err = g.execute(
 		// Does this 'cname_to' edge already exist in the graph?
 		"g.V().hasLabel('subdomain').has('name', sname).has('enum', uuid)."+
 			"out('cname_to').hasLabel('domain','subdomain').has('name', tname)."+
@@ -292,13 +367,7 @@ func (g *Gremlin) insertA(data *DataOptsParams) error {
 		return err
 	}
 
-	conn, err := g.pool.Get()
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	_, err = conn.Client.Execute(
+	err := g.execute(
 		// Does this address already exist in the graph?
 		"g.V().hasLabel('address').has('addr', addr).has('addrtype', addrtype).has('enum', uuid).fold().coalesce(unfold(),"+
 			// Find the subdomain name related vertex in the graph
@@ -330,13 +399,7 @@ func (g *Gremlin) insertAAAA(data *DataOptsParams) error {
 		return err
 	}
 
-	conn, err := g.pool.Get()
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	_, err = conn.Client.Execute(
+	err := g.execute(
 		// Does this address already exist in the graph?
 		"g.V().hasLabel('address').has('addr', addr).has('addrtype', addrtype).has('enum', uuid).fold().coalesce(unfold(),"+
 			// Find the subdomain name related vertex in the graph
@@ -375,13 +438,7 @@ func (g *Gremlin) insertPTR(data *DataOptsParams) error {
 		return err
 	}
 
-	conn, err := g.pool.Get()
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	_, err = conn.Client.Execute(
+	err = g.execute(
 		// Does the 'ptr_to' edge already exist between the ptr and the subdomain?
 		"g.V().hasLabel('ptr').has('name', name).has('enum', uuid).fold().coalesce(unfold(),"+
 			// Add the ptr vertex into the graph
@@ -437,13 +494,7 @@ func (g *Gremlin) insertSRV(data *DataOptsParams) error {
 		return err
 	}
 
-	conn, err := g.pool.Get()
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	_, err = conn.Client.Execute(
+	err = g.execute(
 		// Does the 'srv_to' edge already exist between the two subdomains?
 		"g.V().hasLabel('subdomain').has('name', service).has('enum', uuid).out('service_for')."+
 			"hasLabel('domain','subdomain').has('name', name).has('enum', uuid).fold().coalesce(unfold(),"+
@@ -460,7 +511,7 @@ func (g *Gremlin) insertSRV(data *DataOptsParams) error {
 		return err
 	}
 
-	_, err = conn.Client.Execute(
+	err = g.execute(
 		// Does the 'srv_to' edge already exist between the two subdomains?
 		"g.V().hasLabel('subdomain').has('name', service).has('enum', uuid).out('srv_to')."+
 			"hasLabel('subdomain').has('name', target).has('enum', uuid).fold().coalesce(unfold(),"+
@@ -504,13 +555,7 @@ func (g *Gremlin) insertNS(data *DataOptsParams) error {
 		return err
 	}
 
-	conn, err := g.pool.Get()
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	_, err = conn.Client.Execute(
+	err = g.execute(
 		// Does the 'ns_to' edge already exist between the domain/subdomain and the ns?
 		"g.V().hasLabel('domain','subdomain').has('name', name).has('enum', uuid).out('ns_to')."+
 			"hasLabel('ns').has('name', target).has('enum', uuid).fold().coalesce(unfold(),"+
@@ -554,13 +599,7 @@ func (g *Gremlin) insertMX(data *DataOptsParams) error {
 		return err
 	}
 
-	conn, err := g.pool.Get()
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	_, err = conn.Client.Execute(
+	err = g.execute(
 		// Does the 'mx_to' edge already exist between the domain/subdomain and the mx?
 		"g.V().hasLabel('domain','subdomain').has('name', name).has('enum', uuid).out('mx_to')."+
 			"hasLabel('mx').has('name', target).has('enum', uuid).fold().coalesce(unfold(),"+
@@ -586,13 +625,7 @@ func (g *Gremlin) insertInfrastructure(data *DataOptsParams) error {
 		"asndesc":   data.Description,
 	}
 
-	conn, err := g.pool.Get()
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	_, err = conn.Client.Execute(
+	err := g.execute(
 		// Does this netblock already exist in the graph?
 		"g.V().hasLabel('netblock').has('cidr', cidr).has('enum', uuid).fold().coalesce(unfold(),"+
 			// Add the new netblock vertex
@@ -605,7 +638,7 @@ func (g *Gremlin) insertInfrastructure(data *DataOptsParams) error {
 		return err
 	}
 
-	_, err = conn.Client.Execute(
+	err = g.execute(
 		// Does the 'contains' edge already exist between the netblock and the address?
 		"g.V().hasLabel('netblock').has('cidr', cidr).has('enum', uuid).out('contains')."+
 			"hasLabel('address').has('addr', addr).has('enum', uuid).fold().coalesce(unfold(),"+
@@ -622,7 +655,7 @@ func (g *Gremlin) insertInfrastructure(data *DataOptsParams) error {
 		return err
 	}
 
-	_, err = conn.Client.Execute(
+	err = g.execute(
 		// Does this AS already exist in the graph?
 		"g.V().hasLabel('as').has('asn', asn).has('enum', uuid).fold().coalesce(unfold(),"+
 			// Add the new AS vertex
@@ -635,7 +668,7 @@ func (g *Gremlin) insertInfrastructure(data *DataOptsParams) error {
 		return err
 	}
 
-	_, err = conn.Client.Execute(
+	err = g.execute(
 		// Does the 'has_prefix' edge already exist between the AS and the netblock?
 		"g.V().hasLabel('as').has('asn', asn).has('enum', uuid).out('has_prefix')."+
 			"hasLabel('netblock').has('cidr', cidr).has('enum', uuid).fold().coalesce(unfold(),"+
@@ -651,6 +684,253 @@ func (g *Gremlin) insertInfrastructure(data *DataOptsParams) error {
 	return err
 }
 
+func (g *Gremlin) insertCertificate(data *DataOptsParams) error {
+	bindings := map[string]string{
+		"uuid":        data.UUID,
+		"timestamp":   data.Timestamp,
+		"fingerprint": data.Fingerprint,
+		"addr":        data.Address,
+		"port":        strconv.Itoa(data.Port),
+		"servername":  data.ServerName,
+		"commonname":  data.Name,
+		"names":       strings.Join(data.Names, ","),
+		"org":         data.Organization,
+		"orgunit":     data.OrgUnit,
+		"issuer":      data.Issuer,
+		"notbefore":   data.NotBefore,
+		"notafter":    data.NotAfter,
+		"serial":      data.Serial,
+		"tag":         data.Tag,
+		"source":      data.Source,
+	}
+
+	err := g.execute(
+		// Does this certificate already exist in the graph?
+		"g.V().hasLabel('certificate').has('fingerprint', fingerprint).has('enum', uuid).fold().coalesce(unfold(),"+
+			// Add the new certificate vertex
+			"g.addV('certificate').property('fingerprint', fingerprint).property('type', 'certificate')."+
+			"property('enum', uuid).property('timestamp', timestamp).property('tag', tag).property('source', source)."+
+			"property('common_name', commonname).property('names', names).property('organization', org)."+
+			"property('org_unit', orgunit).property('issuer', issuer).property('not_before', notbefore)."+
+			"property('not_after', notafter).property('serial', serial))",
+		bindings,
+		map[string]string{},
+	)
+	if err != nil || data.Address == "" {
+		return err
+	}
+
+	err = g.execute(
+		// Does the 'has_cert' edge already exist between the address and the certificate?
+		"g.V().hasLabel('address').has('addr', addr).has('enum', uuid).out('has_cert')."+
+			"hasLabel('certificate').has('fingerprint', fingerprint).has('enum', uuid).fold().coalesce(unfold(),"+
+			// Find the address in the graph
+			"g.V().hasLabel('address').has('addr', addr).has('enum', uuid)."+
+			// Add the new edge
+			"addE('has_cert').property('port', port).property('server_name', servername).to("+
+			// Identify the certificate vertex to point the edge to
+			"g.V().hasLabel('certificate').has('fingerprint', fingerprint).has('enum', uuid)))",
+		bindings,
+		map[string]string{},
+	)
+	return err
+}
+
+func (g *Gremlin) insertHTTP(data *DataOptsParams) error {
+	bindings := map[string]string{
+		"uuid":      data.UUID,
+		"timestamp": data.Timestamp,
+		"name":      data.Name,
+		"url":       data.URL,
+		"status":    strconv.Itoa(data.StatusCode),
+		"title":     data.Title,
+		"server":    data.Server,
+		"redirects": strings.Join(data.Redirects, " "),
+		"tag":       data.Tag,
+		"source":    data.Source,
+	}
+
+	if err := g.insertSubdomain(data); err != nil {
+		return err
+	}
+
+	err := g.execute(
+		// Does this URL already exist in the graph?
+		"g.V().hasLabel('url').has('url', url).has('enum', uuid).fold().coalesce(unfold(),"+
+			// Find the subdomain name related vertex in the graph
+			"g.V().hasLabel('domain','subdomain','ns','mx').has('name', name).has('enum', uuid)."+
+			// Add the new edge
+			"addE('has_url').to("+
+			// Add the new URL vertex that the edge should point to
+			"addV('url').property('url', url).property('type', 'url').property('enum', uuid)."+
+			"property('timestamp', timestamp).property('tag', tag).property('source', source)."+
+			"property('status', status).property('title', title).property('server', server)."+
+			"property('redirects', redirects)))",
+		bindings,
+		map[string]string{},
+	)
+	return err
+}
+
+func (g *Gremlin) insertTakeover(data *DataOptsParams) error {
+	bindings := map[string]string{
+		"uuid":      data.UUID,
+		"timestamp": data.Timestamp,
+		"name":      data.Name,
+		"target":    data.TargetName,
+		"provider":  data.Provider,
+		"reason":    data.Reason,
+		"evidence":  data.Evidence,
+	}
+
+	if err := g.insertSubdomain(data); err != nil {
+		return err
+	}
+
+	err := g.execute(
+		// Find the subdomain name related vertex in the graph
+		"g.V().hasLabel('domain','subdomain').has('name', name).has('enum', uuid)."+
+			// Record the takeover finding on the vertex
+			"property('takeover', reason).property('takeover_target', target)."+
+			"property('takeover_provider', provider).property('takeover_evidence', evidence)."+
+			"property('takeover_timestamp', timestamp)",
+		bindings,
+		map[string]string{},
+	)
+	return err
+}
+
+func (g *Gremlin) insertEmailPosture(data *DataOptsParams) error {
+	bindings := map[string]string{
+		"uuid":        data.UUID,
+		"timestamp":   data.Timestamp,
+		"domain":      data.Domain,
+		"spf":         data.SPF,
+		"spflookups":  strconv.Itoa(data.SPFLookups),
+		"spfall":      data.SPFAll,
+		"dmarc":       data.DMARC,
+		"dmarcpolicy": data.DMARCPolicy,
+		"selectors":   strings.Join(data.DKIMSelectors, ","),
+		"issues":      strings.Join(data.Issues, "\n"),
+	}
+
+	if err := g.insertDomain(data); err != nil {
+		return err
+	}
+
+	err := g.execute(
+		// Find the domain vertex in the graph
+		"g.V().hasLabel('domain').has('name', domain).has('enum', uuid)."+
+			// Record the email posture on the vertex
+			"property('posture_timestamp', timestamp).property('spf_record', spf)."+
+			"property('spf_lookups', spflookups).property('spf_all', spfall)."+
+			"property('dmarc_record', dmarc).property('dmarc_policy', dmarcpolicy)."+
+			"property('dkim_selectors', selectors).property('posture_issues', issues)",
+		bindings,
+		map[string]string{},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, cidr := range data.CIDRs {
+		if err := g.insertSPFNetblock("domain", data.Domain, cidr, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Gremlin) insertEmailSender(data *DataOptsParams) error {
+	bindings := map[string]string{
+		"uuid":      data.UUID,
+		"timestamp": data.Timestamp,
+		"domain":    data.Domain,
+		"sender":    data.Name,
+		"tag":       data.Tag,
+		"source":    data.Source,
+	}
+
+	if err := g.insertDomain(data); err != nil {
+		return err
+	}
+
+	err := g.execute(
+		// Does this sender already exist in the graph?
+		"g.V().hasLabel('sender').has('name', sender).has('enum', uuid).fold().coalesce(unfold(),"+
+			// Add the new sender vertex
+			"g.addV('sender').property('name', sender).property('type', 'sender').property('enum', uuid)."+
+			"property('timestamp', timestamp).property('tag', tag).property('source', source))",
+		bindings,
+		map[string]string{},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = g.execute(
+		// Does the 'spf_sender' edge already exist between the domain and the sender?
+		"g.V().hasLabel('domain').has('name', domain).has('enum', uuid).out('spf_sender')."+
+			"hasLabel('sender').has('name', sender).has('enum', uuid).fold().coalesce(unfold(),"+
+			// Find the domain in the graph
+			"g.V().hasLabel('domain').has('name', domain).has('enum', uuid)."+
+			// Add the new edge
+			"addE('spf_sender').to("+
+			// Identify the sender vertex to point the edge to
+			"g.V().hasLabel('sender').has('name', sender).has('enum', uuid)))",
+		bindings,
+		map[string]string{},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, cidr := range data.CIDRs {
+		if err := g.insertSPFNetblock("sender", data.Name, cidr, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Gremlin) insertSPFNetblock(label, name, cidr string, data *DataOptsParams) error {
+	bindings := map[string]string{
+		"uuid":      data.UUID,
+		"timestamp": data.Timestamp,
+		"label":     label,
+		"name":      name,
+		"cidr":      cidr,
+	}
+
+	err := g.execute(
+		// Does this netblock already exist in the graph?
+		"g.V().hasLabel('netblock').has('cidr', cidr).has('enum', uuid).fold().coalesce(unfold(),"+
+			// Add the new netblock vertex
+			"g.addV('netblock').property('cidr', cidr).property('enum', uuid)."+
+			"property('type', 'netblock').property('timestamp', timestamp))",
+		bindings,
+		map[string]string{},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = g.execute(
+		// Does the 'spf_authorizes' edge already exist between the vertex and the netblock?
+		"g.V().hasLabel(label).has('name', name).has('enum', uuid).out('spf_authorizes')."+
+			"hasLabel('netblock').has('cidr', cidr).has('enum', uuid).fold().coalesce(unfold(),"+
+			// Find the domain or sender in the graph
+			"g.V().hasLabel(label).has('name', name).has('enum', uuid)."+
+			// Add the new edge
+			"addE('spf_authorizes').to("+
+			// Identify the netblock vertex to point the edge to
+			"g.V().hasLabel('netblock').has('cidr', cidr).has('enum', uuid)))",
+		bindings,
+		map[string]string{},
+	)
+	return err
+}
+
 // EnumerationList returns a list of enumeration IDs found in the data.
 func (g *Gremlin) EnumerationList() []string {
 	return []string{}
@@ -668,47 +948,104 @@ func (g *Gremlin) EnumerationDateRange(uuid string) (time.Time, time.Time) {
 
 // GetOutput implements the Amass DataHandler interface.
 func (g *Gremlin) GetOutput(uuid string, marked bool) []*core.Output {
-	g.avail.Acquire(1)
-	defer g.avail.Release(1)
-
-	bindings := map[string]string{"uuid": uuid}
+	var output []*core.Output
 
-	conn, err := g.pool.Get()
+	err := g.StreamOutput(uuid, marked, func(o *core.Output) bool {
+		output = append(output, o)
+		return true
+	})
 	if err != nil {
-		return nil
+		g.logf("%v", err)
 	}
-	defer conn.Close()
+	return output
+}
+
+// StreamOutput implements the Amass DataHandler interface.
+// The traversal is performed in pages of the names related to the domain names, so the
+// complete output is not held in the response of the Gremlin Server.
+func (g *Gremlin) StreamOutput(uuid string, marked bool, fn func(*core.Output) bool) error {
+	// Pending data operations are written before the graph is read
+	g.writer.Flush()
 
+	bindings := map[string]string{"uuid": uuid}
 	// Find the vertices connected to all the domain names
-	query := "g.V().hasLabel('domain').has('enum', uuid).out('root_of').has('enum', uuid)."
+	start := "g.V().hasLabel('domain').has('enum', uuid).out('root_of').has('enum', uuid)"
 
-	if !marked {
-		// We are only interested in the vertices not yet marked
-		query = query + "not(has('read','yes'))."
+	resp, err := g.read(start+".count()", bindings)
+	if err != nil {
+		return fmt.Errorf("Gremlin: Failed to count the names: %v", err)
 	}
+	total := parseGremlinCount(resp)
 
-	// Traverse all the 'cname_to' and 'srv_to' edges
-	query = query + "until(outE('cname_to','srv_to').count().is(0).or().loops().is(10))." +
-		"repeat(out('cname_to','srv_to'))." +
-		// Traverse to the address vertices
-		"out('a_to','aaaa_to').hasLabel('address').has('enum', uuid)." +
-		// Traverse to the netblock vertex
-		"in('contains').hasLabel('netblock').has('enum', uuid)." +
-		// Complete the path by reaching the AS
-		"in('has_prefix').hasLabel('as').has('enum', uuid).path().by(valueMap())"
+	// Names are only provided once, even when reached by the paths of several pages
+	seen := make(map[string]struct{})
+	for lo := int64(0); lo < total; lo += gremlinStreamPageSize {
+		// The order allows the traversal to continue from the previous page
+		query := start + ".order().by('name')" + gremlinRange(lo) + "."
 
-	resp, err := conn.Client.Execute(query, bindings, map[string]string{})
-	if err == nil {
-		var output []*core.Output
+		if !marked {
+			// We are only interested in the vertices not yet marked
+			query = query + "not(has('read','yes'))."
+		}
+
+		// Traverse all the 'cname_to' and 'srv_to' edges
+		query = query + "until(outE('cname_to','srv_to').count().is(0).or().loops().is(10))." +
+			"repeat(out('cname_to','srv_to'))." +
+			// Traverse to the address vertices
+			"out('a_to','aaaa_to').hasLabel('address').has('enum', uuid)." +
+			// Traverse to the netblock vertex
+			"in('contains').hasLabel('netblock').has('enum', uuid)." +
+			// Complete the path by reaching the AS
+			"in('has_prefix').hasLabel('as').has('enum', uuid).path().by(valueMap())"
+
+		resp, err := g.read(query, bindings)
+		if err != nil {
+			return fmt.Errorf("Gremlin: Failed to traverse the paths: %v", err)
+		}
 
 		for _, out := range parseGremlinResponse(resp) {
-			output = append(output, out)
+			if _, found := seen[out.Name]; found {
+				continue
+			}
+			seen[out.Name] = struct{}{}
+
+			if !fn(out) {
+				return nil
+			}
 		}
-		return output
 	}
 	return nil
 }
 
+// read executes the traversal using a connection from the pool.
+func (g *Gremlin) read(query string, bindings map[string]string) (interface{}, error) {
+	g.avail.Acquire(1)
+	defer g.avail.Release(1)
+
+	conn, err := g.pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.Client.Execute(query, bindings, map[string]string{})
+}
+
+func (g *Gremlin) logf(format string, v ...interface{}) {
+	if g.Log != nil {
+		g.Log.Printf(format, v...)
+	}
+}
+
+func parseGremlinCount(resp interface{}) int64 {
+	var count [1][]int64
+
+	if err := remarshal(resp, &count); err != nil || len(count[0]) == 0 {
+		return 0
+	}
+	return count[0][0]
+}
+
 func parseGremlinResponse(resp interface{}) []*core.Output {
 	b, err := json.Marshal(resp)
 	if err != nil {
@@ -846,8 +1183,65 @@ func propertiesToData(props map[string][]string) *DataOptsParams {
 	return data
 }
 
+// Query implements the Amass DataHandler interface.
+func (g *Gremlin) Query(uuid string, filter *QueryFilter) ([]*QueryResult, error) {
+	var results []*QueryResult
+
+	err := g.StreamQuery(uuid, filter, func(r *QueryResult) bool {
+		results = append(results, r)
+		return true
+	})
+	sortQueryResults(results)
+	return results, err
+}
+
+// StreamQuery implements the Amass DataHandler interface.
+func (g *Gremlin) StreamQuery(uuid string, filter *QueryFilter, fn func(*QueryResult) bool) error {
+	return g.StreamOutput(uuid, true, func(out *core.Output) bool {
+		if r := outputToQueryResult(uuid, out); filter.Match(r) {
+			return fn(r)
+		}
+		return true
+	})
+}
+
+// DeleteEnumeration removes all the vertices created by the provided enumeration.
+func (g *Gremlin) DeleteEnumeration(uuid string) error {
+	if err := g.writer.Flush(); err != nil {
+		return err
+	}
+
+	g.avail.Acquire(1)
+	defer g.avail.Release(1)
+
+	conn, err := g.pool.Get()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Dropping the vertices also removes their edges
+	_, err = conn.Client.Execute(
+		"g.V().has('enum', uuid).drop()",
+		map[string]string{"uuid": uuid},
+		map[string]string{},
+	)
+	return err
+}
+
+// Compact implements the Amass DataHandler interface.
+// The Gremlin server manages the storage used by the graph.
+func (g *Gremlin) Compact() error {
+	return nil
+}
+
 // MarkAsRead implements the Amass DataHandler interface.
 func (g *Gremlin) MarkAsRead(data *DataOptsParams) error {
+	// Pending data operations are written before the graph is read
+	if err := g.writer.Flush(); err != nil {
+		return err
+	}
+
 	g.avail.Acquire(1)
 	defer g.avail.Release(1)
 
@@ -878,6 +1272,9 @@ func (g *Gremlin) MarkAsRead(data *DataOptsParams) error {
 
 // IsCNAMENode implements the Amass DataHandler interface.
 func (g *Gremlin) IsCNAMENode(data *DataOptsParams) bool {
+	// Pending data operations are written before the graph is read
+	g.writer.Flush()
+
 	g.avail.Acquire(1)
 	defer g.avail.Release(1)
 
@@ -919,5 +1316,348 @@ func (g *Gremlin) IsCNAMENode(data *DataOptsParams) bool {
 
 // VizData returns the current state of the Graph as viz package Nodes and Edges.
 func (g *Gremlin) VizData(uuid string) ([]viz.Node, []viz.Edge) {
-	return []viz.Node{}, []viz.Edge{}
-}
\ No newline at end of file
+	var nodes []viz.Node
+	var edges []viz.Edge
+
+	err := g.StreamVizData(uuid, func(n viz.Node) bool {
+		nodes = append(nodes, n)
+		return true
+	}, func(e viz.Edge) bool {
+		edges = append(edges, e)
+		return true
+	})
+	if err != nil {
+		g.logf("%v", err)
+	}
+	return nodes, edges
+}
+
+// StreamVizData implements the Amass DataHandler interface.
+// The vertices are read in pages, followed by the edges leaving each page of vertices.
+func (g *Gremlin) StreamVizData(uuid string, nodes func(viz.Node) bool, edges func(viz.Edge) bool) error {
+	// Pending data operations are written before the graph is read
+	g.writer.Flush()
+
+	bindings := map[string]string{"uuid": uuid}
+	start := "g.V().has('enum', uuid).has('type')"
+
+	resp, err := g.read(start+".count()", bindings)
+	if err != nil {
+		return fmt.Errorf("Gremlin: Failed to count the vertices: %v", err)
+	}
+	total := parseGremlinCount(resp)
+
+	// The viz package identifies the nodes by their position in the stream
+	ids := make(map[string]int)
+	for lo := int64(0); lo < total; lo += gremlinStreamPageSize {
+		resp, err := g.read(start+".order().by(id)"+gremlinRange(lo)+
+			".project('id','props').by(id).by(valueMap())", bindings)
+		if err != nil {
+			return fmt.Errorf("Gremlin: Failed to read the vertices: %v", err)
+		}
+
+		var page [1][]struct {
+			ID    json.RawMessage     `json:"id"`
+			Props map[string][]string `json:"props"`
+		}
+		if err := remarshal(resp, &page); err != nil {
+			return fmt.Errorf("Gremlin: Failed to parse the vertices: %v", err)
+		}
+
+		for _, v := range page[0] {
+			idx := len(ids)
+			ids[string(v.ID)] = idx
+
+			if !nodes(propertiesToVizNode(idx, v.Props)) {
+				return nil
+			}
+		}
+	}
+
+	for lo := int64(0); lo < total; lo += gremlinStreamPageSize {
+		resp, err := g.read(start+".order().by(id)"+gremlinRange(lo)+
+			".outE().where(inV().has('enum', uuid))."+
+			"project('from','to','label').by(outV().id()).by(inV().id()).by(label())", bindings)
+		if err != nil {
+			return fmt.Errorf("Gremlin: Failed to read the edges: %v", err)
+		}
+
+		var page [1][]struct {
+			From  json.RawMessage `json:"from"`
+			To    json.RawMessage `json:"to"`
+			Label string          `json:"label"`
+		}
+		if err := remarshal(resp, &page); err != nil {
+			return fmt.Errorf("Gremlin: Failed to parse the edges: %v", err)
+		}
+
+		for _, e := range page[0] {
+			from, found := ids[string(e.From)]
+			if !found {
+				continue
+			}
+			to, found := ids[string(e.To)]
+			if !found {
+				continue
+			}
+
+			if !edges(viz.Edge{From: from, To: to, Title: e.Label}) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// gremlinRange returns the step selecting the page of traversers starting at lo.
+func gremlinRange(lo int64) string {
+	return ".range(" + strconv.FormatInt(lo, 10) + ", " + strconv.FormatInt(lo+gremlinStreamPageSize, 10) + ")"
+}
+
+// remarshal converts the response of the Gremlin Server into the provided value.
+func remarshal(resp interface{}, v interface{}) error {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func propertiesToVizNode(idx int, props map[string][]string) viz.Node {
+	prop := func(key string) string {
+		if value := props[key]; len(value) > 0 {
+			return value[0]
+		}
+		return ""
+	}
+
+	t := prop("type")
+	var label string
+	for _, key := range []string{"name", "addr", "cidr", "asn", "fingerprint", "url"} {
+		if label = prop(key); label != "" {
+			break
+		}
+	}
+
+	title := t + ": " + label
+	switch t {
+	case "as":
+		title = title + ", Desc: " + prop("description")
+	case "certificate":
+		title = title + ", Issuer: " + prop("issuer")
+		if org := prop("organization"); org != "" {
+			title = title + ", Organization: " + org
+		}
+	case "url":
+		title = title + ", Status: " + prop("status")
+	}
+
+	return viz.Node{
+		ID:     idx,
+		Type:   t,
+		Label:  label,
+		Title:  title,
+		Source: prop("source"),
+	}
+}
+
+// The annotation vertices are not part of an enumeration, so they do not have an enum property.
+func (g *Gremlin) insertAnnotation(data *DataOptsParams) error {
+	a := annotationFromDataOpts(data)
+	if a.Asset == "" {
+		return permanent(errors.New("Gremlin: The annotation does not identify an asset"))
+	}
+
+	bindings := map[string]string{
+		"asset":          a.Asset,
+		"owner":          a.Owner,
+		"criticality":    a.Criticality,
+		"false_positive": strconv.FormatBool(a.FalsePositive),
+		"decommissioned": strconv.FormatBool(a.Decommissioned),
+		"notes":          a.Notes,
+		"updated":        data.Timestamp,
+	}
+
+	// The annotation replaces the one previously stored for the asset
+	err := g.execute("g.V().hasLabel('annotation').has('asset', asset).drop()", bindings, map[string]string{})
+	if err != nil || a.Empty() {
+		return err
+	}
+
+	return g.execute(
+		"g.addV('annotation').property('asset', asset).property('owner', owner)."+
+			"property('criticality', criticality).property('false_positive', false_positive)."+
+			"property('decommissioned', decommissioned).property('notes', notes).property('updated', updated)",
+		bindings,
+		map[string]string{},
+	)
+}
+
+// Annotate implements the Amass DataHandler interface.
+func (g *Gremlin) Annotate(a *Annotation) error {
+	if err := g.Insert(annotationDataOpts(a)); err != nil {
+		return err
+	}
+	return g.writer.Flush()
+}
+
+// Annotations implements the Amass DataHandler interface.
+func (g *Gremlin) Annotations() map[string]*Annotation {
+	// Pending data operations are written before the graph is read
+	g.writer.Flush()
+
+	resp, err := g.read("g.V().hasLabel('annotation').valueMap()", map[string]string{})
+	if err != nil {
+		g.logf("Gremlin: Failed to read the annotations: %v", err)
+		return nil
+	}
+
+	var vertices [1][]map[string][]string
+	if err := remarshal(resp, &vertices); err != nil {
+		g.logf("Gremlin: Failed to parse the annotations: %v", err)
+		return nil
+	}
+
+	annotations := make(map[string]*Annotation)
+	for _, props := range vertices[0] {
+		prop := func(key string) string {
+			if value := props[key]; len(value) > 0 {
+				return value[0]
+			}
+			return ""
+		}
+
+		asset := prop("asset")
+		if asset == "" {
+			continue
+		}
+		annotations[asset] = &Annotation{
+			Asset:          asset,
+			Owner:          prop("owner"),
+			Criticality:    prop("criticality"),
+			FalsePositive:  prop("false_positive") == "true",
+			Decommissioned: prop("decommissioned") == "true",
+			Notes:          prop("notes"),
+			Updated:        parseAssetTimestamp(prop("updated")),
+		}
+	}
+	return annotations
+}
+
+func (g *Gremlin) insertRDAP(data *DataOptsParams) error {
+	bindings := map[string]string{
+		"uuid":        data.UUID,
+		"timestamp":   data.Timestamp,
+		"url":         data.URL,
+		"handle":      data.Handle,
+		"name":        data.Name,
+		"country":     data.Country,
+		"registrant":  data.Registrant,
+		"registrar":   data.Registrar,
+		"abuse":       data.AbuseContact,
+		"registered":  data.Registered,
+		"expires":     data.Expires,
+		"nameservers": strings.Join(data.Nameservers, ","),
+	}
+
+	// Identify the vertices receiving the registration data
+	var values []string
+	switch {
+	case len(data.CIDRs) > 0:
+		bindings["nodelabel"], bindings["nodekey"] = "netblock", "cidr"
+		values = data.CIDRs
+	case data.ASN != 0:
+		bindings["nodelabel"], bindings["nodekey"] = "as", "asn"
+		values = []string{strconv.Itoa(data.ASN)}
+	case data.Domain != "":
+		if err := g.insertDomain(data); err != nil {
+			return err
+		}
+		bindings["nodelabel"], bindings["nodekey"] = "domain", "name"
+		values = []string{data.Domain}
+	default:
+		return permanent(errors.New("Gremlin: insertRDAP: no domain, ASN or netblock provided"))
+	}
+
+	for _, value := range values {
+		bindings["value"] = value
+
+		err := g.execute(
+			// Does this vertex already exist in the graph?
+			"g.V().hasLabel(nodelabel).has(nodekey, value).has('enum', uuid).fold().coalesce(unfold(),"+
+				// Add the new vertex
+				"g.addV(nodelabel).property(nodekey, value).property('type', nodelabel)."+
+				"property('enum', uuid).property('timestamp', timestamp))."+
+				// Record the registration data on the vertex
+				"property('rdap_timestamp', timestamp).property('rdap_url', url)."+
+				"property('rdap_handle', handle).property('rdap_name', name).property('rdap_country', country)."+
+				"property('registrant', registrant).property('registrar', registrar)."+
+				"property('abuse_contact', abuse).property('registered', registered)."+
+				"property('expires', expires).property('rdap_nameservers', nameservers)",
+			bindings,
+			map[string]string{},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Gremlin) insertIntel(data *DataOptsParams) error {
+	bindings := map[string]string{
+		"uuid":      data.UUID,
+		"timestamp": data.Timestamp,
+		"domain":    data.Domain,
+		"method":    data.Method,
+		"addr":      data.Address,
+		"tag":       data.Tag,
+		"source":    data.Source,
+	}
+
+	if err := g.insertDomain(data); err != nil {
+		return err
+	}
+
+	err := g.execute(
+		// Identify the enumeration as an intelligence collection
+		"g.V().hasLabel('domain').has('name', domain).has('enum', uuid)."+
+			"property('enum_type', 'intel').property('intel_method', method)",
+		bindings,
+		map[string]string{},
+	)
+	if err != nil || data.Address == "" {
+		return err
+	}
+
+	err = g.execute(
+		// Does this address already exist in the graph?
+		"g.V().hasLabel('address').has('addr', addr).has('enum', uuid).fold().coalesce(unfold(),"+
+			// Add the new address vertex
+			"g.addV('address').property('addr', addr).property('enum', uuid).property('type', 'address')."+
+			"property('timestamp', timestamp).property('tag', tag).property('source', source))",
+		bindings,
+		map[string]string{},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = g.execute(
+		// Does the 'discovered_from' edge already exist between the domain and the address?
+		"g.V().hasLabel('domain').has('name', domain).has('enum', uuid).out('discovered_from')."+
+			"hasLabel('address').has('addr', addr).has('enum', uuid).fold().coalesce(unfold(),"+
+			// Find the domain in the graph
+			"g.V().hasLabel('domain').has('name', domain).has('enum', uuid)."+
+			// Add the new edge
+			"addE('discovered_from').to("+
+			// Identify the address vertex to point the edge to
+			"g.V().hasLabel('address').has('addr', addr).has('enum', uuid)))",
+		bindings,
+		map[string]string{},
+	)
+	if err != nil || data.CIDR == "" || data.ASN == 0 {
+		return err
+	}
+	return g.insertInfrastructure(data)
+}
@@ -25,6 +25,20 @@ const (
 	OptNS             = "ns"
 	OptMX             = "mx"
 	OptInfrastructure = "infrastructure"
+	OptCertificate    = "certificate"
+	OptHTTP           = "http"
+	OptTakeover       = "takeover"
+	OptEmailPosture   = "email_posture"
+	OptEmailSender    = "email_sender"
+	OptRDAP           = "rdap"
+	OptIntel          = "intel"
+	OptAnnotation     = "annotation"
+)
+
+// These strings identify the type of collection that produced an enumeration.
+const (
+	EnumTypeEnum  = "enum"
+	EnumTypeIntel = "intel"
 )
 
 // Different data operations require different parameters to be provided:
@@ -38,23 +52,74 @@ const (
 // NS: UUID, Timestamp, Type, Name, Domain, TargetName, TargetDomain, Tag and Source
 // MX: UUID, Timestamp, Type, Name, Domain, TargetName, TargetDomain, Tag and Source
 // Infrastructure: UUID, Timestamp, Type, Address, ASN, CIDR and Description
+// Certificate: UUID, Timestamp, Type, Address, Port, ServerName, Name, Names, Organization,
+//   OrgUnit, Issuer, NotBefore, NotAfter, Serial, Fingerprint, Tag and Source
+// HTTP: UUID, Timestamp, Type, Name, Domain, URL, StatusCode, Title, Server, Redirects, Tag and Source
+// Takeover: UUID, Timestamp, Type, Name, Domain, TargetName, Provider, Reason, Evidence, Tag and Source
+// EmailPosture: UUID, Timestamp, Type, Domain, SPF, SPFLookups, SPFAll, CIDRs, DMARC, DMARCPolicy,
+//   DKIMSelectors, Issues, Tag and Source
+// EmailSender: UUID, Timestamp, Type, Name, Domain, CIDRs, Tag and Source
+// RDAP: UUID, Timestamp, Type, Domain, ASN or CIDRs, Handle, Name, Country, Registrant, Registrar,
+//   AbuseContact, Registered, Expires, Nameservers, URL, Tag and Source
+// Intel: UUID, Timestamp, Type, Domain, Method, Address, CIDR, ASN, Description, Tag and Source
+// Annotation: Timestamp, Type, Name, Owner, Criticality, FalsePositive, Decommissioned and Notes
 
 // DataOptsParams defines the parameters for Amass data operations.
 type DataOptsParams struct {
-	UUID         string `json:"uuid"`
-	Timestamp    string `json:"timestamp"`
-	Type         string `json:"type"`
-	Name         string `json:"name"`
-	Domain       string `json:"domain"`
-	Service      string `json:"service"`
-	TargetName   string `json:"target_name"`
-	TargetDomain string `json:"target_domain"`
-	Address      string `json:"addr"`
-	ASN          int    `json:"asn"`
-	CIDR         string `json:"cidr"`
-	Description  string `json:"desc"`
-	Tag          string `json:"tag"`
-	Source       string `json:"source"`
+	UUID           string   `json:"uuid"`
+	Timestamp      string   `json:"timestamp"`
+	Type           string   `json:"type"`
+	Name           string   `json:"name"`
+	Domain         string   `json:"domain"`
+	Service        string   `json:"service"`
+	TargetName     string   `json:"target_name"`
+	TargetDomain   string   `json:"target_domain"`
+	Address        string   `json:"addr"`
+	ASN            int      `json:"asn"`
+	CIDR           string   `json:"cidr"`
+	Description    string   `json:"desc"`
+	Port           int      `json:"port,omitempty"`
+	ServerName     string   `json:"server_name,omitempty"`
+	Names          []string `json:"names,omitempty"`
+	Organization   string   `json:"org,omitempty"`
+	OrgUnit        string   `json:"org_unit,omitempty"`
+	Issuer         string   `json:"issuer,omitempty"`
+	NotBefore      string   `json:"not_before,omitempty"`
+	NotAfter       string   `json:"not_after,omitempty"`
+	Serial         string   `json:"serial,omitempty"`
+	Fingerprint    string   `json:"fingerprint,omitempty"`
+	URL            string   `json:"url,omitempty"`
+	StatusCode     int      `json:"status,omitempty"`
+	Title          string   `json:"title,omitempty"`
+	Server         string   `json:"server,omitempty"`
+	Redirects      []string `json:"redirects,omitempty"`
+	Provider       string   `json:"provider,omitempty"`
+	Reason         string   `json:"reason,omitempty"`
+	Evidence       string   `json:"evidence,omitempty"`
+	SPF            string   `json:"spf,omitempty"`
+	SPFLookups     int      `json:"spf_lookups,omitempty"`
+	SPFAll         string   `json:"spf_all,omitempty"`
+	CIDRs          []string `json:"cidrs,omitempty"`
+	DMARC          string   `json:"dmarc,omitempty"`
+	DMARCPolicy    string   `json:"dmarc_policy,omitempty"`
+	DKIMSelectors  []string `json:"dkim_selectors,omitempty"`
+	Issues         []string `json:"issues,omitempty"`
+	Handle         string   `json:"handle,omitempty"`
+	Country        string   `json:"country,omitempty"`
+	Registrant     string   `json:"registrant,omitempty"`
+	Registrar      string   `json:"registrar,omitempty"`
+	AbuseContact   string   `json:"abuse_contact,omitempty"`
+	Registered     string   `json:"registered,omitempty"`
+	Expires        string   `json:"expires,omitempty"`
+	Nameservers    []string `json:"nameservers,omitempty"`
+	Method         string   `json:"method,omitempty"`
+	Owner          string   `json:"owner,omitempty"`
+	Criticality    string   `json:"criticality,omitempty"`
+	FalsePositive  bool     `json:"false_positive,omitempty"`
+	Decommissioned bool     `json:"decommissioned,omitempty"`
+	Notes          string   `json:"notes,omitempty"`
+	Tag            string   `json:"tag"`
+	Source         string   `json:"source"`
 }
 
 // DataHandler is the interface for storage of Amass data operations.
@@ -76,6 +141,26 @@ type DataHandler interface {
 	// Returns complete paths in the graph, with the option of only unmarked results.
 	GetOutput(uuid string, marked bool) []*core.Output
 
+	// Calls fn with each complete path in the graph as it is found, with the option of
+	// only unmarked results. The stream is cancelled when fn returns false, and an error
+	// is returned when the handler is unable to read the graph.
+	StreamOutput(uuid string, marked bool, fn func(*core.Output) bool) error
+
+	// Returns the DNS names within the enumeration that match the filter, or an error
+	// when the handler is unable to perform queries.
+	Query(uuid string, filter *QueryFilter) ([]*QueryResult, error)
+
+	// Calls fn with each DNS name within the enumeration that matches the filter as it is
+	// found. The stream is cancelled when fn returns false, and an error is returned when
+	// the handler is unable to perform queries.
+	StreamQuery(uuid string, filter *QueryFilter, fn func(*QueryResult) bool) error
+
+	// Removes all the data collected by the provided enumeration.
+	DeleteEnumeration(uuid string) error
+
+	// Releases the storage left behind by deleted enumerations.
+	Compact() error
+
 	// Sets a 'read' property on the vertex matching Name, Domain and UUID.
 	MarkAsRead(data *DataOptsParams) error
 
@@ -85,6 +170,18 @@ type DataHandler interface {
 	// VizData returns the current state of the Graph as viz package Nodes and Edges.
 	VizData(uuid string) ([]viz.Node, []viz.Edge)
 
+	// Calls nodes with each viz package Node, followed by edges with each Edge between
+	// them. The stream is cancelled when either function returns false, and an error is
+	// returned when the handler is unable to read the graph.
+	StreamVizData(uuid string, nodes func(viz.Node) bool, edges func(viz.Edge) bool) error
+
+	// Stores the annotation for the asset, replacing the previous annotation. Annotations
+	// are not part of an enumeration, so they apply to the asset in every enumeration.
+	Annotate(a *Annotation) error
+
+	// Returns the annotations stored, keyed by the asset.
+	Annotations() map[string]*Annotation
+
 	// Signals the handler to prepare for closing.
 	Close()
 }
@@ -0,0 +1,289 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The data operation type used by the snapshots to record the names marked as read.
+const memoryOptRead = "read"
+
+// MemoryGraph is a Graph kept in memory, which does not require a directory or a server.
+// The data operations inserted can be saved to a data operations file and loaded again.
+type MemoryGraph struct {
+	*Graph
+	Log *log.Logger
+
+	// The data operations file loaded when the graph is created, and saved when it is closed
+	snapshot string
+
+	// The data operations inserted are appended to the journal file instead of being
+	// kept in memory next to the graph
+	journalLock sync.Mutex
+	journal     *os.File
+	journalBuf  *bufio.Writer
+	journalEnc  *json.Encoder
+}
+
+// NewMemoryGraph returns an initialized MemoryGraph. When the snapshot param is not empty,
+// the data operations in the file are loaded into the graph, and the file is replaced with
+// the contents of the graph when it is closed. The journal of the data operations is kept
+// next to the snapshot file, or in a temporary file when no snapshot is provided.
+func NewMemoryGraph(snapshot string, l *log.Logger) (*MemoryGraph, error) {
+	g := newMemoryGraph()
+	if g == nil {
+		return nil, errors.New("Graph: Failed to create the in-memory graph")
+	}
+
+	m := &MemoryGraph{
+		Graph:    g,
+		Log:      l,
+		snapshot: snapshot,
+	}
+
+	if err := m.openJournal(); err != nil {
+		g.Close()
+		return nil, fmt.Errorf("Failed to create the journal: %v", err)
+	}
+
+	if snapshot == "" {
+		return m, nil
+	}
+
+	f, err := os.Open(snapshot)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		m.closeJournal()
+		g.Close()
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := m.Load(f); err != nil {
+		m.closeJournal()
+		g.Close()
+		return nil, fmt.Errorf("Failed to load the snapshot %s: %v", snapshot, err)
+	}
+	return m, nil
+}
+
+// Close implements the Amass DataHandler interface.
+func (m *MemoryGraph) Close() {
+	if m.snapshot != "" {
+		if err := m.Save(m.snapshot); err != nil && m.Log != nil {
+			m.Log.Printf("Failed to save the snapshot %s: %v", m.snapshot, err)
+		}
+	}
+	m.closeJournal()
+	m.Graph.Close()
+}
+
+// String implements the Amass data handler interface.
+func (m *MemoryGraph) String() string {
+	return "Amass In-Memory Graph"
+}
+
+// Insert implements the Amass DataHandler interface.
+func (m *MemoryGraph) Insert(data *DataOptsParams) error {
+	if data.Type == memoryOptRead {
+		return m.MarkAsRead(data)
+	}
+
+	if err := m.Graph.Insert(data); err != nil {
+		return err
+	}
+	return m.appendJournal(data)
+}
+
+// MarkAsRead implements the Amass DataHandler interface.
+// The name is recorded by the journal, so it is still marked as read after the snapshot is loaded.
+func (m *MemoryGraph) MarkAsRead(data *DataOptsParams) error {
+	if err := m.Graph.MarkAsRead(data); err != nil {
+		return err
+	}
+
+	return m.appendJournal(&DataOptsParams{
+		UUID:   data.UUID,
+		Type:   memoryOptRead,
+		Name:   data.Name,
+		Domain: data.Domain,
+	})
+}
+
+// Annotate implements the Amass DataHandler interface.
+func (m *MemoryGraph) Annotate(a *Annotation) error {
+	return m.Insert(annotationDataOpts(a))
+}
+
+// DeleteEnumeration implements the Amass DataHandler interface.
+func (m *MemoryGraph) DeleteEnumeration(uuid string) error {
+	if err := m.Graph.DeleteEnumeration(uuid); err != nil {
+		return err
+	}
+
+	m.journalLock.Lock()
+	defer m.journalLock.Unlock()
+
+	r, err := m.journalReader()
+	if err != nil {
+		return err
+	}
+
+	old, oldBuf, oldEnc := m.journal, m.journalBuf, m.journalEnc
+	if err := m.createJournal(); err != nil {
+		return err
+	}
+
+	// The journal is rewritten without the data operations of the enumeration
+	dec := json.NewDecoder(r)
+	for err == nil {
+		var opt DataOptsParams
+
+		if err = dec.Decode(&opt); err == io.EOF {
+			err = nil
+			break
+		} else if err == nil && !strings.EqualFold(opt.UUID, uuid) {
+			err = m.journalEnc.Encode(&opt)
+		}
+	}
+
+	// The previous journal is kept when the new journal could not be written
+	if err != nil {
+		old, m.journal, m.journalBuf, m.journalEnc = m.journal, old, oldBuf, oldEnc
+	}
+	old.Close()
+	os.Remove(old.Name())
+	return err
+}
+
+// Load inserts the data operations provided via the Reader into the graph.
+func (m *MemoryGraph) Load(r io.Reader) error {
+	dec := json.NewDecoder(r)
+
+	for {
+		var opt DataOptsParams
+
+		if err := dec.Decode(&opt); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if err := m.Insert(&opt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot writes the data operations inserted into the graph to the Writer,
+// including the names that have been marked as read.
+func (m *MemoryGraph) Snapshot(w io.Writer) error {
+	m.journalLock.Lock()
+	defer m.journalLock.Unlock()
+
+	r, err := m.journalReader()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// Save writes the snapshot of the graph to the data operations file at path.
+// The file is replaced only after the entire snapshot has been written.
+func (m *MemoryGraph) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = m.Snapshot(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (m *MemoryGraph) appendJournal(data *DataOptsParams) error {
+	m.journalLock.Lock()
+	defer m.journalLock.Unlock()
+
+	return m.journalEnc.Encode(data)
+}
+
+// journalReader flushes the journal, and returns a Reader for the data operations written to it.
+func (m *MemoryGraph) journalReader() (io.Reader, error) {
+	if err := m.journalBuf.Flush(); err != nil {
+		return nil, err
+	}
+
+	info, err := m.journal.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(m.journal, 0, info.Size()), nil
+}
+
+func (m *MemoryGraph) openJournal() error {
+	m.journalLock.Lock()
+	defer m.journalLock.Unlock()
+
+	return m.createJournal()
+}
+
+// createJournal replaces the journal with a new file, which is not removed until the graph
+// is closed or the journal is replaced again.
+func (m *MemoryGraph) createJournal() error {
+	dir, prefix := os.TempDir(), "amass_memory_journal"
+	if m.snapshot != "" {
+		dir, prefix = filepath.Dir(m.snapshot), filepath.Base(m.snapshot)+".journal"
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	f, err := ioutil.TempFile(dir, prefix)
+	if err != nil {
+		return err
+	}
+
+	m.journal = f
+	m.journalBuf = bufio.NewWriter(f)
+	m.journalEnc = json.NewEncoder(m.journalBuf)
+	return nil
+}
+
+func (m *MemoryGraph) closeJournal() {
+	m.journalLock.Lock()
+	defer m.journalLock.Unlock()
+
+	if m.journal != nil {
+		m.journal.Close()
+		os.Remove(m.journal.Name())
+		m.journal = nil
+	}
+}
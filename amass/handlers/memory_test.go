@@ -0,0 +1,122 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/root-secure/Amass/amass/core"
+)
+
+func memoryOutputNames(out []*core.Output) string {
+	var names []string
+	for _, o := range out {
+		names = append(names, o.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestMemoryGraph(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memory")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	snapshot := filepath.Join(dir, "snapshot.json")
+	mem, err := NewMemoryGraph(snapshot, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the in-memory graph: %v", err)
+	}
+
+	uuid := "5c1e4a6a-0a1b-4c3d-9e8f-102030405060"
+	opts := append(mergeTestOpts(uuid, "2019-01-01T00:00:00Z", "dns"), DataOptsParams{
+		UUID: uuid, Timestamp: "2019-01-01T00:00:00Z", Type: OptCNAME, Name: "cdn.example.com",
+		Domain: "example.com", TargetName: "www.example.com", TargetDomain: "example.com",
+		Tag: "dns", Source: "Forward DNS",
+	})
+	if err := DataOptsDriver(opts, mem); err != nil {
+		t.Fatalf("Failed to insert the data operations: %v", err)
+	}
+
+	if !mem.IsCNAMENode(&DataOptsParams{UUID: uuid, Name: "cdn.example.com", Domain: "example.com"}) {
+		t.Errorf("cdn.example.com was not identified as a CNAME")
+	}
+	if mem.IsCNAMENode(&DataOptsParams{UUID: uuid, Name: "www.example.com", Domain: "example.com"}) {
+		t.Errorf("www.example.com was identified as a CNAME")
+	}
+
+	// The output is expected to match the graph stored on disk
+	graph := NewGraph(filepath.Join(dir, "graph"))
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+	if err := DataOptsDriver(opts, graph); err != nil {
+		t.Fatalf("Failed to insert the data operations: %v", err)
+	}
+
+	names := memoryOutputNames(graph.GetOutput(uuid, true))
+	if got := memoryOutputNames(mem.GetOutput(uuid, true)); names == "" || got != names {
+		t.Fatalf("The in-memory graph returned the names %s instead of %s", got, names)
+	}
+
+	read := &DataOptsParams{UUID: uuid, Name: "cdn.example.com", Domain: "example.com"}
+	graph.MarkAsRead(read)
+	mem.MarkAsRead(read)
+	unread := memoryOutputNames(graph.GetOutput(uuid, false))
+	if got := memoryOutputNames(mem.GetOutput(uuid, false)); got == names || got != unread {
+		t.Errorf("The unread names were %s instead of %s", got, unread)
+	}
+
+	if nodes, edges := mem.VizData(uuid); len(nodes) == 0 || len(edges) == 0 {
+		t.Errorf("VizData did not return the nodes and edges of the graph")
+	}
+	mem.Close()
+
+	// The snapshot saved by Close is loaded by the next graph
+	mem, err = NewMemoryGraph(snapshot, nil)
+	if err != nil {
+		t.Fatalf("Failed to load the snapshot: %v", err)
+	}
+	if enums := mem.EnumerationList(); len(enums) != 1 || enums[0] != uuid {
+		t.Errorf("The snapshot contained the enumerations %v", enums)
+	}
+	if got := memoryOutputNames(mem.GetOutput(uuid, true)); got != names {
+		t.Errorf("The names loaded from the snapshot were %s", got)
+	}
+	// The names marked as read are recorded by the snapshot
+	if got := memoryOutputNames(mem.GetOutput(uuid, false)); got != unread {
+		t.Errorf("The unread names loaded from the snapshot were %s instead of %s", got, unread)
+	}
+
+	if err := mem.DeleteEnumeration(uuid); err != nil {
+		t.Fatalf("Failed to delete the enumeration: %v", err)
+	}
+	mem.Close()
+
+	mem, err = NewMemoryGraph(snapshot, nil)
+	if err != nil {
+		t.Fatalf("Failed to load the snapshot: %v", err)
+	}
+
+	if enums := mem.EnumerationList(); len(enums) != 0 {
+		t.Errorf("The deleted enumeration was kept in the snapshot: %v", enums)
+	}
+	mem.Close()
+
+	// Only the snapshot remains once the journals have been removed
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		if f.Name() != "snapshot.json" && f.Name() != "graph" {
+			t.Errorf("The file %s was left next to the snapshot", f.Name())
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cayleygraph/cayley"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// The number of quads added in each transaction when graphs are merged.
+const graphMergeBatchSize = 10000
+
+// Predicates that hold a single value for each node within an enumeration.
+var singleValuePredicates = map[string]struct{}{
+	"type":      struct{}{},
+	"timestamp": struct{}{},
+	"tag":       struct{}{},
+	"source":    struct{}{},
+}
+
+// MergeConflict describes a property that holds different values in the merged databases.
+// The existing value is kept, and the incoming value is discarded.
+type MergeConflict struct {
+	UUID     string
+	Node     string
+	Property string
+	Existing string
+	Incoming string
+}
+
+// MergeStats reports the results of merging data into a DataHandler.
+type MergeStats struct {
+	// Enumerations merged into the database
+	Merged []string
+
+	// Enumerations skipped since they were already present during an incremental merge
+	Skipped []string
+
+	// The number of records added, and the number already in the database
+	Added      int
+	Duplicates int
+
+	Conflicts []*MergeConflict
+
+	// The number of annotations added or updated
+	Annotations int
+}
+
+func (s *MergeStats) add(other *MergeStats) {
+	s.Merged = append(s.Merged, other.Merged...)
+	s.Skipped = append(s.Skipped, other.Skipped...)
+	s.Added += other.Added
+	s.Duplicates += other.Duplicates
+	s.Conflicts = append(s.Conflicts, other.Conflicts...)
+	s.Annotations += other.Annotations
+}
+
+// Merge brings the enumerations in the src graph into this graph. Enumeration UUIDs and
+// timestamps are kept intact, records already in the graph are not duplicated, and properties
+// with different values are reported as conflicts. When incremental is true, only the
+// enumerations not yet present in this graph are merged.
+func (g *Graph) Merge(src *Graph, incremental bool) (*MergeStats, error) {
+	if src == nil || src == g {
+		return nil, errors.New("Graph: The graph cannot be merged into itself")
+	}
+
+	existing := make(map[string]struct{})
+	for _, uuid := range g.EnumerationList() {
+		existing[uuid] = struct{}{}
+	}
+
+	stats := new(MergeStats)
+	for _, uuid := range src.EnumerationList() {
+		if _, found := existing[uuid]; found && incremental {
+			stats.Skipped = append(stats.Skipped, uuid)
+			continue
+		}
+
+		s, err := g.mergeEnumeration(src, uuid)
+		if err != nil {
+			return stats, err
+		}
+		stats.add(s)
+	}
+
+	var err error
+	stats.Annotations, err = g.mergeAnnotations(src)
+	return stats, err
+}
+
+func (g *Graph) mergeEnumeration(src *Graph, uuid string) (*MergeStats, error) {
+	quads := src.enumerationQuads(uuid)
+
+	g.Lock()
+	defer g.Unlock()
+
+	stats := &MergeStats{Merged: []string{uuid}}
+	// Keep track of the single value properties added by this merge
+	added := make(map[string]string)
+
+	var batch []quad.Quad
+	for _, q := range quads {
+		pred := quad.ToString(q.Predicate)
+		subject := quad.ToString(q.Subject)
+		object := quad.ToString(q.Object)
+
+		if _, single := singleValuePredicates[pred]; single {
+			key := subject + "," + pred
+
+			cur, found := added[key]
+			if !found {
+				cur = g.propertyValue(q.Subject, pred, uuid)
+			}
+			if cur == object {
+				stats.Duplicates++
+				continue
+			} else if cur != "" {
+				stats.Conflicts = append(stats.Conflicts, &MergeConflict{
+					UUID:     uuid,
+					Node:     subject,
+					Property: pred,
+					Existing: cur,
+					Incoming: object,
+				})
+				continue
+			}
+			added[key] = object
+		} else if g.hasQuad(q) {
+			stats.Duplicates++
+			continue
+		}
+
+		batch = append(batch, q)
+		if len(batch) >= graphMergeBatchSize {
+			if err := g.addQuads(batch); err != nil {
+				return stats, err
+			}
+			stats.Added += len(batch)
+			batch = nil
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := g.addQuads(batch); err != nil {
+			return stats, err
+		}
+		stats.Added += len(batch)
+	}
+	return stats, g.indexAssets(uuid)
+}
+
+func (g *Graph) enumerationQuads(uuid string) []quad.Quad {
+	g.Lock()
+	defer g.Unlock()
+
+	return g.labelQuads(uuid)
+}
+
+// labelQuads returns the quads with the label. The caller is expected to hold the lock on the graph.
+func (g *Graph) labelQuads(uuid string) []quad.Quad {
+	label := g.store.ValueOf(quad.String(uuid))
+	if label == nil {
+		return nil
+	}
+
+	var quads []quad.Quad
+	it := g.store.QuadIterator(quad.Label, label)
+	defer it.Close()
+
+	ctx := context.TODO()
+	for it.Next(ctx) {
+		quads = append(quads, g.store.Quad(it.Result()))
+	}
+	return quads
+}
+
+func (g *Graph) hasQuad(q quad.Quad) bool {
+	p := cayley.StartPath(g.store, q.Subject).LabelContext(q.Label).Out(q.Predicate).Is(q.Object)
+	it, _ := p.BuildIterator().Optimize()
+	defer it.Close()
+
+	return it.Next(context.TODO())
+}
+
+func (g *Graph) addQuads(quads []quad.Quad) error {
+	t := cayley.NewTransaction()
+	for _, q := range quads {
+		t.AddQuad(q)
+	}
+
+	if err := g.store.ApplyTransaction(t); err != nil {
+		return fmt.Errorf("Graph: Failed to merge the quads: %v", err)
+	}
+	return nil
+}
+
+// MergeDataOpts brings the enumerations described by the data operations into the handler.
+// When incremental is true, only the enumerations not yet present in the handler are merged.
+// Conflicts can only be detected when the handler is a Graph.
+func MergeDataOpts(handler DataHandler, data []DataOptsParams, incremental bool) (*MergeStats, error) {
+	existing := make(map[string]struct{})
+	if incremental {
+		for _, uuid := range handler.EnumerationList() {
+			existing[uuid] = struct{}{}
+		}
+	}
+
+	stats := new(MergeStats)
+	var opts []DataOptsParams
+	for _, opt := range data {
+		if _, found := existing[opt.UUID]; found {
+			if !containsFold(stats.Skipped, opt.UUID) {
+				stats.Skipped = append(stats.Skipped, opt.UUID)
+			}
+			continue
+		}
+		opts = append(opts, opt)
+	}
+
+	if g, ok := handler.(*Graph); ok {
+		// Build a graph from the data operations, so it can be merged record by record
+		mem := newMemoryGraph()
+		if mem == nil {
+			return stats, errors.New("Graph: Failed to create the in-memory graph")
+		}
+		defer mem.Close()
+
+		if err := DataOptsDriver(opts, mem); err != nil {
+			return stats, err
+		}
+
+		s, err := g.Merge(mem, false)
+		if s != nil {
+			stats.add(s)
+		}
+		return stats, err
+	}
+
+	for _, opt := range opts {
+		if opt.Type == OptAnnotation {
+			stats.Annotations++
+			continue
+		}
+		if !containsFold(stats.Merged, opt.UUID) {
+			stats.Merged = append(stats.Merged, opt.UUID)
+		}
+	}
+	if err := DataOptsDriver(opts, handler); err != nil {
+		return stats, err
+	}
+	stats.Added = len(opts) - stats.Annotations
+	return stats, nil
+}
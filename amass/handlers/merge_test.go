@@ -0,0 +1,135 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func mergeTestOpts(uuid, timestamp, tag string) []DataOptsParams {
+	opts := []DataOptsParams{
+		{Type: OptDomain, Domain: "example.com", Tag: "dns", Source: "Forward DNS"},
+		{Type: OptA, Name: "www.example.com", Domain: "example.com", Address: "192.0.2.10",
+			Tag: tag, Source: "Forward DNS"},
+		{Type: OptInfrastructure, Address: "192.0.2.10", ASN: 64496,
+			CIDR: "192.0.2.0/24", Description: "EXAMPLE-AS"},
+	}
+
+	for i := range opts {
+		opts[i].UUID = uuid
+		opts[i].Timestamp = timestamp
+	}
+	return opts
+}
+
+func TestGraphMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	dst := NewGraph(filepath.Join(dir, "dst"))
+	if dst == nil {
+		t.Fatalf("Failed to create the destination graph")
+	}
+	defer dst.Close()
+
+	src := NewGraph(filepath.Join(dir, "src"))
+	if src == nil {
+		t.Fatalf("Failed to create the source graph")
+	}
+	defer src.Close()
+
+	if err := DataOptsDriver(mergeTestOpts("enum1", "2019-01-01T00:00:00Z", "dns"), dst); err != nil {
+		t.Fatalf("Failed to populate the destination graph: %v", err)
+	}
+	if err := DataOptsDriver(mergeTestOpts("enum1", "2019-01-01T00:00:00Z", "brute"), src); err != nil {
+		t.Fatalf("Failed to populate the source graph: %v", err)
+	}
+	if err := DataOptsDriver(mergeTestOpts("enum2", "2019-02-01T00:00:00Z", "dns"), src); err != nil {
+		t.Fatalf("Failed to populate the source graph: %v", err)
+	}
+
+	stats, err := dst.Merge(src, true)
+	if err != nil {
+		t.Fatalf("Failed to perform the incremental merge: %v", err)
+	}
+	if len(stats.Merged) != 1 || stats.Merged[0] != "enum2" || len(stats.Skipped) != 1 || stats.Added == 0 {
+		t.Errorf("The incremental merge returned %+v", stats)
+	}
+
+	first, last := dst.EnumerationDateRange("enum2")
+	if first.Month() != 2 || !first.Equal(last) {
+		t.Errorf("The merged enumeration had the date range %v to %v", first, last)
+	}
+	if out := dst.GetOutput("enum2", true); len(out) != 1 || out[0].Addresses[0].ASN != 64496 {
+		t.Errorf("The merged enumeration returned %v", out)
+	}
+
+	stats, err = dst.Merge(src, false)
+	if err != nil {
+		t.Fatalf("Failed to perform the merge: %v", err)
+	}
+	if stats.Added != 0 || stats.Duplicates == 0 {
+		t.Errorf("The merge added %d records and found %d duplicates", stats.Added, stats.Duplicates)
+	}
+	if len(stats.Conflicts) != 1 {
+		t.Fatalf("The merge reported %d conflicts, expected 1", len(stats.Conflicts))
+	}
+	if c := stats.Conflicts[0]; c.UUID != "enum1" || c.Node != "www.example.com" ||
+		c.Property != "tag" || c.Existing != "dns" || c.Incoming != "brute" {
+		t.Errorf("The merge reported the conflict %+v", c)
+	}
+
+	list := dst.EnumerationList()
+	sort.Strings(list)
+	if len(list) != 2 || list[0] != "enum1" || list[1] != "enum2" {
+		t.Errorf("The enumeration list after the merge was %v", list)
+	}
+}
+
+func TestMergeDataOpts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	graph := NewGraph(filepath.Join(dir, "graph"))
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	db, err := NewSQL(SQLDriverSQLite, filepath.Join(dir, DefaultSQLiteFile), nil)
+	if err != nil {
+		t.Fatalf("Failed to create the SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	for _, handler := range []DataHandler{graph, db} {
+		if err := DataOptsDriver(mergeTestOpts("enum1", "2019-01-01T00:00:00Z", "dns"), handler); err != nil {
+			t.Fatalf("%s: Failed to populate the database: %v", handler, err)
+		}
+
+		opts := append(mergeTestOpts("enum1", "2019-01-01T00:00:00Z", "dns"),
+			mergeTestOpts("enum2", "2019-02-01T00:00:00Z", "dns")...)
+		stats, err := MergeDataOpts(handler, opts, true)
+		if err != nil {
+			t.Fatalf("%s: Failed to merge the data operations: %v", handler, err)
+		}
+		if len(stats.Merged) != 1 || stats.Merged[0] != "enum2" ||
+			len(stats.Skipped) != 1 || stats.Skipped[0] != "enum1" {
+			t.Errorf("%s: The merge returned %+v", handler, stats)
+		}
+		if out := handler.GetOutput("enum2", true); len(out) != 1 || out[0].Name != "www.example.com" {
+			t.Errorf("%s: The merged enumeration returned %v", handler, out)
+		}
+	}
+}
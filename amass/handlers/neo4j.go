@@ -4,45 +4,64 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/root-secure/Amass/amass/core"
 	"github.com/root-secure/Amass/amass/utils/viz"
 	bolt "github.com/johnnadratowski/golang-neo4j-bolt-driver"
+	"github.com/johnnadratowski/golang-neo4j-bolt-driver/structures/messages"
 )
 
 // Neo4j is the client object for a Neo4j graph database connection.
 type Neo4j struct {
 	sync.Mutex
+	Log    *log.Logger
+	url    string
 	driver bolt.Driver
 	conn   bolt.Conn
+	writer *batchWriter
 }
 
 // NewNeo4j returns a client object that implements the Amass DataHandler interface.
 // The url param typically looks like the following: localhost:7687
-func NewNeo4j(url, username, password string, l *log.Logger) (*Neo4j, error) {
+// Data operations are written in batches, and default settings are used when batch is nil.
+func NewNeo4j(url, username, password string, batch *BatchSettings, l *log.Logger) (*Neo4j, error) {
 	var err error
 
-	neo4j := &Neo4j{driver: bolt.NewDriver()}
+	neo4j := &Neo4j{
+		Log:    l,
+		driver: bolt.NewDriver(),
+	}
 
 	if username != "" && password != "" {
 		url = fmt.Sprintf("%s:%s@%s", username, password, url)
 	}
-	neo4j.conn, err = neo4j.driver.OpenNeo("bolt://" + url)
+	neo4j.url = "bolt://" + url
+
+	neo4j.conn, err = neo4j.driver.OpenNeo(neo4j.url)
 	if err != nil {
 		if l != nil {
 			l.Println("Neo4j: Lost connection to the database: " + err.Error())
 		}
 		return nil, err
 	}
+
+	neo4j.writer, err = newBatchWriter("Neo4j", batch, neo4j.insertBatch, l)
+	if err != nil {
+		neo4j.conn.Close()
+		return nil, err
+	}
 	return neo4j, nil
 }
 
 // Close cleans up the Neo4j client object.
 func (n *Neo4j) Close() {
+	n.writer.Close()
 	n.conn.Close()
 }
 
@@ -51,11 +70,80 @@ func (n *Neo4j) String() string {
 	return "Neo4j Database Handler"
 }
 
+// InsertStats returns the throughput of the inserts performed.
+func (n *Neo4j) InsertStats() InsertStats {
+	return n.writer.Stats()
+}
+
 // Insert implements the Amass DataHandler interface.
+// The data operation is written to the database with the next batch.
 func (n *Neo4j) Insert(data *DataOptsParams) error {
+	return n.writer.Insert(data)
+}
+
+// insertBatch writes the data operations within a single transaction.
+func (n *Neo4j) insertBatch(batch []*DataOptsParams) error {
 	n.Lock()
 	defer n.Unlock()
 
+	tx, err := n.conn.Begin()
+	if err != nil {
+		n.reconnect()
+		return err
+	}
+
+	for _, data := range batch {
+		if err := n.insertData(data); err != nil {
+			tx.Rollback()
+			// Data operations rejected before reaching the server leave the connection usable
+			if !isPermanent(err) {
+				n.reconnect()
+			}
+			return neo4jBatchError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		n.reconnect()
+		return neo4jBatchError(err)
+	}
+	return nil
+}
+
+// neo4jBatchError marks the failures reported by the server as permanent, unless the
+// server classified the failure as transient. Connection failures remain transient.
+func neo4jBatchError(err error) error {
+	inner := err
+	if e, ok := err.(interface{ InnerMost() error }); ok {
+		inner = e.InnerMost()
+	}
+
+	failure, ok := inner.(messages.FailureMessage)
+	if !ok {
+		return err
+	}
+	if code, _ := failure.Metadata["code"].(string); strings.HasPrefix(code, "Neo.TransientError") {
+		return err
+	}
+	return permanent(err)
+}
+
+// reconnect replaces the connection after a failure, so the next attempt is not
+// performed using a broken connection.
+func (n *Neo4j) reconnect() {
+	conn, err := n.driver.OpenNeo(n.url)
+	if err != nil {
+		if n.Log != nil {
+			n.Log.Println("Neo4j: Lost connection to the database: " + err.Error())
+		}
+		return
+	}
+
+	n.conn.Close()
+	n.conn = conn
+}
+
+func (n *Neo4j) insertData(data *DataOptsParams) error {
 	var err error
 	switch data.Type {
 	case OptDomain:
@@ -76,6 +164,22 @@ func (n *Neo4j) Insert(data *DataOptsParams) error {
 		err = n.insertMX(data)
 	case OptInfrastructure:
 		err = n.insertInfrastructure(data)
+	case OptCertificate:
+		err = n.insertCertificate(data)
+	case OptHTTP:
+		err = n.insertHTTP(data)
+	case OptTakeover:
+		err = n.insertTakeover(data)
+	case OptEmailPosture:
+		err = n.insertEmailPosture(data)
+	case OptEmailSender:
+		err = n.insertEmailSender(data)
+	case OptRDAP:
+		err = n.insertRDAP(data)
+	case OptIntel:
+		err = n.insertIntel(data)
+	case OptAnnotation:
+		err = n.insertAnnotation(data)
 	}
 	return err
 }
@@ -463,6 +567,192 @@ func (n *Neo4j) insertInfrastructure(data *DataOptsParams) error {
 	return err
 }
 
+func (n *Neo4j) insertCertificate(data *DataOptsParams) error {
+	params := map[string]interface{}{
+		"uuid":        data.UUID,
+		"timestamp":   data.Timestamp,
+		"fingerprint": data.Fingerprint,
+		"addr":        data.Address,
+		"port":        data.Port,
+		"servername":  data.ServerName,
+		"commonname":  data.Name,
+		"names":       strings.Join(data.Names, ","),
+		"org":         data.Organization,
+		"orgunit":     data.OrgUnit,
+		"issuer":      data.Issuer,
+		"notbefore":   data.NotBefore,
+		"notafter":    data.NotAfter,
+		"serial":      data.Serial,
+		"tag":         data.Tag,
+		"source":      data.Source,
+	}
+
+	_, err := n.conn.ExecNeo("MERGE (c:certificate {fingerprint: {fingerprint}, enum: {uuid}}) "+
+		"ON CREATE SET c.timestamp = {timestamp}, c.tag = {tag}, c.source = {source}, "+
+		"c.common_name = {commonname}, c.names = {names}, c.organization = {org}, "+
+		"c.org_unit = {orgunit}, c.issuer = {issuer}, c.not_before = {notbefore}, "+
+		"c.not_after = {notafter}, c.serial = {serial}", params)
+	if err != nil || data.Address == "" {
+		return err
+	}
+
+	_, err = n.conn.ExecNeo("MATCH (a:address {addr: {addr}, enum: {uuid}}) "+
+		"MATCH (c:certificate {fingerprint: {fingerprint}, enum: {uuid}}) "+
+		"MERGE (a)-[:has_cert {port: {port}, server_name: {servername}}]->(c)", params)
+	return err
+}
+
+func (n *Neo4j) insertHTTP(data *DataOptsParams) error {
+	params := map[string]interface{}{
+		"uuid":      data.UUID,
+		"timestamp": data.Timestamp,
+		"name":      data.Name,
+		"url":       data.URL,
+		"status":    data.StatusCode,
+		"title":     data.Title,
+		"server":    data.Server,
+		"redirects": strings.Join(data.Redirects, " "),
+		"tag":       data.Tag,
+		"source":    data.Source,
+	}
+
+	if err := n.insertSubdomain(data); err != nil {
+		return err
+	}
+
+	_, err := n.conn.ExecNeo("MERGE (u:url {url: {url}, enum: {uuid}}) "+
+		"ON CREATE SET u.timestamp = {timestamp}, u.tag = {tag}, u.source = {source}, "+
+		"u.status = {status}, u.title = {title}, u.server = {server}, u.redirects = {redirects}", params)
+	if err != nil {
+		return err
+	}
+
+	for _, label := range []string{"domain", "subdomain"} {
+		_, err = n.conn.ExecNeo("MATCH (source:"+label+" {name: {name}, enum: {uuid}}) "+
+			"MATCH (u:url {url: {url}, enum: {uuid}}) "+
+			"MERGE (source)-[:has_url]->(u)", params)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Neo4j) insertTakeover(data *DataOptsParams) error {
+	params := map[string]interface{}{
+		"uuid":      data.UUID,
+		"timestamp": data.Timestamp,
+		"name":      data.Name,
+		"target":    data.TargetName,
+		"provider":  data.Provider,
+		"reason":    data.Reason,
+		"evidence":  data.Evidence,
+	}
+
+	if err := n.insertSubdomain(data); err != nil {
+		return err
+	}
+
+	for _, label := range []string{"domain", "subdomain"} {
+		_, err := n.conn.ExecNeo("MATCH (s:"+label+" {name: {name}, enum: {uuid}}) "+
+			"SET s.takeover = {reason}, s.takeover_target = {target}, s.takeover_provider = {provider}, "+
+			"s.takeover_evidence = {evidence}, s.takeover_timestamp = {timestamp}", params)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Neo4j) insertEmailPosture(data *DataOptsParams) error {
+	params := map[string]interface{}{
+		"uuid":        data.UUID,
+		"timestamp":   data.Timestamp,
+		"domain":      data.Domain,
+		"spf":         data.SPF,
+		"spflookups":  data.SPFLookups,
+		"spfall":      data.SPFAll,
+		"dmarc":       data.DMARC,
+		"dmarcpolicy": data.DMARCPolicy,
+		"selectors":   strings.Join(data.DKIMSelectors, ","),
+		"issues":      strings.Join(data.Issues, "\n"),
+	}
+
+	if err := n.insertDomain(data); err != nil {
+		return err
+	}
+
+	_, err := n.conn.ExecNeo("MATCH (d:domain {name: {domain}, enum: {uuid}}) "+
+		"SET d.posture_timestamp = {timestamp}, d.spf_record = {spf}, d.spf_lookups = {spflookups}, "+
+		"d.spf_all = {spfall}, d.dmarc_record = {dmarc}, d.dmarc_policy = {dmarcpolicy}, "+
+		"d.dkim_selectors = {selectors}, d.posture_issues = {issues}", params)
+	if err != nil {
+		return err
+	}
+
+	for _, cidr := range data.CIDRs {
+		if err := n.insertSPFNetblock("domain", data.Domain, cidr, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Neo4j) insertEmailSender(data *DataOptsParams) error {
+	params := map[string]interface{}{
+		"uuid":      data.UUID,
+		"timestamp": data.Timestamp,
+		"domain":    data.Domain,
+		"sender":    data.Name,
+		"tag":       data.Tag,
+		"source":    data.Source,
+	}
+
+	if err := n.insertDomain(data); err != nil {
+		return err
+	}
+
+	_, err := n.conn.ExecNeo("MERGE (s:sender {name: {sender}, enum: {uuid}}) "+
+		"ON CREATE SET s.timestamp = {timestamp}, s.tag = {tag}, s.source = {source}", params)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.conn.ExecNeo("MATCH (d:domain {name: {domain}, enum: {uuid}}) "+
+		"MATCH (s:sender {name: {sender}, enum: {uuid}}) "+
+		"MERGE (d)-[:spf_sender]->(s)", params)
+	if err != nil {
+		return err
+	}
+
+	for _, cidr := range data.CIDRs {
+		if err := n.insertSPFNetblock("sender", data.Name, cidr, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Neo4j) insertSPFNetblock(label, name, cidr string, data *DataOptsParams) error {
+	params := map[string]interface{}{
+		"uuid":      data.UUID,
+		"timestamp": data.Timestamp,
+		"name":      name,
+		"cidr":      cidr,
+	}
+
+	_, err := n.conn.ExecNeo("MERGE (nb:netblock {cidr: {cidr}, enum: {uuid}}) "+
+		"ON CREATE SET nb.timestamp = {timestamp}", params)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.conn.ExecNeo("MATCH (s:"+label+" {name: {name}, enum: {uuid}}) "+
+		"MATCH (nb:netblock {cidr: {cidr}, enum: {uuid}}) "+
+		"MERGE (s)-[:spf_authorizes]->(nb)", params)
+	return err
+}
+
 // EnumerationList returns a list of enumeration IDs found in the data.
 func (n *Neo4j) EnumerationList() []string {
 	return []string{}
@@ -480,11 +770,65 @@ func (n *Neo4j) EnumerationDateRange(uuid string) (time.Time, time.Time) {
 
 // GetOutput implements the Amass DataHandler interface.
 func (n *Neo4j) GetOutput(uuid string, marked bool) []*core.Output {
+	var output []*core.Output
+
+	err := n.StreamOutput(uuid, marked, func(o *core.Output) bool {
+		output = append(output, o)
+		return true
+	})
+	if err != nil && n.Log != nil {
+		n.Log.Println(err)
+	}
+	return output
+}
+
+// StreamOutput implements the Amass DataHandler interface.
+func (n *Neo4j) StreamOutput(uuid string, marked bool, fn func(*core.Output) bool) error {
+	return errors.New("Neo4j: Reading the output from the graph is not supported")
+}
+
+// Query implements the Amass DataHandler interface.
+func (n *Neo4j) Query(uuid string, filter *QueryFilter) ([]*QueryResult, error) {
+	return nil, errors.New("Neo4j: Queries are not supported")
+}
+
+// StreamQuery implements the Amass DataHandler interface.
+func (n *Neo4j) StreamQuery(uuid string, filter *QueryFilter, fn func(*QueryResult) bool) error {
+	return errors.New("Neo4j: Queries are not supported")
+}
+
+// DeleteEnumeration removes all the nodes created by the provided enumeration.
+func (n *Neo4j) DeleteEnumeration(uuid string) error {
+	if err := n.writer.Flush(); err != nil {
+		return err
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	params := map[string]interface{}{"uuid": uuid}
+
+	// Detaching the nodes also removes their relationships
+	_, err := n.conn.ExecNeo("MATCH (n {enum: {uuid}}) DETACH DELETE n", params)
+	return err
+}
+
+// Compact implements the Amass DataHandler interface.
+// The Neo4j server manages the storage used by the graph.
+func (n *Neo4j) Compact() error {
 	return nil
 }
 
 // MarkAsRead implements the Amass DataHandler interface.
 func (n *Neo4j) MarkAsRead(data *DataOptsParams) error {
+	// Pending data operations are written before the graph is read
+	if err := n.writer.Flush(); err != nil {
+		return err
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
 	params := map[string]interface{}{
 		"uuid":   data.UUID,
 		"name":   data.Name,
@@ -492,16 +836,25 @@ func (n *Neo4j) MarkAsRead(data *DataOptsParams) error {
 	}
 
 	for _, label := range []string{"domain", "subdomain", "ns", "mx"} {
-		n.conn.ExecNeo("MATCH (domain:domain {name: {domain}, enum: {uuid}}) "+
+		_, err := n.conn.ExecNeo("MATCH (domain:domain {name: {domain}, enum: {uuid}}) "+
 			"MATCH (target:"+label+" {name: {name}, enum: {uuid}}) "+
 			"MATCH (domain)-[:root_of]->(target) "+
 			"SET target.read = 'yes'", params)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 // IsCNAMENode implements the Amass DataHandler interface.
 func (n *Neo4j) IsCNAMENode(data *DataOptsParams) bool {
+	// Pending data operations are written before the graph is read
+	n.writer.Flush()
+
+	n.Lock()
+	defer n.Unlock()
+
 	params := map[string]interface{}{
 		"uuid":   data.UUID,
 		"name":   data.Name,
@@ -518,5 +871,195 @@ func (n *Neo4j) IsCNAMENode(data *DataOptsParams) bool {
 
 // VizData returns the current state of the Graph as viz package Nodes and Edges.
 func (n *Neo4j) VizData(uuid string) ([]viz.Node, []viz.Edge) {
-	return []viz.Node{}, []viz.Edge{}
+	var nodes []viz.Node
+	var edges []viz.Edge
+
+	err := n.StreamVizData(uuid, func(node viz.Node) bool {
+		nodes = append(nodes, node)
+		return true
+	}, func(e viz.Edge) bool {
+		edges = append(edges, e)
+		return true
+	})
+	if err != nil && n.Log != nil {
+		n.Log.Println(err)
+	}
+	return nodes, edges
+}
+
+// StreamVizData implements the Amass DataHandler interface.
+func (n *Neo4j) StreamVizData(uuid string, nodes func(viz.Node) bool, edges func(viz.Edge) bool) error {
+	return errors.New("Neo4j: Reading the graph for visualizations is not supported")
+}
+
+// The annotation nodes are not part of an enumeration, so they do not have an enum property.
+func (n *Neo4j) insertAnnotation(data *DataOptsParams) error {
+	a := annotationFromDataOpts(data)
+	if a.Asset == "" {
+		return permanent(errors.New("Neo4j: The annotation does not identify an asset"))
+	}
+
+	params := map[string]interface{}{
+		"asset":          a.Asset,
+		"owner":          a.Owner,
+		"criticality":    a.Criticality,
+		"false_positive": a.FalsePositive,
+		"decommissioned": a.Decommissioned,
+		"notes":          a.Notes,
+		"updated":        data.Timestamp,
+	}
+
+	if a.Empty() {
+		_, err := n.conn.ExecNeo("MATCH (a:annotation {asset: {asset}}) DELETE a", params)
+		return err
+	}
+
+	_, err := n.conn.ExecNeo("MERGE (a:annotation {asset: {asset}}) "+
+		"SET a.owner = {owner}, a.criticality = {criticality}, a.false_positive = {false_positive}, "+
+		"a.decommissioned = {decommissioned}, a.notes = {notes}, a.updated = {updated}", params)
+	return err
+}
+
+// Annotate implements the Amass DataHandler interface.
+func (n *Neo4j) Annotate(a *Annotation) error {
+	if err := n.Insert(annotationDataOpts(a)); err != nil {
+		return err
+	}
+	return n.writer.Flush()
+}
+
+// Annotations implements the Amass DataHandler interface.
+func (n *Neo4j) Annotations() map[string]*Annotation {
+	// Pending data operations are written before the graph is read
+	n.writer.Flush()
+
+	n.Lock()
+	defer n.Unlock()
+
+	rows, _, _, err := n.conn.QueryNeoAll("MATCH (a:annotation) RETURN a.asset, a.owner, "+
+		"a.criticality, a.false_positive, a.decommissioned, a.notes, a.updated", nil)
+	if err != nil {
+		if n.Log != nil {
+			n.Log.Printf("Neo4j: Failed to read the annotations: %v", err)
+		}
+		return nil
+	}
+
+	str := func(v interface{}) string {
+		s, _ := v.(string)
+		return s
+	}
+	boolean := func(v interface{}) bool {
+		b, _ := v.(bool)
+		return b
+	}
+
+	annotations := make(map[string]*Annotation)
+	for _, row := range rows {
+		if len(row) < 7 || str(row[0]) == "" {
+			continue
+		}
+
+		asset := str(row[0])
+		annotations[asset] = &Annotation{
+			Asset:          asset,
+			Owner:          str(row[1]),
+			Criticality:    str(row[2]),
+			FalsePositive:  boolean(row[3]),
+			Decommissioned: boolean(row[4]),
+			Notes:          str(row[5]),
+			Updated:        parseAssetTimestamp(str(row[6])),
+		}
+	}
+	return annotations
+}
+
+func (n *Neo4j) insertRDAP(data *DataOptsParams) error {
+	params := map[string]interface{}{
+		"uuid":        data.UUID,
+		"timestamp":   data.Timestamp,
+		"url":         data.URL,
+		"handle":      data.Handle,
+		"name":        data.Name,
+		"country":     data.Country,
+		"registrant":  data.Registrant,
+		"registrar":   data.Registrar,
+		"abuse":       data.AbuseContact,
+		"registered":  data.Registered,
+		"expires":     data.Expires,
+		"nameservers": strings.Join(data.Nameservers, ","),
+	}
+
+	// Identify the nodes receiving the registration data
+	var node string
+	var values []interface{}
+	switch {
+	case len(data.CIDRs) > 0:
+		node = "n:netblock {cidr: {value}, enum: {uuid}}"
+		for _, cidr := range data.CIDRs {
+			values = append(values, cidr)
+		}
+	case data.ASN != 0:
+		node = "n:as {asn: {value}, enum: {uuid}}"
+		values = append(values, data.ASN)
+	case data.Domain != "":
+		if err := n.insertDomain(data); err != nil {
+			return err
+		}
+		node = "n:domain {name: {value}, enum: {uuid}}"
+		values = append(values, data.Domain)
+	default:
+		return permanent(errors.New("Neo4j: insertRDAP: no domain, ASN or netblock provided"))
+	}
+
+	for _, value := range values {
+		params["value"] = value
+
+		_, err := n.conn.ExecNeo("MERGE ("+node+") ON CREATE SET n.timestamp = {timestamp} "+
+			"SET n.rdap_timestamp = {timestamp}, n.rdap_url = {url}, n.rdap_handle = {handle}, "+
+			"n.rdap_name = {name}, n.rdap_country = {country}, n.registrant = {registrant}, "+
+			"n.registrar = {registrar}, n.abuse_contact = {abuse}, n.registered = {registered}, "+
+			"n.expires = {expires}, n.rdap_nameservers = {nameservers}", params)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Neo4j) insertIntel(data *DataOptsParams) error {
+	params := map[string]interface{}{
+		"uuid":      data.UUID,
+		"timestamp": data.Timestamp,
+		"domain":    data.Domain,
+		"method":    data.Method,
+		"addr":      data.Address,
+		"tag":       data.Tag,
+		"source":    data.Source,
+	}
+
+	if err := n.insertDomain(data); err != nil {
+		return err
+	}
+
+	// Identify the enumeration as an intelligence collection
+	_, err := n.conn.ExecNeo("MATCH (d:domain {name: {domain}, enum: {uuid}}) "+
+		"SET d.enum_type = 'intel', d.intel_method = {method}", params)
+	if err != nil || data.Address == "" {
+		return err
+	}
+
+	_, err = n.conn.ExecNeo("MERGE (a:address {addr: {addr}, enum: {uuid}}) "+
+		"ON CREATE SET a.timestamp = {timestamp}, a.tag = {tag}, a.source = {source}", params)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.conn.ExecNeo("MATCH (d:domain {name: {domain}, enum: {uuid}}) "+
+		"MATCH (a:address {addr: {addr}, enum: {uuid}}) "+
+		"MERGE (d)-[:discovered_from]->(a)", params)
+	if err != nil || data.CIDR == "" || data.ASN == 0 {
+		return err
+	}
+	return n.insertInfrastructure(data)
 }
@@ -0,0 +1,224 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+)
+
+// These strings represent the DNS record types that can be selected by a QueryFilter.
+const (
+	RecordA     = "a"
+	RecordAAAA  = "aaaa"
+	RecordCNAME = "cname"
+	RecordPTR   = "ptr"
+	RecordSRV   = "srv"
+	RecordNS    = "ns"
+	RecordMX    = "mx"
+)
+
+// RecordTypes is the list of DNS record types that can be selected by a QueryFilter.
+var RecordTypes = []string{RecordA, RecordAAAA, RecordCNAME, RecordPTR, RecordSRV, RecordNS, RecordMX}
+
+// Graph predicates that identify the DNS records held by a name.
+var recordPredicates = map[string]string{
+	"a_to":     RecordA,
+	"aaaa_to":  RecordAAAA,
+	"cname_to": RecordCNAME,
+	"ptr_to":   RecordPTR,
+	"srv_to":   RecordSRV,
+	"ns_to":    RecordNS,
+	"mx_to":    RecordMX,
+}
+
+// QueryFilter selects the DNS names returned by a DataHandler query.
+// A name matches when it satisfies every field of the filter that has been set.
+type QueryFilter struct {
+	// The name has an address announced by one of the ASNs
+	ASNs []int
+
+	// The name has an address within one of the netblocks
+	CIDRs []*net.IPNet
+
+	// The name was discovered using one of the tags (e.g. brute or scrape)
+	Tags []string
+
+	// The name was discovered by one of the data sources
+	Sources []string
+
+	// The name holds one of the DNS record types
+	RecordTypes []string
+
+	// A pattern (e.g. *.cloudfront.net) matching a target in the CNAME chain of the name
+	CNAMETarget string
+
+	// The name was discovered within the time window
+	Since time.Time
+	Until time.Time
+}
+
+// QueryResult is a DNS name selected by a DataHandler query.
+type QueryResult struct {
+	UUID         string             `json:"uuid"`
+	Timestamp    time.Time          `json:"timestamp"`
+	Name         string             `json:"name"`
+	Domain       string             `json:"domain"`
+	RecordTypes  []string           `json:"types"`
+	CNAMETargets []string           `json:"cname_targets,omitempty"`
+	Addresses    []core.AddressInfo `json:"addresses"`
+	Tag          string             `json:"tag"`
+	Source       string             `json:"source"`
+}
+
+// Output returns the query result as Amass output.
+func (r *QueryResult) Output() *core.Output {
+	return &core.Output{
+		Timestamp: r.Timestamp,
+		Name:      r.Name,
+		Domain:    r.Domain,
+		Addresses: r.Addresses,
+		Tag:       r.Tag,
+		Source:    r.Source,
+	}
+}
+
+// Match returns true when the query result satisfies the filter. When the filter
+// selects ASNs or netblocks, only the matching addresses are kept in the result.
+func (f *QueryFilter) Match(r *QueryResult) bool {
+	if f == nil {
+		return true
+	}
+	if !f.Since.IsZero() && r.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && r.Timestamp.After(f.Until) {
+		return false
+	}
+	if len(f.Tags) > 0 && !containsFold(f.Tags, r.Tag) {
+		return false
+	}
+	if len(f.Sources) > 0 && !containsFold(f.Sources, r.Source) {
+		return false
+	}
+
+	if len(f.RecordTypes) > 0 {
+		var found bool
+		for _, t := range r.RecordTypes {
+			if containsFold(f.RecordTypes, t) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.CNAMETarget != "" {
+		var found bool
+		pattern := strings.ToLower(f.CNAMETarget)
+		for _, target := range r.CNAMETargets {
+			if match, _ := path.Match(pattern, strings.ToLower(target)); match {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.ASNs) == 0 && len(f.CIDRs) == 0 {
+		return true
+	}
+
+	var addrs []core.AddressInfo
+	for _, addr := range r.Addresses {
+		if f.matchAddress(addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	r.Addresses = addrs
+	return len(addrs) > 0
+}
+
+func (f *QueryFilter) matchAddress(addr core.AddressInfo) bool {
+	if len(f.ASNs) > 0 {
+		var found bool
+		for _, asn := range f.ASNs {
+			if addr.ASN == asn {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.CIDRs) > 0 {
+		var found bool
+		for _, cidr := range f.CIDRs {
+			if addr.Address != nil && cidr.Contains(addr.Address) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordTypesFromAddresses identifies the address records for outputs that do not provide the DNS records.
+func recordTypesFromAddresses(addrs []core.AddressInfo) []string {
+	var types []string
+
+	for _, addr := range addrs {
+		t := RecordAAAA
+		if addr.Address.To4() != nil {
+			t = RecordA
+		}
+		if !containsFold(types, t) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// outputToQueryResult converts the output of handlers unable to provide the DNS records.
+func outputToQueryResult(uuid string, out *core.Output) *QueryResult {
+	return &QueryResult{
+		UUID:        uuid,
+		Timestamp:   out.Timestamp,
+		Name:        out.Name,
+		Domain:      out.Domain,
+		RecordTypes: recordTypesFromAddresses(out.Addresses),
+		Addresses:   out.Addresses,
+		Tag:         out.Tag,
+		Source:      out.Source,
+	}
+}
+
+func sortQueryResults(results []*QueryResult) {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+}
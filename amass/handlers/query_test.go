@@ -0,0 +1,102 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "query")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	graph := NewGraph(filepath.Join(dir, "graph"))
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	db, err := NewSQL(SQLDriverSQLite, filepath.Join(dir, DefaultSQLiteFile), nil)
+	if err != nil {
+		t.Fatalf("Failed to create the SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	opts := []*DataOptsParams{
+		{Type: OptCNAME, Name: "www.example.com", Domain: "example.com", TargetName: "d111.cloudfront.net",
+			TargetDomain: "cloudfront.net", Tag: "dns", Source: "Forward DNS", Timestamp: "2019-01-01T00:00:00Z"},
+		{Type: OptA, Name: "d111.cloudfront.net", Domain: "cloudfront.net", Address: "192.0.2.10",
+			Tag: "dns", Source: "Forward DNS", Timestamp: "2019-01-01T00:00:00Z"},
+		{Type: OptInfrastructure, Address: "192.0.2.10", ASN: 16509, CIDR: "192.0.2.0/24",
+			Description: "AMAZON-02", Timestamp: "2019-01-01T00:00:00Z"},
+		{Type: OptA, Name: "vpn.example.com", Domain: "example.com", Address: "10.1.2.3",
+			Tag: "brute", Source: "Brute Forcing", Timestamp: "2019-02-01T00:00:00Z"},
+		{Type: OptInfrastructure, Address: "10.1.2.3", ASN: 64512, CIDR: "10.0.0.0/8",
+			Description: "PRIVATE", Timestamp: "2019-02-01T00:00:00Z"},
+		{Type: OptMX, Name: "example.com", Domain: "example.com", TargetName: "mail.example.com",
+			TargetDomain: "example.com", Tag: "dns", Source: "Forward DNS", Timestamp: "2019-02-01T00:00:00Z"},
+	}
+
+	_, private, _ := net.ParseCIDR("10.0.0.0/8")
+	tests := []struct {
+		filter *QueryFilter
+		names  []string
+	}{
+		{&QueryFilter{}, []string{"cloudfront.net", "d111.cloudfront.net", "example.com",
+			"mail.example.com", "vpn.example.com", "www.example.com"}},
+		{&QueryFilter{ASNs: []int{16509}}, []string{"d111.cloudfront.net", "www.example.com"}},
+		{&QueryFilter{CIDRs: []*net.IPNet{private}, Tags: []string{"brute"}}, []string{"vpn.example.com"}},
+		{&QueryFilter{CIDRs: []*net.IPNet{private}, Sources: []string{"Forward DNS"}}, nil},
+		{&QueryFilter{CNAMETarget: "*.cloudfront.net"}, []string{"www.example.com"}},
+		{&QueryFilter{RecordTypes: []string{RecordMX, RecordCNAME}}, []string{"example.com", "www.example.com"}},
+		{&QueryFilter{Since: time.Date(2019, 1, 15, 0, 0, 0, 0, time.UTC)},
+			[]string{"mail.example.com", "vpn.example.com"}},
+		{&QueryFilter{Until: time.Date(2019, 1, 15, 0, 0, 0, 0, time.UTC), RecordTypes: []string{RecordA}},
+			[]string{"d111.cloudfront.net"}},
+	}
+
+	for _, handler := range []DataHandler{graph, db} {
+		for _, opt := range opts {
+			opt.UUID = "enum1"
+			if err := handler.Insert(opt); err != nil {
+				t.Fatalf("%s: Failed to insert the %s data operation: %v", handler, opt.Type, err)
+			}
+		}
+
+		for i, test := range tests {
+			results, err := handler.Query("enum1", test.filter)
+			if err != nil {
+				t.Fatalf("%s: Query %d failed: %v", handler, i+1, err)
+			}
+			if len(results) != len(test.names) {
+				t.Errorf("%s: Query %d returned %d names, expected %d", handler, i+1, len(results), len(test.names))
+				continue
+			}
+			for j, r := range results {
+				if r.Name != test.names[j] {
+					t.Errorf("%s: Query %d returned %s, expected %s", handler, i+1, r.Name, test.names[j])
+				}
+			}
+		}
+
+		results, _ := handler.Query("enum1", &QueryFilter{CNAMETarget: "*.CloudFront.net"})
+		if len(results) != 1 || len(results[0].Addresses) != 1 || results[0].Addresses[0].ASN != 16509 ||
+			results[0].Domain != "example.com" || results[0].CNAMETargets[0] != "d111.cloudfront.net" {
+			t.Errorf("%s: The query result was not complete: %+v", handler, results)
+		}
+	}
+
+	// Handlers unable to perform queries must report it
+	if _, err := NewDataOptsHandler(ioutil.Discard).Query("enum1", nil); err == nil {
+		t.Errorf("The data operations handler did not report that queries are not supported")
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy selects the enumerations kept in a graph database.
+// A zero value disables the corresponding rule, and an enumeration is only
+// expired when it is not kept by any of the rules that have been set.
+type RetentionPolicy struct {
+	// Keep the last N enumerations performed for each domain
+	KeepLast int
+
+	// Keep the enumerations with findings during the last N days
+	KeepDays int
+
+	// Enumerations that are always kept (e.g. the enumeration in progress)
+	Exclude []string
+}
+
+// Enabled returns true when the policy has at least one rule set.
+func (p *RetentionPolicy) Enabled() bool {
+	return p != nil && (p.KeepLast > 0 || p.KeepDays > 0)
+}
+
+type retentionEnum struct {
+	uuid    string
+	latest  time.Time
+	domains []string
+}
+
+// ExpiredEnumerations returns the enumerations in the handler that are not kept by the policy.
+// When domains are provided, only the enumerations involving those domains are considered.
+func ExpiredEnumerations(handler DataHandler, policy *RetentionPolicy, domains []string, now time.Time) []string {
+	if !policy.Enabled() {
+		return nil
+	}
+
+	var enums []*retentionEnum
+	for _, uuid := range handler.EnumerationList() {
+		e := &retentionEnum{
+			uuid:    uuid,
+			domains: handler.EnumerationDomains(uuid),
+		}
+		if len(domains) > 0 && !retentionInScope(e.domains, domains) {
+			continue
+		}
+		_, e.latest = handler.EnumerationDateRange(uuid)
+		enums = append(enums, e)
+	}
+	// The most recent enumerations come first
+	sort.SliceStable(enums, func(i, j int) bool {
+		return enums[i].latest.After(enums[j].latest)
+	})
+
+	keep := make(map[string]struct{})
+	for _, uuid := range policy.Exclude {
+		keep[uuid] = struct{}{}
+	}
+	if policy.KeepLast > 0 {
+		counts := make(map[string]int)
+
+		for _, e := range enums {
+			for _, d := range e.domains {
+				d = strings.ToLower(d)
+				if counts[d] < policy.KeepLast {
+					keep[e.uuid] = struct{}{}
+				}
+				counts[d]++
+			}
+		}
+	}
+	if policy.KeepDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.KeepDays)
+
+		for _, e := range enums {
+			if !e.latest.Before(cutoff) {
+				keep[e.uuid] = struct{}{}
+			}
+		}
+	}
+
+	var expired []string
+	for _, e := range enums {
+		if _, found := keep[e.uuid]; !found {
+			expired = append(expired, e.uuid)
+		}
+	}
+	return expired
+}
+
+// ApplyRetention deletes the enumerations not kept by the policy and returns their IDs.
+// When domains are provided, only the enumerations involving those domains are considered.
+func ApplyRetention(handler DataHandler, policy *RetentionPolicy, domains []string) ([]string, error) {
+	var deleted []string
+
+	for _, uuid := range ExpiredEnumerations(handler, policy, domains, time.Now()) {
+		if err := handler.DeleteEnumeration(uuid); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, uuid)
+	}
+	return deleted, nil
+}
+
+func retentionInScope(enumDomains, domains []string) bool {
+	for _, d := range enumDomains {
+		if containsFold(domains, d) {
+			return true
+		}
+	}
+	return false
+}
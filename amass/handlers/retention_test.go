@@ -0,0 +1,115 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retention")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	graph := NewGraph(filepath.Join(dir, "graph"))
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	db, err := NewSQL(SQLDriverSQLite, filepath.Join(dir, DefaultSQLiteFile), nil)
+	if err != nil {
+		t.Fatalf("Failed to create the SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	enums := []struct {
+		uuid      string
+		domain    string
+		timestamp string
+	}{
+		{"enum1", "example.com", "2019-01-01T00:00:00Z"},
+		{"enum2", "example.com", "2019-02-01T00:00:00Z"},
+		{"enum3", "example.com", "2019-03-01T00:00:00Z"},
+		{"enum4", "example.org", "2019-01-15T00:00:00Z"},
+	}
+
+	now, _ := time.Parse(time.RFC3339, "2019-03-10T00:00:00Z")
+	tests := []struct {
+		policy  *RetentionPolicy
+		domains []string
+		expired []string
+	}{
+		{&RetentionPolicy{}, nil, nil},
+		{&RetentionPolicy{KeepLast: 1}, nil, []string{"enum1", "enum2"}},
+		{&RetentionPolicy{KeepLast: 1}, []string{"example.org"}, nil},
+		{&RetentionPolicy{KeepDays: 30}, nil, []string{"enum1", "enum2", "enum4"}},
+		{&RetentionPolicy{KeepLast: 1, KeepDays: 40}, nil, []string{"enum1"}},
+		{&RetentionPolicy{KeepLast: 1, Exclude: []string{"enum1"}}, nil, []string{"enum2"}},
+	}
+
+	for _, handler := range []DataHandler{graph, db} {
+		for _, e := range enums {
+			for _, opt := range []*DataOptsParams{
+				{Type: OptDomain, Domain: e.domain, Tag: "dns", Source: "Forward DNS"},
+				{Type: OptA, Name: "www." + e.domain, Domain: e.domain, Address: "192.0.2.10",
+					Tag: "dns", Source: "Forward DNS"},
+				{Type: OptInfrastructure, Address: "192.0.2.10", ASN: 64496,
+					CIDR: "192.0.2.0/24", Description: "EXAMPLE-AS"},
+			} {
+				opt.UUID = e.uuid
+				opt.Timestamp = e.timestamp
+				if err := handler.Insert(opt); err != nil {
+					t.Fatalf("%s: Failed to insert the %s data operation: %v", handler, opt.Type, err)
+				}
+			}
+		}
+
+		for _, test := range tests {
+			expired := ExpiredEnumerations(handler, test.policy, test.domains, now)
+			sort.Strings(expired)
+			if !equalStrings(expired, test.expired) {
+				t.Errorf("%s: The policy %+v expired %v, expected %v", handler, test.policy, expired, test.expired)
+			}
+		}
+
+		if err := handler.DeleteEnumeration("enum2"); err != nil {
+			t.Fatalf("%s: Failed to delete the enumeration: %v", handler, err)
+		}
+		list := handler.EnumerationList()
+		sort.Strings(list)
+		if !equalStrings(list, []string{"enum1", "enum3", "enum4"}) {
+			t.Errorf("%s: The enumeration list after the deletion was %v", handler, list)
+		}
+		if out := handler.GetOutput("enum2", true); len(out) != 0 {
+			t.Errorf("%s: The deleted enumeration returned %d names", handler, len(out))
+		}
+
+		if err := handler.Compact(); err != nil {
+			t.Fatalf("%s: Failed to compact the database: %v", handler, err)
+		}
+		if out := handler.GetOutput("enum3", true); len(out) != 1 || out[0].Name != "www.example.com" {
+			t.Errorf("%s: The compacted database returned %v", handler, out)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
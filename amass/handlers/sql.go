@@ -0,0 +1,1280 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils"
+	"github.com/root-secure/Amass/amass/utils/viz"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"           // Need the postgres driver
+	_ "github.com/mattn/go-sqlite3" // Need the sqlite3 driver
+)
+
+// These strings identify the database drivers supported by the SQL data handler.
+const (
+	SQLDriverSQLite   = "sqlite3"
+	SQLDriverPostgres = "postgres"
+)
+
+// DefaultSQLiteFile is the file name used for the SQLite database in the output directory.
+const DefaultSQLiteFile = "amass.sqlite"
+
+// The SQL data handler keeps each table keyed by the enumeration UUID, and nodes are identified
+// by their value (DNS name, address, CIDR, ASN, certificate fingerprint or URL), so the tables
+// can be joined against each other and against other relational data:
+//
+// enumerations: uuid, enum_type ('enum' or 'intel')
+// names: enum_uuid, name, domain, type (domain, subdomain, ns, mx, ptr or sender), timestamp,
+//   tag, source, is_read
+// addresses: enum_uuid, addr, type (IPv4 or IPv6), timestamp
+// netblocks: enum_uuid, cidr, timestamp
+// asns: enum_uuid, asn, description, timestamp
+// certificates: enum_uuid, fingerprint, common_name, organization, org_unit, issuer,
+//   not_before, not_after, serial, timestamp, tag, source
+// urls: enum_uuid, url, status, title, server, redirects, timestamp, tag, source
+// relations: enum_uuid, from_node, relation, to_node (the same edges as the Amass graph,
+//   e.g. root_of, cname_to, a_to, aaaa_to, ptr_to, ns_to, mx_to, contains and has_prefix)
+// properties: enum_uuid, node, property, value (e.g. takeover, SPF, DMARC and RDAP details)
+//
+// The asset inventory is kept by tables that are not keyed by the enumeration UUID:
+//
+// assets: asset, type (name, address or netblock), first_seen, last_seen
+// asset_enumerations: asset, enum_uuid
+// asset_addresses: name, addr, first_seen, last_seen
+// annotations: asset, owner, criticality, false_positive, decommissioned, notes, updated
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS enumerations (
+		uuid TEXT NOT NULL PRIMARY KEY,
+		enum_type TEXT NOT NULL DEFAULT 'enum'
+	)`,
+	`CREATE TABLE IF NOT EXISTS names (
+		enum_uuid TEXT NOT NULL,
+		name TEXT NOT NULL,
+		domain TEXT NOT NULL,
+		type TEXT NOT NULL,
+		timestamp TEXT NOT NULL,
+		tag TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL DEFAULT '',
+		is_read INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (enum_uuid, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS addresses (
+		enum_uuid TEXT NOT NULL,
+		addr TEXT NOT NULL,
+		type TEXT NOT NULL,
+		timestamp TEXT NOT NULL,
+		PRIMARY KEY (enum_uuid, addr)
+	)`,
+	`CREATE TABLE IF NOT EXISTS netblocks (
+		enum_uuid TEXT NOT NULL,
+		cidr TEXT NOT NULL,
+		timestamp TEXT NOT NULL,
+		PRIMARY KEY (enum_uuid, cidr)
+	)`,
+	`CREATE TABLE IF NOT EXISTS asns (
+		enum_uuid TEXT NOT NULL,
+		asn INTEGER NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		timestamp TEXT NOT NULL,
+		PRIMARY KEY (enum_uuid, asn)
+	)`,
+	`CREATE TABLE IF NOT EXISTS certificates (
+		enum_uuid TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		common_name TEXT NOT NULL DEFAULT '',
+		organization TEXT NOT NULL DEFAULT '',
+		org_unit TEXT NOT NULL DEFAULT '',
+		issuer TEXT NOT NULL DEFAULT '',
+		not_before TEXT NOT NULL DEFAULT '',
+		not_after TEXT NOT NULL DEFAULT '',
+		serial TEXT NOT NULL DEFAULT '',
+		timestamp TEXT NOT NULL,
+		tag TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (enum_uuid, fingerprint)
+	)`,
+	`CREATE TABLE IF NOT EXISTS urls (
+		enum_uuid TEXT NOT NULL,
+		url TEXT NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		title TEXT NOT NULL DEFAULT '',
+		server TEXT NOT NULL DEFAULT '',
+		redirects TEXT NOT NULL DEFAULT '',
+		timestamp TEXT NOT NULL,
+		tag TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (enum_uuid, url)
+	)`,
+	`CREATE TABLE IF NOT EXISTS relations (
+		enum_uuid TEXT NOT NULL,
+		from_node TEXT NOT NULL,
+		relation TEXT NOT NULL,
+		to_node TEXT NOT NULL,
+		PRIMARY KEY (enum_uuid, from_node, relation, to_node)
+	)`,
+	`CREATE INDEX IF NOT EXISTS relations_to_node ON relations (enum_uuid, to_node, relation)`,
+	`CREATE TABLE IF NOT EXISTS properties (
+		enum_uuid TEXT NOT NULL,
+		node TEXT NOT NULL,
+		property TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (enum_uuid, node, property, value)
+	)`,
+	`CREATE TABLE IF NOT EXISTS assets (
+		asset TEXT NOT NULL PRIMARY KEY,
+		type TEXT NOT NULL,
+		first_seen TEXT NOT NULL,
+		last_seen TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS asset_enumerations (
+		asset TEXT NOT NULL,
+		enum_uuid TEXT NOT NULL,
+		PRIMARY KEY (asset, enum_uuid)
+	)`,
+	`CREATE INDEX IF NOT EXISTS asset_enumerations_uuid ON asset_enumerations (enum_uuid)`,
+	`CREATE TABLE IF NOT EXISTS asset_addresses (
+		name TEXT NOT NULL,
+		addr TEXT NOT NULL,
+		first_seen TEXT NOT NULL,
+		last_seen TEXT NOT NULL,
+		PRIMARY KEY (name, addr)
+	)`,
+	`CREATE TABLE IF NOT EXISTS annotations (
+		asset TEXT NOT NULL PRIMARY KEY,
+		owner TEXT NOT NULL DEFAULT '',
+		criticality TEXT NOT NULL DEFAULT '',
+		false_positive INTEGER NOT NULL DEFAULT 0,
+		decommissioned INTEGER NOT NULL DEFAULT 0,
+		notes TEXT NOT NULL DEFAULT '',
+		updated TEXT NOT NULL
+	)`,
+}
+
+// The tables holding the data collected by each enumeration.
+var sqlEnumTables = []string{
+	"names", "addresses", "netblocks", "asns", "certificates", "urls", "relations", "properties",
+}
+
+// SQL is the client object for a SQLite or PostgreSQL database connection.
+type SQL struct {
+	sync.Mutex
+	db     *sqlx.DB
+	driver string
+}
+
+// NewSQL returns a client object that implements the Amass DataHandler interface.
+// The source param is the path to the database file for sqlite3, and typically looks
+// like the following for postgres: host=localhost user=amass dbname=amass sslmode=disable
+func NewSQL(driver, source string, l *log.Logger) (*SQL, error) {
+	if driver != SQLDriverSQLite && driver != SQLDriverPostgres {
+		return nil, fmt.Errorf("SQL: Unsupported database driver: %s", driver)
+	}
+
+	if driver == SQLDriverSQLite {
+		if source == "" {
+			return nil, errors.New("SQL: No SQLite database file provided")
+		}
+		// If the directory does not yet exist, create it
+		if err := os.MkdirAll(filepath.Dir(source), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sqlx.Connect(driver, source)
+	if err != nil {
+		if l != nil {
+			l.Println("SQL: Failed to connect with the database: " + err.Error())
+		}
+		return nil, err
+	}
+	// SQLite allows a single writer at a time
+	if driver == SQLDriverSQLite {
+		db.SetMaxOpenConns(1)
+	}
+
+	for _, stmt := range sqlSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("SQL: Failed to create the schema: %v", err)
+		}
+	}
+	return &SQL{
+		db:     db,
+		driver: driver,
+	}, nil
+}
+
+// Close implements the Amass DataHandler interface.
+func (s *SQL) Close() {
+	s.db.Close()
+}
+
+// String returns a description for the SQL client object.
+func (s *SQL) String() string {
+	return "SQL Database Handler"
+}
+
+func (s *SQL) exec(tx *sqlx.Tx, query string, args ...interface{}) (int64, error) {
+	result, err := tx.Exec(tx.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQL) queryStrings(query string, args ...interface{}) []string {
+	var results []string
+
+	if err := s.db.Select(&results, s.db.Rebind(query), args...); err != nil {
+		return nil
+	}
+	return results
+}
+
+func (s *SQL) queryString(query string, args ...interface{}) string {
+	var result string
+
+	if err := s.db.Get(&result, s.db.Rebind(query+" LIMIT 1"), args...); err != nil {
+		return ""
+	}
+	return result
+}
+
+func (s *SQL) propertyValue(node, pname, uuid string) string {
+	return s.queryString("SELECT value FROM properties WHERE enum_uuid = ? "+
+		"AND node = ? AND property = ? ORDER BY value", uuid, node, pname)
+}
+
+// relationTargets returns the nodes reached by the relation leaving the node.
+func (s *SQL) relationTargets(node, relation, uuid string) []string {
+	return s.queryStrings("SELECT to_node FROM relations WHERE enum_uuid = ? "+
+		"AND from_node = ? AND relation = ? ORDER BY to_node", uuid, node, relation)
+}
+
+func (s *SQL) relationTarget(node, relation, uuid string) string {
+	return s.queryString("SELECT to_node FROM relations WHERE enum_uuid = ? "+
+		"AND from_node = ? AND relation = ? ORDER BY to_node", uuid, node, relation)
+}
+
+// relationSource returns a node with the relation arriving at the node.
+func (s *SQL) relationSource(node, relation, uuid string) string {
+	return s.queryString("SELECT from_node FROM relations WHERE enum_uuid = ? "+
+		"AND to_node = ? AND relation = ? ORDER BY from_node", uuid, node, relation)
+}
+
+// Insert implements the Amass DataHandler interface.
+func (s *SQL) Insert(data *DataOptsParams) error {
+	s.Lock()
+	defer s.Unlock()
+
+	// Annotations are not part of an enumeration
+	if data.Type == OptAnnotation {
+		return s.insertAnnotation(data)
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(tx, "INSERT INTO enumerations (uuid, enum_type) VALUES (?, ?) "+
+		"ON CONFLICT DO NOTHING", data.UUID, EnumTypeEnum)
+	if err == nil {
+		switch data.Type {
+		case OptDomain:
+			err = s.insertDomain(tx, data)
+		case OptSubdomain:
+			err = s.insertSubdomain(tx, data)
+		case OptCNAME:
+			err = s.insertCNAME(tx, data)
+		case OptA:
+			err = s.insertAddress(tx, "a_to", data)
+		case OptAAAA:
+			err = s.insertAddress(tx, "aaaa_to", data)
+		case OptPTR:
+			err = s.insertPTR(tx, data)
+		case OptSRV:
+			err = s.insertSRV(tx, data)
+		case OptNS:
+			err = s.insertNameserver(tx, "ns", data)
+		case OptMX:
+			err = s.insertNameserver(tx, "mx", data)
+		case OptInfrastructure:
+			err = s.insertInfrastructure(tx, data)
+		case OptCertificate:
+			err = s.insertCertificate(tx, data)
+		case OptHTTP:
+			err = s.insertHTTP(tx, data)
+		case OptTakeover:
+			err = s.insertTakeover(tx, data)
+		case OptEmailPosture:
+			err = s.insertEmailPosture(tx, data)
+		case OptEmailSender:
+			err = s.insertEmailSender(tx, data)
+		case OptRDAP:
+			err = s.insertRDAP(tx, data)
+		case OptIntel:
+			err = s.insertIntel(tx, data)
+		}
+	}
+	if err == nil {
+		err = s.updateAssets(tx, data)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQL) insertRelation(tx *sqlx.Tx, from, relation, to, uuid string) error {
+	_, err := s.exec(tx, "INSERT INTO relations (enum_uuid, from_node, relation, to_node) "+
+		"VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING", uuid, from, relation, to)
+	return err
+}
+
+func (s *SQL) insertProperty(tx *sqlx.Tx, node, pname, value, uuid string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := s.exec(tx, "INSERT INTO properties (enum_uuid, node, property, value) "+
+		"VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING", uuid, node, pname, value)
+	return err
+}
+
+func (s *SQL) hasProperty(tx *sqlx.Tx, node, pname, uuid string) bool {
+	var count int
+
+	err := tx.Get(&count, tx.Rebind("SELECT COUNT(*) FROM properties WHERE enum_uuid = ? "+
+		"AND node = ? AND property = ?"), uuid, node, pname)
+	return err == nil && count > 0
+}
+
+func (s *SQL) insertDomain(tx *sqlx.Tx, data *DataOptsParams) error {
+	if data.Domain == "" {
+		return errors.New("SQL: insertDomain: no domain name provided")
+	}
+
+	_, err := s.exec(tx, "INSERT INTO names (enum_uuid, name, domain, type, timestamp, tag, source) "+
+		"VALUES (?, ?, ?, 'domain', ?, ?, ?) ON CONFLICT DO NOTHING", data.UUID,
+		data.Domain, data.Domain, data.Timestamp, data.Tag, data.Source)
+	return err
+}
+
+func (s *SQL) insertSubdomain(tx *sqlx.Tx, data *DataOptsParams) error {
+	return s.insertSub(tx, "subdomain", data)
+}
+
+func (s *SQL) insertSub(tx *sqlx.Tx, label string, data *DataOptsParams) error {
+	if data.Name == "" {
+		return errors.New("SQL: insertSub: no name provided")
+	}
+	if err := s.insertDomain(tx, data); err != nil {
+		return err
+	}
+	if data.Name == data.Domain {
+		return nil
+	}
+
+	inserted, err := s.exec(tx, "INSERT INTO names (enum_uuid, name, domain, type, timestamp, tag, source) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING", data.UUID,
+		data.Name, data.Domain, label, data.Timestamp, data.Tag, data.Source)
+	if err != nil || inserted == 0 {
+		return err
+	}
+	// Create the edge between the domain and the subdomain
+	return s.insertRelation(tx, data.Domain, "root_of", data.Name, data.UUID)
+}
+
+func (s *SQL) insertCNAME(tx *sqlx.Tx, data *DataOptsParams) error {
+	if err := s.insertSubdomain(tx, data); err != nil {
+		return err
+	}
+
+	err := s.insertSubdomain(tx, &DataOptsParams{
+		UUID:      data.UUID,
+		Timestamp: data.Timestamp,
+		Name:      data.TargetName,
+		Domain:    data.TargetDomain,
+		Tag:       data.Tag,
+		Source:    data.Source,
+	})
+	if err != nil {
+		return err
+	}
+	// Create the edge between the CNAME and the subdomain
+	return s.insertRelation(tx, data.Name, "cname_to", data.TargetName, data.UUID)
+}
+
+func (s *SQL) insertAddrNode(tx *sqlx.Tx, addr, timestamp, uuid string) error {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return fmt.Errorf("SQL: insertAddrNode: invalid address provided: %s", addr)
+	}
+
+	atype := "IPv6"
+	if ip.To4() != nil {
+		atype = "IPv4"
+	}
+
+	_, err := s.exec(tx, "INSERT INTO addresses (enum_uuid, addr, type, timestamp) "+
+		"VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING", uuid, addr, atype, timestamp)
+	return err
+}
+
+func (s *SQL) insertAddress(tx *sqlx.Tx, relation string, data *DataOptsParams) error {
+	if err := s.insertSubdomain(tx, data); err != nil {
+		return err
+	}
+	if err := s.insertAddrNode(tx, data.Address, data.Timestamp, data.UUID); err != nil {
+		return err
+	}
+	// Create the edge between the DNS name and the address
+	return s.insertRelation(tx, data.Name, relation, data.Address, data.UUID)
+}
+
+func (s *SQL) insertPTR(tx *sqlx.Tx, data *DataOptsParams) error {
+	if err := s.insertSub(tx, "ptr", data); err != nil {
+		return err
+	}
+
+	err := s.insertSubdomain(tx, &DataOptsParams{
+		UUID:      data.UUID,
+		Timestamp: data.Timestamp,
+		Name:      data.TargetName,
+		Domain:    data.Domain,
+		Tag:       data.Tag,
+		Source:    data.Source,
+	})
+	if err != nil {
+		return err
+	}
+	// Create the edge between the PTR and the subdomain
+	return s.insertRelation(tx, data.Name, "ptr_to", data.TargetName, data.UUID)
+}
+
+func (s *SQL) insertSRV(tx *sqlx.Tx, data *DataOptsParams) error {
+	if err := s.insertSubdomain(tx, data); err != nil {
+		return err
+	}
+
+	for _, name := range []string{data.Service, data.TargetName} {
+		err := s.insertSubdomain(tx, &DataOptsParams{
+			UUID:      data.UUID,
+			Timestamp: data.Timestamp,
+			Name:      name,
+			Domain:    data.Domain,
+			Tag:       data.Tag,
+			Source:    data.Source,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// Create the edge between the service and the subdomain
+	if err := s.insertRelation(tx, data.Service, "service_for", data.Name, data.UUID); err != nil {
+		return err
+	}
+	// Create the edge between the service and the target
+	return s.insertRelation(tx, data.Service, "srv_to", data.TargetName, data.UUID)
+}
+
+// insertNameserver handles both NS and MX records, since the label is also the relation prefix.
+func (s *SQL) insertNameserver(tx *sqlx.Tx, label string, data *DataOptsParams) error {
+	if err := s.insertSubdomain(tx, data); err != nil {
+		return err
+	}
+	// A subdomain already known becomes a nameserver or mail server node
+	swapped, err := s.exec(tx, "UPDATE names SET type = ? WHERE enum_uuid = ? AND name = ? "+
+		"AND type <> 'domain'", label, data.UUID, data.TargetName)
+	if err != nil {
+		return err
+	}
+	if swapped == 0 {
+		err := s.insertSub(tx, label, &DataOptsParams{
+			UUID:      data.UUID,
+			Timestamp: data.Timestamp,
+			Name:      data.TargetName,
+			Domain:    data.TargetDomain,
+			Tag:       data.Tag,
+			Source:    data.Source,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// Create the edge between the subdomain and the target
+	return s.insertRelation(tx, data.Name, label+"_to", data.TargetName, data.UUID)
+}
+
+func (s *SQL) insertNetblock(tx *sqlx.Tx, cidr, timestamp, uuid string) error {
+	_, err := s.exec(tx, "INSERT INTO netblocks (enum_uuid, cidr, timestamp) "+
+		"VALUES (?, ?, ?) ON CONFLICT DO NOTHING", uuid, cidr, timestamp)
+	return err
+}
+
+func (s *SQL) insertAS(tx *sqlx.Tx, asn int, desc, timestamp, uuid string) error {
+	_, err := s.exec(tx, "INSERT INTO asns (enum_uuid, asn, description, timestamp) "+
+		"VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING", uuid, asn, desc, timestamp)
+	return err
+}
+
+func (s *SQL) insertInfrastructure(tx *sqlx.Tx, data *DataOptsParams) error {
+	if data.CIDR == "" || data.ASN == 0 {
+		return errors.New("SQL: insertInfrastructure: no netblock or ASN provided")
+	}
+
+	if err := s.insertNetblock(tx, data.CIDR, data.Timestamp, data.UUID); err != nil {
+		return err
+	}
+	// Create the edge between the CIDR and the address
+	if err := s.insertRelation(tx, data.CIDR, "contains", data.Address, data.UUID); err != nil {
+		return err
+	}
+
+	if err := s.insertAS(tx, data.ASN, data.Description, data.Timestamp, data.UUID); err != nil {
+		return err
+	}
+	// Create the edge between the AS and the netblock
+	return s.insertRelation(tx, strconv.Itoa(data.ASN), "has_prefix", data.CIDR, data.UUID)
+}
+
+func (s *SQL) insertCertificate(tx *sqlx.Tx, data *DataOptsParams) error {
+	if data.Fingerprint == "" {
+		return errors.New("SQL: insertCertificate: no fingerprint provided")
+	}
+
+	inserted, err := s.exec(tx, "INSERT INTO certificates (enum_uuid, fingerprint, common_name, "+
+		"organization, org_unit, issuer, not_before, not_after, serial, timestamp, tag, source) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING", data.UUID,
+		data.Fingerprint, data.Name, data.Organization, data.OrgUnit, data.Issuer, data.NotBefore,
+		data.NotAfter, data.Serial, data.Timestamp, data.Tag, data.Source)
+	if err != nil {
+		return err
+	}
+	if inserted > 0 {
+		for _, name := range data.Names {
+			if err := s.insertProperty(tx, data.Fingerprint, "cert_name", name, data.UUID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if data.Address == "" {
+		return nil
+	}
+	if err := s.insertAddrNode(tx, data.Address, data.Timestamp, data.UUID); err != nil {
+		return err
+	}
+	// Create the edge between the address and the certificate
+	if err := s.insertRelation(tx, data.Address, "has_cert", data.Fingerprint, data.UUID); err != nil {
+		return err
+	}
+	if data.Port != 0 {
+		if err := s.insertProperty(tx, data.Fingerprint, "port", strconv.Itoa(data.Port), data.UUID); err != nil {
+			return err
+		}
+	}
+	return s.insertProperty(tx, data.Fingerprint, "server_name", data.ServerName, data.UUID)
+}
+
+func (s *SQL) insertHTTP(tx *sqlx.Tx, data *DataOptsParams) error {
+	if data.URL == "" {
+		return errors.New("SQL: insertHTTP: no URL provided")
+	}
+	if err := s.insertSubdomain(tx, data); err != nil {
+		return err
+	}
+
+	// URLs cannot contain spaces, so the chain is kept in order as a single value
+	_, err := s.exec(tx, "INSERT INTO urls (enum_uuid, url, status, title, server, redirects, "+
+		"timestamp, tag, source) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		data.UUID, data.URL, data.StatusCode, data.Title, data.Server,
+		strings.Join(data.Redirects, " "), data.Timestamp, data.Tag, data.Source)
+	if err != nil {
+		return err
+	}
+	// Create the edge between the DNS name and the URL
+	return s.insertRelation(tx, data.Name, "has_url", data.URL, data.UUID)
+}
+
+func (s *SQL) insertProperties(tx *sqlx.Tx, node, uuid string, props [][2]string) error {
+	for _, p := range props {
+		if err := s.insertProperty(tx, node, p[0], p[1], uuid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQL) insertTakeover(tx *sqlx.Tx, data *DataOptsParams) error {
+	if err := s.insertSubdomain(tx, data); err != nil {
+		return err
+	}
+	// Check if the finding has already been recorded for the DNS name
+	if s.hasProperty(tx, data.Name, "takeover_timestamp", data.UUID) {
+		return nil
+	}
+
+	return s.insertProperties(tx, data.Name, data.UUID, [][2]string{
+		{"takeover", data.Reason},
+		{"takeover_target", data.TargetName},
+		{"takeover_provider", data.Provider},
+		{"takeover_evidence", data.Evidence},
+		{"takeover_timestamp", data.Timestamp},
+	})
+}
+
+func (s *SQL) insertEmailPosture(tx *sqlx.Tx, data *DataOptsParams) error {
+	if err := s.insertDomain(tx, data); err != nil {
+		return err
+	}
+	// Check if the posture has already been recorded for the domain
+	if s.hasProperty(tx, data.Domain, "posture_timestamp", data.UUID) {
+		return nil
+	}
+
+	props := [][2]string{
+		{"posture_timestamp", data.Timestamp},
+		{"spf_record", data.SPF},
+		{"spf_lookups", strconv.Itoa(data.SPFLookups)},
+		{"spf_all", data.SPFAll},
+		{"dmarc_record", data.DMARC},
+		{"dmarc_policy", data.DMARCPolicy},
+	}
+	for _, sel := range data.DKIMSelectors {
+		props = append(props, [2]string{"dkim_selector", sel})
+	}
+	for _, issue := range data.Issues {
+		props = append(props, [2]string{"posture_issue", issue})
+	}
+	if err := s.insertProperties(tx, data.Domain, data.UUID, props); err != nil {
+		return err
+	}
+
+	for _, cidr := range data.CIDRs {
+		if err := s.insertSPFNetblock(tx, data.Domain, cidr, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQL) insertEmailSender(tx *sqlx.Tx, data *DataOptsParams) error {
+	if data.Name == "" {
+		return errors.New("SQL: insertEmailSender: no sender name provided")
+	}
+	if err := s.insertDomain(tx, data); err != nil {
+		return err
+	}
+
+	_, err := s.exec(tx, "INSERT INTO names (enum_uuid, name, domain, type, timestamp, tag, source) "+
+		"VALUES (?, ?, '', 'sender', ?, ?, ?) ON CONFLICT DO NOTHING", data.UUID,
+		data.Name, data.Timestamp, data.Tag, data.Source)
+	if err != nil {
+		return err
+	}
+	// Create the edge between the domain and the third-party sender
+	if err := s.insertRelation(tx, data.Domain, "spf_sender", data.Name, data.UUID); err != nil {
+		return err
+	}
+
+	for _, cidr := range data.CIDRs {
+		if err := s.insertSPFNetblock(tx, data.Name, cidr, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQL) insertSPFNetblock(tx *sqlx.Tx, from, cidr string, data *DataOptsParams) error {
+	if err := s.insertNetblock(tx, cidr, data.Timestamp, data.UUID); err != nil {
+		return err
+	}
+	// Create the edge between the domain or sender and the authorized netblock
+	return s.insertRelation(tx, from, "spf_authorizes", cidr, data.UUID)
+}
+
+func (s *SQL) insertRDAP(tx *sqlx.Tx, data *DataOptsParams) error {
+	// Identify the nodes receiving the registration data
+	var nodes []string
+	switch {
+	case len(data.CIDRs) > 0:
+		for _, cidr := range data.CIDRs {
+			if err := s.insertNetblock(tx, cidr, data.Timestamp, data.UUID); err != nil {
+				return err
+			}
+			nodes = append(nodes, cidr)
+		}
+	case data.ASN != 0:
+		if err := s.insertAS(tx, data.ASN, data.Name, data.Timestamp, data.UUID); err != nil {
+			return err
+		}
+		nodes = append(nodes, strconv.Itoa(data.ASN))
+	case data.Domain != "":
+		if err := s.insertDomain(tx, data); err != nil {
+			return err
+		}
+		nodes = append(nodes, data.Domain)
+	default:
+		return errors.New("SQL: insertRDAP: no domain, ASN or netblock provided")
+	}
+
+	for _, node := range nodes {
+		// Check if the registration data has already been recorded for the node
+		if s.hasProperty(tx, node, "rdap_timestamp", data.UUID) {
+			continue
+		}
+
+		props := [][2]string{
+			{"rdap_timestamp", data.Timestamp},
+			{"rdap_url", data.URL},
+			{"rdap_handle", data.Handle},
+			{"rdap_name", data.Name},
+			{"rdap_country", data.Country},
+			{"registrant", data.Registrant},
+			{"registrar", data.Registrar},
+			{"abuse_contact", data.AbuseContact},
+			{"registered", data.Registered},
+			{"expires", data.Expires},
+		}
+		for _, ns := range data.Nameservers {
+			props = append(props, [2]string{"rdap_nameserver", ns})
+		}
+		if err := s.insertProperties(tx, node, data.UUID, props); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQL) insertIntel(tx *sqlx.Tx, data *DataOptsParams) error {
+	if err := s.insertDomain(tx, data); err != nil {
+		return err
+	}
+	// Identify the enumeration as an intelligence collection
+	_, err := s.exec(tx, "UPDATE enumerations SET enum_type = ? WHERE uuid = ?", EnumTypeIntel, data.UUID)
+	if err != nil {
+		return err
+	}
+	if err := s.insertProperty(tx, data.Domain, "intel_method", data.Method, data.UUID); err != nil {
+		return err
+	}
+
+	if data.Address == "" {
+		return nil
+	}
+	if err := s.insertAddrNode(tx, data.Address, data.Timestamp, data.UUID); err != nil {
+		return err
+	}
+	// Create the edge between the domain and the address it was discovered from
+	if err := s.insertRelation(tx, data.Domain, "discovered_from", data.Address, data.UUID); err != nil {
+		return err
+	}
+
+	if data.CIDR == "" || data.ASN == 0 {
+		return nil
+	}
+	return s.insertInfrastructure(tx, data)
+}
+
+// EnumerationList returns a list of enumeration IDs found in the data.
+func (s *SQL) EnumerationList() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.queryStrings("SELECT DISTINCT enum_uuid FROM names WHERE type = 'domain' ORDER BY enum_uuid")
+}
+
+// EnumerationType returns EnumTypeIntel when the data was collected by the intel subcommand.
+func (s *SQL) EnumerationType(uuid string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	if t := s.queryString("SELECT enum_type FROM enumerations WHERE uuid = ?", uuid); t != "" {
+		return t
+	}
+	return EnumTypeEnum
+}
+
+// EnumerationDomains returns the domains that were involved in the provided enumeration.
+func (s *SQL) EnumerationDomains(uuid string) []string {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.enumerationDomains(uuid)
+}
+
+func (s *SQL) enumerationDomains(uuid string) []string {
+	return s.queryStrings("SELECT name FROM names WHERE enum_uuid = ? "+
+		"AND type = 'domain' ORDER BY name", uuid)
+}
+
+// EnumerationDateRange returns the date range associated with the provided enumeration UUID.
+func (s *SQL) EnumerationDateRange(uuid string) (time.Time, time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	var query []string
+	var args []interface{}
+	for _, table := range []string{"names", "addresses", "netblocks", "asns", "certificates", "urls"} {
+		query = append(query, "SELECT timestamp FROM "+table+" WHERE enum_uuid = ?")
+		args = append(args, uuid)
+	}
+
+	first := true
+	var earliest, latest time.Time
+	for _, timestamp := range s.queryStrings(strings.Join(query, " UNION "), args...) {
+		tt, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			continue
+		}
+		if first {
+			earliest = tt
+			latest = tt
+			first = false
+			continue
+		}
+		if tt.Before(earliest) {
+			earliest = tt
+		}
+		if tt.After(latest) {
+			latest = tt
+		}
+	}
+	return earliest, latest
+}
+
+// GetOutput returns new findings within the enumeration.
+func (s *SQL) GetOutput(uuid string, marked bool) []*core.Output {
+	var results []*core.Output
+
+	s.StreamOutput(uuid, marked, func(o *core.Output) bool {
+		results = append(results, o)
+		return true
+	})
+	return results
+}
+
+// StreamOutput implements the Amass DataHandler interface.
+// The database is locked until the stream completes, so fn must not call the SQL methods.
+func (s *SQL) StreamOutput(uuid string, marked bool, fn func(*core.Output) bool) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, domain := range s.enumerationDomains(uuid) {
+		for _, name := range s.getSubdomainNames(domain, uuid, marked) {
+			if o := s.buildOutput(name, uuid); o != nil {
+				o.Domain = domain
+				if !fn(o) {
+					return nil
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SQL) getSubdomainNames(domain, uuid string, marked bool) []string {
+	names := []string{domain}
+
+	query := "SELECT r.to_node FROM relations r JOIN names n ON n.enum_uuid = r.enum_uuid " +
+		"AND n.name = r.to_node WHERE r.enum_uuid = ? AND r.from_node = ? AND r.relation = 'root_of' " +
+		"AND n.type IN ('subdomain', 'ns', 'mx')"
+	if !marked {
+		// Only the DNS name related nodes that have not already been read
+		query += " AND n.is_read = 0"
+	}
+
+	for _, sub := range s.queryStrings(query+" ORDER BY r.to_node", uuid, domain) {
+		// Check for a SRV name
+		if srv := s.relationTarget(sub, "srv_to", uuid); srv != "" {
+			names = utils.UniqueAppend(names, srv)
+		}
+		// Grab all the CNAMEs chained to this subdomain name
+		names = utils.UniqueAppend(names, s.getCNAMEs(sub, uuid)...)
+	}
+	return names
+}
+
+func (s *SQL) getCNAMEs(sub, uuid string) []string {
+	names := []string{sub}
+
+	cname := sub
+	for i := 0; i < 10; i++ {
+		target := s.relationTarget(cname, "cname_to", uuid)
+		if target == "" {
+			break
+		}
+		// Traverse to the next CNAME
+		cname = target
+		names = append(names, target)
+	}
+	return names
+}
+
+func (s *SQL) buildOutput(sub, uuid string) *core.Output {
+	var row struct {
+		Timestamp string `db:"timestamp"`
+		Tag       string `db:"tag"`
+		Source    string `db:"source"`
+	}
+
+	err := s.db.Get(&row, s.db.Rebind("SELECT timestamp, tag, source FROM names "+
+		"WHERE enum_uuid = ? AND name = ?"), uuid, sub)
+	if err != nil {
+		return nil
+	}
+	ts, err := time.Parse(time.RFC3339, row.Timestamp)
+	if err != nil {
+		return nil
+	}
+	output := &core.Output{
+		Timestamp: ts,
+		Name:      sub,
+		Tag:       row.Tag,
+		Source:    row.Source,
+	}
+	// Traverse CNAME and SRV records
+	target := sub
+	for i := 0; i < 10; i++ {
+		next := s.relationTarget(target, "cname_to", uuid)
+		if next == "" {
+			next = s.relationTarget(target, "srv_to", uuid)
+			if next == "" {
+				break
+			}
+		}
+		target = next
+	}
+	// Get all the IPv4 and IPv6 addresses
+	var addrs []string
+	addrs = append(addrs, s.relationTargets(target, "a_to", uuid)...)
+	addrs = append(addrs, s.relationTargets(target, "aaaa_to", uuid)...)
+	// Get the addresses that led the intelligence collection to the name
+	output.Method = s.propertyValue(sub, "intel_method", uuid)
+	addrs = append(addrs, s.relationTargets(sub, "discovered_from", uuid)...)
+
+	for _, addr := range addrs {
+		if i := s.buildAddrInfo(addr, uuid); i != nil {
+			output.Addresses = append(output.Addresses, *i)
+		}
+	}
+
+	if len(output.Addresses) == 0 {
+		return nil
+	}
+	return output
+}
+
+func (s *SQL) buildAddrInfo(addr, uuid string) *core.AddressInfo {
+	ainfo := &core.AddressInfo{Address: net.ParseIP(addr)}
+
+	cidr := s.relationSource(addr, "contains", uuid)
+	if cidr == "" {
+		return nil
+	}
+	ainfo.CIDRStr = cidr
+	_, ainfo.Netblock, _ = net.ParseCIDR(cidr)
+
+	asn := s.relationSource(cidr, "has_prefix", uuid)
+	if asn == "" {
+		return nil
+	}
+	ainfo.ASN, _ = strconv.Atoi(asn)
+	ainfo.Description = s.queryString("SELECT description FROM asns WHERE enum_uuid = ? AND asn = ?", uuid, ainfo.ASN)
+	return ainfo
+}
+
+// Query returns the DNS names within the enumeration that match the filter.
+func (s *SQL) Query(uuid string, filter *QueryFilter) ([]*QueryResult, error) {
+	var results []*QueryResult
+
+	err := s.StreamQuery(uuid, filter, func(r *QueryResult) bool {
+		results = append(results, r)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// StreamQuery implements the Amass DataHandler interface, and provides the names in order.
+// The database is locked until the stream completes, so fn must not call the SQL methods.
+func (s *SQL) StreamQuery(uuid string, filter *QueryFilter, fn func(*QueryResult) bool) error {
+	s.Lock()
+	defer s.Unlock()
+
+	var names []struct {
+		Name      string `db:"name"`
+		Domain    string `db:"domain"`
+		Timestamp string `db:"timestamp"`
+		Tag       string `db:"tag"`
+		Source    string `db:"source"`
+	}
+	err := s.db.Select(&names, s.db.Rebind("SELECT name, domain, timestamp, tag, source FROM names "+
+		"WHERE enum_uuid = ? AND type IN ('domain', 'subdomain', 'ns', 'mx', 'ptr') ORDER BY name"), uuid)
+	if err != nil {
+		return fmt.Errorf("SQL: Failed to query the names: %v", err)
+	}
+
+	for _, n := range names {
+		ts, err := time.Parse(time.RFC3339, n.Timestamp)
+		if err != nil {
+			continue
+		}
+		r := &QueryResult{
+			UUID:      uuid,
+			Timestamp: ts,
+			Name:      n.Name,
+			Domain:    n.Domain,
+			Tag:       n.Tag,
+			Source:    n.Source,
+		}
+
+		// Identify the DNS records held by the name
+		for _, relation := range s.queryStrings("SELECT DISTINCT relation FROM relations "+
+			"WHERE enum_uuid = ? AND from_node = ? ORDER BY relation", uuid, n.Name) {
+			if t, ok := recordPredicates[relation]; ok {
+				r.RecordTypes = append(r.RecordTypes, t)
+			}
+		}
+		// Traverse CNAME and SRV records
+		target := n.Name
+		for i := 0; i < 10; i++ {
+			next := s.relationTarget(target, "cname_to", uuid)
+			if next != "" {
+				r.CNAMETargets = append(r.CNAMETargets, next)
+			} else if next = s.relationTarget(target, "srv_to", uuid); next == "" {
+				break
+			}
+			target = next
+		}
+
+		var addrs []string
+		addrs = append(addrs, s.relationTargets(target, "a_to", uuid)...)
+		addrs = append(addrs, s.relationTargets(target, "aaaa_to", uuid)...)
+		addrs = append(addrs, s.relationTargets(n.Name, "discovered_from", uuid)...)
+		for _, addr := range addrs {
+			if i := s.buildAddrInfo(addr, uuid); i != nil {
+				r.Addresses = append(r.Addresses, *i)
+			} else if ip := net.ParseIP(addr); ip != nil {
+				r.Addresses = append(r.Addresses, core.AddressInfo{Address: ip})
+			}
+		}
+
+		if filter.Match(r) && !fn(r) {
+			break
+		}
+	}
+	return nil
+}
+
+// DeleteEnumeration removes all the data collected by the provided enumeration from the database.
+func (s *SQL) DeleteEnumeration(uuid string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.exec(tx, "DELETE FROM enumerations WHERE uuid = ?", uuid); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("SQL: Failed to delete enumeration %s: %v", uuid, err)
+	}
+	for _, table := range sqlEnumTables {
+		if _, err := s.exec(tx, "DELETE FROM "+table+" WHERE enum_uuid = ?", uuid); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("SQL: Failed to delete enumeration %s: %v", uuid, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Compact releases the space left behind by deleted enumerations.
+func (s *SQL) Compact() error {
+	s.Lock()
+	defer s.Unlock()
+
+	_, err := s.db.Exec("VACUUM")
+	return err
+}
+
+// MarkAsRead implements the Amass DataHandler interface.
+func (s *SQL) MarkAsRead(data *DataOptsParams) error {
+	s.Lock()
+	defer s.Unlock()
+
+	_, err := s.db.Exec(s.db.Rebind("UPDATE names SET is_read = 1 WHERE enum_uuid = ? AND name = ?"),
+		data.UUID, data.Name)
+	return err
+}
+
+// IsCNAMENode implements the Amass DataHandler interface.
+func (s *SQL) IsCNAMENode(data *DataOptsParams) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.relationTarget(data.Name, "cname_to", data.UUID) != ""
+}
+
+// VizData returns the current state of the enumeration as viz package Nodes and Edges.
+func (s *SQL) VizData(uuid string) ([]viz.Node, []viz.Edge) {
+	var nodes []viz.Node
+	var edges []viz.Edge
+
+	s.StreamVizData(uuid, func(n viz.Node) bool {
+		nodes = append(nodes, n)
+		return true
+	}, func(e viz.Edge) bool {
+		edges = append(edges, e)
+		return true
+	})
+	return nodes, edges
+}
+
+// StreamVizData implements the Amass DataHandler interface.
+// The database is locked until the stream completes, so the functions must not call the SQL methods.
+func (s *SQL) StreamVizData(uuid string, nodes func(viz.Node) bool, edges func(viz.Edge) bool) error {
+	s.Lock()
+	defer s.Unlock()
+
+	// Once the stream has been cancelled, the remaining nodes are skipped
+	cancelled := false
+	rnodes := make(map[string]int)
+	addNode := func(t, label, title, source string) {
+		if _, found := rnodes[label]; found || label == "" || cancelled {
+			return
+		}
+
+		id := len(rnodes)
+		rnodes[label] = id
+		cancelled = !nodes(viz.Node{
+			ID:     id,
+			Type:   t,
+			Label:  label,
+			Title:  t + ": " + label + title,
+			Source: source,
+		})
+	}
+
+	var names []struct {
+		Name   string `db:"name"`
+		Type   string `db:"type"`
+		Source string `db:"source"`
+	}
+	s.db.Select(&names, s.db.Rebind("SELECT name, type, source FROM names "+
+		"WHERE enum_uuid = ? ORDER BY name"), uuid)
+	for _, n := range names {
+		var title string
+		if n.Type == "domain" {
+			if reg := s.propertyValue(n.Name, "registrant", uuid); reg != "" {
+				title = ", Registrant: " + reg
+			}
+		}
+		if n.Type == "ptr" {
+			n.Source = ""
+		}
+		addNode(n.Type, n.Name, title, n.Source)
+	}
+
+	for _, addr := range s.queryStrings("SELECT addr FROM addresses WHERE enum_uuid = ? ORDER BY addr", uuid) {
+		addNode("address", addr, "", "")
+	}
+	for _, cidr := range s.queryStrings("SELECT cidr FROM netblocks WHERE enum_uuid = ? ORDER BY cidr", uuid) {
+		addNode("netblock", cidr, "", "")
+	}
+
+	var asns []struct {
+		ASN         int    `db:"asn"`
+		Description string `db:"description"`
+	}
+	s.db.Select(&asns, s.db.Rebind("SELECT asn, description FROM asns "+
+		"WHERE enum_uuid = ? ORDER BY asn"), uuid)
+	for _, a := range asns {
+		asn := strconv.Itoa(a.ASN)
+		title := ", Desc: " + a.Description
+		if reg := s.propertyValue(asn, "registrant", uuid); reg != "" {
+			title = title + ", Registrant: " + reg
+		}
+		addNode("as", asn, title, "")
+	}
+
+	var certs []struct {
+		Fingerprint  string `db:"fingerprint"`
+		Issuer       string `db:"issuer"`
+		Organization string `db:"organization"`
+		Source       string `db:"source"`
+	}
+	s.db.Select(&certs, s.db.Rebind("SELECT fingerprint, issuer, organization, source "+
+		"FROM certificates WHERE enum_uuid = ? ORDER BY fingerprint"), uuid)
+	for _, c := range certs {
+		title := ", Issuer: " + c.Issuer
+		if c.Organization != "" {
+			title = title + ", Organization: " + c.Organization
+		}
+		addNode("certificate", c.Fingerprint, title, c.Source)
+	}
+
+	var urls []struct {
+		URL    string `db:"url"`
+		Status int    `db:"status"`
+		Source string `db:"source"`
+	}
+	s.db.Select(&urls, s.db.Rebind("SELECT url, status, source FROM urls "+
+		"WHERE enum_uuid = ? ORDER BY url"), uuid)
+	for _, u := range urls {
+		addNode("url", u.URL, ", Status: "+strconv.Itoa(u.Status), u.Source)
+	}
+
+	if cancelled {
+		return nil
+	}
+
+	rows, err := s.db.Queryx(s.db.Rebind("SELECT from_node, relation, to_node FROM relations "+
+		"WHERE enum_uuid = ? ORDER BY from_node, relation, to_node"), uuid)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var from, relation, to string
+
+		if err := rows.Scan(&from, &relation, &to); err != nil {
+			continue
+		}
+		f, ok1 := rnodes[from]
+		t, ok2 := rnodes[to]
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		if !edges(viz.Edge{
+			From:  f,
+			To:    t,
+			Title: relation,
+		}) {
+			return nil
+		}
+	}
+	return rows.Err()
+}
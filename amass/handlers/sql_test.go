@@ -0,0 +1,101 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sql")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewSQL(SQLDriverSQLite, filepath.Join(dir, DefaultSQLiteFile), nil)
+	if err != nil {
+		t.Fatalf("Failed to create the SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	for _, opt := range []*DataOptsParams{
+		{Type: OptDomain, Domain: "example.com", Tag: "dns", Source: "Forward DNS"},
+		{Type: OptCNAME, Name: "www.example.com", Domain: "example.com",
+			TargetName: "web.example.com", TargetDomain: "example.com", Tag: "dns", Source: "Forward DNS"},
+		{Type: OptA, Name: "web.example.com", Domain: "example.com", Address: "192.0.2.10",
+			Tag: "dns", Source: "Forward DNS"},
+		{Type: OptAAAA, Name: "web.example.com", Domain: "example.com", Address: "2001:db8::10",
+			Tag: "dns", Source: "Forward DNS"},
+		{Type: OptNS, Name: "example.com", Domain: "example.com",
+			TargetName: "ns1.example.com", TargetDomain: "example.com", Tag: "dns", Source: "Forward DNS"},
+		{Type: OptInfrastructure, Address: "192.0.2.10", ASN: 64496,
+			CIDR: "192.0.2.0/24", Description: "EXAMPLE-AS"},
+		{Type: OptInfrastructure, Address: "2001:db8::10", ASN: 64496,
+			CIDR: "2001:db8::/32", Description: "EXAMPLE-AS"},
+	} {
+		opt.UUID = "enum1"
+		opt.Timestamp = "2019-01-01T00:00:00Z"
+		if err := db.Insert(opt); err != nil {
+			t.Fatalf("Failed to insert the %s data operation: %v", opt.Type, err)
+		}
+	}
+
+	if list := db.EnumerationList(); len(list) != 1 || list[0] != "enum1" {
+		t.Errorf("The enumeration list was %v", list)
+	}
+	if domains := db.EnumerationDomains("enum1"); len(domains) != 1 || domains[0] != "example.com" {
+		t.Errorf("The enumeration domains were %v", domains)
+	}
+	if first, last := db.EnumerationDateRange("enum1"); first.Year() != 2019 || !first.Equal(last) {
+		t.Errorf("The enumeration date range was %v to %v", first, last)
+	}
+	if !db.IsCNAMENode(&DataOptsParams{UUID: "enum1", Name: "www.example.com", Domain: "example.com"}) {
+		t.Errorf("The CNAME was not identified")
+	}
+
+	results := db.GetOutput("enum1", false)
+	if len(results) != 2 {
+		t.Fatalf("The database returned %d names with addresses, expected 2", len(results))
+	}
+	for _, out := range results {
+		if out.Domain != "example.com" || len(out.Addresses) != 2 ||
+			out.Addresses[0].ASN != 64496 || out.Addresses[0].Description != "EXAMPLE-AS" {
+			t.Errorf("The output for %s was not complete: %+v", out.Name, out)
+		}
+	}
+
+	db.MarkAsRead(&DataOptsParams{UUID: "enum1", Name: "www.example.com", Domain: "example.com"})
+	db.MarkAsRead(&DataOptsParams{UUID: "enum1", Name: "web.example.com", Domain: "example.com"})
+	if results := db.GetOutput("enum1", false); len(results) != 0 {
+		t.Errorf("The database returned %d names already read", len(results))
+	}
+	if results := db.GetOutput("enum1", true); len(results) != 2 {
+		t.Errorf("The database returned %d names including those read, expected 2", len(results))
+	}
+
+	nodes, edges := db.VizData("enum1")
+	if len(nodes) != 9 {
+		t.Errorf("The visualization data had %d nodes, expected 9", len(nodes))
+	}
+	found := make(map[string]bool)
+	for _, e := range edges {
+		found[nodes[e.From].Label+" "+e.Title+" "+nodes[e.To].Label] = true
+	}
+	for _, edge := range []string{
+		"example.com root_of www.example.com",
+		"www.example.com cname_to web.example.com",
+		"web.example.com a_to 192.0.2.10",
+		"example.com ns_to ns1.example.com",
+		"192.0.2.0/24 contains 192.0.2.10",
+		"64496 has_prefix 192.0.2.0/24",
+	} {
+		if !found[edge] {
+			t.Errorf("The visualization data did not include the edge: %s", edge)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils/viz"
+)
+
+func TestStreamOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stream")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	graph := NewGraph(filepath.Join(dir, "graph"))
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	db, err := NewSQL(SQLDriverSQLite, filepath.Join(dir, DefaultSQLiteFile), nil)
+	if err != nil {
+		t.Fatalf("Failed to create the SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	uuid := "0e7b1d3c-58a4-4f3e-9d0a-6b2c1a9e8f70"
+	opts := append(mergeTestOpts(uuid, "2019-01-01T00:00:00Z", "dns"), DataOptsParams{
+		UUID: uuid, Timestamp: "2019-01-01T00:00:00Z", Type: OptA, Name: "mail.example.com",
+		Domain: "example.com", Address: "192.0.2.20", Tag: "dns", Source: "Forward DNS",
+	}, DataOptsParams{
+		UUID: uuid, Timestamp: "2019-01-01T00:00:00Z", Type: OptInfrastructure, Address: "192.0.2.20",
+		ASN: 64496, CIDR: "192.0.2.0/24", Description: "EXAMPLE-AS",
+	})
+
+	for _, handler := range []DataHandler{graph, db} {
+		if err := DataOptsDriver(opts, handler); err != nil {
+			t.Fatalf("%s: Failed to insert the data operations: %v", handler, err)
+		}
+
+		var streamed []*core.Output
+		if err := handler.StreamOutput(uuid, true, func(o *core.Output) bool {
+			streamed = append(streamed, o)
+			return true
+		}); err != nil {
+			t.Errorf("%s: Failed to stream the output: %v", handler, err)
+		}
+		if out := handler.GetOutput(uuid, true); len(out) < 2 || len(streamed) != len(out) {
+			t.Errorf("%s: Streamed %d names, while GetOutput returned %d", handler, len(streamed), len(out))
+		}
+
+		// Returning false cancels the stream
+		var count int
+		handler.StreamOutput(uuid, true, func(o *core.Output) bool {
+			count++
+			return false
+		})
+		if count != 1 {
+			t.Errorf("%s: The output stream was not cancelled", handler)
+		}
+
+		var nodes []viz.Node
+		var edges []viz.Edge
+		err := handler.StreamVizData(uuid, func(n viz.Node) bool {
+			if n.ID != len(nodes) {
+				t.Errorf("%s: The node %s had the ID %d instead of %d", handler, n.Label, n.ID, len(nodes))
+			}
+			nodes = append(nodes, n)
+			return true
+		}, func(e viz.Edge) bool {
+			edges = append(edges, e)
+			return true
+		})
+		if err != nil {
+			t.Errorf("%s: Failed to stream the viz data: %v", handler, err)
+		}
+		vnodes, vedges := handler.VizData(uuid)
+		if len(edges) == 0 || !reflect.DeepEqual(nodes, vnodes) || !reflect.DeepEqual(edges, vedges) {
+			t.Errorf("%s: The streamed nodes and edges did not match VizData", handler)
+		}
+
+		count = 0
+		handler.StreamVizData(uuid, func(n viz.Node) bool {
+			count++
+			return false
+		}, func(e viz.Edge) bool {
+			t.Errorf("%s: An edge was streamed after the stream was cancelled", handler)
+			return false
+		})
+		if count != 1 {
+			t.Errorf("%s: The viz stream was not cancelled", handler)
+		}
+
+		var results []*QueryResult
+		if err := handler.StreamQuery(uuid, nil, func(r *QueryResult) bool {
+			results = append(results, r)
+			return true
+		}); err != nil {
+			t.Errorf("%s: Failed to stream the query: %v", handler, err)
+		}
+		if found, _ := handler.Query(uuid, nil); len(found) < 2 || !reflect.DeepEqual(results, found) {
+			t.Errorf("%s: The streamed query results did not match Query", handler)
+		}
+	}
+}
+
+func TestGraphStreamReads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stream")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	graph := NewGraph(dir)
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	uuid := "0e7b1d3c-58a4-4f3e-9d0a-6b2c1a9e8f70"
+	if err := DataOptsDriver(mergeTestOpts(uuid, "2019-01-01T00:00:00Z", "dns"), graph); err != nil {
+		t.Fatalf("Failed to insert the data operations: %v", err)
+	}
+
+	// The functions handling the streams can read from the graph
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		graph.StreamOutput(uuid, true, func(o *core.Output) bool {
+			graph.IsCNAMENode(&DataOptsParams{UUID: uuid, Name: o.Name})
+			return true
+		})
+		graph.StreamVizData(uuid, func(n viz.Node) bool {
+			graph.EnumerationDomains(uuid)
+			return true
+		}, func(e viz.Edge) bool {
+			graph.EnumerationDateRange(uuid)
+			return true
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("The graph was locked while the streams were handled")
+	}
+}
+
+func TestStreamUnsupported(t *testing.T) {
+	handlers := []DataHandler{
+		NewDataOptsHandler(ioutil.Discard),
+		&Neo4j{Log: log.New(ioutil.Discard, "", 0)},
+	}
+
+	for _, handler := range handlers {
+		if err := handler.StreamOutput("uuid", true, func(o *core.Output) bool {
+			return true
+		}); err == nil {
+			t.Errorf("%s: No error was returned for the unsupported output stream", handler)
+		}
+		if err := handler.StreamVizData("uuid", func(n viz.Node) bool {
+			return true
+		}, func(e viz.Edge) bool {
+			return true
+		}); err == nil {
+			t.Errorf("%s: No error was returned for the unsupported viz stream", handler)
+		}
+	}
+}
+
+func TestPropertiesToVizNode(t *testing.T) {
+	n := propertiesToVizNode(3, map[string][]string{
+		"type":        {"as"},
+		"asn":         {"64496"},
+		"description": {"EXAMPLE-AS"},
+	})
+
+	expected := viz.Node{ID: 3, Type: "as", Label: "64496", Title: "as: 64496, Desc: EXAMPLE-AS"}
+	if n != expected {
+		t.Errorf("Unexpected node: %+v", n)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"crypto/tls"
+	"html"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils"
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultHTTPProbeTimeout = 10 * time.Second
+
+	// The most redirects followed from the first request made for a name
+	maxHTTPRedirects = 10
+
+	// The most bytes read from each response body
+	maxHTTPBodySize = 1 << 20
+
+	// The most linked JavaScript files requested for each page
+	maxScriptsPerPage = 10
+)
+
+var (
+	titleRE  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	scriptRE = regexp.MustCompile(`(?i)<script[^>]+src\s*=\s*["']([^"']+)["']`)
+
+	// The response headers that commonly reference other hosts of the organization
+	nameHeaders = []string{
+		"Location",
+		"Content-Security-Policy",
+		"Content-Security-Policy-Report-Only",
+		"Access-Control-Allow-Origin",
+	}
+)
+
+// HTTPProbeService is the Service that makes HTTP and HTTPS requests to the discovered
+// names, records details of the responses and extracts new names found within them.
+type HTTPProbeService struct {
+	core.BaseService
+
+	maxProbes utils.Semaphore
+	filter    utils.StringFilter
+	subre     *regexp.Regexp
+	client    *http.Client
+}
+
+// NewHTTPProbeService returns he object initialized, but not yet started.
+func NewHTTPProbeService(config *core.Config, bus *core.EventBus) *HTTPProbeService {
+	hps := &HTTPProbeService{
+		maxProbes: utils.NewSimpleSemaphore(50),
+		filter:    config.NewStringFilter(),
+		subre:     utils.AnySubdomainRegex(),
+		client: &http.Client{
+			Timeout: defaultHTTPProbeTimeout,
+			Transport: &http.Transport{
+				DialContext: (&net.Dialer{
+					Timeout: defaultTLSConnectTimeout,
+				}).DialContext,
+				TLSHandshakeTimeout: defaultHandshakeDeadline,
+				TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+				DisableKeepAlives:   true,
+			},
+			// Redirects are followed by the service, so the chain can be recorded
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	hps.BaseService = *core.NewBaseService(hps, "HTTP Probe", config, bus)
+	return hps
+}
+
+// OnStart implements the Service interface
+func (hps *HTTPProbeService) OnStart() error {
+	hps.BaseService.OnStart()
+
+	hps.Bus().Subscribe(core.NameResolvedTopic, hps.nameResolved)
+	go hps.processRequests()
+	return nil
+}
+
+// OnStop implements the Service interface.
+func (hps *HTTPProbeService) OnStop() error {
+	hps.filter.Close()
+	return nil
+}
+
+func (hps *HTTPProbeService) nameResolved(req *core.DNSRequest) {
+	if !hps.Config().IsDomainInScope(req.Name) {
+		return
+	}
+
+	for _, rec := range req.Records {
+		t := uint16(rec.Type)
+
+		if t == dns.TypeA || t == dns.TypeAAAA || t == dns.TypeCNAME {
+			if !hps.filter.Duplicate(req.Name) {
+				hps.SendDNSRequest(req)
+			}
+			return
+		}
+	}
+}
+
+func (hps *HTTPProbeService) processRequests() {
+	for {
+		select {
+		case <-hps.PauseChan():
+			<-hps.ResumeChan()
+		case <-hps.Quit():
+			return
+		case req := <-hps.DNSRequestChan():
+			hps.maxProbes.Acquire(1)
+			go hps.probeName(req)
+		case <-hps.AddrRequestChan():
+		case <-hps.ASNRequestChan():
+		case <-hps.WhoisRequestChan():
+		}
+	}
+}
+
+func (hps *HTTPProbeService) probeName(req *core.DNSRequest) {
+	defer hps.maxProbes.Release(1)
+
+	for _, scheme := range []string{"https", "http"} {
+		hps.SetActive()
+
+		u := scheme + "://" + req.Name + "/"
+		if probe := hps.probe(u); probe != nil {
+			probe.Name = req.Name
+			probe.Domain = req.Domain
+			hps.Bus().Publish(core.HTTPProbeTopic, probe)
+		}
+	}
+}
+
+func (hps *HTTPProbeService) probe(u string) *core.HTTPProbe {
+	probe := &core.HTTPProbe{
+		Timestamp: time.Now(),
+		URL:       u,
+		Tag:       core.HTTP,
+		Source:    hps.String(),
+	}
+
+	current := u
+	for i := 0; ; i++ {
+		resp, err := hps.request(current)
+		if err != nil {
+			if i == 0 {
+				return nil
+			}
+			break
+		}
+
+		body := readBody(resp.Body, maxHTTPBodySize)
+		resp.Body.Close()
+		hps.SetActive()
+
+		probe.StatusCode = resp.StatusCode
+		probe.Server = resp.Header.Get("Server")
+		for _, h := range nameHeaders {
+			hps.namesFromText(resp.Header.Get(h))
+		}
+
+		loc, err := resp.Location()
+		if err != nil || resp.StatusCode < 300 || resp.StatusCode >= 400 {
+			probe.Title = pageTitle(body)
+			hps.namesFromText(body)
+			hps.namesFromScripts(resp.Request.URL, body)
+			break
+		}
+
+		next := loc.String()
+		probe.Redirects = append(probe.Redirects, next)
+		// Do not follow redirects that leave the host or the enumeration scope
+		host := loc.Hostname()
+		if i >= maxHTTPRedirects || (host != resp.Request.URL.Hostname() &&
+			!hps.Config().IsDomainInScope(host)) {
+			break
+		}
+		current = next
+	}
+	return probe
+}
+
+func (hps *HTTPProbeService) request(u string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", utils.UserAgent)
+	req.Header.Set("Accept", utils.Accept)
+	req.Header.Set("Accept-Language", utils.AcceptLang)
+	return hps.client.Do(req)
+}
+
+func (hps *HTTPProbeService) namesFromScripts(base *url.URL, page string) {
+	var count int
+
+	for _, match := range scriptRE.FindAllStringSubmatch(page, -1) {
+		if count >= maxScriptsPerPage {
+			break
+		}
+
+		ref, err := url.Parse(html.UnescapeString(match[1]))
+		if err != nil {
+			continue
+		}
+
+		script := base.ResolveReference(ref)
+		if script.Scheme != "http" && script.Scheme != "https" {
+			continue
+		}
+		if !hps.Config().IsDomainInScope(script.Hostname()) || hps.filter.Duplicate(script.String()) {
+			continue
+		}
+
+		count++
+		hps.SetActive()
+		resp, err := hps.request(script.String())
+		if err != nil {
+			continue
+		}
+
+		body := readBody(resp.Body, maxHTTPBodySize)
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			hps.namesFromText(body)
+		}
+	}
+}
+
+func (hps *HTTPProbeService) namesFromText(text string) {
+	if text == "" {
+		return
+	}
+
+	for _, name := range hps.subre.FindAllString(text, -1) {
+		name = strings.ToLower(name)
+
+		if domain := hps.Config().WhichDomain(name); domain != "" {
+			hps.Bus().Publish(core.NewNameTopic, &core.DNSRequest{
+				Name:   name,
+				Domain: domain,
+				Tag:    core.HTTP,
+				Source: hps.String(),
+			})
+		}
+	}
+}
+
+func readBody(r io.Reader, max int64) string {
+	body, err := ioutil.ReadAll(io.LimitReader(r, max))
+	if err != nil {
+		return ""
+	}
+	return string(body)
+}
+
+func pageTitle(page string) string {
+	match := titleRE.FindStringSubmatch(page)
+	if len(match) < 2 {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(match[1])), " ")
+}
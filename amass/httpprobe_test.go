@@ -0,0 +1,75 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+)
+
+func TestHTTPProbe(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/home", http.StatusFound)
+			return
+		}
+
+		w.Header().Set("Server", "amass-test")
+		w.Header().Set("Content-Security-Policy", "default-src 'self' https://static.owasp.org")
+		fmt.Fprint(w, "<html><head><title>OWASP &amp; Amass</title></head>"+
+			"<body><a href=\"https://www.owasp.org/index.php\">Home</a></body></html>")
+	}))
+	defer ts.Close()
+
+	config := setupConfig("owasp.org")
+	bus, out := setupEventBus(core.NewNameTopic)
+	defer bus.Stop()
+
+	hps := NewHTTPProbeService(config, bus)
+	defer hps.filter.Close()
+
+	probe := hps.probe(ts.URL + "/")
+	if probe == nil {
+		t.Fatalf("The probe did not return a result")
+	}
+	if probe.StatusCode != http.StatusOK {
+		t.Errorf("The probe returned status %d, expected %d", probe.StatusCode, http.StatusOK)
+	}
+	if probe.Title != "OWASP & Amass" {
+		t.Errorf("The probe returned the title %q", probe.Title)
+	}
+	if probe.Server != "amass-test" {
+		t.Errorf("The probe returned the server header %q", probe.Server)
+	}
+	if len(probe.Redirects) != 1 || probe.Redirects[0] != ts.URL+"/home" {
+		t.Errorf("The probe returned the redirect chain %v", probe.Redirects)
+	}
+
+	expected := map[string]bool{
+		"static.owasp.org": false,
+		"www.owasp.org":    false,
+	}
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < len(expected); i++ {
+		select {
+		case req := <-out:
+			if req.Tag != core.HTTP {
+				t.Errorf("%s was published with the %s tag", req.Name, req.Tag)
+			}
+			expected[req.Name] = true
+		case <-timeout:
+			t.Fatalf("Timed out waiting for the names extracted from the responses")
+		}
+	}
+	for name, found := range expected {
+		if !found {
+			t.Errorf("%s was not extracted from the responses", name)
+		}
+	}
+}
@@ -15,8 +15,10 @@ import (
 	"time"
 
 	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/handlers"
 	"github.com/root-secure/Amass/amass/sources"
 	"github.com/root-secure/Amass/amass/utils"
+	"github.com/google/uuid"
 )
 
 // IntelCollection is the object type used to execute a open source information gathering with Amass.
@@ -24,6 +26,9 @@ type IntelCollection struct {
 	Config *core.Config
 	Bus    *core.EventBus
 
+	// The graph searched for names sharing infrastructure with the seed domains
+	Graph handlers.DataHandler
+
 	// The channel that will receive the results
 	Output chan *core.Output
 
@@ -37,12 +42,31 @@ type IntelCollection struct {
 	cidrChan   chan *net.IPNet
 	domainChan chan *core.Output
 	activeChan chan struct{}
+
+	// The registration data obtained via RDAP for the root domains
+	regLock       sync.Mutex
+	registrations []*core.RDAPRecord
+
+	// The root domains sharing infrastructure with the seed domains
+	pivotLock sync.Mutex
+	pivots    []*core.PivotCandidate
+
+	// The root domains found in certificates with a subject organization
+	certLock     sync.Mutex
+	certEvidence []*core.CertEvidence
+	certKeys     map[string]struct{}
+
+	resolve    func(name, qtype string) ([]core.DNSAnswer, error)
+	rootDomain func(name string) string
 }
 
 // NewIntelCollection returns an initialized IntelCollection object that has not been started yet.
 func NewIntelCollection() *IntelCollection {
 	return &IntelCollection{
-		Config:     &core.Config{Log: log.New(ioutil.Discard, "", 0)},
+		Config: &core.Config{
+			UUID: uuid.New(),
+			Log:  log.New(ioutil.Discard, "", 0),
+		},
 		Bus:        core.NewEventBus(),
 		Output:     make(chan *core.Output, 100),
 		Done:       make(chan struct{}, 2),
@@ -50,6 +74,11 @@ func NewIntelCollection() *IntelCollection {
 		cidrChan:   make(chan *net.IPNet, 100),
 		domainChan: make(chan *core.Output, 100),
 		activeChan: make(chan struct{}, 100),
+		certKeys:   make(map[string]struct{}),
+		resolve: func(name, qtype string) ([]core.DNSAnswer, error) {
+			return core.Resolve(name, qtype, core.PriorityHigh)
+		},
+		rootDomain: core.SubdomainToDomain,
 	}
 }
 
@@ -60,6 +89,8 @@ func (ic *IntelCollection) HostedDomains() error {
 	} else if err := ic.Config.CheckSettings(); err != nil {
 		return err
 	}
+	// The offline database provides the AS evidence for the addresses investigated
+	LoadOfflineASNDatabase(ic.Config)
 
 	go ic.startAddressRanges()
 	go ic.processCIDRs()
@@ -71,7 +102,8 @@ func (ic *IntelCollection) HostedDomains() error {
 	ic.asnsToCIDRs()
 
 	var active bool
-	filter := utils.NewStringFilter()
+	filter := ic.Config.NewStringFilter()
+	defer filter.Close()
 	t := time.NewTicker(5 * time.Second)
 loop:
 	for {
@@ -100,7 +132,7 @@ loop:
 func (ic *IntelCollection) startAddressRanges() {
 	for _, addr := range ic.Config.Addresses {
 		ic.Config.SemMaxDNSQueries.Acquire(1)
-		go ic.investigateAddr(addr.String())
+		go ic.investigateAddr(addr.String(), nil)
 	}
 }
 
@@ -112,13 +144,13 @@ func (ic *IntelCollection) processCIDRs() {
 		case cidr := <-ic.cidrChan:
 			for _, addr := range utils.NetHosts(cidr) {
 				ic.Config.SemMaxDNSQueries.Acquire(1)
-				go ic.investigateAddr(addr.String())
+				go ic.investigateAddr(addr.String(), cidr)
 			}
 		}
 	}
 }
 
-func (ic *IntelCollection) investigateAddr(addr string) {
+func (ic *IntelCollection) investigateAddr(addr string, cidr *net.IPNet) {
 	defer ic.Config.SemMaxDNSQueries.Release(1)
 
 	ip := net.ParseIP(addr)
@@ -126,7 +158,7 @@ func (ic *IntelCollection) investigateAddr(addr string) {
 		return
 	}
 
-	addrinfo := core.AddressInfo{Address: ip}
+	addrinfo := ic.addressInfo(ip, cidr)
 	ic.activeChan <- struct{}{}
 	if _, answer, err := core.ReverseDNS(addr); err == nil {
 		if d := strings.TrimSpace(core.SubdomainToDomain(answer)); d != "" {
@@ -136,6 +168,7 @@ func (ic *IntelCollection) investigateAddr(addr string) {
 				Addresses: []core.AddressInfo{addrinfo},
 				Tag:       core.DNS,
 				Source:    "Reverse DNS",
+				Method:    core.IntelReverseDNS,
 			}
 		}
 	}
@@ -145,25 +178,88 @@ func (ic *IntelCollection) investigateAddr(addr string) {
 		return
 	}
 
-	for _, r := range PullCertificateNames(addr, ic.Config.Ports) {
-		if d := strings.TrimSpace(r.Domain); d != "" {
-			ic.domainChan <- &core.Output{
-				Name:      d,
-				Domain:    d,
-				Addresses: []core.AddressInfo{addrinfo},
-				Tag:       core.CERT,
-				Source:    "Active Cert",
+	for _, cert := range PullCertificates(addr, ic.Config.Ports, "") {
+		cert.Tag = core.CERT
+		cert.Source = "Active Cert"
+		// Keep the subject organization, so related root domains can be reported
+		ic.addCertEvidence(cert)
+
+		for _, r := range reqFromNames(cert.Names) {
+			if d := strings.TrimSpace(r.Domain); d != "" {
+				ic.domainChan <- &core.Output{
+					Name:      d,
+					Domain:    d,
+					Addresses: []core.AddressInfo{addrinfo},
+					Tag:       core.CERT,
+					Source:    "Active Cert",
+					Method:    core.IntelCert,
+				}
 			}
 		}
 	}
 	ic.activeChan <- struct{}{}
 }
 
+// addressInfo returns the netblock and autonomous system evidence for the address.
+func (ic *IntelCollection) addressInfo(ip net.IP, cidr *net.IPNet) core.AddressInfo {
+	info := core.AddressInfo{Address: ip}
+
+	if cidr != nil {
+		info.Netblock = cidr
+		info.CIDRStr = cidr.String()
+
+		ic.netLock.Lock()
+		for _, record := range ic.netCache {
+			for _, netblock := range record.Netblocks {
+				if _, ipnet, err := net.ParseCIDR(netblock); err == nil && ipnet.String() == info.CIDRStr {
+					info.ASN = record.ASN
+					info.Description = record.Description
+				}
+			}
+		}
+		ic.netLock.Unlock()
+	}
+	if info.ASN != 0 {
+		return info
+	}
+
+	// The offline database can identify the AS announcing the address
+	if db := offlineASNDatabase(); db != nil {
+		if req := db.LookupIP(ip.String()); req != nil {
+			info.ASN = req.ASN
+			info.Description = req.Description
+			if info.Netblock == nil {
+				_, info.Netblock, _ = net.ParseCIDR(req.Prefix)
+				info.CIDRStr = req.Prefix
+			}
+		}
+	}
+	return info
+}
+
 func (ic *IntelCollection) asnsToCIDRs() {
 	if len(ic.Config.ASNs) == 0 {
 		return
 	}
 
+	// The offline database is queried before the online sources
+	var remaining []int
+	if LoadOfflineASNDatabase(ic.Config) {
+		for _, asn := range ic.Config.ASNs {
+			if req := offlineASNDatabase().LookupASN(asn); req != nil {
+				ic.updateNetCache(req)
+				continue
+			}
+			remaining = append(remaining, asn)
+		}
+	} else {
+		remaining = ic.Config.ASNs
+	}
+	if len(remaining) == 0 {
+		ic.sendNetblockCIDRs()
+		return
+	}
+
 	ic.Bus.Subscribe(core.NewASNTopic, ic.updateNetCache)
 	defer ic.Bus.Unsubscribe(core.NewASNTopic, ic.updateNetCache)
 
@@ -185,7 +281,7 @@ func (ic *IntelCollection) asnsToCIDRs() {
 	srcs = keep
 
 	// Send the ASN requests to the data sources
-	for _, asn := range ic.Config.ASNs {
+	for _, asn := range remaining {
 		for _, src := range srcs {
 			src.SendASNRequest(&core.ASNRequest{ASN: asn})
 		}
@@ -217,7 +313,8 @@ func (ic *IntelCollection) sendNetblockCIDRs() {
 	ic.netLock.Lock()
 	defer ic.netLock.Unlock()
 
-	filter := utils.NewStringFilter()
+	filter := ic.Config.NewStringFilter()
+	defer filter.Close()
 	for _, record := range ic.netCache {
 		for _, netblock := range record.Netblocks {
 			_, ipnet, err := net.ParseCIDR(netblock)
@@ -258,11 +355,52 @@ func (ic *IntelCollection) updateNetCache(req *core.ASNRequest) {
 	ic.netCache[req.ASN] = c
 }
 
+// InsertIntelOutput provides the intelligence collection result to the DataHandler as Intel data
+// operations, so the root domain and the evidence that led to it are kept under the UUID.
+func InsertIntelOutput(handler handlers.DataHandler, uuid string, out *core.Output) error {
+	ts := out.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	data := &handlers.DataOptsParams{
+		UUID:      uuid,
+		Timestamp: ts.Format(time.RFC3339),
+		Type:      handlers.OptIntel,
+		Domain:    out.Domain,
+		Method:    out.Method,
+		Tag:       out.Tag,
+		Source:    out.Source,
+	}
+	if len(out.Addresses) == 0 {
+		return handler.Insert(data)
+	}
+
+	for _, addr := range out.Addresses {
+		d := *data
+		d.Address = addr.Address.String()
+		d.CIDR = addr.CIDRStr
+		d.ASN = addr.ASN
+		d.Description = addr.Description
+		if err := handler.Insert(&d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // LookupASNsByName returns core.ASNRequest objects for autonomous systems with
-// descriptions that contain the string provided by the parameter.
+// descriptions that contain the string provided by the parameter. The offline ASN
+// database is searched first, when available to the process.
 func LookupASNsByName(s string) ([]*core.ASNRequest, error) {
 	var records []*core.ASNRequest
 
+	if db := offlineASNDatabase(); db != nil {
+		if records = db.ASNsByName(s); len(records) > 0 {
+			return records, nil
+		}
+	}
+
 	s = strings.ToLower(s)
 	url := "https://raw.githubusercontent.com/root-secure/Amass/master/wordlists/asnlist.txt"
 	page, err := utils.RequestWebPage(url, nil, nil, "", "")
@@ -293,7 +431,8 @@ func LookupASNsByName(s string) ([]*core.ASNRequest, error) {
 
 // ReverseWhois returns domain names that are related to the domains provided
 func (ic *IntelCollection) ReverseWhois() error {
-	filter := utils.NewStringFilter()
+	filter := ic.Config.NewStringFilter()
+	defer filter.Close()
 
 	collect := func(req *core.WhoisRequest) {
 		for _, d := range req.NewDomains {
@@ -303,6 +442,7 @@ func (ic *IntelCollection) ReverseWhois() error {
 					Domain: d,
 					Tag:    req.Tag,
 					Source: req.Source,
+					Method: core.IntelWhois,
 				}
 			}
 		}
@@ -328,9 +468,22 @@ func (ic *IntelCollection) ReverseWhois() error {
 	srcs = keep
 
 	// Send the whois requests to the data sources
+	client := NewRDAPClient(RDAPCacheDir(ic.Config))
 	for _, domain := range ic.Config.Domains() {
+		req := core.WhoisRequest{Domain: domain}
+
+		// The registrant from RDAP gives the data sources more to search with
+		if rec, err := client.QueryDomain(domain); err == nil {
+			req.Company = rec.Registrant
+			req.Email = rec.RegistrantEmail
+			ic.addRegistration(rec)
+		} else {
+			ic.Config.Log.Printf("%v", err)
+		}
+
 		for _, src := range srcs {
-			src.SendWhoisRequest(&core.WhoisRequest{Domain: domain})
+			r := req
+			src.SendWhoisRequest(&r)
 		}
 	}
 
@@ -357,3 +510,53 @@ loop:
 	close(ic.Output)
 	return nil
 }
+
+func (ic *IntelCollection) addRegistration(rec *core.RDAPRecord) {
+	ic.regLock.Lock()
+	defer ic.regLock.Unlock()
+
+	ic.registrations = append(ic.registrations, rec)
+	ic.Bus.Publish(core.RDAPTopic, rec)
+}
+
+// Registrations returns the RDAP registration data obtained for the root domains.
+func (ic *IntelCollection) Registrations() []*core.RDAPRecord {
+	ic.regLock.Lock()
+	defer ic.regLock.Unlock()
+
+	recs := make([]*core.RDAPRecord, len(ic.registrations))
+	copy(recs, ic.registrations)
+	return recs
+}
+
+// LookupASNRegistrations returns the RDAP registration data of the autonomous systems provided.
+func LookupASNRegistrations(config *core.Config, records []*core.ASNRequest) map[int]*core.RDAPRecord {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[int]*core.RDAPRecord)
+	client := NewRDAPClient(RDAPCacheDir(config))
+	// RDAP servers are quick to rate limit clients
+	sem := utils.NewSimpleSemaphore(5)
+
+	for _, rec := range records {
+		wg.Add(1)
+		sem.Acquire(1)
+
+		go func(asn int) {
+			defer wg.Done()
+			defer sem.Release(1)
+
+			reg, err := client.QueryASN(asn)
+			if err != nil {
+				config.Log.Printf("%v", err)
+				return
+			}
+
+			lock.Lock()
+			results[asn] = reg
+			lock.Unlock()
+		}(rec.ASN)
+	}
+	wg.Wait()
+	return results
+}
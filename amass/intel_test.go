@@ -0,0 +1,101 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/handlers"
+)
+
+func TestInsertIntelOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "intel")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ic := NewIntelCollection()
+	ic.updateNetCache(&core.ASNRequest{
+		ASN:         64496,
+		Description: "EXAMPLE-AS",
+		Netblocks:   []string{"192.0.2.0/24"},
+	})
+	_, cidr, _ := net.ParseCIDR("192.0.2.0/24")
+	info := ic.addressInfo(net.ParseIP("192.0.2.10"), cidr)
+	if info.ASN != 64496 || info.CIDRStr != "192.0.2.0/24" || info.Description != "EXAMPLE-AS" {
+		t.Errorf("The address evidence was not complete: %+v", info)
+	}
+
+	outputs := []*core.Output{
+		{
+			Name:      "example.com",
+			Domain:    "example.com",
+			Addresses: []core.AddressInfo{info},
+			Tag:       core.DNS,
+			Source:    "Reverse DNS",
+			Method:    core.IntelReverseDNS,
+		},
+		{
+			Name:   "example.org",
+			Domain: "example.org",
+			Tag:    core.API,
+			Source: "Umbrella",
+			Method: core.IntelWhois,
+		},
+	}
+
+	// Write the data operations, and import them into the graph
+	var buf bytes.Buffer
+	uuid := ic.Config.UUID.String()
+	writer := handlers.NewDataOptsHandler(&buf)
+	for _, out := range outputs {
+		if err := InsertIntelOutput(writer, uuid, out); err != nil {
+			t.Fatalf("Failed to write the data operations: %v", err)
+		}
+	}
+	opts, err := handlers.ParseDataOpts(&buf)
+	if err != nil || len(opts) != 2 {
+		t.Fatalf("Failed to parse the data operations: %v", err)
+	}
+
+	graph := handlers.NewGraph(dir)
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	if err := handlers.DataOptsDriver(opts, graph); err != nil {
+		t.Fatalf("Failed to import the data operations: %v", err)
+	}
+	if et := graph.EnumerationType(uuid); et != handlers.EnumTypeIntel {
+		t.Errorf("The enumeration type was %s", et)
+	}
+
+	results := graph.GetOutput(uuid, true)
+	if len(results) != 1 {
+		t.Fatalf("The graph returned %d names with address evidence, expected 1", len(results))
+	}
+	if out := results[0]; out.Name != "example.com" || out.Method != core.IntelReverseDNS ||
+		out.Addresses[0].ASN != 64496 || out.Addresses[0].CIDRStr != "192.0.2.0/24" {
+		t.Errorf("The intel result from the graph was not complete: %+v", out)
+	}
+
+	var found bool
+	nodes, edges := graph.VizData(uuid)
+	for _, e := range edges {
+		if e.Title == "discovered_from" && nodes[e.From].Label == "example.com" &&
+			nodes[e.To].Label == "192.0.2.10" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("The visualization data did not include the intel relationship")
+	}
+}
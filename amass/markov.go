@@ -47,16 +47,16 @@ type MarkovService struct {
 	model      *markovModel
 	subsLock   sync.Mutex
 	subs       map[string]*core.DNSRequest
-	inFilter   *utils.StringFilter
-	outFilter  *utils.StringFilter
+	inFilter   utils.StringFilter
+	outFilter  utils.StringFilter
 }
 
 // NewMarkovService returns he object initialized, but not yet started.
 func NewMarkovService(config *core.Config, bus *core.EventBus) *MarkovService {
 	m := &MarkovService{
 		subs:      make(map[string]*core.DNSRequest),
-		inFilter:  utils.NewStringFilter(),
-		outFilter: utils.NewStringFilter(),
+		inFilter:  config.NewStringFilter(),
+		outFilter: config.NewStringFilter(),
 		model: &markovModel{
 			NgramSize: 3,
 			Ngrams:    make(map[string]map[rune]*lenDist),
@@ -78,6 +78,13 @@ func (m *MarkovService) OnStart() error {
 	return nil
 }
 
+// OnStop implements the Service interface.
+func (m *MarkovService) OnStop() error {
+	m.inFilter.Close()
+	m.outFilter.Close()
+	return nil
+}
+
 // OnLowNumberOfNames implements the Service interface.
 func (m *MarkovService) OnLowNumberOfNames() error {
 	m.model.Lock()
@@ -288,7 +295,7 @@ func (m *MarkovService) generateChar(ngram string) string {
 
 func (m *MarkovService) sendGeneratedName(name, domain string) {
 	name = strings.Trim(name, "-")
-	if name == "" || m.outFilter.Duplicate(name) {
+	if name == "" || !m.Config().InShard(name) || m.outFilter.Duplicate(name) {
 		return
 	}
 
@@ -0,0 +1,259 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/root-secure/Amass/amass/core"
+)
+
+// ProviderSignatures identify the CDN, cloud and hosting providers serving common CNAME targets.
+// They are always consulted by ClassifyMovement, after the signatures provided by the caller.
+var ProviderSignatures = []*TakeoverSignature{
+	{Provider: "Amazon CloudFront", CNAME: []string{"cloudfront.net"}},
+	{Provider: "Amazon S3", CNAME: []string{"s3.amazonaws.com", "s3-website"}},
+	{Provider: "Amazon Elastic Load Balancing", CNAME: []string{"elb.amazonaws.com"}},
+	{Provider: "Amazon Web Services", CNAME: []string{"amazonaws.com", "awsglobalaccelerator.com"}},
+	{Provider: "Akamai", CNAME: []string{"akamai.net", "akamaiedge.net", "akamaized.net",
+		"akamaihd.net", "edgekey.net", "edgesuite.net"}},
+	{Provider: "Cloudflare", CNAME: []string{"cdn.cloudflare.net"}},
+	{Provider: "Fastly", CNAME: []string{"fastly.net", "fastlylb.net"}},
+	{Provider: "Google Cloud", CNAME: []string{"googlehosted.com", "appspot.com",
+		"googleusercontent.com", "storage.googleapis.com"}},
+	{Provider: "Microsoft Azure", CNAME: []string{"azurewebsites.net", "cloudapp.net", "cloudapp.azure.com",
+		"azureedge.net", "azurefd.net", "trafficmanager.net", "blob.core.windows.net"}},
+	{Provider: "Incapsula", CNAME: []string{"incapdns.net"}},
+	{Provider: "Sucuri", CNAME: []string{"sucuri.net"}},
+	{Provider: "StackPath", CNAME: []string{"stackpathdns.com"}},
+	{Provider: "Edgecast", CNAME: []string{"edgecastcdn.net"}},
+	{Provider: "Limelight", CNAME: []string{"llnwd.net"}},
+	{Provider: "Netlify", CNAME: []string{"netlify.com", "netlify.app"}},
+	{Provider: "Vercel", CNAME: []string{"vercel-dns.com", "now.sh"}},
+	{Provider: "Heroku", CNAME: []string{"herokuapp.com", "herokudns.com", "herokussl.com"}},
+	{Provider: "GitHub Pages", CNAME: []string{"github.io"}},
+	{Provider: "Shopify", CNAME: []string{"myshopify.com"}},
+	{Provider: "Zendesk", CNAME: []string{"zendesk.com"}},
+	{Provider: "WordPress", CNAME: []string{"wordpress.com"}},
+	{Provider: "Pantheon", CNAME: []string{"pantheonsite.io"}},
+}
+
+// ClassifyMovement identifies the categories of infrastructure movement for the changed DNS name,
+// using the addresses found by the later and earlier enumerations, and the CNAME targets held by
+// the event. Each address is compared with the same address in the earlier enumeration, or with
+// the earlier addresses no longer returned when the address is new, and addresses without a
+// known ASN are not classified. A different CNAME target is only a movement when the provider
+// serving the target changed, which is identified by the signatures provided, ProviderSignatures,
+// or the ASNs announcing the addresses. When the name moved to a different ASN or CNAME provider, the
+// providers are also added to the event.
+func ClassifyMovement(e *core.TrackEvent, addrs, prevAddrs []core.AddressInfo, sigs []*TakeoverSignature) {
+	found := make(map[string]bool)
+
+	if len(prevAddrs) == 0 && len(addrs) > 0 {
+		found[core.TrackStartedResolving] = true
+	} else if len(prevAddrs) > 0 && len(addrs) == 0 {
+		found[core.TrackStoppedResolving] = true
+	}
+
+	var prevIPv6 bool
+	prev := make(map[string]core.AddressInfo)
+	for _, addr := range prevAddrs {
+		if addr.Address != nil {
+			prev[addr.Address.String()] = addr
+		}
+		if isIPv6(addr) {
+			prevIPv6 = true
+		}
+	}
+
+	cur := make(map[string]struct{})
+	for _, addr := range addrs {
+		if addr.Address != nil {
+			cur[addr.Address.String()] = struct{}{}
+		}
+	}
+	// New addresses are compared with the addresses they replaced, or with all the
+	// earlier addresses when none were replaced
+	var replaced []core.AddressInfo
+	for _, addr := range prevAddrs {
+		if addr.Address == nil {
+			continue
+		}
+		if _, found := cur[addr.Address.String()]; !found {
+			replaced = append(replaced, addr)
+		}
+	}
+	if len(replaced) == 0 {
+		replaced = prevAddrs
+	}
+
+	for _, addr := range addrs {
+		if isIPv6(addr) && !prevIPv6 {
+			found[core.TrackIPv6Added] = true
+		}
+		// Addresses without a known ASN cannot be placed in the infrastructure
+		if addr.ASN == 0 {
+			continue
+		}
+
+		earlier := replaced
+		if addr.Address != nil {
+			if p, known := prev[addr.Address.String()]; known {
+				earlier = []core.AddressInfo{p}
+			}
+		}
+		if c := addressMovement(addr, earlier); c != "" {
+			found[c] = true
+		}
+	}
+
+	var provider, prevProvider string
+	target, prevTarget := lastTarget(e.CNAMETargets), lastTarget(e.PreviousCNAMEs)
+	if target != "" && target != prevTarget {
+		provider = cnameProvider(target, addrs, sigs)
+		if prevTarget != "" {
+			prevProvider = cnameProvider(prevTarget, prevAddrs, sigs)
+		}
+		if provider != prevProvider {
+			found[core.TrackCNAMETarget] = true
+		}
+	}
+
+	e.Categories = nil
+	for _, c := range core.TrackCategories {
+		if found[c] {
+			e.Categories = append(e.Categories, c)
+		}
+	}
+	if found[core.TrackASNMove] {
+		e.Providers = providerStrings(addrs)
+		e.PreviousProviders = providerStrings(prevAddrs)
+	} else if found[core.TrackCNAMETarget] {
+		e.Providers = []string{provider}
+		if prevProvider != "" {
+			e.PreviousProviders = []string{prevProvider}
+		}
+	}
+}
+
+// addressMovement compares the address with the earlier addresses expected to hold it, and
+// returns the category of the movement, or an empty string when the address did not move.
+func addressMovement(addr core.AddressInfo, earlier []core.AddressInfo) string {
+	var known, sameASN bool
+
+	cidr := netblockString(addr)
+	for _, e := range earlier {
+		if e.ASN == 0 {
+			continue
+		}
+
+		known = true
+		if e.ASN != addr.ASN {
+			continue
+		}
+		sameASN = true
+		if cidr == "" || netblockString(e) == cidr {
+			return ""
+		}
+	}
+
+	if !known {
+		return ""
+	}
+	if !sameASN {
+		return core.TrackASNMove
+	}
+	return core.TrackNetblockMove
+}
+
+// lastTarget returns the end of the CNAME chain, which is served by the provider.
+func lastTarget(targets []string) string {
+	if len(targets) == 0 {
+		return ""
+	}
+	return strings.ToLower(core.RemoveLastDot(targets[len(targets)-1]))
+}
+
+// cnameProvider returns the provider serving the CNAME target, as identified by the signatures,
+// the built-in provider signatures, or the ASNs announcing the addresses of the target. The
+// target is returned when none identify the provider.
+func cnameProvider(target string, addrs []core.AddressInfo, sigs []*TakeoverSignature) string {
+	for _, list := range [][]*TakeoverSignature{sigs, ProviderSignatures} {
+		for _, sig := range list {
+			if sig.Provider != "" && sig.Matches(target) {
+				return sig.Provider
+			}
+		}
+	}
+
+	var asns []int
+	seen := make(map[int]struct{})
+	for _, addr := range addrs {
+		if _, found := seen[addr.ASN]; addr.ASN != 0 && !found {
+			seen[addr.ASN] = struct{}{}
+			asns = append(asns, addr.ASN)
+		}
+	}
+	if len(asns) == 0 {
+		return target
+	}
+
+	sort.Ints(asns)
+	var providers []string
+	for _, asn := range asns {
+		providers = append(providers, "AS"+strconv.Itoa(asn))
+	}
+	return strings.Join(providers, ", ")
+}
+
+// MovementLabels returns the descriptions of the movement categories held by the event.
+func MovementLabels(e *core.TrackEvent) []string {
+	var labels []string
+
+	for _, c := range e.Categories {
+		if label, found := core.TrackCategoryLabels[c]; found {
+			labels = append(labels, label)
+		} else {
+			labels = append(labels, c)
+		}
+	}
+	return labels
+}
+
+func isIPv6(addr core.AddressInfo) bool {
+	return addr.Address != nil && addr.Address.To4() == nil
+}
+
+// providerStrings returns the unique ASNs announcing the addresses, along with their descriptions.
+func providerStrings(addrs []core.AddressInfo) []string {
+	seen := make(map[int]struct{})
+
+	var asns []int
+	desc := make(map[int]string)
+	for _, addr := range addrs {
+		if addr.ASN == 0 {
+			continue
+		}
+		if _, found := seen[addr.ASN]; !found {
+			seen[addr.ASN] = struct{}{}
+			asns = append(asns, addr.ASN)
+		}
+		if desc[addr.ASN] == "" {
+			desc[addr.ASN] = addr.Description
+		}
+	}
+	sort.Ints(asns)
+
+	var providers []string
+	for _, asn := range asns {
+		p := "AS" + strconv.Itoa(asn)
+		if d := strings.TrimSpace(desc[asn]); d != "" {
+			p += " " + d
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/root-secure/Amass/amass/core"
+)
+
+func TestClassifyMovement(t *testing.T) {
+	managed := compareTestAddr("192.0.2.10", "192.0.2.0/24", 64500)
+	managed.Description = "EXAMPLE-NET"
+	sameASN := compareTestAddr("198.51.100.10", "198.51.100.0/24", 64500)
+	hosting := compareTestAddr("203.0.113.9", "203.0.113.0/24", 64999)
+	hosting.Description = "CHEAP-HOSTING"
+	v6 := compareTestAddr("2001:db8::10", "2001:db8::/32", 64500)
+	unknown := compareTestAddr("198.51.100.20", "", 0)
+	other := compareTestAddr("198.51.100.5", "198.51.100.0/24", 64501)
+	// The same address is now announced by the ASN of another address
+	reannounced := compareTestAddr("192.0.2.10", "192.0.2.0/24", 64501)
+	cdnA := compareTestAddr("203.0.113.7", "203.0.113.0/24", 13335)
+	cdnB := compareTestAddr("203.0.113.8", "203.0.113.0/24", 13335)
+	cdnC := compareTestAddr("198.51.100.40", "198.51.100.0/24", 16509)
+
+	tests := []struct {
+		label      string
+		addrs      []core.AddressInfo
+		prev       []core.AddressInfo
+		cnames     []string
+		prevCNAMEs []string
+		expected   []string
+	}{
+		{"ASN move", []core.AddressInfo{hosting}, []core.AddressInfo{managed}, nil, nil,
+			[]string{core.TrackASNMove}},
+		{"netblock move", []core.AddressInfo{sameASN}, []core.AddressInfo{managed}, nil, nil,
+			[]string{core.TrackNetblockMove}},
+		{"same netblock", []core.AddressInfo{compareTestAddr("192.0.2.11", "192.0.2.0/24", 64500)},
+			[]core.AddressInfo{managed}, nil, nil, nil},
+		{"CDN migration", []core.AddressInfo{managed}, []core.AddressInfo{managed},
+			[]string{"d111.cloudfront.net"}, nil, []string{core.TrackCNAMETarget}},
+		{"unknown ASN", []core.AddressInfo{unknown}, []core.AddressInfo{managed}, nil, nil, nil},
+		{"ASN of another address", []core.AddressInfo{reannounced, other}, []core.AddressInfo{managed, other},
+			nil, nil, []string{core.TrackASNMove}},
+		{"same CNAME provider", []core.AddressInfo{cdnB}, []core.AddressInfo{cdnA},
+			[]string{"static.cdn-b.net"}, []string{"static.cdn-a.net"}, nil},
+		{"CNAME provider change", []core.AddressInfo{cdnC}, []core.AddressInfo{cdnA},
+			[]string{"static.cdn-c.net"}, []string{"static.cdn-a.net"},
+			[]string{core.TrackASNMove, core.TrackCNAMETarget}},
+		{"started resolving", []core.AddressInfo{managed}, nil, nil, nil,
+			[]string{core.TrackStartedResolving}},
+		{"stopped resolving", nil, []core.AddressInfo{managed}, nil, nil,
+			[]string{core.TrackStoppedResolving}},
+		{"IPv6 added", []core.AddressInfo{managed, v6}, []core.AddressInfo{managed}, nil, nil,
+			[]string{core.TrackNetblockMove, core.TrackIPv6Added}},
+	}
+
+	for _, test := range tests {
+		e := &core.TrackEvent{
+			Type:           core.TrackChanged,
+			CNAMETargets:   test.cnames,
+			PreviousCNAMEs: test.prevCNAMEs,
+		}
+
+		ClassifyMovement(e, test.addrs, test.prev, nil)
+		if !reflect.DeepEqual(e.Categories, test.expected) {
+			t.Errorf("%s: Returned the categories %v", test.label, e.Categories)
+		}
+	}
+
+	e := &core.TrackEvent{Type: core.TrackChanged}
+	ClassifyMovement(e, []core.AddressInfo{hosting}, []core.AddressInfo{managed}, nil)
+	if len(e.Providers) != 1 || e.Providers[0] != "AS64999 CHEAP-HOSTING" ||
+		len(e.PreviousProviders) != 1 || e.PreviousProviders[0] != "AS64500 EXAMPLE-NET" {
+		t.Errorf("Unexpected providers: %v and %v", e.Providers, e.PreviousProviders)
+	}
+	if labels := MovementLabels(e); len(labels) != 1 || labels[0] != "ASN or provider move" {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+
+	// The signatures identify the providers of targets served from the same ASN
+	sigs := []*TakeoverSignature{
+		{Provider: "CDN A", CNAME: []string{"cdn-a.net"}},
+		{Provider: "CDN B", CNAME: []string{"cdn-b.net"}},
+	}
+	e = &core.TrackEvent{
+		Type:           core.TrackChanged,
+		CNAMETargets:   []string{"static.cdn-b.net"},
+		PreviousCNAMEs: []string{"static.cdn-a.net"},
+	}
+	ClassifyMovement(e, []core.AddressInfo{cdnB}, []core.AddressInfo{cdnA}, sigs)
+	if !reflect.DeepEqual(e.Categories, []string{core.TrackCNAMETarget}) ||
+		!reflect.DeepEqual(e.Providers, []string{"CDN B"}) || !reflect.DeepEqual(e.PreviousProviders, []string{"CDN A"}) {
+		t.Errorf("The CNAME providers were not identified: %v, %v and %v", e.Categories, e.Providers, e.PreviousProviders)
+	}
+	// The built-in signatures are used without a signatures file
+	e = &core.TrackEvent{
+		Type:           core.TrackChanged,
+		CNAMETargets:   []string{"www.example.com.edgekey.net"},
+		PreviousCNAMEs: []string{"d111.cloudfront.net"},
+	}
+	ClassifyMovement(e, []core.AddressInfo{cdnB}, []core.AddressInfo{cdnA}, nil)
+	if !reflect.DeepEqual(e.Providers, []string{"Akamai"}) || !reflect.DeepEqual(e.PreviousProviders, []string{"Amazon CloudFront"}) {
+		t.Errorf("The built-in providers were not identified: %v and %v", e.Providers, e.PreviousProviders)
+	}
+}
@@ -23,11 +23,11 @@ type timesRequest struct {
 type NameService struct {
 	core.BaseService
 
-	filter            *utils.StringFilter
+	filter            utils.StringFilter
 	times             *utils.Queue
 	sanityRE          *regexp.Regexp
-	trustedNameFilter *utils.StringFilter
-	otherNameFilter   *utils.StringFilter
+	trustedNameFilter utils.StringFilter
+	otherNameFilter   utils.StringFilter
 	graph             handlers.DataHandler
 }
 
@@ -35,11 +35,11 @@ type NameService struct {
 // The object returned is initialized, but has not yet been started.
 func NewNameService(config *core.Config, bus *core.EventBus) *NameService {
 	ns := &NameService{
-		filter:            utils.NewStringFilter(),
+		filter:            config.NewStringFilter(),
 		times:             utils.NewQueue(),
 		sanityRE:          utils.AnySubdomainRegex(),
-		trustedNameFilter: utils.NewStringFilter(),
-		otherNameFilter:   utils.NewStringFilter(),
+		trustedNameFilter: config.NewStringFilter(),
+		otherNameFilter:   config.NewStringFilter(),
 	}
 	ns.BaseService = *core.NewBaseService(ns, "Name Service", config, bus)
 	return ns
@@ -56,6 +56,14 @@ func (ns *NameService) OnStart() error {
 	return nil
 }
 
+// OnStop implements the Service interface.
+func (ns *NameService) OnStop() error {
+	ns.filter.Close()
+	ns.trustedNameFilter.Close()
+	ns.otherNameFilter.Close()
+	return nil
+}
+
 // RegisterGraph makes the Graph available to the NameService.
 func (ns *NameService) RegisterGraph(graph handlers.DataHandler) {
 	ns.graph = graph
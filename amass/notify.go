@@ -0,0 +1,196 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils"
+)
+
+// Notifier is implemented by the services alerted when the track subcommand discovers changes.
+type Notifier interface {
+	fmt.Stringer
+
+	// Delivers the report of the changes discovered.
+	Notify(report *core.TrackReport) error
+}
+
+// NewNotifiers returns the notifiers configured by the notifications settings.
+func NewNotifiers(config *core.Config) []Notifier {
+	var notifiers []Notifier
+
+	for _, url := range config.NotifyWebhooks {
+		notifiers = append(notifiers, &WebhookNotifier{URL: url})
+	}
+	if config.NotifySMTPServer != "" {
+		notifiers = append(notifiers, &SMTPNotifier{
+			Server:   config.NotifySMTPServer,
+			Username: config.NotifySMTPUsername,
+			Password: config.NotifySMTPPassword,
+			From:     config.NotifyEmailFrom,
+			To:       config.NotifyEmailTo,
+		})
+	}
+	if config.NotifyExec != "" {
+		notifiers = append(notifiers, &ExecNotifier{Command: config.NotifyExec})
+	}
+	return notifiers
+}
+
+// WebhookNotifier sends the report in JSON format to the URL using a POST request.
+type WebhookNotifier struct {
+	URL string
+}
+
+// String implements the Notifier interface.
+func (w *WebhookNotifier) String() string {
+	return "Webhook " + w.URL
+}
+
+// Notify implements the Notifier interface.
+func (w *WebhookNotifier) Notify(report *core.TrackReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	_, err = utils.RequestWebPage(w.URL, bytes.NewReader(body),
+		map[string]string{"Content-Type": "application/json"}, "", "")
+	return err
+}
+
+// SMTPNotifier sends the report as a plain text email message via the SMTP server.
+type SMTPNotifier struct {
+	// The host and port of the SMTP server
+	Server string
+
+	// The credentials used to authenticate with the server, when provided
+	Username string
+	Password string
+
+	From string
+	To   []string
+}
+
+// String implements the Notifier interface.
+func (s *SMTPNotifier) String() string {
+	return "SMTP server " + s.Server
+}
+
+// Notify implements the Notifier interface.
+func (s *SMTPNotifier) Notify(report *core.TrackReport) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Server)
+		if err != nil {
+			host = s.Server
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Server, auth, s.From, s.To, s.message(report))
+}
+
+func (s *SMTPNotifier) message(report *core.TrackReport) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", s.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", TrackReportSubject(report))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+
+	for _, line := range TrackReportLines(report) {
+		buf.WriteString(line + "\r\n")
+	}
+	return buf.Bytes()
+}
+
+// ExecNotifier runs the command with the shell, providing the report in JSON format via
+// standard input. The number of changes and the domains are also provided by the
+// AMASS_CHANGES and AMASS_DOMAINS environment variables.
+type ExecNotifier struct {
+	Command string
+}
+
+// String implements the Notifier interface.
+func (e *ExecNotifier) String() string {
+	return "Command " + e.Command
+}
+
+// Notify implements the Notifier interface.
+func (e *ExecNotifier) Notify(report *core.TrackReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", e.Command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", e.Command)
+	}
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"AMASS_CHANGES="+strconv.Itoa(len(report.Events)),
+		"AMASS_DOMAINS="+strings.Join(report.Domains, ","),
+	)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// TrackReportSubject returns a one line summary of the changes in the report.
+func TrackReportSubject(report *core.TrackReport) string {
+	return fmt.Sprintf("OWASP Amass discovered %d changes for %s",
+		len(report.Events), strings.Join(report.Domains, ", "))
+}
+
+// TrackReportLines returns a line of plain text describing each change in the report.
+func TrackReportLines(report *core.TrackReport) []string {
+	var lines []string
+
+	for _, e := range report.Events {
+		var line string
+
+		switch e.Type {
+		case core.TrackAdded:
+			line = "Found: " + e.Name + " " + strings.Join(e.Addresses, ",")
+		case core.TrackRemoved:
+			line = "Removed: " + e.Name + " " + strings.Join(e.PreviousAddresses, ",")
+		case core.TrackChanged:
+			line = "Moved: " + e.Name + " from " + strings.Join(e.PreviousAddresses, ",") +
+				" to " + strings.Join(e.Addresses, ",")
+			if prev, cur := strings.Join(e.PreviousCNAMEs, ","), strings.Join(e.CNAMETargets, ","); prev != cur {
+				line += " (CNAME from " + prev + " to " + cur + ")"
+			}
+		}
+		if len(e.Categories) > 0 {
+			line += " [" + strings.Join(MovementLabels(e), ", ") + "]"
+		}
+		if e.Annotation != "" {
+			line += " {" + e.Annotation + "}"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
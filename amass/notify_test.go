@@ -0,0 +1,207 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+)
+
+func notifyTestReport() *core.TrackReport {
+	return &core.TrackReport{
+		Timestamp: time.Date(2019, 2, 2, 0, 0, 0, 0, time.UTC),
+		Domains:   []string{"example.com"},
+		Events: []*core.TrackEvent{
+			{
+				Type:         core.TrackAdded,
+				Name:         "api.example.com",
+				Domain:       "example.com",
+				Addresses:    []string{"192.0.2.11"},
+				Enum:         "22222222-2222-2222-2222-222222222222",
+				PreviousEnum: "11111111-1111-1111-1111-111111111111",
+			},
+			{
+				Type:              core.TrackChanged,
+				Name:              "www.example.com",
+				Domain:            "example.com",
+				Addresses:         []string{"192.0.2.11"},
+				PreviousAddresses: []string{"192.0.2.10"},
+				Enum:              "22222222-2222-2222-2222-222222222222",
+				PreviousEnum:      "11111111-1111-1111-1111-111111111111",
+				Annotation:        "owner: web-team",
+			},
+		},
+	}
+}
+
+func TestNewNotifiers(t *testing.T) {
+	config := &core.Config{
+		NotifyWebhooks:   []string{"https://hooks.example.com/a", "https://hooks.example.com/b"},
+		NotifyExec:       "logger",
+		NotifySMTPServer: "smtp.example.com:25",
+		NotifyEmailFrom:  "amass@example.com",
+		NotifyEmailTo:    []string{"secops@example.com"},
+	}
+
+	if n := len(NewNotifiers(config)); n != 4 {
+		t.Errorf("Returned %d notifiers", n)
+	}
+	if n := len(NewNotifiers(new(core.Config))); n != 0 {
+		t.Errorf("Returned %d notifiers without the notifications settings", n)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got core.TrackReport
+	var contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer ts.Close()
+
+	n := &WebhookNotifier{URL: ts.URL}
+	if err := n.Notify(notifyTestReport()); err != nil {
+		t.Fatalf("The webhook was not notified: %v", err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("The request had the content type %s", contentType)
+	}
+	if len(got.Events) != 2 || got.Events[1].PreviousAddresses[0] != "192.0.2.10" {
+		t.Errorf("The webhook received an unexpected report: %+v", got)
+	}
+
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer fail.Close()
+
+	n = &WebhookNotifier{URL: fail.URL}
+	if err := n.Notify(notifyTestReport()); err == nil {
+		t.Errorf("The failed webhook request was not reported")
+	}
+}
+
+func TestExecNotifier(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The test command requires a POSIX shell")
+	}
+
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.json")
+	n := &ExecNotifier{Command: "echo $AMASS_CHANGES $AMASS_DOMAINS > " + path + ".env; cat > " + path}
+	if err := n.Notify(notifyTestReport()); err != nil {
+		t.Fatalf("The command was not executed: %v", err)
+	}
+
+	env, _ := ioutil.ReadFile(path + ".env")
+	if got := strings.TrimSpace(string(env)); got != "2 example.com" {
+		t.Errorf("The command received the environment %s", got)
+	}
+	data, _ := ioutil.ReadFile(path)
+	var got core.TrackReport
+	if err := json.Unmarshal(data, &got); err != nil || len(got.Events) != 2 {
+		t.Errorf("The command did not receive the report: %s", data)
+	}
+
+	n = &ExecNotifier{Command: "echo failed >&2; exit 3"}
+	if err := n.Notify(notifyTestReport()); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("The failed command was not reported: %v", err)
+	}
+}
+
+// testSMTPServer accepts a single message and provides it via the channel.
+func testSMTPServer(t *testing.T) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start the SMTP server: %v", err)
+	}
+
+	msgs := make(chan string, 1)
+	go func() {
+		defer ln.Close()
+
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+		reply := func(line string) {
+			rw.WriteString(line + "\r\n")
+			rw.Flush()
+		}
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := rw.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+
+				var msg []string
+				for {
+					l, err := rw.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg = append(msg, l)
+				}
+				msgs <- strings.Join(msg, "")
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), msgs
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, msgs := testSMTPServer(t)
+
+	n := &SMTPNotifier{
+		Server: addr,
+		From:   "amass@example.com",
+		To:     []string{"secops@example.com"},
+	}
+	if err := n.Notify(notifyTestReport()); err != nil {
+		t.Fatalf("The message was not sent: %v", err)
+	}
+
+	msg := <-msgs
+	if !strings.Contains(msg, "Subject: OWASP Amass discovered 2 changes for example.com") {
+		t.Errorf("The message did not have the expected subject:\n%s", msg)
+	}
+	if !strings.Contains(msg, "Moved: www.example.com from 192.0.2.10 to 192.0.2.11 {owner: web-team}") {
+		t.Errorf("The message did not describe the changes:\n%s", msg)
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/sources"
+	"github.com/root-secure/Amass/amass/utils"
+	"github.com/miekg/dns"
+)
+
+// The infrastructure attributes shared between related root domains.
+const (
+	PivotNS  = "ns"
+	PivotMX  = "mx"
+	PivotSOA = "soa"
+)
+
+// pivotPool collects the candidate root domains and the evidence that led to them.
+type pivotPool struct {
+	sync.Mutex
+
+	config  *core.Config
+	attrs   map[string]map[string]struct{}
+	sources map[string][]string
+}
+
+func newPivotPool(config *core.Config) *pivotPool {
+	return &pivotPool{
+		config:  config,
+		attrs:   make(map[string]map[string]struct{}),
+		sources: make(map[string][]string),
+	}
+}
+
+// add records the domain as a candidate. The attribute is optional, since domains
+// in the graph are candidates until their SOA records have been checked.
+func (p *pivotPool) add(domain, attr, source string) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" || p.config.IsDomainInScope(domain) {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if _, found := p.attrs[domain]; !found {
+		p.attrs[domain] = make(map[string]struct{})
+	}
+	if attr != "" {
+		p.attrs[domain][attr] = struct{}{}
+	}
+	p.sources[domain] = utils.UniqueAppend(p.sources[domain], source)
+}
+
+// InfrastructurePivot discovers root domains sharing the nameservers, mail servers and
+// SOA contacts of the seed domains. Candidates are found using the graph, the data sources
+// supporting reverse NS and MX lookups, and the SOA records of the root domains in the graph.
+// Each candidate is scored by the number of attributes shared with the seed domains.
+func (ic *IntelCollection) InfrastructurePivot() error {
+	defer close(ic.Output)
+
+	if err := ic.Config.CheckSettings(); err != nil {
+		return err
+	}
+
+	seeds := make(map[string]struct{})
+	for _, domain := range ic.Config.Domains() {
+		for _, attr := range ic.domainAttributes(domain) {
+			seeds[attr] = struct{}{}
+		}
+	}
+	if len(seeds) == 0 {
+		err := errors.New("No nameservers, mail servers or SOA contacts were found for the seed domains")
+		ic.Config.Log.Printf("%v", err)
+		return err
+	}
+
+	pool := newPivotPool(ic.Config)
+	if ic.Graph != nil {
+		ic.graphPivots(seeds, pool)
+	}
+	ic.sourcePivots(seeds, pool)
+
+	candidates := ic.scoreCandidates(seeds, pool)
+	ic.pivotLock.Lock()
+	ic.pivots = candidates
+	ic.pivotLock.Unlock()
+
+	for _, c := range candidates {
+		ic.Output <- &core.Output{
+			Name:   c.Domain,
+			Domain: c.Domain,
+			Tag:    core.DNS,
+			Source: c.Sources[0],
+			Method: core.IntelPivot,
+		}
+	}
+	return nil
+}
+
+// Pivots returns the candidates discovered by InfrastructurePivot, highest score first.
+func (ic *IntelCollection) Pivots() []*core.PivotCandidate {
+	ic.pivotLock.Lock()
+	defer ic.pivotLock.Unlock()
+
+	candidates := make([]*core.PivotCandidate, len(ic.pivots))
+	copy(candidates, ic.pivots)
+	return candidates
+}
+
+// domainAttributes returns the NS, MX and SOA RNAME attributes of the domain.
+func (ic *IntelCollection) domainAttributes(domain string) []string {
+	var attrs []string
+
+	for _, qtype := range []string{"NS", "MX", "SOA"} {
+		answers, err := ic.resolve(domain, qtype)
+		if err != nil {
+			continue
+		}
+
+		for _, a := range answers {
+			switch uint16(a.Type) {
+			case dns.TypeNS:
+				attrs = utils.UniqueAppend(attrs, PivotNS+":"+pivotHost(a.Data))
+			case dns.TypeMX:
+				attrs = utils.UniqueAppend(attrs, PivotMX+":"+pivotHost(a.Data))
+			case dns.TypeSOA:
+				// The data holds the primary nameserver followed by the RNAME
+				if fields := strings.Fields(a.Data); len(fields) == 2 {
+					attrs = utils.UniqueAppend(attrs, PivotSOA+":"+soaContact(fields[1]))
+				}
+			}
+		}
+	}
+	return attrs
+}
+
+func (ic *IntelCollection) graphPivots(seeds map[string]struct{}, pool *pivotPool) {
+	for _, uuid := range ic.Graph.EnumerationList() {
+		nodes, edges := ic.Graph.VizData(uuid)
+
+		roots := make(map[int]string)
+		for _, n := range nodes {
+			// The SOA records of the root domains are checked while scoring
+			if n.Type == "domain" {
+				roots[n.ID] = n.Label
+				pool.add(n.Label, "", "Graph")
+			}
+		}
+		// The graph already knows the root domain of each subdomain
+		for _, e := range edges {
+			if e.Title == "root_of" && e.From < len(nodes) && e.To < len(nodes) {
+				roots[e.To] = nodes[e.From].Label
+			}
+		}
+
+		for _, e := range edges {
+			var kind string
+			switch e.Title {
+			case "ns_to":
+				kind = PivotNS
+			case "mx_to":
+				kind = PivotMX
+			default:
+				continue
+			}
+			if e.From >= len(nodes) || e.To >= len(nodes) {
+				continue
+			}
+
+			attr := kind + ":" + pivotHost(nodes[e.To].Label)
+			if _, found := seeds[attr]; !found {
+				continue
+			}
+
+			domain, found := roots[e.From]
+			if !found {
+				domain = core.SubdomainToDomain(nodes[e.From].Label)
+			}
+			pool.add(domain, attr, "Graph")
+		}
+	}
+}
+
+func (ic *IntelCollection) sourcePivots(seeds map[string]struct{}, pool *pivotPool) {
+	srcs := sources.GetAllSources(ic.Config, ic.Bus)
+	// Select the data sources desired by the user
+	if len(ic.Config.DisabledDataSources) > 0 {
+		srcs = ic.Config.ExcludeDisabledDataSources(srcs)
+	}
+
+	for _, src := range srcs {
+		rl, ok := src.(core.ReverseLookup)
+		if !ok {
+			continue
+		}
+		if err := src.Start(); err != nil {
+			src.Stop()
+			continue
+		}
+
+		for attr := range seeds {
+			select {
+			case <-ic.Done:
+				src.Stop()
+				return
+			default:
+			}
+
+			parts := strings.SplitN(attr, ":", 2)
+			if parts[0] == PivotSOA {
+				continue
+			}
+
+			domains, err := rl.ReverseLookup(strings.ToUpper(parts[0]), parts[1])
+			if err != nil {
+				ic.Config.Log.Printf("%v", err)
+				continue
+			}
+			for _, d := range domains {
+				pool.add(core.SubdomainToDomain(d), attr, src.String())
+			}
+		}
+		src.Stop()
+	}
+}
+
+// scoreCandidates obtains the current attributes of each candidate, and keeps
+// the candidates sharing at least one attribute with the seed domains.
+func (ic *IntelCollection) scoreCandidates(seeds map[string]struct{}, pool *pivotPool) []*core.PivotCandidate {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var candidates []*core.PivotCandidate
+
+loop:
+	for domain, evidence := range pool.attrs {
+		select {
+		case <-ic.Done:
+			break loop
+		default:
+		}
+
+		wg.Add(1)
+		ic.Config.SemMaxDNSQueries.Acquire(1)
+		go func(domain string, evidence map[string]struct{}) {
+			defer wg.Done()
+			defer ic.Config.SemMaxDNSQueries.Release(1)
+
+			shared := make(map[string]struct{})
+			for attr := range evidence {
+				shared[attr] = struct{}{}
+			}
+			for _, attr := range ic.domainAttributes(domain) {
+				if _, found := seeds[attr]; found {
+					shared[attr] = struct{}{}
+				}
+			}
+			if len(shared) == 0 {
+				return
+			}
+
+			c := &core.PivotCandidate{
+				Domain:  domain,
+				Score:   len(shared),
+				Sources: pool.sources[domain],
+			}
+			for attr := range shared {
+				c.Shared = append(c.Shared, attr)
+			}
+			sort.Strings(c.Shared)
+
+			lock.Lock()
+			candidates = append(candidates, c)
+			lock.Unlock()
+		}(domain, evidence)
+	}
+	wg.Wait()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Domain < candidates[j].Domain
+	})
+	return candidates
+}
+
+func pivotHost(name string) string {
+	return strings.ToLower(core.RemoveLastDot(strings.TrimSpace(name)))
+}
+
+// soaContact converts the SOA RNAME to an email address, where the first
+// unescaped label separator represents the '@' (RFC 1035 section 8).
+func soaContact(mbox string) string {
+	mbox = pivotHost(mbox)
+
+	for i := 0; i < len(mbox); i++ {
+		if mbox[i] == '\\' {
+			i++
+			continue
+		}
+		if mbox[i] == '.' {
+			return strings.Replace(mbox[:i], "\\", "", -1) + "@" + mbox[i+1:]
+		}
+	}
+	return mbox
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/handlers"
+	"github.com/miekg/dns"
+)
+
+func TestInfrastructurePivot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pivot")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	graph := handlers.NewGraph(dir)
+	if graph == nil {
+		t.Fatalf("Failed to create the graph")
+	}
+	defer graph.Close()
+
+	// A previous enumeration of another organization using the same nameserver
+	for _, opt := range []*handlers.DataOptsParams{
+		{Type: handlers.OptDomain, Domain: "acquired.com"},
+		{Type: handlers.OptNS, Name: "acquired.com", Domain: "acquired.com",
+			TargetName: "ns1.seed-dns.net", TargetDomain: "seed-dns.net"},
+		{Type: handlers.OptDomain, Domain: "contact.org"},
+		{Type: handlers.OptDomain, Domain: "unrelated.net"},
+	} {
+		opt.UUID = "enum1"
+		opt.Timestamp = "2019-01-01T00:00:00Z"
+		if err := graph.Insert(opt); err != nil {
+			t.Fatalf("Failed to insert the data operation: %v", err)
+		}
+	}
+
+	records := map[string][]core.DNSAnswer{
+		"example.com:NS":   {{Type: int(dns.TypeNS), Data: "ns1.seed-dns.net"}},
+		"example.com:MX":   {{Type: int(dns.TypeMX), Data: "MX.Example.com."}},
+		"example.com:SOA":  {{Type: int(dns.TypeSOA), Data: "ns1.seed-dns.net. dns\\.admin.example.com."}},
+		"acquired.com:MX":  {{Type: int(dns.TypeMX), Data: "mx.example.com"}},
+		"contact.org:SOA":  {{Type: int(dns.TypeSOA), Data: "ns.contact.org. dns\\.admin.example.com."}},
+		"unrelated.net:NS": {{Type: int(dns.TypeNS), Data: "ns.unrelated.net"}},
+	}
+
+	ic := NewIntelCollection()
+	ic.Config = setupConfig("example.com")
+	// Keep the data sources from performing reverse lookups over the network
+	ic.Config.DisabledDataSources = []string{"HackerTarget", "Umbrella", "ViewDNS"}
+	ic.Graph = graph
+	ic.resolve = func(name, qtype string) ([]core.DNSAnswer, error) {
+		if answers, found := records[name+":"+qtype]; found {
+			return answers, nil
+		}
+		return nil, errors.New("NXDOMAIN")
+	}
+
+	go ic.InfrastructurePivot()
+	var names []string
+	for out := range ic.Output {
+		names = append(names, out.Domain)
+	}
+
+	candidates := ic.Pivots()
+	if len(candidates) != 2 || len(names) != 2 {
+		t.Fatalf("The pivot returned %d candidates and %d names, expected 2", len(candidates), len(names))
+	}
+
+	// The nameserver and mail server are shared with the seed domain
+	if c := candidates[0]; c.Domain != "acquired.com" || c.Score != 2 ||
+		c.Shared[0] != "mx:mx.example.com" || c.Shared[1] != "ns:ns1.seed-dns.net" {
+		t.Errorf("The first candidate was %+v", c)
+	}
+	if c := candidates[1]; c.Domain != "contact.org" || c.Score != 1 ||
+		c.Shared[0] != "soa:dns.admin@example.com" {
+		t.Errorf("The second candidate was %+v", c)
+	}
+}
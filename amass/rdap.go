@@ -0,0 +1,543 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils"
+)
+
+// The RDAP object classes returned by the RDAPClient queries.
+const (
+	RDAPDomain  = "domain"
+	RDAPNetwork = "ip network"
+	RDAPAutnum  = "autnum"
+)
+
+const (
+	// DefaultRDAPBootstrapURL is the location of the IANA RDAP bootstrap registries.
+	DefaultRDAPBootstrapURL = "https://data.iana.org/rdap/"
+
+	// The cached copy of a bootstrap registry is obtained again once older than this
+	rdapBootstrapTTL = 24 * time.Hour
+
+	rdapMediaType = "application/rdap+json"
+)
+
+// The IANA bootstrap registries (RFC 7484)
+const (
+	rdapRegistryDNS  = "dns"
+	rdapRegistryIPv4 = "ipv4"
+	rdapRegistryIPv6 = "ipv6"
+	rdapRegistryASN  = "asn"
+)
+
+// RDAPClient queries the RDAP servers responsible for IP networks, autonomous systems
+// and domain names, as identified by the IANA bootstrap registries.
+type RDAPClient struct {
+	sync.Mutex
+
+	// The base URL of the bootstrap registry files
+	BootstrapURL string
+
+	// The directory keeping a copy of the bootstrap registries between executions
+	CacheDir string
+
+	registries map[string][]*rdapService
+}
+
+type rdapService struct {
+	Entries []string
+	URLs    []string
+}
+
+type rdapBootstrap struct {
+	Services [][][]string `json:"services"`
+}
+
+type rdapObject struct {
+	ObjectClassName string       `json:"objectClassName"`
+	Handle          string       `json:"handle"`
+	LDHName         string       `json:"ldhName"`
+	Name            string       `json:"name"`
+	Country         string       `json:"country"`
+	StartAddress    string       `json:"startAddress"`
+	EndAddress      string       `json:"endAddress"`
+	StartAutnum     int          `json:"startAutnum"`
+	CIDRs           []rdapCIDR   `json:"cidr0_cidrs"`
+	Events          []rdapEvent  `json:"events"`
+	Entities        []rdapEntity `json:"entities"`
+	Nameservers     []struct {
+		LDHName string `json:"ldhName"`
+	} `json:"nameservers"`
+	Links []struct {
+		Rel  string `json:"rel"`
+		Href string `json:"href"`
+		Type string `json:"type"`
+	} `json:"links"`
+}
+
+type rdapCIDR struct {
+	V4Prefix string `json:"v4prefix"`
+	V6Prefix string `json:"v6prefix"`
+	Length   int    `json:"length"`
+}
+
+type rdapEvent struct {
+	Action string `json:"eventAction"`
+	Date   string `json:"eventDate"`
+}
+
+type rdapEntity struct {
+	Handle   string        `json:"handle"`
+	Roles    []string      `json:"roles"`
+	VCard    []interface{} `json:"vcardArray"`
+	Entities []rdapEntity  `json:"entities"`
+}
+
+// RDAPCacheDir returns the directory used for the copy of the bootstrap registries.
+func RDAPCacheDir(config *core.Config) string {
+	dir := core.OutputDirectory(config.Dir)
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "rdap")
+}
+
+// NewRDAPClient returns a client that keeps the bootstrap registries in the cache
+// directory provided. The registries are only kept in memory when cacheDir is empty.
+func NewRDAPClient(cacheDir string) *RDAPClient {
+	return &RDAPClient{
+		BootstrapURL: DefaultRDAPBootstrapURL,
+		CacheDir:     cacheDir,
+		registries:   make(map[string][]*rdapService),
+	}
+}
+
+// QueryDomain returns the registration data for the domain name.
+func (c *RDAPClient) QueryDomain(domain string) (*core.RDAPRecord, error) {
+	domain = strings.ToLower(core.RemoveLastDot(domain))
+
+	services, err := c.registry(rdapRegistryDNS)
+	if err != nil {
+		return nil, err
+	}
+
+	var base string
+	labels := strings.Split(domain, ".")
+	// The longest matching suffix identifies the responsible server
+	for i := 0; i < len(labels) && base == ""; i++ {
+		suffix := strings.Join(labels[i:], ".")
+
+		for _, svc := range services {
+			if containsFold(svc.Entries, suffix) {
+				base = svc.baseURL()
+				break
+			}
+		}
+	}
+	if base == "" {
+		return nil, fmt.Errorf("No RDAP server was identified for %s", domain)
+	}
+	return c.query(base+"domain/"+domain, domain)
+}
+
+// QueryIP returns the registration data for the IP network containing the address,
+// or matching the CIDR notation provided.
+func (c *RDAPClient) QueryIP(addr string) (*core.RDAPRecord, error) {
+	path := addr
+	ip := net.ParseIP(addr)
+	if _, ipnet, err := net.ParseCIDR(addr); err == nil {
+		ip = ipnet.IP
+		path = ipnet.String()
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("%s is not a valid IP address", addr)
+	}
+
+	registry := rdapRegistryIPv6
+	if ip.To4() != nil {
+		registry = rdapRegistryIPv4
+	}
+
+	services, err := c.registry(registry)
+	if err != nil {
+		return nil, err
+	}
+
+	var base string
+	longest := -1
+	for _, svc := range services {
+		for _, entry := range svc.Entries {
+			_, ipnet, err := net.ParseCIDR(entry)
+			if err != nil || !ipnet.Contains(ip) {
+				continue
+			}
+
+			if ones, _ := ipnet.Mask.Size(); ones > longest {
+				longest = ones
+				base = svc.baseURL()
+			}
+		}
+	}
+	if base == "" {
+		return nil, fmt.Errorf("No RDAP server was identified for %s", addr)
+	}
+	return c.query(base+"ip/"+path, addr)
+}
+
+// QueryASN returns the registration data for the autonomous system.
+func (c *RDAPClient) QueryASN(asn int) (*core.RDAPRecord, error) {
+	services, err := c.registry(rdapRegistryASN)
+	if err != nil {
+		return nil, err
+	}
+
+	var base string
+	for _, svc := range services {
+		for _, entry := range svc.Entries {
+			parts := strings.SplitN(entry, "-", 2)
+
+			first, err := strconv.Atoi(parts[0])
+			if err != nil {
+				continue
+			}
+			last := first
+			if len(parts) == 2 {
+				if last, err = strconv.Atoi(parts[1]); err != nil {
+					continue
+				}
+			}
+
+			if asn >= first && asn <= last {
+				base = svc.baseURL()
+				break
+			}
+		}
+	}
+	if base == "" {
+		return nil, fmt.Errorf("No RDAP server was identified for AS%d", asn)
+	}
+
+	rec, err := c.query(base+"autnum/"+strconv.Itoa(asn), strconv.Itoa(asn))
+	if err == nil && rec.ASN == 0 {
+		rec.ASN = asn
+	}
+	return rec, err
+}
+
+func (c *RDAPClient) query(u, query string) (*core.RDAPRecord, error) {
+	obj, err := c.fetchObject(u)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := obj.record()
+	rec.Query = query
+	rec.URL = u
+	// Registries commonly refer to the registrar for the registrant details of a domain
+	if rec.Class == RDAPDomain && rec.Registrant == "" {
+		for _, link := range obj.Links {
+			if link.Rel != "related" || !strings.Contains(link.Type, rdapMediaType) {
+				continue
+			}
+
+			if related, err := c.fetchObject(link.Href); err == nil {
+				mergeRDAPRecords(rec, related.record())
+			}
+			break
+		}
+	}
+	return rec, nil
+}
+
+func (c *RDAPClient) fetchObject(u string) (*rdapObject, error) {
+	page, err := utils.RequestWebPage(u, nil, map[string]string{"Accept": rdapMediaType}, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("The RDAP query %s failed: %v", u, err)
+	}
+
+	var obj rdapObject
+	if err := json.Unmarshal([]byte(page), &obj); err != nil {
+		return nil, fmt.Errorf("Failed to parse the RDAP response from %s: %v", u, err)
+	}
+	if obj.ObjectClassName == "" {
+		return nil, fmt.Errorf("The RDAP response from %s did not contain an object", u)
+	}
+	return &obj, nil
+}
+
+// registry returns the services of the bootstrap registry, obtaining a new copy
+// when the cached copy is missing or has expired.
+func (c *RDAPClient) registry(name string) ([]*rdapService, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if services, found := c.registries[name]; found {
+		return services, nil
+	}
+
+	var path string
+	var stale []byte
+	if c.CacheDir != "" {
+		path = filepath.Join(c.CacheDir, name+".json")
+
+		if info, err := os.Stat(path); err == nil {
+			data, err := ioutil.ReadFile(path)
+
+			if err == nil && time.Since(info.ModTime()) < rdapBootstrapTTL {
+				if services, err := parseRDAPBootstrap(data); err == nil {
+					c.registries[name] = services
+					return services, nil
+				}
+			}
+			stale = data
+		}
+	}
+
+	var services []*rdapService
+	page, err := utils.RequestWebPage(c.BootstrapURL+name+".json", nil, nil, "", "")
+	if err == nil {
+		if services, err = parseRDAPBootstrap([]byte(page)); err == nil && path != "" {
+			if err := os.MkdirAll(c.CacheDir, 0755); err == nil {
+				ioutil.WriteFile(path, []byte(page), 0644)
+			}
+		}
+	}
+	// An expired copy is better than no bootstrap data
+	if err != nil && stale != nil {
+		services, err = parseRDAPBootstrap(stale)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to obtain the RDAP %s bootstrap registry: %v", name, err)
+	}
+
+	c.registries[name] = services
+	return services, nil
+}
+
+func parseRDAPBootstrap(data []byte) ([]*rdapService, error) {
+	var bootstrap rdapBootstrap
+
+	if err := json.Unmarshal(data, &bootstrap); err != nil {
+		return nil, err
+	}
+	if len(bootstrap.Services) == 0 {
+		return nil, errors.New("The bootstrap registry did not contain services")
+	}
+
+	var services []*rdapService
+	for _, svc := range bootstrap.Services {
+		if len(svc) < 2 {
+			continue
+		}
+		// The entries and URLs are always the last two members of a service
+		services = append(services, &rdapService{
+			Entries: svc[len(svc)-2],
+			URLs:    svc[len(svc)-1],
+		})
+	}
+	return services, nil
+}
+
+// baseURL returns the service URL, preferring HTTPS, always ending with a slash.
+func (svc *rdapService) baseURL() string {
+	var base string
+
+	for _, u := range svc.URLs {
+		if base == "" || strings.HasPrefix(u, "https://") {
+			base = u
+		}
+	}
+	if base != "" && !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return base
+}
+
+func (obj *rdapObject) record() *core.RDAPRecord {
+	rec := &core.RDAPRecord{
+		Timestamp: time.Now(),
+		Class:     strings.ToLower(obj.ObjectClassName),
+		Handle:    obj.Handle,
+		Name:      obj.Name,
+		Country:   obj.Country,
+		Tag:       core.API,
+		Source:    "RDAP",
+	}
+
+	switch rec.Class {
+	case RDAPDomain:
+		rec.Domain = strings.ToLower(core.RemoveLastDot(obj.LDHName))
+	case RDAPAutnum:
+		rec.ASN = obj.StartAutnum
+	case RDAPNetwork:
+		rec.CIDRs = obj.netblocks()
+	}
+
+	for _, event := range obj.Events {
+		t, err := time.Parse(time.RFC3339, event.Date)
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(event.Action) {
+		case "registration":
+			rec.Registered = t
+		case "expiration":
+			rec.Expires = t
+		case "last changed":
+			rec.Updated = t
+		}
+	}
+
+	for _, ns := range obj.Nameservers {
+		if name := strings.ToLower(core.RemoveLastDot(ns.LDHName)); name != "" {
+			rec.Nameservers = utils.UniqueAppend(rec.Nameservers, name)
+		}
+	}
+
+	if e := findRDAPEntity(obj.Entities, "registrant"); e != nil {
+		rec.Registrant = e.displayName()
+		rec.RegistrantEmail = e.vcardValue("email")
+	}
+	if e := findRDAPEntity(obj.Entities, "registrar"); e != nil {
+		rec.Registrar = e.displayName()
+	}
+	if e := findRDAPEntity(obj.Entities, "abuse"); e != nil {
+		rec.AbuseEmail = e.vcardValue("email")
+	}
+	return rec
+}
+
+func (obj *rdapObject) netblocks() []string {
+	var cidrs []string
+
+	for _, c := range obj.CIDRs {
+		prefix := c.V4Prefix
+		if prefix == "" {
+			prefix = c.V6Prefix
+		}
+
+		if _, ipnet, err := net.ParseCIDR(prefix + "/" + strconv.Itoa(c.Length)); err == nil {
+			cidrs = append(cidrs, ipnet.String())
+		}
+	}
+	if len(cidrs) > 0 {
+		return cidrs
+	}
+
+	start, end := net.ParseIP(obj.StartAddress), net.ParseIP(obj.EndAddress)
+	if start == nil || end == nil {
+		return nil
+	}
+	for _, ipnet := range rangeToCIDRs(start, end) {
+		cidrs = append(cidrs, ipnet.String())
+	}
+	return cidrs
+}
+
+// mergeRDAPRecords fills the registration details missing from rec using other.
+func mergeRDAPRecords(rec, other *core.RDAPRecord) {
+	if rec.Registrant == "" {
+		rec.Registrant = other.Registrant
+		rec.RegistrantEmail = other.RegistrantEmail
+	}
+	if rec.Registrar == "" {
+		rec.Registrar = other.Registrar
+	}
+	if rec.AbuseEmail == "" {
+		rec.AbuseEmail = other.AbuseEmail
+	}
+	if rec.Expires.IsZero() {
+		rec.Expires = other.Expires
+	}
+	if len(rec.Nameservers) == 0 {
+		rec.Nameservers = other.Nameservers
+	}
+}
+
+// findRDAPEntity searches the entities, and the entities nested within them, for the role.
+func findRDAPEntity(entities []rdapEntity, role string) *rdapEntity {
+	for i := range entities {
+		if containsFold(entities[i].Roles, role) {
+			return &entities[i]
+		}
+	}
+	for i := range entities {
+		if e := findRDAPEntity(entities[i].Entities, role); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// displayName returns the organization of the entity, or the full name when not available.
+func (e *rdapEntity) displayName() string {
+	if org := e.vcardValue("org"); org != "" {
+		return org
+	}
+	return e.vcardValue("fn")
+}
+
+// vcardValue returns the first value of the jCard (RFC 7095) property requested.
+func (e *rdapEntity) vcardValue(prop string) string {
+	if len(e.VCard) < 2 {
+		return ""
+	}
+
+	props, ok := e.VCard[1].([]interface{})
+	if !ok {
+		return ""
+	}
+	for _, p := range props {
+		fields, ok := p.([]interface{})
+		if !ok || len(fields) < 4 {
+			continue
+		}
+		if name, _ := fields[0].(string); !strings.EqualFold(name, prop) {
+			continue
+		}
+
+		switch v := fields[3].(type) {
+		case string:
+			if v = strings.TrimSpace(v); v != "" {
+				return v
+			}
+		case []interface{}:
+			// Structured values, such as an organization with units
+			var parts []string
+			for _, part := range v {
+				if s, ok := part.(string); ok && strings.TrimSpace(s) != "" {
+					parts = append(parts, strings.TrimSpace(s))
+				}
+			}
+			if len(parts) > 0 {
+				return strings.Join(parts, ", ")
+			}
+		}
+	}
+	return ""
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+var rdapTestResponses = map[string]string{
+	"/rdap/domain/example.com": `{
+		"objectClassName": "domain",
+		"handle": "2336799_DOMAIN_COM-VRSN",
+		"ldhName": "EXAMPLE.COM",
+		"events": [
+			{"eventAction": "registration", "eventDate": "1995-08-14T04:00:00Z"},
+			{"eventAction": "expiration", "eventDate": "2030-08-13T04:00:00Z"}
+		],
+		"nameservers": [{"ldhName": "A.IANA-SERVERS.NET"}, {"ldhName": "B.IANA-SERVERS.NET."}],
+		"entities": [{
+			"roles": ["registrar"],
+			"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "RESERVED-Internet Assigned Numbers Authority"]]]
+		}],
+		"links": [{"rel": "related", "type": "application/rdap+json", "href": "{base}/registrar/domain/example.com"}]
+	}`,
+	"/registrar/domain/example.com": `{
+		"objectClassName": "domain",
+		"ldhName": "example.com",
+		"entities": [{
+			"roles": ["registrant"],
+			"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Domain Administrator"],
+				["org", {}, "text", "Example Organization"], ["email", {}, "text", "admin@example.com"]]],
+			"entities": [{
+				"roles": ["abuse"],
+				"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["email", {}, "text", "abuse@example.com"]]]
+			}]
+		}]
+	}`,
+	"/rdap/autnum/64496": `{
+		"objectClassName": "autnum",
+		"handle": "AS64496",
+		"startAutnum": 64496,
+		"endAutnum": 64496,
+		"name": "EXAMPLE-AS",
+		"entities": [{
+			"roles": ["registrant"],
+			"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example Networks"]]]
+		}]
+	}`,
+	"/rdap/ip/192.0.2.1": `{
+		"objectClassName": "ip network",
+		"handle": "NET-192-0-2-0-1",
+		"startAddress": "192.0.2.0",
+		"endAddress": "192.0.2.255",
+		"name": "TEST-NET-1",
+		"country": "US"
+	}`,
+}
+
+func TestRDAPClient(t *testing.T) {
+	var base string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bootstrap := map[string]string{
+			"/dns.json":  `{"services": [[["org", "com"], ["{base}/rdap/"]]]}`,
+			"/asn.json":  `{"services": [[["1-1876", "64496-64511"], ["{base}/rdap"]]]}`,
+			"/ipv4.json": `{"services": [[["0.0.0.0/0"], ["http://unused.example/"]], [["192.0.0.0/8"], ["{base}/rdap/"]]]}`,
+			"/ipv6.json": `{"services": [[["2001:db8::/32"], ["{base}/rdap/"]]]}`,
+		}
+
+		body, found := bootstrap[r.URL.Path]
+		if !found {
+			body, found = rdapTestResponses[r.URL.Path]
+		}
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, strings.Replace(body, "{base}", base, -1))
+	}))
+	defer srv.Close()
+	base = srv.URL
+
+	dir, err := ioutil.TempDir("", "rdap")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	client := NewRDAPClient(dir)
+	client.BootstrapURL = srv.URL + "/"
+
+	if _, err := client.QueryDomain("www.example.com"); err == nil {
+		t.Errorf("A query for a name without registration data did not return an error")
+	}
+	rec, err := client.QueryDomain("Example.com.")
+	if err != nil {
+		t.Fatalf("The domain query failed: %v", err)
+	}
+	if rec.Class != RDAPDomain || rec.Domain != "example.com" {
+		t.Errorf("The domain record was not identified: %+v", rec)
+	}
+	if rec.Registrant != "Example Organization" || rec.RegistrantEmail != "admin@example.com" ||
+		rec.AbuseEmail != "abuse@example.com" {
+		t.Errorf("The registrant from the registrar was not included: %+v", rec)
+	}
+	if rec.Registrar != "RESERVED-Internet Assigned Numbers Authority" {
+		t.Errorf("The registrar was %q", rec.Registrar)
+	}
+	if rec.Registered.Year() != 1995 || rec.Expires.Year() != 2030 {
+		t.Errorf("The registration dates were %v and %v", rec.Registered, rec.Expires)
+	}
+	if len(rec.Nameservers) != 2 || rec.Nameservers[1] != "b.iana-servers.net" {
+		t.Errorf("The nameservers were %v", rec.Nameservers)
+	}
+
+	rec, err = client.QueryASN(64496)
+	if err != nil {
+		t.Fatalf("The ASN query failed: %v", err)
+	}
+	if rec.ASN != 64496 || rec.Name != "EXAMPLE-AS" || rec.Registrant != "Example Networks" {
+		t.Errorf("The ASN record was not complete: %+v", rec)
+	}
+	if _, err := client.QueryASN(3000); err == nil {
+		t.Errorf("An ASN missing from the bootstrap registry did not return an error")
+	}
+
+	// The most specific bootstrap entry must be selected
+	rec, err = client.QueryIP("192.0.2.1")
+	if err != nil {
+		t.Fatalf("The IP query failed: %v", err)
+	}
+	if len(rec.CIDRs) != 1 || rec.CIDRs[0] != "192.0.2.0/24" || rec.Country != "US" {
+		t.Errorf("The IP network record was not complete: %+v", rec)
+	}
+
+	// The cached copy of the bootstrap registries is used by new clients
+	cached := NewRDAPClient(dir)
+	cached.BootstrapURL = "http://127.0.0.1:1/"
+	if _, err := cached.QueryASN(64496); err != nil {
+		t.Errorf("The cached bootstrap registry was not used: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"strconv"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils"
+)
+
+// RDAPService is the Service that obtains the registration data of the root domains,
+// autonomous systems and netblocks discovered during the enumeration.
+type RDAPService struct {
+	core.BaseService
+
+	// RDAP servers are quick to rate limit clients
+	maxQueries utils.Semaphore
+	filter     utils.StringFilter
+	client     *RDAPClient
+}
+
+// NewRDAPService returns he object initialized, but not yet started.
+func NewRDAPService(config *core.Config, bus *core.EventBus) *RDAPService {
+	rs := &RDAPService{
+		maxQueries: utils.NewSimpleSemaphore(5),
+		filter:     config.NewStringFilter(),
+		client:     NewRDAPClient(RDAPCacheDir(config)),
+	}
+
+	rs.BaseService = *core.NewBaseService(rs, "RDAP", config, bus)
+	return rs
+}
+
+// OnStart implements the Service interface
+func (rs *RDAPService) OnStart() error {
+	rs.BaseService.OnStart()
+
+	rs.Bus().Subscribe(core.NameResolvedTopic, rs.nameResolved)
+	rs.Bus().Subscribe(core.NewASNTopic, rs.SendASNRequest)
+	go rs.processRequests()
+	return nil
+}
+
+// OnStop implements the Service interface.
+func (rs *RDAPService) OnStop() error {
+	rs.filter.Close()
+	return nil
+}
+
+func (rs *RDAPService) nameResolved(req *core.DNSRequest) {
+	if req.Domain != "" && rs.Config().IsDomainInScope(req.Domain) {
+		rs.SendWhoisRequest(&core.WhoisRequest{Domain: req.Domain})
+	}
+}
+
+func (rs *RDAPService) processRequests() {
+	for {
+		select {
+		case <-rs.PauseChan():
+			<-rs.ResumeChan()
+		case <-rs.Quit():
+			return
+		case req := <-rs.WhoisRequestChan():
+			if !rs.filter.Duplicate(req.Domain) {
+				rs.maxQueries.Acquire(1)
+				go rs.queryDomain(req.Domain)
+			}
+		case req := <-rs.ASNRequestChan():
+			if req.ASN != 0 && !rs.filter.Duplicate("AS"+strconv.Itoa(req.ASN)) {
+				rs.maxQueries.Acquire(1)
+				go rs.queryASN(req.ASN)
+			}
+			if req.Prefix != "" && !rs.filter.Duplicate(req.Prefix) {
+				rs.maxQueries.Acquire(1)
+				go rs.queryNetblock(req.Prefix)
+			}
+		case <-rs.DNSRequestChan():
+		case <-rs.AddrRequestChan():
+		}
+	}
+}
+
+func (rs *RDAPService) queryDomain(domain string) {
+	defer rs.maxQueries.Release(1)
+
+	rs.SetActive()
+	rec, err := rs.client.QueryDomain(domain)
+	rs.publish(rec, err)
+}
+
+func (rs *RDAPService) queryASN(asn int) {
+	defer rs.maxQueries.Release(1)
+
+	rs.SetActive()
+	rec, err := rs.client.QueryASN(asn)
+	rs.publish(rec, err)
+}
+
+func (rs *RDAPService) queryNetblock(cidr string) {
+	defer rs.maxQueries.Release(1)
+
+	rs.SetActive()
+	rec, err := rs.client.QueryIP(cidr)
+	rs.publish(rec, err)
+}
+
+func (rs *RDAPService) publish(rec *core.RDAPRecord, err error) {
+	if err != nil {
+		rs.Config().Log.Printf("%s: %v", rs.String(), err)
+		return
+	}
+
+	rs.SetActive()
+	rs.Bus().Publish(core.RDAPTopic, rec)
+}
@@ -15,7 +15,7 @@ type ArchiveIt struct {
 	domain     string
 	baseURL    string
 	SourceType string
-	filter     *utils.StringFilter
+	filter     utils.StringFilter
 }
 
 // NewArchiveIt returns he object initialized, but not yet started.
@@ -24,7 +24,6 @@ func NewArchiveIt(config *core.Config, bus *core.EventBus) *ArchiveIt {
 		domain:     "wayback.archive-it.org",
 		baseURL:    "https://wayback.archive-it.org/all",
 		SourceType: core.ARCHIVE,
-		filter:     utils.NewStringFilter(),
 	}
 
 	a.BaseService = *core.NewBaseService(a, "ArchiveIt", config, bus)
@@ -35,11 +34,22 @@ func NewArchiveIt(config *core.Config, bus *core.EventBus) *ArchiveIt {
 func (a *ArchiveIt) OnStart() error {
 	a.BaseService.OnStart()
 
+	// The filter is created once the configuration has been loaded
+	a.filter = a.Config().NewStringFilter()
+
 	a.Bus().Subscribe(core.NameResolvedTopic, a.SendDNSRequest)
 	go a.processRequests()
 	return nil
 }
 
+// OnStop implements the Service interface
+func (a *ArchiveIt) OnStop() error {
+	if a.filter != nil {
+		a.filter.Close()
+	}
+	return nil
+}
+
 func (a *ArchiveIt) processRequests() {
 	for {
 		select {
@@ -15,7 +15,7 @@ type ArchiveToday struct {
 	domain     string
 	baseURL    string
 	SourceType string
-	filter     *utils.StringFilter
+	filter     utils.StringFilter
 }
 
 // NewArchiveToday returns he object initialized, but not yet started.
@@ -24,7 +24,6 @@ func NewArchiveToday(config *core.Config, bus *core.EventBus) *ArchiveToday {
 		domain:     "archive.is",
 		baseURL:    "http://archive.is",
 		SourceType: core.ARCHIVE,
-		filter:     utils.NewStringFilter(),
 	}
 
 	a.BaseService = *core.NewBaseService(a, "ArchiveToday", config, bus)
@@ -35,11 +34,22 @@ func NewArchiveToday(config *core.Config, bus *core.EventBus) *ArchiveToday {
 func (a *ArchiveToday) OnStart() error {
 	a.BaseService.OnStart()
 
+	// The filter is created once the configuration has been loaded
+	a.filter = a.Config().NewStringFilter()
+
 	a.Bus().Subscribe(core.NameResolvedTopic, a.SendDNSRequest)
 	go a.processRequests()
 	return nil
 }
 
+// OnStop implements the Service interface
+func (a *ArchiveToday) OnStop() error {
+	if a.filter != nil {
+		a.filter.Close()
+	}
+	return nil
+}
+
 func (a *ArchiveToday) processRequests() {
 	for {
 		select {
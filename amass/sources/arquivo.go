@@ -15,7 +15,7 @@ type Arquivo struct {
 	domain     string
 	baseURL    string
 	SourceType string
-	filter     *utils.StringFilter
+	filter     utils.StringFilter
 }
 
 // NewArquivo returns he object initialized, but not yet started.
@@ -24,7 +24,6 @@ func NewArquivo(config *core.Config, bus *core.EventBus) *Arquivo {
 		domain:     "arquivo.pt",
 		baseURL:    "http://arquivo.pt/wayback",
 		SourceType: core.ARCHIVE,
-		filter:     utils.NewStringFilter(),
 	}
 
 	a.BaseService = *core.NewBaseService(a, "Arquivo", config, bus)
@@ -35,11 +34,22 @@ func NewArquivo(config *core.Config, bus *core.EventBus) *Arquivo {
 func (a *Arquivo) OnStart() error {
 	a.BaseService.OnStart()
 
+	// The filter is created once the configuration has been loaded
+	a.filter = a.Config().NewStringFilter()
+
 	a.Bus().Subscribe(core.NameResolvedTopic, a.SendDNSRequest)
 	go a.processRequests()
 	return nil
 }
 
+// OnStop implements the Service interface
+func (a *Arquivo) OnStop() error {
+	if a.filter != nil {
+		a.filter.Close()
+	}
+	return nil
+}
+
 func (a *Arquivo) processRequests() {
 	for {
 		select {
@@ -80,13 +80,74 @@ type censysRequest struct {
 	Fields []string `json:"fields"`
 }
 
+type censysCert struct {
+	Names        []string `json:"parsed.names"`
+	CommonName   []string `json:"parsed.subject.common_name"`
+	Organization []string `json:"parsed.subject.organization"`
+	OrgUnit      []string `json:"parsed.subject.organizational_unit"`
+	Issuer       string   `json:"parsed.issuer_dn"`
+	NotBefore    string   `json:"parsed.validity.start"`
+	NotAfter     string   `json:"parsed.validity.end"`
+	Serial       string   `json:"parsed.serial_number"`
+	Fingerprint  string   `json:"parsed.fingerprint_sha256"`
+}
+
+var censysCertFields = []string{
+	"parsed.names",
+	"parsed.subject.common_name",
+	"parsed.subject.organization",
+	"parsed.subject.organizational_unit",
+	"parsed.issuer_dn",
+	"parsed.validity.start",
+	"parsed.validity.end",
+	"parsed.serial_number",
+	"parsed.fingerprint_sha256",
+}
+
 func (c *Censys) apiQuery(domain string) {
-	for page := 1; ; page++ {
+	c.searchCertificates("parsed.names: "+domain, 0, func(cert *censysCert) {
+		for _, name := range cert.Names {
+			n := strings.TrimSpace(name)
+			n = utils.RemoveAsteriskLabel(n)
+
+			if c.Config().IsDomainInScope(n) {
+				c.Bus().Publish(core.NewNameTopic, &core.DNSRequest{
+					Name:   n,
+					Domain: domain,
+					Tag:    c.SourceType,
+					Source: c.String(),
+				})
+			}
+		}
+		// Keep the subject organization of the certificate
+		if cert.Fingerprint != "" {
+			c.Bus().Publish(core.NewCertTopic, c.certRequest(cert))
+		}
+	})
+}
+
+// OrganizationLookup implements the OrganizationLookup interface.
+func (c *Censys) OrganizationLookup(org string) ([]*core.CertRequest, error) {
+	if c.API == nil || c.API.Key == "" || c.API.Secret == "" {
+		return nil, nil
+	}
+
+	var certs []*core.CertRequest
+	query := fmt.Sprintf("parsed.subject.organization: %q", org)
+	// Organizations can have many certificates, so the number of pages is limited
+	c.searchCertificates(query, 10, func(cert *censysCert) {
+		certs = append(certs, c.certRequest(cert))
+	})
+	return certs, nil
+}
+
+func (c *Censys) searchCertificates(query string, maxPages int, callback func(*censysCert)) {
+	for page := 1; maxPages == 0 || page <= maxPages; page++ {
 		c.SetActive()
 		jsonStr, err := json.Marshal(&censysRequest{
-			Query:  "parsed.names: " + domain,
+			Query:  query,
 			Page:   page,
-			Fields: []string{"parsed.names"},
+			Fields: censysCertFields,
 		})
 		if err != nil {
 			break
@@ -107,9 +168,7 @@ func (c *Censys) apiQuery(domain string) {
 				Page  int `json:"page"`
 				Pages int `json:"pages"`
 			} `json:"metadata"`
-			Results []struct {
-				Names []string `json:"parsed.names"`
-			} `json:"results"`
+			Results []censysCert `json:"results"`
 		}
 		if err := json.Unmarshal([]byte(resp), &m); err != nil || m.Status != "ok" {
 			c.Config().Log.Printf("%s: %s: %v", c.String(), u, err)
@@ -119,20 +178,8 @@ func (c *Censys) apiQuery(domain string) {
 			break
 		}
 
-		for _, result := range m.Results {
-			for _, name := range result.Names {
-				n := strings.TrimSpace(name)
-				n = utils.RemoveAsteriskLabel(n)
-
-				if c.Config().IsDomainInScope(n) {
-					c.Bus().Publish(core.NewNameTopic, &core.DNSRequest{
-						Name:   n,
-						Domain: domain,
-						Tag:    c.SourceType,
-						Source: c.String(),
-					})
-				}
-			}
+		for i := range m.Results {
+			callback(&m.Results[i])
 		}
 
 		if m.Metadata.Page >= m.Metadata.Pages {
@@ -142,6 +189,30 @@ func (c *Censys) apiQuery(domain string) {
 	}
 }
 
+func (c *Censys) certRequest(cert *censysCert) *core.CertRequest {
+	req := &core.CertRequest{
+		Organization: cert.Organization,
+		OrgUnit:      cert.OrgUnit,
+		Issuer:       cert.Issuer,
+		Serial:       cert.Serial,
+		Fingerprint:  cert.Fingerprint,
+		Tag:          c.SourceType,
+		Source:       c.String(),
+	}
+
+	if len(cert.CommonName) > 0 {
+		req.CommonName = cert.CommonName[0]
+	}
+	for _, name := range cert.Names {
+		if n := utils.RemoveAsteriskLabel(strings.TrimSpace(name)); n != "" {
+			req.Names = utils.UniqueAppend(req.Names, n)
+		}
+	}
+	req.NotBefore, _ = time.Parse(time.RFC3339, cert.NotBefore)
+	req.NotAfter, _ = time.Parse(time.RFC3339, cert.NotAfter)
+	return req
+}
+
 func (c *Censys) apiURL() string {
 	return "https://www.censys.io/api/v1/search/certificates"
 }
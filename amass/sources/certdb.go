@@ -76,6 +76,13 @@ func (c *CertDB) executeQuery(domain string) {
 		Domains []struct {
 			Domain string `json:"domain"`
 		}
+		Subject struct {
+			CommonName   string `json:"CN"`
+			Organization string `json:"O"`
+			OrgUnit      string `json:"OU"`
+		} `json:"subject"`
+		Serial      string `json:"serial_number"`
+		Fingerprint string `json:"fingerprint_sha256"`
 	}
 
 	if err := json.Unmarshal([]byte(page), &results); err != nil {
@@ -85,8 +92,12 @@ func (c *CertDB) executeQuery(domain string) {
 
 	c.SetActive()
 	re := c.Config().DomainRegex(domain)
-	for _, domains := range results {
-		for _, name := range domains.Domains {
+	for _, result := range results {
+		var names []string
+
+		for _, name := range result.Domains {
+			names = utils.UniqueAppend(names, cleanName(utils.RemoveAsteriskLabel(name.Domain)))
+
 			n := re.FindString(name.Domain)
 			if n == "" {
 				continue
@@ -99,6 +110,24 @@ func (c *CertDB) executeQuery(domain string) {
 				Source: c.String(),
 			})
 		}
+
+		// Keep the subject organization of the certificate
+		if result.Fingerprint == "" || result.Subject.Organization == "" {
+			continue
+		}
+		cert := &core.CertRequest{
+			CommonName:   result.Subject.CommonName,
+			Names:        names,
+			Organization: []string{result.Subject.Organization},
+			Serial:       result.Serial,
+			Fingerprint:  strings.ToLower(result.Fingerprint),
+			Tag:          c.SourceType,
+			Source:       c.String(),
+		}
+		if result.Subject.OrgUnit != "" {
+			cert.OrgUnit = []string{result.Subject.OrgUnit}
+		}
+		c.Bus().Publish(core.NewCertTopic, cert)
 	}
 }
 
@@ -78,12 +78,22 @@ func (c *CertSpotter) executeQuery(domain string) {
 	// Extract the subdomain names from the certificate information
 	var m []struct {
 		Names []string `json:"dns_names"`
+		Cert  struct {
+			Data string `json:"data"`
+		} `json:"cert"`
 	}
 	if err := json.Unmarshal([]byte(page), &m); err != nil {
 		return
 	}
 
 	for _, result := range m {
+		// Keep the subject organization of the certificate
+		if cert := certRequestFromBase64(result.Cert.Data); cert != nil {
+			cert.Tag = c.SourceType
+			cert.Source = c.String()
+			c.Bus().Publish(core.NewCertTopic, cert)
+		}
+
 		for _, name := range result.Names {
 			if re.MatchString(name) {
 				c.Bus().Publish(core.NewNameTopic, &core.DNSRequest{
@@ -104,7 +114,7 @@ func (c *CertSpotter) getURL(domain string) string {
 		"domain":             {domain},
 		"include_subdomains": {"true"},
 		"match_wildcards":    {"true"},
-		"expand":             {"dns_names"},
+		"expand":             {"dns_names", "cert"},
 	}.Encode()
 	return u.String()
 }
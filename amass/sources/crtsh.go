@@ -5,12 +5,24 @@ package sources
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/root-secure/Amass/amass/core"
 	"github.com/root-secure/Amass/amass/utils"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq" // Need the postgres driver
+	"github.com/lib/pq"
+)
+
+const (
+	// The number of recent certificates kept for each name found by the query
+	crtshCertsPerName = 5
+
+	// The maximum number of certificates with a subject organization obtained for a domain
+	crtshMaxOrgCerts = 1000
 )
 
 // Crtsh is the Service that handles access to the Crtsh data source.
@@ -72,25 +84,30 @@ func (c *Crtsh) executeQuery(domain string) {
 	}
 
 	var results []struct {
-		Domain string `db:"domain"`
+		Domain string        `db:"domain"`
+		IDs    pq.Int64Array `db:"ids"`
 	}
 
 	pattern := "%." + domain
 	err := c.db.Select(&results,
-		`SELECT DISTINCT ci.NAME_VALUE as domain 
+		`SELECT ci.NAME_VALUE as domain, 
+		(array_agg(DISTINCT ci.CERTIFICATE_ID ORDER BY ci.CERTIFICATE_ID DESC))[1:$2] as ids 
 		FROM certificate_identity ci 
 		WHERE reverse(lower(ci.NAME_VALUE)) LIKE reverse(lower($1)) 
-		ORDER BY ci.NAME_VALUE`, pattern)
+		GROUP BY ci.NAME_VALUE 
+		ORDER BY ci.NAME_VALUE`, pattern, crtshCertsPerName)
 	if err != nil {
 		c.Config().Log.Printf("%s: Query pattern %s: %v", c.String(), pattern, err)
 		return
 	}
 
 	c.SetActive()
-	// Extract the subdomain names from the results
+	// Extract the subdomain names and the recent certificates from the results
 	var names []string
+	var ids []int64
 	for _, result := range results {
 		names = utils.UniqueAppend(names, strings.ToLower(utils.RemoveAsteriskLabel(result.Domain)))
+		ids = append(ids, result.IDs...)
 	}
 
 	for _, name := range names {
@@ -101,6 +118,88 @@ func (c *Crtsh) executeQuery(domain string) {
 			Source: c.String(),
 		})
 	}
+
+	if len(ids) == 0 {
+		return
+	}
+	// Keep the subject organization of the recent certificates issued for the names.
+	// Only the certificates providing an organization are obtained
+	certs, err := c.queryCertificates(
+		`SELECT CERTIFICATE_ID FROM certificate_identity 
+		WHERE CERTIFICATE_ID = ANY($1) AND NAME_TYPE = 'organizationName' 
+		LIMIT `+strconv.Itoa(crtshMaxOrgCerts), pq.Array(ids))
+	if err != nil {
+		c.Config().Log.Printf("%s: Certificate query for %s: %v", c.String(), domain, err)
+		return
+	}
+	for _, cert := range certs {
+		if len(cert.Organization) > 0 {
+			c.Bus().Publish(core.NewCertTopic, cert)
+		}
+	}
+}
+
+// OrganizationLookup implements the OrganizationLookup interface.
+func (c *Crtsh) OrganizationLookup(org string) ([]*core.CertRequest, error) {
+	if !c.haveConnection {
+		return c.scrapeOrganization(org)
+	}
+
+	return c.queryCertificates(
+		`SELECT CERTIFICATE_ID FROM certificate_identity 
+		WHERE NAME_TYPE = 'organizationName' AND lower(NAME_VALUE) LIKE lower($1) 
+		LIMIT 10000`, "%"+org+"%")
+}
+
+// queryCertificates returns the certificates identified by the subquery, with the
+// names and subject organization of each certificate.
+func (c *Crtsh) queryCertificates(subquery string, arg interface{}) ([]*core.CertRequest, error) {
+	var results []struct {
+		ID          int64  `db:"id"`
+		Fingerprint string `db:"fingerprint"`
+		Type        string `db:"type"`
+		Value       string `db:"value"`
+	}
+
+	err := c.db.Select(&results,
+		`SELECT c.ID as id, encode(sha256(c.CERTIFICATE), 'hex') as fingerprint, 
+		ci.NAME_TYPE as type, ci.NAME_VALUE as value 
+		FROM certificate c JOIN certificate_identity ci ON ci.CERTIFICATE_ID = c.ID 
+		WHERE c.ID IN (`+subquery+`) 
+		AND ci.NAME_TYPE IN ('commonName', 'dNSName', 'organizationName', 'organizationalUnitName')`, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetActive()
+	var certs []*core.CertRequest
+	byID := make(map[int64]*core.CertRequest)
+	for _, result := range results {
+		cert, found := byID[result.ID]
+		if !found {
+			cert = &core.CertRequest{
+				Fingerprint: result.Fingerprint,
+				Tag:         c.SourceType,
+				Source:      c.String(),
+			}
+			byID[result.ID] = cert
+			certs = append(certs, cert)
+		}
+
+		switch result.Type {
+		case "commonName":
+			cert.CommonName = result.Value
+		case "dNSName":
+			if n := strings.ToLower(utils.RemoveAsteriskLabel(result.Value)); n != "" {
+				cert.Names = utils.UniqueAppend(cert.Names, n)
+			}
+		case "organizationName":
+			cert.Organization = append(cert.Organization, result.Value)
+		case "organizationalUnitName":
+			cert.OrgUnit = append(cert.OrgUnit, result.Value)
+		}
+	}
+	return certs, nil
 }
 
 func (c *Crtsh) scrape(domain string) {
@@ -129,6 +228,54 @@ func (c *Crtsh) scrape(domain string) {
 	}
 }
 
+func (c *Crtsh) scrapeOrganization(org string) ([]*core.CertRequest, error) {
+	u := c.getOrgURL(org)
+	page, err := utils.RequestWebPage(u, nil, nil, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %v", c.String(), u, err)
+	}
+
+	c.SetActive()
+	var results []struct {
+		ID         int64  `json:"id"`
+		CommonName string `json:"common_name"`
+		Names      string `json:"name_value"`
+		Issuer     string `json:"issuer_name"`
+		NotBefore  string `json:"not_before"`
+		NotAfter   string `json:"not_after"`
+		Serial     string `json:"serial_number"`
+	}
+	if err := json.Unmarshal([]byte(page), &results); err != nil {
+		return nil, fmt.Errorf("%s: Failed to unmarshal JSON: %v", c.String(), err)
+	}
+
+	var certs []*core.CertRequest
+	for _, r := range results {
+		// The organization is not provided, but every result was issued to the organization
+		cert := &core.CertRequest{
+			CommonName:   r.CommonName,
+			Organization: []string{org},
+			Issuer:       r.Issuer,
+			Serial:       r.Serial,
+			Tag:          c.SourceType,
+			Source:       c.String(),
+		}
+		for _, name := range strings.Fields(r.Names) {
+			if n := strings.ToLower(utils.RemoveAsteriskLabel(name)); n != "" {
+				cert.Names = utils.UniqueAppend(cert.Names, n)
+			}
+		}
+		cert.NotBefore, _ = time.Parse("2006-01-02T15:04:05", r.NotBefore)
+		cert.NotAfter, _ = time.Parse("2006-01-02T15:04:05", r.NotAfter)
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
 func (c *Crtsh) getURL(domain string) string {
 	return "https://crt.sh/?q=%25." + domain + "&output=json"
 }
+
+func (c *Crtsh) getOrgURL(org string) string {
+	return "https://crt.sh/?O=" + url.QueryEscape(org) + "&output=json"
+}
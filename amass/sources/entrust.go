@@ -4,6 +4,7 @@
 package sources
 
 import (
+	"encoding/json"
 	"net/url"
 	"regexp"
 	"strings"
@@ -75,6 +76,8 @@ func (e *Entrust) executeQuery(domain string) {
 		})
 	}
 
+	e.publishCertificates(page)
+
 	for _, name := range e.extractReversedSubmatches(page) {
 		if match := re.FindString(name); match != "" {
 			e.Bus().Publish(core.NewNameTopic, &core.DNSRequest{
@@ -87,6 +90,24 @@ func (e *Entrust) executeQuery(domain string) {
 	}
 }
 
+// publishCertificates keeps the subject organization of the certificates in the results.
+func (e *Entrust) publishCertificates(page string) {
+	var results []struct {
+		Cert string `json:"cert"`
+	}
+	if err := json.Unmarshal([]byte(page), &results); err != nil {
+		return
+	}
+
+	for _, r := range results {
+		if cert := certRequestFromBase64(r.Cert); cert != nil && len(cert.Organization) > 0 {
+			cert.Tag = e.SourceType
+			cert.Source = e.String()
+			e.Bus().Publish(core.NewCertTopic, cert)
+		}
+	}
+}
+
 func (e *Entrust) getURL(domain string) string {
 	u, _ := url.Parse("https://ctsearch.entrust.com/api/v1/certificates")
 
@@ -4,6 +4,7 @@
 package sources
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -124,3 +125,34 @@ func (h *HackerTarget) getASNURL(addr string) string {
 
 	return fmt.Sprintf(format, addr)
 }
+
+// ReverseLookup implements the core.ReverseLookup interface.
+func (h *HackerTarget) ReverseLookup(qtype, host string) ([]string, error) {
+	if qtype != "NS" {
+		return nil, nil
+	}
+
+	url := h.getSharedDNSURL(host)
+	page, err := utils.RequestWebPage(url, nil, nil, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %v", h.String(), url, err)
+	}
+	// Errors and exceeded limits are returned as a single line of text
+	if strings.HasPrefix(page, "error") || strings.Contains(page, "API count exceeded") {
+		return nil, errors.New(h.String() + ": " + strings.TrimSpace(page))
+	}
+
+	var domains []string
+	for _, line := range strings.Split(page, "\n") {
+		if d := strings.ToLower(strings.TrimSpace(line)); d != "" && !strings.Contains(d, " ") {
+			domains = utils.UniqueAppend(domains, d)
+		}
+	}
+	return domains, nil
+}
+
+func (h *HackerTarget) getSharedDNSURL(host string) string {
+	format := "https://api.hackertarget.com/findshareddns/?q=%s"
+
+	return fmt.Sprintf(format, host)
+}
@@ -15,7 +15,7 @@ type LoCArchive struct {
 	domain     string
 	baseURL    string
 	SourceType string
-	filter     *utils.StringFilter
+	filter     utils.StringFilter
 }
 
 // NewLoCArchive returns he object initialized, but not yet started.
@@ -24,7 +24,6 @@ func NewLoCArchive(config *core.Config, bus *core.EventBus) *LoCArchive {
 		domain:     "webarchive.loc.gov",
 		baseURL:    "http://webarchive.loc.gov/all",
 		SourceType: core.ARCHIVE,
-		filter:     utils.NewStringFilter(),
 	}
 
 	l.BaseService = *core.NewBaseService(l, "LoCArchive", config, bus)
@@ -35,11 +34,22 @@ func NewLoCArchive(config *core.Config, bus *core.EventBus) *LoCArchive {
 func (l *LoCArchive) OnStart() error {
 	l.BaseService.OnStart()
 
+	// The filter is created once the configuration has been loaded
+	l.filter = l.Config().NewStringFilter()
+
 	l.Bus().Subscribe(core.NameResolvedTopic, l.SendDNSRequest)
 	go l.processRequests()
 	return nil
 }
 
+// OnStop implements the Service interface
+func (l *LoCArchive) OnStop() error {
+	if l.filter != nil {
+		l.filter.Close()
+	}
+	return nil
+}
+
 func (l *LoCArchive) processRequests() {
 	for {
 		select {
@@ -15,7 +15,7 @@ type OpenUKArchive struct {
 	domain     string
 	baseURL    string
 	SourceType string
-	filter     *utils.StringFilter
+	filter     utils.StringFilter
 }
 
 // NewOpenUKArchive returns he object initialized, but not yet started.
@@ -24,7 +24,6 @@ func NewOpenUKArchive(config *core.Config, bus *core.EventBus) *OpenUKArchive {
 		domain:     "webarchive.org.uk",
 		baseURL:    "http://www.webarchive.org.uk/wayback/archive",
 		SourceType: core.ARCHIVE,
-		filter:     utils.NewStringFilter(),
 	}
 
 	o.BaseService = *core.NewBaseService(o, "OpenUKArchive", config, bus)
@@ -35,11 +34,22 @@ func NewOpenUKArchive(config *core.Config, bus *core.EventBus) *OpenUKArchive {
 func (o *OpenUKArchive) OnStart() error {
 	o.BaseService.OnStart()
 
+	// The filter is created once the configuration has been loaded
+	o.filter = o.Config().NewStringFilter()
+
 	o.Bus().Subscribe(core.NameResolvedTopic, o.SendDNSRequest)
 	go o.processRequests()
 	return nil
 }
 
+// OnStop implements the Service interface
+func (o *OpenUKArchive) OnStop() error {
+	if o.filter != nil {
+		o.filter.Close()
+	}
+	return nil
+}
+
 func (o *OpenUKArchive) processRequests() {
 	for {
 		select {
@@ -4,6 +4,9 @@
 package sources
 
 import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -99,6 +102,26 @@ func cleanName(name string) string {
 	return name
 }
 
+// certRequestFromBase64 parses the base64 encoded DER or PEM certificate provided by a data source.
+func certRequestFromBase64(data string) *core.CertRequest {
+	var der []byte
+
+	if block, _ := pem.Decode([]byte(data)); block != nil {
+		der = block.Bytes
+	} else if d, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data)); err == nil {
+		der = d
+	}
+	if len(der) == 0 {
+		return nil
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil
+	}
+	return core.NewCertRequest(cert)
+}
+
 func crawl(service core.Service, baseURL, baseDomain, subdomain, domain string) ([]string, error) {
 	var results []string
 
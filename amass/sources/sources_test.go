@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils"
 )
 
 var (
@@ -50,6 +51,43 @@ func TestCleanName(t *testing.T) {
 	}
 }
 
+func TestArchiveFilterConfig(t *testing.T) {
+	config := setupConfig(domainTest)
+	bus := core.NewEventBus()
+	defer bus.Stop()
+
+	archiveIt := NewArchiveIt(config, bus)
+	archiveToday := NewArchiveToday(config, bus)
+	arquivo := NewArquivo(config, bus)
+	locArchive := NewLoCArchive(config, bus)
+	openUKArchive := NewOpenUKArchive(config, bus)
+	ukGovArchive := NewUKGovArchive(config, bus)
+	wayback := NewWayback(config, bus)
+
+	// The configuration file is loaded after the data sources have been created
+	config.FilterMode = core.FilterProbabilistic
+
+	archives := []struct {
+		srv    core.Service
+		filter func() utils.StringFilter
+	}{
+		{archiveIt, func() utils.StringFilter { return archiveIt.filter }},
+		{archiveToday, func() utils.StringFilter { return archiveToday.filter }},
+		{arquivo, func() utils.StringFilter { return arquivo.filter }},
+		{locArchive, func() utils.StringFilter { return locArchive.filter }},
+		{openUKArchive, func() utils.StringFilter { return openUKArchive.filter }},
+		{ukGovArchive, func() utils.StringFilter { return ukGovArchive.filter }},
+		{wayback, func() utils.StringFilter { return wayback.filter }},
+	}
+	for _, archive := range archives {
+		archive.srv.Start()
+		if _, ok := archive.filter().(*utils.ProbabilisticFilter); !ok {
+			t.Errorf("%s did not use the configured filtering mode", archive.srv.String())
+		}
+		archive.srv.Stop()
+	}
+}
+
 func setupConfig(domain string) *core.Config {
 	config := &core.Config{}
 
@@ -15,7 +15,7 @@ type UKGovArchive struct {
 	domain     string
 	baseURL    string
 	SourceType string
-	filter     *utils.StringFilter
+	filter     utils.StringFilter
 }
 
 // NewUKGovArchive returns he object initialized, but not yet started.
@@ -24,7 +24,6 @@ func NewUKGovArchive(config *core.Config, bus *core.EventBus) *UKGovArchive {
 		domain:     "webarchive.nationalarchives.gov.uk",
 		baseURL:    "http://webarchive.nationalarchives.gov.uk",
 		SourceType: core.ARCHIVE,
-		filter:     utils.NewStringFilter(),
 	}
 
 	u.BaseService = *core.NewBaseService(u, "UKGovArchive", config, bus)
@@ -35,11 +34,22 @@ func NewUKGovArchive(config *core.Config, bus *core.EventBus) *UKGovArchive {
 func (u *UKGovArchive) OnStart() error {
 	u.BaseService.OnStart()
 
+	// The filter is created once the configuration has been loaded
+	u.filter = u.Config().NewStringFilter()
+
 	u.Bus().Subscribe(core.NameResolvedTopic, u.SendDNSRequest)
 	go u.processRequests()
 	return nil
 }
 
+// OnStop implements the Service interface
+func (u *UKGovArchive) OnStop() error {
+	if u.filter != nil {
+		u.filter.Close()
+	}
+	return nil
+}
+
 func (u *UKGovArchive) processRequests() {
 	for {
 		select {
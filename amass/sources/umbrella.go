@@ -299,6 +299,14 @@ func (u *Umbrella) executeWhoisQuery(domain string) {
 	}
 }
 
+// ReverseLookup implements the core.ReverseLookup interface.
+func (u *Umbrella) ReverseLookup(qtype, host string) ([]string, error) {
+	if qtype != "NS" || u.API == nil || u.API.Key == "" {
+		return nil, nil
+	}
+	return u.queryReverseWhois(u.reverseWhoisByNSURL(host)), nil
+}
+
 func (u *Umbrella) restHeaders() map[string]string {
 	headers := map[string]string{"Content-Type": "application/json"}
 
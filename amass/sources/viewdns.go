@@ -134,6 +134,33 @@ func (v *ViewDNS) executeWhoisQuery(domain string) {
 	}
 }
 
+// ReverseLookup implements the core.ReverseLookup interface.
+func (v *ViewDNS) ReverseLookup(qtype, host string) ([]string, error) {
+	var u string
+	switch qtype {
+	case "NS":
+		u = "http://viewdns.info/reversens/?ns=" + host
+	case "MX":
+		u = "http://viewdns.info/reversemx/?mx=" + host
+	default:
+		return nil, nil
+	}
+
+	page, err := utils.RequestWebPage(u, nil, nil, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %v", v.String(), u, err)
+	}
+
+	var domains []string
+	re := regexp.MustCompile("<td>([a-zA-Z0-9]{1}[a-zA-Z0-9-.]{0,252}[.]{1}[a-zA-Z]{2,})</td>")
+	for _, match := range re.FindAllStringSubmatch(page, -1) {
+		if d := strings.ToLower(strings.TrimSpace(match[1])); d != strings.ToLower(host) {
+			domains = utils.UniqueAppend(domains, d)
+		}
+	}
+	return domains, nil
+}
+
 func (v *ViewDNS) getURL(domain string) string {
 	format := "http://viewdns.info/reversewhois/?q=%s"
 
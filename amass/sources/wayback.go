@@ -15,7 +15,7 @@ type Wayback struct {
 	domain     string
 	baseURL    string
 	SourceType string
-	filter     *utils.StringFilter
+	filter     utils.StringFilter
 }
 
 // NewWayback returns he object initialized, but not yet started.
@@ -24,7 +24,6 @@ func NewWayback(config *core.Config, bus *core.EventBus) *Wayback {
 		domain:     "web.archive.org",
 		baseURL:    "http://web.archive.org/web",
 		SourceType: core.ARCHIVE,
-		filter:     utils.NewStringFilter(),
 	}
 
 	w.BaseService = *core.NewBaseService(w, "Wayback", config, bus)
@@ -35,11 +34,22 @@ func NewWayback(config *core.Config, bus *core.EventBus) *Wayback {
 func (w *Wayback) OnStart() error {
 	w.BaseService.OnStart()
 
+	// The filter is created once the configuration has been loaded
+	w.filter = w.Config().NewStringFilter()
+
 	w.Bus().Subscribe(core.NameResolvedTopic, w.SendDNSRequest)
 	go w.processRequests()
 	return nil
 }
 
+// OnStop implements the Service interface
+func (w *Wayback) OnStop() error {
+	if w.filter != nil {
+		w.filter.Close()
+	}
+	return nil
+}
+
 func (w *Wayback) processRequests() {
 	for {
 		select {
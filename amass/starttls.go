@@ -0,0 +1,306 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// The LDAP StartTLS extended operation (RFC 4511 section 4.14) with message ID one
+var ldapStartTLSRequest = []byte{
+	0x30, 0x1d, 0x02, 0x01, 0x01, 0x77, 0x18, 0x80, 0x16,
+	'1', '.', '3', '.', '6', '.', '1', '.', '4', '.', '1', '.',
+	'1', '4', '6', '6', '.', '2', '0', '0', '3', '7',
+}
+
+// startTLSFunc performs the plaintext negotiation that upgrades a connection to TLS.
+type startTLSFunc func(conn net.Conn, serverName string) error
+
+// The plaintext protocols that are upgraded to TLS when found on their well-known ports
+var startTLSProtocols = map[int]startTLSFunc{
+	21:   startTLSFTP,
+	25:   startTLSSMTP,
+	110:  startTLSPOP3,
+	143:  startTLSIMAP,
+	389:  startTLSLDAP,
+	587:  startTLSSMTP,
+	5222: startTLSXMPPClient,
+	5269: startTLSXMPPServer,
+}
+
+// StartTLSPort returns true if connections to the port are upgraded using STARTTLS.
+func StartTLSPort(port int) bool {
+	_, found := startTLSProtocols[port]
+	return found
+}
+
+func startTLS(conn net.Conn, port int, serverName string) error {
+	if f, found := startTLSProtocols[port]; found {
+		return f(conn, serverName)
+	}
+	return nil
+}
+
+// readReplyCode reads a reply in the format shared by SMTP and FTP, where
+// continuation lines have a hyphen following the three digit code.
+func readReplyCode(r *bufio.Reader, code string) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 3 {
+			return fmt.Errorf("Malformed reply: %s", line)
+		}
+		if line[:3] != code {
+			return fmt.Errorf("Unexpected reply: %s", line)
+		}
+		if len(line) == 3 || line[3] != '-' {
+			return nil
+		}
+	}
+}
+
+func ehloName(serverName string) string {
+	if serverName == "" {
+		return "localhost"
+	}
+	return serverName
+}
+
+func startTLSSMTP(conn net.Conn, serverName string) error {
+	r := bufio.NewReader(conn)
+
+	if err := readReplyCode(r, "220"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(conn, "EHLO "+ehloName(serverName)+"\r\n"); err != nil {
+		return err
+	}
+	if err := readReplyCode(r, "250"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(conn, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+	return readReplyCode(r, "220")
+}
+
+func startTLSFTP(conn net.Conn, serverName string) error {
+	r := bufio.NewReader(conn)
+
+	if err := readReplyCode(r, "220"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(conn, "AUTH TLS\r\n"); err != nil {
+		return err
+	}
+	return readReplyCode(r, "234")
+}
+
+func startTLSPOP3(conn net.Conn, serverName string) error {
+	r := bufio.NewReader(conn)
+
+	if line, err := r.ReadString('\n'); err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("Unexpected POP3 greeting: %s", strings.TrimSpace(line))
+	}
+	if _, err := io.WriteString(conn, "STLS\r\n"); err != nil {
+		return err
+	}
+	if line, err := r.ReadString('\n'); err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("POP3 STLS was refused: %s", strings.TrimSpace(line))
+	}
+	return nil
+}
+
+func startTLSIMAP(conn net.Conn, serverName string) error {
+	r := bufio.NewReader(conn)
+
+	if line, err := r.ReadString('\n'); err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "* OK") {
+		return fmt.Errorf("Unexpected IMAP greeting: %s", strings.TrimSpace(line))
+	}
+	if _, err := io.WriteString(conn, "a001 STARTTLS\r\n"); err != nil {
+		return err
+	}
+	// Skip untagged responses until the tagged completion result arrives
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "a001 ") {
+			continue
+		}
+		if !strings.HasPrefix(line, "a001 OK") {
+			return fmt.Errorf("IMAP STARTTLS was refused: %s", strings.TrimSpace(line))
+		}
+		return nil
+	}
+}
+
+func startTLSXMPPClient(conn net.Conn, serverName string) error {
+	return startTLSXMPP(conn, serverName, "jabber:client")
+}
+
+func startTLSXMPPServer(conn net.Conn, serverName string) error {
+	return startTLSXMPP(conn, serverName, "jabber:server")
+}
+
+func startTLSXMPP(conn net.Conn, serverName, namespace string) error {
+	if serverName == "" {
+		return errors.New("XMPP requires the server name of the stream")
+	}
+
+	header := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='%s' "+
+		"xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>", serverName, namespace)
+	if _, err := io.WriteString(conn, header); err != nil {
+		return err
+	}
+
+	features, err := readUntil(conn, "</stream:features>")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(features, "urn:ietf:params:xml:ns:xmpp-tls") {
+		return errors.New("XMPP server does not offer STARTTLS")
+	}
+
+	if _, err := io.WriteString(conn, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"); err != nil {
+		return err
+	}
+
+	resp, err := readUntil(conn, "/>")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(resp, "<proceed") {
+		return errors.New("XMPP STARTTLS was refused")
+	}
+	return nil
+}
+
+// readUntil reads one byte at a time, so no data belonging to the TLS handshake is consumed.
+func readUntil(r io.Reader, delim string) (string, error) {
+	var data []byte
+	buf := make([]byte, 1)
+
+	for len(data) < 65536 {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return string(data), err
+		}
+
+		data = append(data, buf[0])
+		if bytes.HasSuffix(data, []byte(delim)) {
+			return string(data), nil
+		}
+	}
+	return string(data), errors.New("Response exceeded the maximum size")
+}
+
+func startTLSLDAP(conn net.Conn, serverName string) error {
+	if _, err := conn.Write(ldapStartTLSRequest); err != nil {
+		return err
+	}
+
+	msg, err := readBERElement(conn)
+	if err != nil {
+		return err
+	}
+	// Skip the message ID to reach the ExtendedResponse
+	_, _, rest, err := parseBERElement(msg)
+	if err != nil {
+		return err
+	}
+
+	tag, resp, _, err := parseBERElement(rest)
+	if err != nil {
+		return err
+	}
+	if tag != 0x78 {
+		return fmt.Errorf("Unexpected LDAP response tag: %#x", tag)
+	}
+	// The first element of the response is the enumerated result code
+	if len(resp) < 3 || resp[0] != 0x0a || resp[1] != 0x01 {
+		return errors.New("Malformed LDAP ExtendedResponse")
+	}
+	if resp[2] != 0 {
+		return fmt.Errorf("LDAP StartTLS was refused with result code %d", resp[2])
+	}
+	return nil
+}
+
+// readBERElement reads a complete BER encoded element and returns its contents.
+func readBERElement(r io.Reader) ([]byte, error) {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+
+	length := int(hdr[1])
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 3 {
+			return nil, errors.New("Unsupported BER length encoding")
+		}
+
+		lbytes := make([]byte, n)
+		if _, err := io.ReadFull(r, lbytes); err != nil {
+			return nil, err
+		}
+
+		length = 0
+		for _, b := range lbytes {
+			length = length<<8 | int(b)
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// parseBERElement returns the tag and contents of the first element in data,
+// along with the bytes that follow the element.
+func parseBERElement(data []byte) (byte, []byte, []byte, error) {
+	if len(data) < 2 {
+		return 0, nil, nil, errors.New("Truncated BER element")
+	}
+
+	tag := data[0]
+	length := int(data[1])
+	offset := 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 3 || len(data) < offset+n {
+			return 0, nil, nil, errors.New("Unsupported BER length encoding")
+		}
+
+		length = 0
+		for _, b := range data[offset : offset+n] {
+			length = length<<8 | int(b)
+		}
+		offset += n
+	}
+
+	if len(data) < offset+length {
+		return 0, nil, nil, errors.New("Truncated BER element")
+	}
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestStartTLSSMTP(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+
+		io.WriteString(server, "220 mail.owasp.org ESMTP\r\n")
+		r.ReadString('\n')
+		io.WriteString(server, "250-mail.owasp.org\r\n250-PIPELINING\r\n250 STARTTLS\r\n")
+		if line, _ := r.ReadString('\n'); line != "STARTTLS\r\n" {
+			io.WriteString(server, "502 Unexpected command\r\n")
+			return
+		}
+		io.WriteString(server, "220 Ready to start TLS\r\n")
+	}()
+
+	if err := startTLS(client, 25, "mail.owasp.org"); err != nil {
+		t.Errorf("The SMTP STARTTLS negotiation failed: %v", err)
+	}
+}
+
+func TestStartTLSLDAP(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+
+		req := make([]byte, len(ldapStartTLSRequest))
+		io.ReadFull(server, req)
+		if !bytes.Equal(req, ldapStartTLSRequest) {
+			return
+		}
+		// An ExtendedResponse with a success result code
+		server.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07,
+			0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00})
+	}()
+
+	if err := startTLS(client, 389, ""); err != nil {
+		t.Errorf("The LDAP StartTLS negotiation failed: %v", err)
+	}
+}
+
+func TestStartTLSRefused(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+
+		io.WriteString(server, "+OK POP3 server ready\r\n")
+		r.ReadString('\n')
+		io.WriteString(server, "-ERR Command not permitted\r\n")
+	}()
+
+	if err := startTLS(client, 110, ""); err == nil {
+		t.Errorf("The refused POP3 STLS negotiation did not return an error")
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils"
+	"github.com/miekg/dns"
+)
+
+// The reasons provided for subdomain takeover findings.
+const (
+	TakeoverNXDOMAIN    = "nxdomain"
+	TakeoverFingerprint = "fingerprint"
+)
+
+const (
+	defaultTakeoverSigsURL = "https://raw.githubusercontent.com/root-secure/Amass/master/wordlists/takeover_signatures.json"
+)
+
+// TakeoverSignature describes the CNAME targets used by a third-party service and
+// the content returned by the service when a resource has not been claimed.
+type TakeoverSignature struct {
+	Provider     string   `json:"provider"`
+	CNAME        []string `json:"cname"`
+	Fingerprints []string `json:"fingerprints"`
+}
+
+// Matches returns true when the CNAME target belongs to the third-party service.
+// Each CNAME pattern of the signature matches when found anywhere within the target.
+func (ts *TakeoverSignature) Matches(target string) bool {
+	target = strings.ToLower(core.RemoveLastDot(target))
+
+	for _, c := range ts.CNAME {
+		if c != "" && strings.Contains(target, strings.ToLower(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTakeoverSignatures decodes the JSON signatures provided via a Reader.
+func ParseTakeoverSignatures(r io.Reader) ([]*TakeoverSignature, error) {
+	var sigs []*TakeoverSignature
+
+	if err := json.NewDecoder(r).Decode(&sigs); err != nil {
+		return nil, fmt.Errorf("Failed to parse the takeover signatures: %v", err)
+	}
+	return sigs, nil
+}
+
+// GetTakeoverSignatures returns the signatures found in the file at path. The latest
+// signatures are obtained from the Amass repository when path is empty.
+func GetTakeoverSignatures(path string) ([]*TakeoverSignature, error) {
+	if path == "" {
+		page, err := utils.RequestWebPage(defaultTakeoverSigsURL, nil, nil, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to obtain the takeover signatures: %v", err)
+		}
+		return ParseTakeoverSignatures(strings.NewReader(page))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open the takeover signatures file: %v", err)
+	}
+	defer f.Close()
+
+	return ParseTakeoverSignatures(f)
+}
+
+// TakeoverService is the Service that checks resolved CNAME records for targets that
+// no longer exist, or third-party resources that have not been claimed.
+type TakeoverService struct {
+	core.BaseService
+
+	maxChecks  utils.Semaphore
+	filter     utils.StringFilter
+	signatures []*TakeoverSignature
+	client     *http.Client
+}
+
+// NewTakeoverService returns he object initialized, but not yet started.
+func NewTakeoverService(config *core.Config, bus *core.EventBus) *TakeoverService {
+	ts := &TakeoverService{
+		maxChecks: utils.NewSimpleSemaphore(50),
+		filter:    config.NewStringFilter(),
+		client: &http.Client{
+			Timeout: defaultHTTPProbeTimeout,
+			Transport: &http.Transport{
+				DialContext: (&net.Dialer{
+					Timeout: defaultTLSConnectTimeout,
+				}).DialContext,
+				TLSHandshakeTimeout: defaultHandshakeDeadline,
+				TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+				DisableKeepAlives:   true,
+			},
+		},
+	}
+
+	ts.BaseService = *core.NewBaseService(ts, "Takeover Check", config, bus)
+	return ts
+}
+
+// OnStart implements the Service interface
+func (ts *TakeoverService) OnStart() error {
+	ts.BaseService.OnStart()
+
+	sigs, err := GetTakeoverSignatures(ts.Config().TakeoverSignatures)
+	if err != nil {
+		// A signatures file provided by the user must be usable
+		if ts.Config().TakeoverSignatures != "" {
+			return err
+		}
+		ts.Config().Log.Printf("%s: %v", ts.String(), err)
+	}
+	ts.signatures = sigs
+
+	ts.Bus().Subscribe(core.NameResolvedTopic, ts.nameResolved)
+	go ts.processRequests()
+	return nil
+}
+
+// OnStop implements the Service interface.
+func (ts *TakeoverService) OnStop() error {
+	ts.filter.Close()
+	return nil
+}
+
+func (ts *TakeoverService) nameResolved(req *core.DNSRequest) {
+	if !ts.Config().IsDomainInScope(req.Name) {
+		return
+	}
+
+	for _, rec := range req.Records {
+		if uint16(rec.Type) == dns.TypeCNAME && !ts.filter.Duplicate(req.Name) {
+			ts.SendDNSRequest(req)
+			return
+		}
+	}
+}
+
+func (ts *TakeoverService) processRequests() {
+	for {
+		select {
+		case <-ts.PauseChan():
+			<-ts.ResumeChan()
+		case <-ts.Quit():
+			return
+		case req := <-ts.DNSRequestChan():
+			ts.maxChecks.Acquire(1)
+			go ts.checkName(req)
+		case <-ts.AddrRequestChan():
+		case <-ts.ASNRequestChan():
+		case <-ts.WhoisRequestChan():
+		}
+	}
+}
+
+func (ts *TakeoverService) checkName(req *core.DNSRequest) {
+	defer ts.maxChecks.Release(1)
+
+	// The last CNAME record identifies the end of the chain
+	var target string
+	for _, rec := range req.Records {
+		if uint16(rec.Type) == dns.TypeCNAME {
+			target = strings.ToLower(core.RemoveLastDot(rec.Data))
+		}
+	}
+	// Targets within the enumeration scope are checked when they are resolved
+	if target == "" || ts.Config().IsDomainInScope(target) {
+		return
+	}
+
+	ts.SetActive()
+	finding := ts.checkTarget(req.Name, target)
+	if finding == nil {
+		return
+	}
+
+	finding.Domain = req.Domain
+	ts.Bus().Publish(core.TakeoverTopic, finding)
+}
+
+func (ts *TakeoverService) checkTarget(name, target string) *core.TakeoverFinding {
+	finding := &core.TakeoverFinding{
+		Timestamp: time.Now(),
+		Name:      name,
+		Target:    target,
+		Tag:       core.DNS,
+		Source:    ts.String(),
+	}
+
+	var sig *TakeoverSignature
+	for _, s := range ts.signatures {
+		if s.Matches(target) {
+			sig = s
+			finding.Provider = s.Provider
+			break
+		}
+	}
+
+	_, err := core.Resolve(target, "A", core.PriorityHigh)
+	if rerr, ok := err.(*core.ResolveError); ok && rerr.Rcode == dns.RcodeNameError {
+		finding.Reason = TakeoverNXDOMAIN
+		finding.Evidence = "NXDOMAIN returned for " + target
+		return finding
+	}
+	if sig == nil || len(sig.Fingerprints) == 0 {
+		return nil
+	}
+
+	for _, scheme := range []string{"https", "http"} {
+		ts.SetActive()
+
+		if fp := ts.matchFingerprint(scheme+"://"+name+"/", sig.Fingerprints); fp != "" {
+			finding.Tag = core.HTTP
+			finding.Reason = TakeoverFingerprint
+			finding.Evidence = fp
+			return finding
+		}
+	}
+	return nil
+}
+
+func (ts *TakeoverService) matchFingerprint(u string, fingerprints []string) string {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return ""
+	}
+
+	req.Header.Set("User-Agent", utils.UserAgent)
+	req.Header.Set("Accept", utils.Accept)
+	req.Header.Set("Accept-Language", utils.AcceptLang)
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return ""
+	}
+
+	body := readBody(resp.Body, maxHTTPBodySize)
+	resp.Body.Close()
+	for _, fp := range fingerprints {
+		if fp != "" && strings.Contains(body, fp) {
+			return fp
+		}
+	}
+	return ""
+}
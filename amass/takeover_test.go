@@ -0,0 +1,75 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package amass
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/root-secure/Amass/amass/core"
+)
+
+func TestTakeoverSignatures(t *testing.T) {
+	f, err := os.Open("../wordlists/takeover_signatures.json")
+	if err != nil {
+		t.Fatalf("Failed to open the signatures file: %v", err)
+	}
+	defer f.Close()
+
+	sigs, err := ParseTakeoverSignatures(f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	tests := map[string]string{
+		"owasp.github.io.":                          "GitHub Pages",
+		"amass-demo.herokuapp.com":                  "Heroku",
+		"bucket.s3-website-us-east-1.amazonaws.com": "Amazon S3",
+		"www.owasp.org":                             "",
+	}
+	for target, expected := range tests {
+		var provider string
+
+		for _, sig := range sigs {
+			if sig.Matches(target) {
+				provider = sig.Provider
+				break
+			}
+		}
+		if provider != expected {
+			t.Errorf("%s matched the provider %q, expected %q", target, provider, expected)
+		}
+	}
+
+	if _, err := ParseTakeoverSignatures(strings.NewReader("{")); err == nil {
+		t.Errorf("ParseTakeoverSignatures did not return an error for bad JSON")
+	}
+}
+
+func TestTakeoverFingerprint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "<html><body><p>There isn't a GitHub Pages site here.</p></body></html>")
+	}))
+	defer srv.Close()
+
+	config := setupConfig("owasp.org")
+	bus := core.NewEventBus()
+	defer bus.Stop()
+
+	ts := NewTakeoverService(config, bus)
+	defer ts.filter.Close()
+
+	fps := []string{"No such app", "There isn't a GitHub Pages site here."}
+	if fp := ts.matchFingerprint(srv.URL+"/", fps); fp != fps[1] {
+		t.Errorf("The fingerprint %q was returned, expected %q", fp, fps[1])
+	}
+	if fp := ts.matchFingerprint(srv.URL+"/", fps[:1]); fp != "" {
+		t.Errorf("The fingerprint %q was returned for a claimed resource", fp)
+	}
+}
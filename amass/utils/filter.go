@@ -0,0 +1,286 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/irfansharif/cfilter"
+)
+
+const (
+	// The number of independently locked partitions in an ExactFilter
+	exactFilterShards = 64
+
+	// Approximate bytes used by the map for each entry, not including the string data
+	exactFilterEntryOverhead = 64
+
+	// Default settings used by the cfilter package
+	cfilterBuckets     = (1 << 18) / 4
+	cfilterBucketSize  = 4
+	cfilterFingerprint = 2
+)
+
+var (
+	filterBucket = []byte("names")
+)
+
+// StringFilter is implemented by the objects that perform filtering of strings
+// to ensure that only unique items get through the filter.
+type StringFilter interface {
+	// Duplicate checks if the string provided has been seen before by this filter.
+	Duplicate(s string) bool
+
+	// Len returns the number of unique strings that have passed through the filter.
+	Len() int
+
+	// MemoryUsage returns the approximate number of bytes of memory held by the filter.
+	MemoryUsage() int64
+
+	// Close releases the resources held by the filter.
+	Close()
+}
+
+// NewStringFilter returns an initialized StringFilter that is exact and kept in memory.
+func NewStringFilter() StringFilter {
+	return NewExactFilter("", 0)
+}
+
+// ProbabilisticFilter is a StringFilter backed by a cuckoo filter. It uses a fixed amount
+// of memory, but false positives will cause some unique strings to be reported as duplicates.
+type ProbabilisticFilter struct {
+	sync.Mutex
+	filter *cfilter.CFilter
+}
+
+// NewProbabilisticFilter returns an initialized ProbabilisticFilter.
+func NewProbabilisticFilter() *ProbabilisticFilter {
+	return &ProbabilisticFilter{filter: cfilter.New()}
+}
+
+// Duplicate implements the StringFilter interface.
+func (pf *ProbabilisticFilter) Duplicate(s string) bool {
+	pf.Lock()
+	defer pf.Unlock()
+
+	if pf.filter.Lookup([]byte(s)) {
+		return true
+	}
+	pf.filter.Insert([]byte(s))
+	return false
+}
+
+// Len implements the StringFilter interface.
+func (pf *ProbabilisticFilter) Len() int {
+	pf.Lock()
+	defer pf.Unlock()
+
+	return int(pf.filter.Count())
+}
+
+// MemoryUsage implements the StringFilter interface.
+func (pf *ProbabilisticFilter) MemoryUsage() int64 {
+	// Each bucket is a slice of fingerprint slices
+	perBucket := int64(24 + cfilterBucketSize*(24+cfilterFingerprint))
+
+	return cfilterBuckets * perBucket
+}
+
+// Close implements the StringFilter interface.
+func (pf *ProbabilisticFilter) Close() {
+	return
+}
+
+type exactFilterShard struct {
+	sync.Mutex
+	entries map[string]struct{}
+	bytes   int64
+}
+
+// ExactFilter is a StringFilter that never reports a unique string as a duplicate.
+// The strings are partitioned across independently locked shards, so concurrent
+// callers rarely contend. Once the number of strings held in memory reaches the
+// configured maximum, shards are spilled to a bolt database on disk.
+type ExactFilter struct {
+	// Accessed atomically, so it must remain 64-bit aligned
+	spilled int64
+
+	shards      [exactFilterShards]*exactFilterShard
+	maxPerShard int
+	dir         string
+	dbLock      sync.Mutex
+	db          *bolt.DB
+	tempDir     string
+}
+
+// NewExactFilter returns an initialized ExactFilter. When maxEntries is greater than
+// zero, strings beyond that number are stored in a temporary file within dir. The
+// system temporary directory is used when dir is empty.
+func NewExactFilter(dir string, maxEntries int) *ExactFilter {
+	ef := &ExactFilter{dir: dir}
+
+	if maxEntries > 0 {
+		ef.maxPerShard = maxEntries / exactFilterShards
+		if ef.maxPerShard == 0 {
+			ef.maxPerShard = 1
+		}
+	}
+	for i := range ef.shards {
+		ef.shards[i] = &exactFilterShard{entries: make(map[string]struct{})}
+	}
+	return ef
+}
+
+func (ef *ExactFilter) shard(s string) *exactFilterShard {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+
+	return ef.shards[h.Sum32()%exactFilterShards]
+}
+
+// Duplicate implements the StringFilter interface.
+func (ef *ExactFilter) Duplicate(s string) bool {
+	shard := ef.shard(s)
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	if _, found := shard.entries[s]; found {
+		return true
+	}
+	if ef.onDisk(s) {
+		return true
+	}
+
+	shard.entries[s] = struct{}{}
+	shard.bytes += int64(len(s)) + exactFilterEntryOverhead
+	if ef.maxPerShard > 0 && len(shard.entries) >= ef.maxPerShard {
+		ef.spill(shard)
+	}
+	return false
+}
+
+func (ef *ExactFilter) database() *bolt.DB {
+	ef.dbLock.Lock()
+	defer ef.dbLock.Unlock()
+
+	return ef.db
+}
+
+func (ef *ExactFilter) onDisk(s string) bool {
+	db := ef.database()
+	if db == nil {
+		return false
+	}
+
+	var found bool
+	db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(filterBucket); b != nil && b.Get([]byte(s)) != nil {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+// spill moves the entries of the shard to the disk. The shard lock must be held.
+func (ef *ExactFilter) spill(shard *exactFilterShard) {
+	db, err := ef.openDatabase()
+	if err != nil {
+		// Keep the entries in memory, since dropping them would break exactness
+		return
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(filterBucket)
+		if err != nil {
+			return err
+		}
+
+		for s := range shard.entries {
+			if err := b.Put([]byte(s), []byte{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	atomic.AddInt64(&ef.spilled, int64(len(shard.entries)))
+	shard.entries = make(map[string]struct{})
+	shard.bytes = 0
+}
+
+func (ef *ExactFilter) openDatabase() (*bolt.DB, error) {
+	ef.dbLock.Lock()
+	defer ef.dbLock.Unlock()
+
+	if ef.db != nil {
+		return ef.db, nil
+	}
+
+	dir, err := ioutil.TempDir(ef.dir, "amass_filter")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, "filter.bolt"), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	// The file is removed when the filter is closed, so durability is not required
+	db.NoSync = true
+
+	ef.db = db
+	ef.tempDir = dir
+	return db, nil
+}
+
+// Len implements the StringFilter interface.
+func (ef *ExactFilter) Len() int {
+	total := int(atomic.LoadInt64(&ef.spilled))
+
+	for _, shard := range ef.shards {
+		shard.Lock()
+		total += len(shard.entries)
+		shard.Unlock()
+	}
+	return total
+}
+
+// MemoryUsage implements the StringFilter interface.
+func (ef *ExactFilter) MemoryUsage() int64 {
+	var total int64
+
+	for _, shard := range ef.shards {
+		shard.Lock()
+		total += shard.bytes
+		shard.Unlock()
+	}
+	return total
+}
+
+// Close implements the StringFilter interface.
+func (ef *ExactFilter) Close() {
+	ef.dbLock.Lock()
+	defer ef.dbLock.Unlock()
+
+	if ef.db == nil {
+		return
+	}
+
+	ef.db.Close()
+	os.RemoveAll(ef.tempDir)
+	ef.db = nil
+}
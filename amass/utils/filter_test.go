@@ -0,0 +1,81 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestExactFilterDuplicate(t *testing.T) {
+	ef := NewExactFilter("", 0)
+	defer ef.Close()
+
+	if ef.Duplicate("www.owasp.org") {
+		t.Errorf("The first occurrence of a name was reported as a duplicate")
+	}
+	if !ef.Duplicate("www.owasp.org") {
+		t.Errorf("The second occurrence of a name was not reported as a duplicate")
+	}
+	if ef.Len() != 1 {
+		t.Errorf("Len returned %d, expected 1", ef.Len())
+	}
+	if ef.MemoryUsage() <= 0 {
+		t.Errorf("MemoryUsage did not report the memory held by the filter")
+	}
+}
+
+func TestExactFilterSpillsToDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "amass_filter_test")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	num := 5000
+	ef := NewExactFilter(dir, 128)
+	defer ef.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func(start int) {
+			defer wg.Done()
+
+			for j := start; j < num; j += 4 {
+				if ef.Duplicate("name" + strconv.Itoa(j) + ".owasp.org") {
+					t.Errorf("A unique name was reported as a duplicate")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < num; i++ {
+		if !ef.Duplicate("name" + strconv.Itoa(i) + ".owasp.org") {
+			t.Errorf("name%d.owasp.org was not reported as a duplicate", i)
+		}
+	}
+	if ef.Len() != num {
+		t.Errorf("Len returned %d, expected %d", ef.Len(), num)
+	}
+	if ef.database() == nil {
+		t.Errorf("The filter did not spill names to the disk")
+	}
+}
+
+func TestProbabilisticFilterDuplicate(t *testing.T) {
+	pf := NewProbabilisticFilter()
+
+	if pf.Duplicate("www.owasp.org") {
+		t.Errorf("The first occurrence of a name was reported as a duplicate")
+	}
+	if !pf.Duplicate("www.owasp.org") {
+		t.Errorf("The second occurrence of a name was not reported as a duplicate")
+	}
+}
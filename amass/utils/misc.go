@@ -8,8 +8,6 @@ import (
 	"io"
 	"regexp"
 	"strings"
-
-	"github.com/irfansharif/cfilter"
 )
 
 const (
@@ -49,54 +47,6 @@ func getWordList(reader io.Reader) []string {
 	return words
 }
 
-type filterRequest struct {
-	String string
-	Result chan bool
-}
-
-// StringFilter implements an object that performs filtering of strings
-// to ensure that only unique items get through the filter.
-type StringFilter struct {
-	filter   *cfilter.CFilter
-	requests chan filterRequest
-	quit     chan struct{}
-}
-
-// NewStringFilter returns an initialized StringFilter.
-func NewStringFilter() *StringFilter {
-	sf := &StringFilter{
-		filter:   cfilter.New(),
-		requests: make(chan filterRequest),
-		quit:     make(chan struct{}),
-	}
-	go sf.processRequests()
-	return sf
-}
-
-// Duplicate checks if the name provided has been seen before by this filter.
-func (sf *StringFilter) Duplicate(s string) bool {
-	result := make(chan bool)
-
-	sf.requests <- filterRequest{String: s, Result: result}
-	return <-result
-}
-
-func (sf *StringFilter) processRequests() {
-	for {
-		select {
-		case <-sf.quit:
-			return
-		case r := <-sf.requests:
-			if sf.filter.Lookup([]byte(r.String)) {
-				r.Result <- true
-			} else {
-				sf.filter.Insert([]byte(r.String))
-				r.Result <- false
-			}
-		}
-	}
-}
-
 // SubdomainRegex returns a Regexp object initialized to match
 // subdomain names that end with the domain provided by the parameter.
 func SubdomainRegex(domain string) *regexp.Regexp {
@@ -4,44 +4,141 @@
 package utils
 
 import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// The priority classes supported by the Queue. Elements with a higher priority
+// are always returned before elements with a lower priority.
+const (
+	QueuePriorityLow int = iota
+	QueuePriorityNormal
+	QueuePriorityHigh
+	numQueuePriorities
 )
 
+// The number of elements moved from the disk back into memory at once
+const queueSpillBatchSize = 1000
+
+// QueueCodec converts the elements of a Queue to and from bytes, so they can be spilled to disk.
+type QueueCodec interface {
+	Encode(data interface{}) ([]byte, error)
+	Decode(b []byte) (interface{}, error)
+}
+
 type queueNode struct {
 	Next *queueNode
 	Data interface{}
 }
 
-// Queue implements a FIFO data structure.
+type queueList struct {
+	head, tail *queueNode
+	size       int
+	onDisk     int
+}
+
+func (l *queueList) append(data interface{}) {
+	element := &queueNode{Data: data}
+
+	l.size++
+	if l.head == nil {
+		l.head = element
+	}
+
+	end := l.tail
+	if end != nil {
+		end.Next = element
+	}
+	l.tail = element
+}
+
+func (l *queueList) next() interface{} {
+	l.size--
+	element := l.head
+	l.head = element.Next
+	if l.tail == element {
+		l.tail = nil
+	}
+	element.Next = nil
+	return element.Data
+}
+
+// Queue implements a FIFO data structure with priority classes. Elements of the same
+// priority are returned in the order they were appended. Queues created by NewSpillQueue
+// move elements to disk once too many are held in memory.
 type Queue struct {
 	sync.Mutex
-	size       int
-	head, tail *queueNode
+	lists [numQueuePriorities]queueList
+
+	// Settings used for spilling elements to the disk
+	maxInMemory int
+	dir         string
+	codec       QueueCodec
+	tempDir     string
+	db          *bolt.DB
 }
 
-// NewQueue returns a Queue FIFO data structure.
+// NewQueue returns a Queue FIFO data structure that holds all elements in memory.
 func NewQueue() *Queue {
 	return new(Queue)
 }
 
-// Append adds the data to the end of the Queue.
-func (q *Queue) Append(data interface{}) {
-	element := new(queueNode)
+// NewSpillQueue returns a Queue that moves elements to a temporary file in dir once more
+// than maxInMemory elements are held in memory. The system temporary directory is used
+// when dir is empty. A maxInMemory value of zero or a nil codec disables the spillover.
+func NewSpillQueue(dir string, maxInMemory int, codec QueueCodec) *Queue {
+	q := NewQueue()
+
+	q.EnableSpill(dir, maxInMemory, codec)
+	return q
+}
+
+// EnableSpill applies the settings of NewSpillQueue to the Queue, so the limit can be set once
+// the configuration is known. The elements already held in memory remain there.
+func (q *Queue) EnableSpill(dir string, maxInMemory int, codec QueueCodec) {
+	if maxInMemory <= 0 || codec == nil {
+		return
+	}
 
 	q.Lock()
 	defer q.Unlock()
 
-	q.size++
-	if q.head == nil {
-		q.head = element
+	q.dir = dir
+	q.maxInMemory = maxInMemory
+	q.codec = codec
+}
+
+// Append adds the data to the end of the Queue with normal priority.
+func (q *Queue) Append(data interface{}) {
+	q.AppendPriority(data, QueuePriorityNormal)
+}
+
+// AppendPriority adds the data to the end of the Queue within the provided priority class.
+func (q *Queue) AppendPriority(data interface{}, priority int) {
+	if priority < QueuePriorityLow {
+		priority = QueuePriorityLow
+	} else if priority > QueuePriorityHigh {
+		priority = QueuePriorityHigh
 	}
 
-	end := q.tail
-	if end != nil {
-		end.Next = element
+	q.Lock()
+	defer q.Unlock()
+
+	l := &q.lists[priority]
+	// Once elements of this priority are on disk, new elements must follow them
+	if q.codec != nil && (l.onDisk > 0 || q.inMemory() >= q.maxInMemory) {
+		if err := q.writeToDisk(priority, data); err == nil {
+			return
+		}
 	}
-	q.tail = element
-	element.Data = data
+	l.append(data)
 }
 
 // Next returns the data at the front of the Queue.
@@ -49,32 +146,163 @@ func (q *Queue) Next() (interface{}, bool) {
 	q.Lock()
 	defer q.Unlock()
 
-	if q.head == nil {
-		return nil, false
-	}
+	for p := numQueuePriorities - 1; p >= 0; p-- {
+		l := &q.lists[p]
 
-	q.size--
-	element := q.head
-	q.head = element.Next
-	if q.tail == element {
-		q.tail = nil
+		if l.head == nil && l.onDisk > 0 {
+			q.readFromDisk(p)
+		}
+		if l.head != nil {
+			return l.next(), true
+		}
 	}
-	element.Next = nil
-	return element.Data, true
+	return nil, false
 }
 
 // Empty return true if the Queue is empty.
 func (q *Queue) Empty() bool {
+	return q.Len() == 0
+}
+
+// Len returns the current length of the Queue
+func (q *Queue) Len() int {
 	q.Lock()
 	defer q.Unlock()
 
-	if q.head == nil {
-		return true
+	var total int
+	for _, l := range q.lists {
+		total += l.size + l.onDisk
 	}
-	return false
+	return total
 }
 
-// Len returns the current length of the Queue
-func (q *Queue) Len() int {
-	return q.size
+// LenOnDisk returns the number of elements currently spilled to the disk.
+func (q *Queue) LenOnDisk() int {
+	q.Lock()
+	defer q.Unlock()
+
+	var total int
+	for _, l := range q.lists {
+		total += l.onDisk
+	}
+	return total
+}
+
+// Close releases the temporary file used by the Queue. Elements on disk are discarded.
+func (q *Queue) Close() {
+	q.Lock()
+	defer q.Unlock()
+
+	for i := range q.lists {
+		q.lists[i].onDisk = 0
+	}
+
+	q.codec = nil
+	if q.db != nil {
+		q.db.Close()
+		os.RemoveAll(q.tempDir)
+		q.db = nil
+	}
+}
+
+func (q *Queue) inMemory() int {
+	var total int
+
+	for _, l := range q.lists {
+		total += l.size
+	}
+	return total
+}
+
+func (q *Queue) database() (*bolt.DB, error) {
+	if q.db != nil {
+		return q.db, nil
+	}
+
+	dir, err := ioutil.TempDir(q.dir, "amass_queue")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, "queue.bolt"), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	// The file is removed when the queue is closed, so durability is not required
+	db.NoSync = true
+
+	q.db = db
+	q.tempDir = dir
+	return db, nil
+}
+
+func queueBucket(priority int) []byte {
+	return []byte("priority" + strconv.Itoa(priority))
+}
+
+func (q *Queue) writeToDisk(priority int, data interface{}) error {
+	db, err := q.database()
+	if err != nil {
+		return err
+	}
+
+	value, err := q.codec.Encode(data)
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(queueBucket(priority))
+		if err != nil {
+			return err
+		}
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		return b.Put(key, value)
+	})
+	if err == nil {
+		q.lists[priority].onDisk++
+	}
+	return err
+}
+
+func (q *Queue) readFromDisk(priority int) {
+	if q.db == nil {
+		return
+	}
+
+	l := &q.lists[priority]
+	q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(queueBucket(priority))
+		if b == nil {
+			l.onDisk = 0
+			return nil
+		}
+
+		var keys [][]byte
+		c := b.Cursor()
+		for k, v := c.First(); k != nil && len(keys) < queueSpillBatchSize; k, v = c.Next() {
+			keys = append(keys, k)
+
+			if data, err := q.codec.Decode(v); err == nil {
+				l.append(data)
+			}
+		}
+		for _, k := range keys {
+			b.Delete(k)
+		}
+
+		l.onDisk -= len(keys)
+		if len(keys) == 0 {
+			l.onDisk = 0
+		}
+		return nil
+	})
 }
@@ -0,0 +1,106 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+)
+
+type stringCodec struct{}
+
+func (sc *stringCodec) Encode(data interface{}) ([]byte, error) {
+	return []byte(data.(string)), nil
+}
+
+func (sc *stringCodec) Decode(b []byte) (interface{}, error) {
+	return string(b), nil
+}
+
+func TestQueuePriorities(t *testing.T) {
+	q := NewQueue()
+
+	q.AppendPriority("low", QueuePriorityLow)
+	q.Append("normal1")
+	q.AppendPriority("high", QueuePriorityHigh)
+	q.Append("normal2")
+
+	expected := []string{"high", "normal1", "normal2", "low"}
+	for _, e := range expected {
+		element, ok := q.Next()
+		if !ok || element.(string) != e {
+			t.Errorf("Got %v, expected %s", element, e)
+		}
+	}
+	if !q.Empty() {
+		t.Errorf("The queue is not empty after removing all the elements")
+	}
+}
+
+func TestSpillQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "amass_queue_test")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	num := 2500
+	q := NewSpillQueue(dir, 100, new(stringCodec))
+	defer q.Close()
+
+	for i := 0; i < num; i++ {
+		q.Append(strconv.Itoa(i))
+	}
+	q.AppendPriority("trusted", QueuePriorityHigh)
+
+	if q.Len() != num+1 {
+		t.Errorf("Len returned %d, expected %d", q.Len(), num+1)
+	}
+	if q.LenOnDisk() == 0 {
+		t.Errorf("The queue did not spill elements to the disk")
+	}
+
+	if element, ok := q.Next(); !ok || element.(string) != "trusted" {
+		t.Errorf("Got %v, expected the high priority element", element)
+	}
+	for i := 0; i < num; i++ {
+		element, ok := q.Next()
+		if !ok || element.(string) != strconv.Itoa(i) {
+			t.Fatalf("Got %v, expected %d", element, i)
+		}
+	}
+	if _, ok := q.Next(); ok {
+		t.Errorf("The queue returned an element after being emptied")
+	}
+}
+
+func TestQueueEnableSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "amass_queue_test")
+	if err != nil {
+		t.Fatalf("Failed to create the temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	q := NewQueue()
+	defer q.Close()
+
+	// Elements appended before the limit was set remain in memory
+	q.Append("0")
+	q.EnableSpill(dir, 10, new(stringCodec))
+	for i := 1; i < 100; i++ {
+		q.Append(strconv.Itoa(i))
+	}
+	if q.LenOnDisk() != 90 {
+		t.Errorf("The queue held %d elements on disk, expected 90", q.LenOnDisk())
+	}
+
+	for i := 0; i < 100; i++ {
+		element, ok := q.Next()
+		if !ok || element.(string) != strconv.Itoa(i) {
+			t.Fatalf("Got %v, expected %d", element, i)
+		}
+	}
+}
@@ -78,22 +78,6 @@ type gexfMeta struct {
 	Desc         string `xml:"description"`
 }
 
-type gexfGraph struct {
-	Mode     string         `xml:"mode,attr,omitempty"`
-	EdgeType string         `xml:"defaultedgetype,attr,omitempty"`
-	Attrs    gexfAttributes `xml:"attributes,omitempty"`
-	Nodes    []gexfNode     `xml:"nodes>node,omitempty"`
-	Edges    []gexfEdge     `xml:"edges>edge,omitempty"`
-}
-
-type gexf struct {
-	XMLName xml.Name
-	Version string    `xml:"version,attr"`
-	Viz     string    `xml:"xmlns:viz,attr"`
-	Meta    gexfMeta  `xml:"meta"`
-	Graph   gexfGraph `xml:"graph"`
-}
-
 var (
 	gexfGreen  = &gexfColor{R: 34, G: 153, B: 84}
 	gexfRed    = &gexfColor{R: 242, G: 44, B: 13}
@@ -107,82 +91,157 @@ var (
 
 // WriteGEXFData generates a GEXF file to display the Amass graph using Gephi.
 func WriteGEXFData(output io.Writer, nodes []Node, edges []Edge) {
+	w := NewGEXFWriter(output)
+
+	for _, n := range nodes {
+		w.WriteNode(n)
+	}
+	for _, e := range edges {
+		w.WriteEdge(e)
+	}
+	w.Close()
+}
+
+// GEXFWriter generates a GEXF file to display the Amass graph using Gephi, while the
+// nodes and edges are streamed.
+type GEXFWriter struct {
+	bufwr *bufio.Writer
+	enc   *xml.Encoder
+	edges int
+	err   error
+
+	// The element currently open within the graph element
+	open string
+}
+
+// NewGEXFWriter returns a GEXFWriter that has written the start of the document to output.
+func NewGEXFWriter(output io.Writer) *GEXFWriter {
 	bufwr := bufio.NewWriter(output)
 
 	bufwr.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
 	bufwr.Flush()
 
-	doc := &gexf{
-		XMLName: xml.Name{
-			Space: xmlNS,
-			Local: "gexf",
-		},
-		Version: "1.3",
-		Viz:     xmlNSVIZ,
-		Meta: gexfMeta{
-			LastModified: time.Now().UTC().Format("2006-01-02"),
-			Creator:      "OWASP Amass - https://github.com/root-secure/Amass",
-			Desc:         "OWASP Amass Network Mapping",
+	w := &GEXFWriter{bufwr: bufwr, enc: xml.NewEncoder(bufwr)}
+	w.enc.Indent("  ", "    ")
+
+	w.start("gexf",
+		xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: xmlNS},
+		xml.Attr{Name: xml.Name{Local: "version"}, Value: "1.3"},
+		xml.Attr{Name: xml.Name{Local: "xmlns:viz"}, Value: xmlNSVIZ},
+	)
+	w.encode("meta", gexfMeta{
+		LastModified: time.Now().UTC().Format("2006-01-02"),
+		Creator:      "OWASP Amass - https://github.com/root-secure/Amass",
+		Desc:         "OWASP Amass Network Mapping",
+	})
+	w.start("graph",
+		xml.Attr{Name: xml.Name{Local: "mode"}, Value: modeStatic},
+		xml.Attr{Name: xml.Name{Local: "defaultedgetype"}, Value: edgeTypeDirected},
+	)
+	w.encode("attributes", gexfAttributes{
+		Class: classNode,
+		Attrs: []gexfAttribute{
+			{ID: "0", Title: "Title", Type: "string"},
+			{ID: "1", Title: "Source", Type: "string"},
+			{ID: "2", Title: "Type", Type: "string"},
 		},
-		Graph: gexfGraph{
-			Mode:     modeStatic,
-			EdgeType: edgeTypeDirected,
-			Attrs: gexfAttributes{
-				Class: classNode,
-				Attrs: []gexfAttribute{
-					{ID: "0", Title: "Title", Type: "string"},
-					{ID: "1", Title: "Source", Type: "string"},
-					{ID: "2", Title: "Type", Type: "string"},
-				},
-			},
+	})
+	return w
+}
+
+// WriteNode implements the StreamWriter interface.
+func (w *GEXFWriter) WriteNode(n Node) error {
+	var color *gexfColor
+
+	switch n.Type {
+	case "subdomain":
+		color = gexfGreen
+	case "domain":
+		color = gexfRed
+	case "address":
+		color = gexfOrange
+	case "ptr":
+		color = gexfYellow
+	case "ns":
+		color = gexfCyan
+	case "mx":
+		color = gexfPurple
+	case "netblock":
+		color = gexfPink
+	case "as":
+		color = gexfBlue
+	}
+
+	w.section("nodes")
+	w.encode("node", gexfNode{
+		ID:    strconv.Itoa(n.ID),
+		Label: n.Label,
+		Attrs: []gexfAttrValue{
+			{For: "0", Value: n.Title},
+			{For: "1", Value: n.Source},
+			{For: "2", Value: n.Type},
 		},
+		Color: color,
+	})
+	return w.err
+}
+
+// WriteEdge implements the StreamWriter interface.
+func (w *GEXFWriter) WriteEdge(e Edge) error {
+	w.section("edges")
+	w.encode("edge", gexfEdge{
+		ID:     strconv.Itoa(w.edges),
+		Label:  e.Label,
+		Source: strconv.Itoa(e.From),
+		Target: strconv.Itoa(e.To),
+	})
+	w.edges++
+	return w.err
+}
+
+// Close implements the StreamWriter interface.
+func (w *GEXFWriter) Close() error {
+	w.section("")
+	w.end("graph")
+	w.end("gexf")
+
+	if err := w.enc.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if err := w.bufwr.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// section closes the nodes or edges element that is open, and opens the named element.
+func (w *GEXFWriter) section(name string) {
+	if w.open == name {
+		return
+	}
+	if w.open != "" {
+		w.end(w.open)
 	}
+	if name != "" {
+		w.start(name)
+	}
+	w.open = name
+}
 
-	for idx, n := range nodes {
-		var color *gexfColor
-
-		switch n.Type {
-		case "subdomain":
-			color = gexfGreen
-		case "domain":
-			color = gexfRed
-		case "address":
-			color = gexfOrange
-		case "ptr":
-			color = gexfYellow
-		case "ns":
-			color = gexfCyan
-		case "mx":
-			color = gexfPurple
-		case "netblock":
-			color = gexfPink
-		case "as":
-			color = gexfBlue
-		}
-
-		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{
-			ID:    strconv.Itoa(idx),
-			Label: n.Label,
-			Attrs: []gexfAttrValue{
-				{For: "0", Value: n.Title},
-				{For: "1", Value: n.Source},
-				{For: "2", Value: n.Type},
-			},
-			Color: color,
-		})
+func (w *GEXFWriter) start(name string, attrs ...xml.Attr) {
+	if w.err == nil {
+		w.err = w.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
 	}
+}
 
-	for idx, e := range edges {
-		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{
-			ID:     strconv.Itoa(idx),
-			Label:  e.Label,
-			Source: strconv.Itoa(e.From),
-			Target: strconv.Itoa(e.To),
-		})
+func (w *GEXFWriter) end(name string) {
+	if w.err == nil {
+		w.err = w.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
 	}
+}
 
-	enc := xml.NewEncoder(bufwr)
-	enc.Indent("  ", "    ")
-	enc.Encode(doc)
-	bufwr.Flush()
+func (w *GEXFWriter) encode(name string, v interface{}) {
+	if w.err == nil {
+		w.err = w.enc.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: name}})
+	}
 }
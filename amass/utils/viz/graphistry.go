@@ -4,6 +4,8 @@
 package viz
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"io"
 	"strconv"
@@ -35,53 +37,147 @@ type graphistryREST struct {
 	Name     string             `json:"name"`
 	Type     string             `json:"type"`
 	Bindings graphistryBindings `json:"bindings"`
-	Edges    []graphistryEdges  `json:"graph"`
-	Nodes    []graphistryNodes  `json:"labels"`
+}
+
+var graphistryColors = map[string]int{
+	"subdomain": 3,
+	"domain":    5,
+	"address":   7,
+	"ptr":       10,
+	"ns":        0,
+	"mx":        9,
+	"netblock":  4,
+	"as":        1,
 }
 
 // WriteGraphistryData generates a JSON file to display the Amass graph using Graphistry.
 func WriteGraphistryData(output io.Writer, nodes []Node, edges []Edge) {
-	colors := map[string]int{
-		"subdomain": 3,
-		"domain":    5,
-		"address":   7,
-		"ptr":       10,
-		"ns":        0,
-		"mx":        9,
-		"netblock":  4,
-		"as":        1,
+	w := NewGraphistryWriter(output)
+
+	for _, n := range nodes {
+		w.WriteNode(n)
+	}
+	for _, e := range edges {
+		w.WriteEdge(e)
 	}
-	name := "OWASP_Amass_" + time.Now().Format("Jan_2_2006_15_04_05")
-	restJSON := &graphistryREST{
-		Name: name,
+	w.Close()
+}
+
+// GraphistryWriter generates a JSON file to display the Amass graph using Graphistry, while
+// the nodes and edges are streamed.
+type GraphistryWriter struct {
+	bufwr *bufio.Writer
+	err   error
+
+	// The array currently open within the document, and the number of elements written to it
+	open  string
+	count int
+}
+
+// NewGraphistryWriter returns a GraphistryWriter that has written the start of the document to output.
+func NewGraphistryWriter(output io.Writer) *GraphistryWriter {
+	w := &GraphistryWriter{bufwr: bufio.NewWriter(output)}
+
+	header, err := json.MarshalIndent(&graphistryREST{
+		Name: "OWASP_Amass_" + time.Now().Format("Jan_2_2006_15_04_05"),
 		Type: "edgelist",
 		Bindings: graphistryBindings{
 			SourceField:      "src",
 			DestinationField: "dst",
 			IDField:          "node",
 		},
+	}, "", "  ")
+	if err != nil {
+		w.err = err
+		return w
 	}
 
-	for idx, node := range nodes {
-		restJSON.Nodes = append(restJSON.Nodes, graphistryNodes{
-			NodeID: strconv.Itoa(idx),
-			Label:  node.Label,
-			Title:  node.Title,
-			Color:  colors[node.Type],
-			Type:   node.Type,
-			Source: node.Source,
-		})
+	// The header object is left open, so the arrays can be added to it
+	header = bytes.TrimSuffix(header, []byte("\n}"))
+	_, w.err = w.bufwr.Write(header)
+	return w
+}
+
+// WriteNode implements the StreamWriter interface.
+func (w *GraphistryWriter) WriteNode(n Node) error {
+	w.element("labels", &graphistryNodes{
+		NodeID: strconv.Itoa(n.ID),
+		Label:  n.Label,
+		Title:  n.Title,
+		Color:  graphistryColors[n.Type],
+		Type:   n.Type,
+		Source: n.Source,
+	})
+	return w.err
+}
+
+// WriteEdge implements the StreamWriter interface.
+func (w *GraphistryWriter) WriteEdge(e Edge) error {
+	w.element("graph", &graphistryEdges{
+		Source:      strconv.Itoa(e.From),
+		Destination: strconv.Itoa(e.To),
+		Title:       e.Title,
+	})
+	return w.err
+}
+
+// Close implements the StreamWriter interface.
+func (w *GraphistryWriter) Close() error {
+	// Graphistry expects both arrays to be present
+	w.section("graph")
+	w.section("")
+	w.write([]byte("\n}\n"))
+
+	if err := w.bufwr.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// element adds the JSON encoding of v to the named array.
+func (w *GraphistryWriter) element(name string, v interface{}) {
+	w.section(name)
+	if w.err != nil {
+		return
+	}
+
+	data, err := json.MarshalIndent(v, "    ", "  ")
+	if err != nil {
+		w.err = err
+		return
 	}
+	if w.count > 0 {
+		w.write([]byte(","))
+	}
+	w.write([]byte("\n    "))
+	w.write(data)
+	w.count++
+}
 
-	for _, edge := range edges {
-		restJSON.Edges = append(restJSON.Edges, graphistryEdges{
-			Source:      strconv.Itoa(edge.From),
-			Destination: strconv.Itoa(edge.To),
-			Title:       edge.Title,
-		})
+// section closes the array that is open, and opens the named array.
+func (w *GraphistryWriter) section(name string) {
+	if w.open == name {
+		return
+	}
+	// The labels array is always written before the graph array
+	if name == "graph" && w.open == "" {
+		w.section("labels")
+	}
+	if w.open != "" {
+		if w.count > 0 {
+			w.write([]byte("\n  "))
+		}
+		w.write([]byte("]"))
 	}
+	if name != "" {
+		w.write([]byte(",\n  \"" + name + "\": ["))
+	}
+	w.open = name
+	w.count = 0
+}
 
-	enc := json.NewEncoder(output)
-	enc.SetIndent("", "  ")
-	enc.Encode(restJSON)
+func (w *GraphistryWriter) write(p []byte) {
+	if w.err == nil {
+		_, w.err = w.bufwr.Write(p)
+	}
 }
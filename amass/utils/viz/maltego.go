@@ -15,7 +15,35 @@ import (
 // WriteMaltegoData converts the Amass graph nodes and edges into a
 // structured table format (CSV) that can be input by Maltego.
 func WriteMaltegoData(output io.Writer, nodes []Node, edges []Edge) {
-	filter := make(map[int]struct{})
+	w := NewMaltegoWriter(output)
+
+	for _, n := range nodes {
+		w.WriteNode(n)
+	}
+	for _, e := range edges {
+		w.WriteEdge(e)
+	}
+	w.Close()
+}
+
+// MaltegoWriter converts the Amass graph nodes and edges into a structured table
+// format (CSV) that can be input by Maltego, while they are streamed. Only the label
+// and type of each node are kept, so the rows can be written as the edges arrive.
+type MaltegoWriter struct {
+	output io.Writer
+	labels map[int]string
+	types  map[int]string
+	err    error
+}
+
+// NewMaltegoWriter returns a MaltegoWriter that has written the column types to output.
+func NewMaltegoWriter(output io.Writer) *MaltegoWriter {
+	w := &MaltegoWriter{
+		output: output,
+		labels: make(map[int]string),
+		types:  make(map[int]string),
+	}
+
 	types := []string{
 		"maltego.Domain",
 		"maltego.DNSName",
@@ -28,25 +56,67 @@ func WriteMaltegoData(output io.Writer, nodes []Node, edges []Edge) {
 		"maltego.DNSName",
 	}
 	// Print the column types in the first row
-	fmt.Fprintln(output, strings.Join(types, ","))
-	// Start the graph tranersal from the autonomous systems
-	for idx, node := range nodes {
-		if node.Type == "as" {
-			traverseTree(output, idx, nodes, edges, filter)
+	fmt.Fprintln(w, strings.Join(types, ","))
+	return w
+}
+
+// WriteNode implements the StreamWriter interface.
+func (w *MaltegoWriter) WriteNode(n Node) error {
+	// Only the nodes that fill a Maltego column are needed by the edges
+	if typeToIndex(n.Type) < 0 {
+		return w.err
+	}
+
+	w.labels[n.ID] = n.Label
+	w.types[n.ID] = n.Type
+	// Print the line containing the AS company
+	if n.Type == "as" {
+		if parts := strings.Split(n.Title, ":"); len(parts) > 2 {
+			company := strings.Replace(strings.TrimSpace(parts[2]), ",", "", -1)
+			writeMaltegoTableLine(w, n.Label, n.Type, company, "company")
 		}
 	}
+	return w.err
 }
 
-func checkFilter(id int, filter map[int]struct{}) bool {
-	if _, ok := filter[id]; ok {
-		return true
+// WriteEdge implements the StreamWriter interface.
+func (w *MaltegoWriter) WriteEdge(e Edge) error {
+	t1, found := w.types[e.From]
+	if !found {
+		return w.err
+	}
+	t2, found := w.types[e.To]
+	if !found {
+		return w.err
+	}
+
+	// The alias of a CNAME record is placed in its own column
+	if strings.Contains(e.Title, "cname") {
+		t1 = "cname"
 	}
-	filter[id] = struct{}{}
-	return false
+	writeMaltegoTableLine(w, w.labels[e.From], t1, w.labels[e.To], t2)
+	return w.err
+}
+
+// Close implements the StreamWriter interface.
+func (w *MaltegoWriter) Close() error {
+	return w.err
+}
+
+// Write passes the table lines to the output, and keeps the first error.
+func (w *MaltegoWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n, err := w.output.Write(p)
+	w.err = err
+	return n, err
 }
 
 func typeToIndex(t string) int {
-	var idx int
+	// Node types without a Maltego column are not written
+	idx := -1
 
 	switch t {
 	case "domain":
@@ -89,64 +159,6 @@ func writeMaltegoTableLine(out io.Writer, data1, type1, data2, type2 string) {
 	fmt.Fprintln(out, strings.Join(row, ","))
 }
 
-func traverseTree(out io.Writer, id int, nodes []Node, edges []Edge, filter map[int]struct{}) {
-	d1 := nodes[id].Label
-	t1 := nodes[id].Type
-
-	var from bool
-	if t1 == "netblock" || t1 == "as" {
-		from = true
-	}
-
-	if checkFilter(id, filter) {
-		return
-	}
-	// Print the line containing the AS company
-	if t1 == "as" {
-		parts := strings.Split(nodes[id].Title, ":")
-		company := strings.Replace(strings.TrimSpace(parts[2]), ",", "", -1)
-		writeMaltegoTableLine(out, d1, t1, company, "company")
-	}
-
-	for _, edge := range edges {
-		subFrom := from
-		n, found := selectNextEdge(id, from, edge)
-		if !found && (t1 == "subdomain" || t1 == "domain") {
-			subFrom = true
-			n, found = selectNextEdge(id, subFrom, edge)
-		}
-		if !found {
-			continue
-		}
-		d2 := nodes[n].Label
-		t2 := nodes[n].Type
-		// Need to properly handle CNAME records
-		if strings.Contains(edge.Title, "cname") {
-			if subFrom {
-				writeMaltegoTableLine(out, d1, "cname", d2, t2)
-			} else {
-				writeMaltegoTableLine(out, d1, t1, d2, "cname")
-			}
-		} else {
-			writeMaltegoTableLine(out, d1, t1, d2, t2)
-		}
-		traverseTree(out, n, nodes, edges, filter)
-	}
-}
-
-func selectNextEdge(id int, from bool, edge Edge) (int, bool) {
-	if from {
-		if edge.From == id {
-			return edge.To, true
-		}
-	} else {
-		if edge.To == id {
-			return edge.From, true
-		}
-	}
-	return 0, false
-}
-
 func cidrToMaltegoNetblock(cidr string) string {
 	_, ipnet, err := net.ParseCIDR(cidr)
 	if err != nil {
@@ -0,0 +1,215 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package viz
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The namespace defined by STIX 2.1 for the deterministic identifiers of cyber observables.
+var stixSCONamespace = uuid.MustParse("00abedb4-aa42-466c-9c01-fed23315a9b7")
+
+// The namespace used for the identifiers of the grouping and relationship objects, so they
+// are also the same each time the findings are exported.
+var stixAmassNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/OWASP/Amass"))
+
+// The timestamp format required by STIX 2.1.
+const stixTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// The graph does not record when a relationship was first seen, so the relationship objects
+// are always created at this time. Each deterministic identifier then has the same created
+// timestamp in every export, and only the modified timestamp changes.
+const stixRelationshipCreated = "1970-01-01T00:00:00.000Z"
+
+type stixObject struct {
+	Type               string            `json:"type"`
+	SpecVersion        string            `json:"spec_version"`
+	ID                 string            `json:"id"`
+	Created            string            `json:"created,omitempty"`
+	Modified           string            `json:"modified,omitempty"`
+	Value              string            `json:"value,omitempty"`
+	Number             int               `json:"number,omitempty"`
+	Name               string            `json:"name,omitempty"`
+	Description        string            `json:"description,omitempty"`
+	RelationshipType   string            `json:"relationship_type,omitempty"`
+	SourceRef          string            `json:"source_ref,omitempty"`
+	TargetRef          string            `json:"target_ref,omitempty"`
+	Context            string            `json:"context,omitempty"`
+	ObjectRefs         []string          `json:"object_refs,omitempty"`
+	ExternalReferences []stixExternalRef `json:"external_references,omitempty"`
+}
+
+type stixExternalRef struct {
+	SourceName string `json:"source_name"`
+	ExternalID string `json:"external_id"`
+}
+
+type stixBundle struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id"`
+	Objects []*stixObject `json:"objects"`
+}
+
+// WriteSTIXData generates a STIX 2.1 bundle containing the domain-name, ipv4-addr, ipv6-addr and
+// autonomous-system observables from the Amass graph, the resolves-to and belongs-to relationships
+// between them, and a grouping object for the enumeration. The identifiers are deterministic, so
+// exporting the findings again updates the objects instead of duplicating them. The specification
+// recommends UUIDv4 for the identifiers of the relationship and grouping objects, while these use
+// UUIDv5 values generated from the objects they relate, which the specification also permits.
+func WriteSTIXData(output io.Writer, enum string, first, last time.Time, nodes []Node, edges []Edge) {
+	created := first.UTC().Format(stixTimeFormat)
+	modified := last.UTC().Format(stixTimeFormat)
+
+	objects := make(map[string]*stixObject)
+	ids := make(map[int]string)
+	for idx, node := range nodes {
+		var obj *stixObject
+
+		switch node.Type {
+		case "domain", "subdomain", "ns", "mx":
+			obj = stixObservable("domain-name", map[string]interface{}{"value": node.Label})
+			obj.Value = node.Label
+		case "address":
+			ip := net.ParseIP(node.Label)
+			if ip == nil {
+				continue
+			}
+
+			t := "ipv6-addr"
+			if ip.To4() != nil {
+				t = "ipv4-addr"
+			}
+			obj = stixObservable(t, map[string]interface{}{"value": node.Label})
+			obj.Value = node.Label
+		case "as":
+			asn, err := strconv.Atoi(node.Label)
+			if err != nil {
+				continue
+			}
+
+			obj = stixObservable("autonomous-system", map[string]interface{}{"number": asn})
+			obj.Number = asn
+			obj.Name = asDescription(node.Title)
+		default:
+			continue
+		}
+
+		ids[idx] = obj.ID
+		objects[obj.ID] = obj
+	}
+
+	addRelationship := func(rel string, from, to int) {
+		src, ok1 := ids[from]
+		dst, ok2 := ids[to]
+		if !ok1 || !ok2 || src == dst {
+			return
+		}
+
+		id := "relationship--" + uuid.NewSHA1(stixAmassNamespace, []byte(src+","+rel+","+dst)).String()
+		objects[id] = &stixObject{
+			Type:             "relationship",
+			SpecVersion:      "2.1",
+			ID:               id,
+			Created:          stixRelationshipCreated,
+			Modified:         modified,
+			RelationshipType: rel,
+			SourceRef:        src,
+			TargetRef:        dst,
+		}
+	}
+
+	prefixes := make(map[int][]int)
+	for _, edge := range edges {
+		if edge.Title == "has_prefix" {
+			prefixes[edge.To] = append(prefixes[edge.To], edge.From)
+		}
+	}
+	for _, edge := range edges {
+		switch edge.Title {
+		case "a_to", "aaaa_to", "cname_to":
+			addRelationship("resolves-to", edge.From, edge.To)
+		case "contains":
+			// The address belongs to the autonomous systems announcing the netblock
+			for _, as := range prefixes[edge.From] {
+				addRelationship("belongs-to", edge.To, as)
+			}
+		}
+	}
+
+	var refs []string
+	for id := range objects {
+		refs = append(refs, id)
+	}
+	sort.Strings(refs)
+
+	group := &stixObject{
+		Type:        "grouping",
+		SpecVersion: "2.1",
+		ID:          "grouping--" + uuid.NewSHA1(stixAmassNamespace, []byte(enum)).String(),
+		Created:     created,
+		Modified:    modified,
+		Name:        "OWASP Amass enumeration " + enum,
+		Description: "The attack surface discovered by the OWASP Amass enumeration " + enum,
+		Context:     "unspecified",
+		ObjectRefs:  refs,
+		ExternalReferences: []stixExternalRef{
+			{SourceName: "OWASP Amass", ExternalID: enum},
+		},
+	}
+
+	bundle := &stixBundle{
+		Type: "bundle",
+		ID:   "bundle--" + uuid.NewSHA1(stixAmassNamespace, []byte("bundle,"+enum)).String(),
+	}
+	for _, id := range refs {
+		bundle.Objects = append(bundle.Objects, objects[id])
+	}
+	bundle.Objects = append(bundle.Objects, group)
+
+	enc := json.NewEncoder(output)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(bundle)
+}
+
+// stixObservable returns a cyber observable with the identifier generated from the
+// ID contributing properties, as defined by the STIX 2.1 specification.
+func stixObservable(t string, props map[string]interface{}) *stixObject {
+	var buf bytes.Buffer
+
+	// The properties are serialized with the keys sorted and without whitespace
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(props)
+
+	name := bytes.TrimSpace(buf.Bytes())
+	return &stixObject{
+		Type:        t,
+		SpecVersion: "2.1",
+		ID:          t + "--" + uuid.NewSHA1(stixSCONamespace, name).String(),
+	}
+}
+
+// asDescription extracts the description from the title of an autonomous system node.
+func asDescription(title string) string {
+	i := strings.Index(title, ", Desc: ")
+	if i == -1 {
+		return ""
+	}
+
+	desc := title[i+len(", Desc: "):]
+	if j := strings.Index(desc, ", Registrant: "); j != -1 {
+		desc = desc[:j]
+	}
+	return strings.TrimSpace(desc)
+}
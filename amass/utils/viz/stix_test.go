@@ -0,0 +1,109 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package viz
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteSTIXData(t *testing.T) {
+	nodes := []Node{
+		{ID: 0, Type: "domain", Label: "example.com"},
+		{ID: 1, Type: "subdomain", Label: "www.example.com"},
+		{ID: 2, Type: "subdomain", Label: "web.example.com"},
+		{ID: 3, Type: "address", Label: "192.0.2.10"},
+		{ID: 4, Type: "netblock", Label: "192.0.2.0/24"},
+		{ID: 5, Type: "as", Label: "64496", Title: "as: 64496, Desc: EXAMPLE-AS"},
+	}
+	edges := []Edge{
+		{From: 0, To: 1, Title: "root_of"},
+		{From: 1, To: 2, Title: "cname_to"},
+		{From: 2, To: 3, Title: "a_to"},
+		{From: 4, To: 3, Title: "contains"},
+		{From: 5, To: 4, Title: "has_prefix"},
+	}
+	first, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	last, _ := time.Parse(time.RFC3339, "2019-01-01T01:00:00Z")
+
+	var buf1, buf2, buf3 bytes.Buffer
+	WriteSTIXData(&buf1, "enum1", first, last, nodes, edges)
+	WriteSTIXData(&buf2, "enum1", first, last, nodes, edges)
+	if buf1.String() != buf2.String() {
+		t.Errorf("The STIX bundle was not the same when exported again")
+	}
+	// The relationships found by a later enumeration keep the same created timestamp
+	WriteSTIXData(&buf3, "enum2", last, last.Add(time.Hour), nodes, edges)
+
+	var bundle stixBundle
+	if err := json.Unmarshal(buf1.Bytes(), &bundle); err != nil {
+		t.Fatalf("Failed to parse the STIX bundle: %v", err)
+	}
+
+	objects := make(map[string]*stixObject)
+	counts := make(map[string]int)
+	for _, obj := range bundle.Objects {
+		objects[obj.ID] = obj
+		counts[obj.Type]++
+	}
+	for typ, count := range map[string]int{
+		"domain-name":       3,
+		"ipv4-addr":         1,
+		"autonomous-system": 1,
+		"relationship":      3,
+		"grouping":          1,
+	} {
+		if counts[typ] != count {
+			t.Errorf("The STIX bundle had %d %s objects, expected %d", counts[typ], typ, count)
+		}
+	}
+
+	www := "domain-name--3b96539c-807f-59c9-b4df-fe1865757b1e"
+	addr := "ipv4-addr--a2fbe2c6-009e-52e0-b452-90b71e3109c2"
+	as := "autonomous-system--9ad79ee3-2fde-5015-b0cc-7a96404effca"
+	for _, id := range []string{www, addr, as} {
+		if _, found := objects[id]; !found {
+			t.Errorf("The STIX bundle did not include the deterministic identifier %s", id)
+		}
+	}
+	if obj := objects[as]; obj != nil && (obj.Number != 64496 || obj.Name != "EXAMPLE-AS") {
+		t.Errorf("The autonomous system was not complete: %+v", obj)
+	}
+
+	found := make(map[string]bool)
+	for _, obj := range bundle.Objects {
+		if obj.Type == "relationship" {
+			found[objects[obj.SourceRef].Value+" "+obj.RelationshipType+" "+
+				objects[obj.TargetRef].Value+objects[obj.TargetRef].Name] = true
+		}
+	}
+	for _, rel := range []string{
+		"www.example.com resolves-to web.example.com",
+		"web.example.com resolves-to 192.0.2.10",
+		"192.0.2.10 belongs-to EXAMPLE-AS",
+	} {
+		if !found[rel] {
+			t.Errorf("The STIX bundle did not include the relationship: %s", rel)
+		}
+	}
+
+	var later stixBundle
+	if err := json.Unmarshal(buf3.Bytes(), &later); err != nil {
+		t.Fatalf("Failed to parse the later STIX bundle: %v", err)
+	}
+	for _, obj := range later.Objects {
+		if obj.Type != "relationship" {
+			continue
+		}
+
+		if prev, found := objects[obj.ID]; !found || prev.Created != obj.Created {
+			t.Errorf("The relationship %s was not created at the same time in both exports", obj.ID)
+		}
+		if obj.Modified != "2019-01-01T02:00:00.000Z" || obj.Modified < obj.Created {
+			t.Errorf("The relationship %s had unexpected timestamps: %s, %s", obj.ID, obj.Created, obj.Modified)
+		}
+	}
+}
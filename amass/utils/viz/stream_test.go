@@ -0,0 +1,159 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package viz
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+var streamTestNodes = []Node{
+	{ID: 0, Type: "domain", Label: "example.com"},
+	{ID: 1, Type: "subdomain", Label: "www.example.com"},
+	{ID: 2, Type: "subdomain", Label: "web.example.com"},
+	{ID: 3, Type: "address", Label: "192.0.2.10"},
+	{ID: 4, Type: "netblock", Label: "192.0.2.0/24"},
+	{ID: 5, Type: "as", Label: "64496", Title: "as: 64496, Desc: EXAMPLE-AS"},
+	{ID: 6, Type: "certificate", Label: "abcdef"},
+}
+
+var streamTestEdges = []Edge{
+	{From: 0, To: 1, Title: "root_of"},
+	{From: 1, To: 2, Title: "cname_to"},
+	{From: 2, To: 3, Title: "a_to"},
+	{From: 4, To: 3, Title: "contains"},
+	{From: 5, To: 4, Title: "has_prefix"},
+	{From: 1, To: 6, Title: "has_cert"},
+}
+
+func TestGEXFWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	w := NewGEXFWriter(&buf)
+	for _, n := range streamTestNodes {
+		w.WriteNode(n)
+	}
+	for _, e := range streamTestEdges {
+		w.WriteEdge(e)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to write the GEXF file: %v", err)
+	}
+
+	var doc struct {
+		Nodes []gexfNode `xml:"graph>nodes>node"`
+		Edges []gexfEdge `xml:"graph>edges>edge"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("The GEXF file could not be parsed: %v", err)
+	}
+	if len(doc.Nodes) != len(streamTestNodes) || len(doc.Edges) != len(streamTestEdges) {
+		t.Fatalf("The GEXF file had %d nodes and %d edges", len(doc.Nodes), len(doc.Edges))
+	}
+	if n := doc.Nodes[5]; n.ID != "5" || n.Label != "64496" {
+		t.Errorf("Unexpected node: %+v", n)
+	}
+	if e := doc.Edges[3]; e.ID != "3" || e.Source != "4" || e.Target != "3" {
+		t.Errorf("Unexpected edge: %+v", e)
+	}
+}
+
+func TestMaltegoWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	WriteMaltegoData(&buf, streamTestNodes, streamTestEdges)
+
+	expected := []string{
+		",,,,,,64496,EXAMPLE-AS,",
+		"example.com,www.example.com,,,,,,,",
+		",web.example.com,,,,,,,www.example.com",
+		",web.example.com,,,192.0.2.10,,,,",
+		",,,,192.0.2.10,192.0.2.0-192.0.2.255,,,",
+		",,,,,192.0.2.0-192.0.2.255,64496,,",
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(expected)+1 {
+		t.Fatalf("The Maltego table had %d lines:\n%s", len(lines), buf.String())
+	}
+	for i, line := range expected {
+		if lines[i+1] != line {
+			t.Errorf("Line %d was %s, expected %s", i+1, lines[i+1], line)
+		}
+	}
+}
+
+func TestGraphistryWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	w := NewGraphistryWriter(&buf)
+	for _, n := range streamTestNodes {
+		w.WriteNode(n)
+	}
+	for _, e := range streamTestEdges {
+		w.WriteEdge(e)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to write the Graphistry file: %v", err)
+	}
+
+	var doc struct {
+		Type     string             `json:"type"`
+		Bindings graphistryBindings `json:"bindings"`
+		Edges    []graphistryEdges  `json:"graph"`
+		Nodes    []graphistryNodes  `json:"labels"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("The Graphistry file could not be parsed: %v\n%s", err, buf.String())
+	}
+	if doc.Type != "edgelist" || doc.Bindings.IDField != "node" {
+		t.Errorf("Unexpected header: %+v", doc)
+	}
+	if len(doc.Nodes) != len(streamTestNodes) || len(doc.Edges) != len(streamTestEdges) {
+		t.Fatalf("The Graphistry file had %d nodes and %d edges", len(doc.Nodes), len(doc.Edges))
+	}
+	if n := doc.Nodes[5]; n.NodeID != "5" || n.Label != "64496" || n.Color != 1 {
+		t.Errorf("Unexpected node: %+v", n)
+	}
+	if e := doc.Edges[3]; e.Source != "4" || e.Destination != "3" || e.Title != "contains" {
+		t.Errorf("Unexpected edge: %+v", e)
+	}
+
+	// Both arrays are written when the graph is empty
+	buf.Reset()
+	NewGraphistryWriter(&buf).Close()
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Errorf("The empty Graphistry file could not be parsed: %v\n%s", err, buf.String())
+	}
+}
+
+func TestVisjsWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	w := NewVisjsWriter(&buf)
+	for _, n := range streamTestNodes {
+		w.WriteNode(n)
+	}
+	for _, e := range streamTestEdges {
+		w.WriteEdge(e)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to write the Visjs file: %v", err)
+	}
+
+	page := buf.String()
+	nodes := strings.Index(page, "var nodes = [\n")
+	edges := strings.Index(page, "];\nvar edges = [\n")
+	if nodes == -1 || edges < nodes || !strings.HasSuffix(page, htmlEnd) {
+		t.Fatalf("The Visjs file was not structured as expected:\n%s", page)
+	}
+	if !strings.Contains(page[nodes:edges], "{id: 6, title: 'as: 64496, Desc: EXAMPLE-AS', color: {background: 'blue'}},\n") {
+		t.Errorf("The autonomous system node was missing:\n%s", page[nodes:edges])
+	}
+	if !strings.Contains(page[edges:], "{from: 5, to: 4, title: 'contains'},\n") {
+		t.Errorf("The contains edge was missing:\n%s", page[edges:])
+	}
+}
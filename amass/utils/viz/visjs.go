@@ -101,57 +101,95 @@ const htmlEnd string = `
 
 // WriteVisjsData generates a HTML file that displays the Amass graph using Visjs.
 func WriteVisjsData(output io.Writer, nodes []Node, edges []Edge) {
+	w := NewVisjsWriter(output)
+
+	for _, n := range nodes {
+		w.WriteNode(n)
+	}
+	for _, e := range edges {
+		w.WriteEdge(e)
+	}
+	w.Close()
+}
+
+// VisjsWriter generates a HTML file that displays the Amass graph using Visjs, while the
+// nodes and edges are streamed.
+type VisjsWriter struct {
+	bufwr *bufio.Writer
+	edges bool
+}
+
+// NewVisjsWriter returns a VisjsWriter that has written the start of the document to output.
+func NewVisjsWriter(output io.Writer) *VisjsWriter {
 	bufwr := bufio.NewWriter(output)
 
 	bufwr.WriteString(htmlStart)
-	bufwr.Flush()
-
-	nStr := "var nodes = [\n"
-	for idx, node := range nodes {
-		idxStr := strconv.Itoa(idx + 1)
-
-		switch node.Type {
-		case "subdomain":
-			nStr += "{id: " + idxStr + ", title: '" + node.Title +
-				", Source: " + node.Source + "', color: {background: 'green'}},\n"
-		case "domain":
-			nStr += "{id: " + idxStr + ", title: '" + node.Title +
-				", Source: " + node.Source + "', color: {background: 'red'}},\n"
-		case "address":
-			nStr += "{id: " + idxStr + ", title: '" + node.Title +
-				"', color: {background: 'orange'}},\n"
-		case "ptr":
-			nStr += "{id: " + idxStr + ", title: '" + node.Title +
-				"', color: {background: 'yellow'}},\n"
-		case "ns":
-			nStr += "{id: " + idxStr + ", title: '" + node.Title +
-				", Source: " + node.Source + "', color: {background: 'cyan'}},\n"
-		case "mx":
-			nStr += "{id: " + idxStr + ", title: '" + node.Title +
-				", Source: " + node.Source + "', color: {background: 'purple'}},\n"
-		case "netblock":
-			nStr += "{id: " + idxStr + ", title: '" + node.Title +
-				"', color: {background: 'pink'}},\n"
-		case "as":
-			nStr += "{id: " + idxStr + ", title: '" + node.Title +
-				"', color: {background: 'blue'}},\n"
-		}
+	bufwr.WriteString("var nodes = [\n")
+	return &VisjsWriter{bufwr: bufwr}
+}
 
+// WriteNode implements the StreamWriter interface.
+func (w *VisjsWriter) WriteNode(n Node) error {
+	idxStr := strconv.Itoa(n.ID + 1)
+
+	var nStr string
+	switch n.Type {
+	case "subdomain":
+		nStr = "{id: " + idxStr + ", title: '" + n.Title +
+			", Source: " + n.Source + "', color: {background: 'green'}},\n"
+	case "domain":
+		nStr = "{id: " + idxStr + ", title: '" + n.Title +
+			", Source: " + n.Source + "', color: {background: 'red'}},\n"
+	case "address":
+		nStr = "{id: " + idxStr + ", title: '" + n.Title +
+			"', color: {background: 'orange'}},\n"
+	case "ptr":
+		nStr = "{id: " + idxStr + ", title: '" + n.Title +
+			"', color: {background: 'yellow'}},\n"
+	case "ns":
+		nStr = "{id: " + idxStr + ", title: '" + n.Title +
+			", Source: " + n.Source + "', color: {background: 'cyan'}},\n"
+	case "mx":
+		nStr = "{id: " + idxStr + ", title: '" + n.Title +
+			", Source: " + n.Source + "', color: {background: 'purple'}},\n"
+	case "netblock":
+		nStr = "{id: " + idxStr + ", title: '" + n.Title +
+			"', color: {background: 'pink'}},\n"
+	case "as":
+		nStr = "{id: " + idxStr + ", title: '" + n.Title +
+			"', color: {background: 'blue'}},\n"
 	}
-	nStr += "];\n"
-	bufwr.WriteString(nStr)
-	bufwr.Flush()
-
-	eStr := "var edges = [\n"
-	for _, edge := range edges {
-		from := strconv.Itoa(edge.From + 1)
-		to := strconv.Itoa(edge.To + 1)
-		eStr += "{from: " + from + ", to: " + to + ", title: '" + edge.Title + "'},\n"
+
+	_, err := w.bufwr.WriteString(nStr)
+	return err
+}
+
+// WriteEdge implements the StreamWriter interface.
+func (w *VisjsWriter) WriteEdge(e Edge) error {
+	w.startEdges()
+
+	from := strconv.Itoa(e.From + 1)
+	to := strconv.Itoa(e.To + 1)
+	_, err := w.bufwr.WriteString("{from: " + from + ", to: " + to + ", title: '" + e.Title + "'},\n")
+	return err
+}
+
+// Close implements the StreamWriter interface.
+func (w *VisjsWriter) Close() error {
+	w.startEdges()
+
+	w.bufwr.WriteString("];\n")
+	w.bufwr.WriteString(htmlEnd)
+	return w.bufwr.Flush()
+}
+
+// startEdges ends the array of nodes and begins the array of edges.
+func (w *VisjsWriter) startEdges() {
+	if w.edges {
+		return
 	}
-	eStr += "];\n"
-	bufwr.WriteString(eStr)
-	bufwr.Flush()
 
-	bufwr.WriteString(htmlEnd)
-	bufwr.Flush()
+	w.bufwr.WriteString("];\n")
+	w.bufwr.WriteString("var edges = [\n")
+	w.edges = true
 }
@@ -18,3 +18,12 @@ type Node struct {
 	Title  string
 	Source string
 }
+
+// StreamWriter writes the nodes and edges of an Amass graph as they are provided, so the
+// complete graph does not need to be held in memory. All the nodes must be written before
+// the edges, and Close completes the output.
+type StreamWriter interface {
+	WriteNode(n Node) error
+	WriteEdge(e Edge) error
+	Close() error
+}
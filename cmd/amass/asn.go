@@ -0,0 +1,179 @@
+// Copyright 2017 Jeff Foley. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/root-secure/Amass/amass"
+	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/utils"
+	"github.com/fatih/color"
+)
+
+const (
+	asnUsageMsg = "asn [options]"
+)
+
+type asnArgs struct {
+	Addresses utils.ParseIPs
+	ASNs      utils.ParseInts
+	Format    string
+	Imports   utils.ParseStrings
+	OrgName   string
+	Options   struct {
+		Info bool
+	}
+	Filepaths struct {
+		ConfigFile string
+		Database   string
+		Directory  string
+	}
+}
+
+func runASNCommand(clArgs []string) {
+	var args asnArgs
+	var help1, help2 bool
+	asnCommand := flag.NewFlagSet("asn", flag.ExitOnError)
+
+	asnBuf := new(bytes.Buffer)
+	asnCommand.SetOutput(asnBuf)
+
+	asnCommand.BoolVar(&help1, "h", false, "Show the program usage message")
+	asnCommand.BoolVar(&help2, "help", false, "Show the program usage message")
+	asnCommand.Var(&args.Addresses, "addr", "IPs and ranges (192.168.1.1-254) separated by commas")
+	asnCommand.Var(&args.ASNs, "asn", "ASNs separated by commas (can be used multiple times)")
+	asnCommand.StringVar(&args.Format, "format", "", "Dataset format: iptoasn, rir, mrt, bgpdump or asnlist (detected by default)")
+	asnCommand.Var(&args.Imports, "import", "Path to an IP-to-ASN dataset file to import (can be used multiple times)")
+	asnCommand.BoolVar(&args.Options.Info, "info", false, "Print the datasets and last update time of the database")
+	asnCommand.StringVar(&args.OrgName, "org", "", "Search string provided against AS description information")
+	asnCommand.StringVar(&args.Filepaths.ConfigFile, "config", "", "Path to the INI configuration file. Additional details below")
+	asnCommand.StringVar(&args.Filepaths.Database, "db", "", "Path to the offline ASN database file")
+	asnCommand.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the output files")
+
+	if len(clArgs) < 1 {
+		commandUsage(asnUsageMsg, asnCommand, asnBuf)
+		return
+	}
+
+	if err := asnCommand.Parse(clArgs); err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if help1 || help2 {
+		commandUsage(asnUsageMsg, asnCommand, asnBuf)
+		return
+	}
+
+	config := &core.Config{Log: log.New(ioutil.Discard, "", 0)}
+	core.AcquireConfig(args.Filepaths.Directory, args.Filepaths.ConfigFile, config)
+	if args.Filepaths.Directory != "" {
+		config.Dir = args.Filepaths.Directory
+	}
+	if args.Filepaths.Database != "" {
+		config.ASNDatabase = args.Filepaths.Database
+	}
+
+	path := amass.ASNDatabasePath(config)
+	if path == "" {
+		r.Fprintln(color.Error, "Failed to identify the path of the ASN database")
+		os.Exit(1)
+	}
+
+	if len(args.Imports) > 0 {
+		if err := importASNDatasets(path, &args); err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !args.Options.Info && args.OrgName == "" && len(args.Addresses) == 0 && len(args.ASNs) == 0 {
+		commandUsage(asnUsageMsg, asnCommand, asnBuf)
+		os.Exit(1)
+	}
+
+	adb, err := amass.OpenASNDatabase(path)
+	if err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	defer adb.Close()
+
+	if args.Options.Info {
+		updated, datasets := adb.Updated()
+
+		fmt.Fprintf(color.Output, "%s: %s\n", blue("Database"), green(adb.Path))
+		if !updated.IsZero() {
+			fmt.Fprintf(color.Output, "%s: %s\n", blue("Updated"), green(updated.Format("01/02 15:04:05 2006 MST")))
+		}
+		fmt.Fprintf(color.Output, "%s: %s\n", blue("Datasets"), green(strings.Join(datasets, ", ")))
+	}
+
+	for _, addr := range args.Addresses {
+		if req := adb.LookupIP(addr.String()); req != nil {
+			fmt.Fprintf(color.Output, "%s\t%s\t%s\n", green(addr.String()), yellow(req.Prefix), blue(asnDescription(req)))
+		} else {
+			fmt.Fprintf(color.Output, "%s\t%s\n", green(addr.String()), r.Sprint("Not found"))
+		}
+	}
+
+	for _, asn := range args.ASNs {
+		req := adb.LookupASN(asn)
+		if req == nil {
+			fmt.Fprintf(color.Output, "%s\t%s\n", green(fmt.Sprintf("ASN %d", asn)), r.Sprint("Not found"))
+			continue
+		}
+
+		fmt.Fprintf(color.Output, "%s\n", blue(asnDescription(req)))
+		for _, cidr := range req.Netblocks {
+			fmt.Fprintf(color.Output, "\t%s\n", yellow(cidr))
+		}
+	}
+
+	if args.OrgName != "" {
+		for _, req := range adb.ASNsByName(args.OrgName) {
+			fmt.Printf("%d, %s\n", req.ASN, req.Description)
+		}
+	}
+}
+
+func importASNDatasets(path string, args *asnArgs) error {
+	adb, err := amass.NewASNDatabase(path)
+	if err != nil {
+		return err
+	}
+	defer adb.Close()
+
+	for _, input := range args.Imports {
+		f, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("Failed to open the dataset file: %v", err)
+		}
+
+		count, err := adb.Import(f, args.Format, filepath.Base(input))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("Failed to import %s: %v", input, err)
+		}
+		fmt.Fprintf(color.Output, "%s: %s prefixes imported into %s\n", blue(input), green(count), yellow(path))
+	}
+	return nil
+}
+
+func asnDescription(req *core.ASNRequest) string {
+	desc := fmt.Sprintf("ASN %d", req.ASN)
+
+	if req.Description != "" {
+		desc += " - " + req.Description
+	}
+	return desc
+}
@@ -5,11 +5,16 @@ package main
 
 import (
 	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,22 +30,52 @@ const (
 )
 
 type dbArgs struct {
+	Annotate   string
+	Annotation struct {
+		Owner          string
+		Criticality    string
+		FalsePositive  bool
+		Decommissioned bool
+		Notes          string
+	}
 	Domains utils.ParseStrings
 	Enum    int
+	Filters struct {
+		ASNs        utils.ParseInts
+		CIDRs       utils.ParseCIDRs
+		Tags        utils.ParseStrings
+		Sources     utils.ParseStrings
+		RecordTypes utils.ParseStrings
+		CNAME       string
+		Since       string
+		Until       string
+	}
+	Format    string
+	Delete    string
+	Retention struct {
+		KeepLast int
+		KeepDays int
+	}
+	Merge   utils.ParseStrings
 	Options struct {
+		Assets           bool
+		Compact          bool
 		DemoMode         bool
+		Incremental      bool
 		IPs              bool
 		IPv4             bool
 		IPv6             bool
 		ListEnumerations bool
+		Query            bool
 		Show             bool
 		Sources          bool
 	}
 	Filepaths struct {
-		ConfigFile string
-		Directory  string
-		Domains    string
-		Import     string
+		Annotations string
+		ConfigFile  string
+		Directory   string
+		Domains     string
+		Import      string
 	}
 }
 
@@ -54,15 +89,39 @@ func runDBCommand(clArgs []string) {
 
 	dbCommand.BoolVar(&help1, "h", false, "Show the program usage message")
 	dbCommand.BoolVar(&help2, "help", false, "Show the program usage message")
+	dbCommand.BoolVar(&args.Options.Assets, "assets", false, "Print the asset inventory with the first and last time each asset was seen")
+	dbCommand.StringVar(&args.Annotate, "annotate", "", "Annotate the name, address or netblock with the owner, criticality, false-positive, decommissioned and notes flags")
+	dbCommand.Var(&args.Filters.ASNs, "asn", "Query for names with addresses in the ASNs separated by commas (can be used multiple times)")
+	dbCommand.Var(&args.Filters.CIDRs, "cidr", "Query for names with addresses in the CIDRs separated by commas (can be used multiple times)")
+	dbCommand.StringVar(&args.Filters.CNAME, "cname", "", "Query for names with a CNAME target matching the pattern (e.g. *.cloudfront.net)")
+	dbCommand.BoolVar(&args.Options.Compact, "compact", false, "Release the storage left behind by deleted enumerations")
 	dbCommand.Var(&args.Domains, "d", "Domain names separated by commas (can be used multiple times)")
 	dbCommand.IntVar(&args.Enum, "enum", 0, "Identify an enumeration via an index from the listing")
+	dbCommand.StringVar(&args.Annotation.Criticality, "criticality", "", "Criticality of the annotated asset: "+strings.Join(handlers.Criticalities, ", "))
+	dbCommand.BoolVar(&args.Annotation.Decommissioned, "decommissioned", false, "Mark the annotated asset as decommissioned")
+	dbCommand.StringVar(&args.Delete, "delete", "", "Delete an enumeration identified via an index from the listing or the UUID")
 	dbCommand.BoolVar(&args.Options.DemoMode, "demo", false, "Censor output to make it suitable for demonstrations")
+	dbCommand.BoolVar(&args.Annotation.FalsePositive, "false-positive", false, "Mark the annotated asset as a false positive")
+	dbCommand.StringVar(&args.Format, "format", "text", "Output format: text, json or csv for queries, and text or json for assets")
+	dbCommand.BoolVar(&args.Options.Incremental, "incremental", false, "Only merge the enumerations not already in the database")
 	dbCommand.BoolVar(&args.Options.IPs, "ip", false, "Show the IP addresses for discovered names")
 	dbCommand.BoolVar(&args.Options.IPv4, "ipv4", false, "Show the IPv4 addresses for discovered names")
 	dbCommand.BoolVar(&args.Options.IPv6, "ipv6", false, "Show the IPv6 addresses for discovered names")
+	dbCommand.IntVar(&args.Retention.KeepDays, "keep-days", 0, "Delete the enumerations without findings during the last N days")
+	dbCommand.IntVar(&args.Retention.KeepLast, "keep-last", 0, "Delete all but the last N enumerations for each domain")
 	dbCommand.BoolVar(&args.Options.ListEnumerations, "list", false, "Numbered list of enums filtered on provided domains")
+	dbCommand.Var(&args.Merge, "merge", "Merge a graph database directory or data operations JSON file (can be used multiple times)")
+	dbCommand.StringVar(&args.Annotation.Notes, "notes", "", "Notes for the annotated asset")
+	dbCommand.StringVar(&args.Annotation.Owner, "owner", "", "Owner of the annotated asset")
+	dbCommand.BoolVar(&args.Options.Query, "query", false, "Print the names matching the filters for the enumeration index + domains provided")
+	dbCommand.StringVar(&args.Filters.Since, "since", "", "Query for names discovered after (format: "+timeFormat+")")
+	dbCommand.Var(&args.Filters.Sources, "source", "Query for names from the data sources separated by commas (can be used multiple times)")
 	dbCommand.BoolVar(&args.Options.Sources, "src", false, "Print data sources for the discovered names")
 	dbCommand.BoolVar(&args.Options.Show, "show", false, "Print the results for the enumeration index + domains provided")
+	dbCommand.Var(&args.Filters.Tags, "tag", "Query for names with the tags (e.g. brute, dns, scrape) separated by commas (can be used multiple times)")
+	dbCommand.Var(&args.Filters.RecordTypes, "type", "Query for names with the DNS record types (a, aaaa, cname, ptr, srv, ns, mx) separated by commas")
+	dbCommand.StringVar(&args.Filters.Until, "until", "", "Query for names discovered before (format: "+timeFormat+")")
+	dbCommand.StringVar(&args.Filepaths.Annotations, "annotations-file", "", "Path to a CSV file providing the annotations of assets")
 	dbCommand.StringVar(&args.Filepaths.ConfigFile, "config", "", "Path to the INI configuration file. Additional details below")
 	dbCommand.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the graph database")
 	dbCommand.StringVar(&args.Filepaths.Domains, "df", "", "Path to a file providing root domain names")
@@ -118,6 +177,34 @@ func runDBCommand(clArgs []string) {
 		return
 	}
 
+	if len(args.Merge) > 0 {
+		if err := mergeDatabases(&args, db); err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args.Annotate != "" || args.Filepaths.Annotations != "" {
+		// Only the annotation flags provided change the annotation
+		set := make(map[string]bool)
+		dbCommand.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+		if err := annotateAssets(&args, set, db); err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args.Delete != "" || args.Retention.KeepLast > 0 || args.Retention.KeepDays > 0 || args.Options.Compact {
+		if err := maintainDatabase(&args, db); err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if args.Options.ListEnumerations {
 		listEnumerations(args.Domains, db)
 		return
@@ -128,6 +215,27 @@ func runDBCommand(clArgs []string) {
 		return
 	}
 
+	if args.Options.Assets {
+		if err := printAssetInventory(&args, db); err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args.Options.Query {
+		filter, err := queryFilter(&args)
+		if err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		if err := queryEnumeration(&args, filter, db); err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	commandUsage(dbUsageMsg, dbCommand, dbBuf)
 }
 
@@ -135,15 +243,29 @@ func openGraphDatabase(dir string, config *core.Config) handlers.DataHandler {
 	var db handlers.DataHandler
 	// Attempt to connect to an Amass graph database
 	/*if args.Options.Neo4j {
-		neo, err := handlers.NewNeo4j(args.URL, args.User, args.Password, nil)
+		neo, err := handlers.NewNeo4j(args.URL, args.User, args.Password, nil, nil)
 		if err != nil {
 			db = neo
 		}
 	} else */
 	if config.GremlinURL != "" {
-		if g := handlers.NewGremlin(config.GremlinURL, config.GremlinUser, config.GremlinPass, nil); g != nil {
+		batch := &handlers.BatchSettings{
+			Size:          config.GremlinBatchSize,
+			FlushInterval: config.GremlinFlushInterval,
+			MaxRetries:    config.GremlinMaxRetries,
+			SpoolFile:     config.GremlinSpoolFile,
+		}
+		if g, err := handlers.NewGremlin(config.GremlinURL, config.GremlinUser, config.GremlinPass, batch, nil); err == nil {
 			db = g
 		}
+	} else if config.SQLDriver != "" {
+		source := config.SQLDataSource
+		if source == "" && config.SQLDriver == handlers.SQLDriverSQLite {
+			source = filepath.Join(core.OutputDirectory(dir), handlers.DefaultSQLiteFile)
+		}
+		if s, err := handlers.NewSQL(config.SQLDriver, source, nil); err == nil {
+			db = s
+		}
 	} else {
 		if d := core.OutputDirectory(dir); d != "" {
 			// Check that the graph database directory exists
@@ -175,6 +297,147 @@ func inputDataOperations(args *dbArgs, db handlers.DataHandler) error {
 	return nil
 }
 
+// Merge other graph databases and data operations files into the database.
+func mergeDatabases(args *dbArgs, db handlers.DataHandler) error {
+	for _, path := range args.Merge {
+		stats, err := mergeDatabase(path, args, db)
+		if stats != nil {
+			printMergeStats(path, stats)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to merge %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func mergeDatabase(path string, args *dbArgs, db handlers.DataHandler) (*handlers.MergeStats, error) {
+	finfo, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !finfo.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		opts, err := handlers.ParseDataOpts(f)
+		if err != nil {
+			return nil, errors.New("Failed to parse the provided data operations")
+		}
+		return handlers.MergeDataOpts(db, opts, args.Options.Incremental)
+	}
+
+	graph, ok := db.(*handlers.Graph)
+	if !ok {
+		return nil, errors.New("Graph database directories can only be merged into the local graph database")
+	}
+	// The bolt file cannot be opened twice by the same process
+	if sameDirectory(path, core.OutputDirectory(args.Filepaths.Directory)) {
+		return nil, errors.New("The graph database cannot be merged into itself")
+	}
+	if finfo, err := os.Stat(filepath.Join(path, "indexes.bolt")); err != nil || finfo.IsDir() {
+		return nil, errors.New("The directory does not contain a graph database")
+	}
+
+	src := handlers.NewGraph(path)
+	if src == nil {
+		return nil, errors.New("Failed to open the graph database")
+	}
+	defer src.Close()
+
+	return graph.Merge(src, args.Options.Incremental)
+}
+
+func sameDirectory(a, b string) bool {
+	ainfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	binfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ainfo, binfo)
+}
+
+func printMergeStats(path string, stats *handlers.MergeStats) {
+	g.Printf("Merged %d enumerations from %s: ", len(stats.Merged), path)
+	g.Printf("%d records added, %d duplicates\n", stats.Added, stats.Duplicates)
+	if len(stats.Skipped) > 0 {
+		y.Printf("Skipped %d enumerations already in the database\n", len(stats.Skipped))
+	}
+	if stats.Annotations > 0 {
+		g.Printf("Merged %d annotations\n", stats.Annotations)
+	}
+
+	for _, c := range stats.Conflicts {
+		r.Printf("Conflict in enumeration %s: %s %s kept '%s', discarded '%s'\n",
+			c.UUID, c.Node, c.Property, c.Existing, c.Incoming)
+	}
+}
+
+// Delete enumerations from the database and release the storage they used.
+func maintainDatabase(args *dbArgs, db handlers.DataHandler) error {
+	if args.Retention.KeepLast < 0 || args.Retention.KeepDays < 0 {
+		return errors.New("The retention settings cannot be negative")
+	}
+
+	if args.Delete != "" {
+		uuid := args.Delete
+		// Check if the enumeration was identified via an index from the listing
+		if idx, err := strconv.Atoi(args.Delete); err == nil {
+			if uuid = enumIndexToID(idx, args.Domains, db); uuid == "" {
+				return fmt.Errorf("No enumeration found at index %d", idx)
+			}
+		} else if !containsString(db.EnumerationList(), uuid) {
+			return fmt.Errorf("No enumeration found with the ID %s", uuid)
+		}
+
+		if err := db.DeleteEnumeration(uuid); err != nil {
+			return fmt.Errorf("Failed to delete the enumeration: %v", err)
+		}
+		g.Printf("Deleted enumeration %s\n", uuid)
+	}
+
+	policy := &handlers.RetentionPolicy{
+		KeepLast: args.Retention.KeepLast,
+		KeepDays: args.Retention.KeepDays,
+	}
+	if policy.Enabled() {
+		deleted, err := handlers.ApplyRetention(db, policy, args.Domains)
+		for _, uuid := range deleted {
+			g.Printf("Deleted enumeration %s\n", uuid)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to apply the retention policy: %v", err)
+		}
+		if len(deleted) == 0 {
+			g.Println("No enumerations were removed by the retention policy")
+		}
+	}
+
+	if args.Options.Compact {
+		if err := db.Compact(); err != nil {
+			return fmt.Errorf("Failed to compact the database: %v", err)
+		}
+		g.Println("The database has been compacted")
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 func listEnumerations(domains []string, db handlers.DataHandler) {
 	enums := enumIDs(domains, db)
 	if len(enums) == 0 {
@@ -189,6 +452,11 @@ func listEnumerations(domains []string, db handlers.DataHandler) {
 			g.Println()
 		}
 		g.Printf("%d) %s -> %s: ", i+1, earliest[i].Format(timeFormat), latest[i].Format(timeFormat))
+		// Identify the results collected by the intel subcommand
+		if t, ok := db.(interface{ EnumerationType(string) string }); ok &&
+			t.EnumerationType(enums[i]) == handlers.EnumTypeIntel {
+			y.Print("[intel] ")
+		}
 		// Print out the scope for this enumeration
 		for x, domain := range db.EnumerationDomains(enums[i]) {
 			if x != 0 {
@@ -202,16 +470,17 @@ func listEnumerations(domains []string, db handlers.DataHandler) {
 
 func showEnumeration(args *dbArgs, db handlers.DataHandler) {
 	var total int
+	annotations := db.Annotations()
 	tags := make(map[string]int)
 	asns := make(map[int]*amass.ASNSummaryData)
-	for _, out := range getEnumOutput(args.Enum, args.Domains, db) {
+	streamEnumOutput(args.Enum, args.Domains, db, func(out *core.Output) bool {
 		if len(args.Domains) > 0 && !domainNameInScope(out.Name, args.Domains) {
-			continue
+			return true
 		}
 
 		out.Addresses = amass.DesiredAddrTypes(out.Addresses, args.Options.IPv4, args.Options.IPv6)
 		if len(out.Addresses) == 0 {
-			continue
+			return true
 		}
 
 		total++
@@ -223,8 +492,10 @@ func showEnumeration(args *dbArgs, db handlers.DataHandler) {
 			ips = " " + ips
 		}
 
-		fmt.Fprintf(color.Output, "%s%s%s\n", blue(source), green(name), yellow(ips))
-	}
+		fmt.Fprintf(color.Output, "%s%s%s%s\n", blue(source), green(name),
+			yellow(ips), blue(annotationTag(annotations, out.Name)))
+		return true
+	})
 	if total == 0 {
 		r.Println("No names were discovered")
 	} else {
@@ -232,52 +503,367 @@ func showEnumeration(args *dbArgs, db handlers.DataHandler) {
 	}
 }
 
-func getEnumOutput(id int, domains []string, db handlers.DataHandler) []*core.Output {
-	var output []*core.Output
+func queryFilter(args *dbArgs) (*handlers.QueryFilter, error) {
+	filter := &handlers.QueryFilter{
+		ASNs:        args.Filters.ASNs,
+		CIDRs:       args.Filters.CIDRs,
+		Tags:        args.Filters.Tags,
+		Sources:     args.Filters.Sources,
+		CNAMETarget: strings.TrimSpace(args.Filters.CNAME),
+	}
+
+	for _, t := range args.Filters.RecordTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+
+		var valid bool
+		for _, rt := range handlers.RecordTypes {
+			if t == rt {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("%s is not a supported DNS record type: %s", t,
+				strings.Join(handlers.RecordTypes, ", "))
+		}
+		filter.RecordTypes = append(filter.RecordTypes, t)
+	}
+
+	var err error
+	if args.Filters.Since != "" {
+		filter.Since, err = time.Parse(timeFormat, args.Filters.Since)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not in the correct format: %s", args.Filters.Since, timeFormat)
+		}
+	}
+	if args.Filters.Until != "" {
+		filter.Until, err = time.Parse(timeFormat, args.Filters.Until)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not in the correct format: %s", args.Filters.Until, timeFormat)
+		}
+	}
+	return filter, nil
+}
+
+func queryEnumeration(args *dbArgs, filter *handlers.QueryFilter, db handlers.DataHandler) error {
+	format := strings.ToLower(args.Format)
+	if format != "text" && format != "json" && format != "csv" {
+		return errors.New("The query output format must be text, json or csv")
+	}
+
+	var enums []string
+	if args.Enum > 0 {
+		enum := enumIndexToID(args.Enum, args.Domains, db)
+		if enum == "" {
+			return errors.New("No enumerations found within the provided scope")
+		}
+		enums = append(enums, enum)
+	} else {
+		// The most recent enumeration provides the findings for names seen multiple times
+		enums, _, _ = orderedEnumsAndDateRanges(enumIDs(args.Domains, db), db)
+	}
+	if len(enums) == 0 {
+		// Handlers unable to perform queries report it, even without enumerations
+		if _, err := db.Query("", filter); err != nil {
+			return err
+		}
+		return errors.New("No enumerations found within the provided scope")
+	}
+
+	var results []*handlers.QueryResult
+	names := utils.NewStringFilter()
+	for _, enum := range enums {
+		found, err := db.Query(enum, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, result := range found {
+			if len(args.Domains) > 0 && !domainNameInScope(result.Name, args.Domains) {
+				continue
+			}
+
+			result.Addresses = amass.DesiredAddrTypes(result.Addresses, args.Options.IPv4, args.Options.IPv6)
+			if (args.Options.IPv4 || args.Options.IPv6) && len(result.Addresses) == 0 {
+				continue
+			}
+			if !names.Duplicate(result.Name) {
+				results = append(results, result)
+			}
+		}
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(color.Output)
+		for _, result := range results {
+			enc.Encode(result)
+		}
+	case "csv":
+		return writeQueryCSV(color.Output, results)
+	default:
+		printQueryResults(args, results, db.Annotations())
+	}
+	return nil
+}
+
+func printQueryResults(args *dbArgs, results []*handlers.QueryResult, annotations map[string]*handlers.Annotation) {
+	if len(results) == 0 {
+		r.Println("No names matched the query")
+		return
+	}
+
+	for _, result := range results {
+		source, name, ips := amass.OutputLineParts(result.Output(), args.Options.Sources,
+			args.Options.IPs || args.Options.IPv4 || args.Options.IPv6, args.Options.DemoMode)
+
+		var target string
+		if n := len(result.CNAMETargets); n > 0 {
+			_, target, _ = amass.OutputLineParts(&core.Output{Name: result.CNAMETargets[n-1]},
+				false, false, args.Options.DemoMode)
+			target = " -> " + target
+		}
+		if ips != "" {
+			ips = " " + ips
+		}
+
+		fmt.Fprintf(color.Output, "%s%s%s %s%s%s\n", blue(source), green(name), blue(target),
+			yellow("["+strings.Join(result.RecordTypes, ",")+"]"), yellow(ips),
+			blue(annotationTag(annotations, result.Name)))
+	}
+	g.Printf("\n%d names matched the query\n", len(results))
+}
 
+func writeQueryCSV(w io.Writer, results []*handlers.QueryResult) error {
+	writer := csv.NewWriter(w)
+
+	writer.Write([]string{"uuid", "timestamp", "name", "domain", "types",
+		"cname_targets", "addresses", "cidrs", "asns", "tag", "source"})
+	for _, result := range results {
+		var addrs, cidrs, asns []string
+		for _, addr := range result.Addresses {
+			addrs = append(addrs, addr.Address.String())
+			if addr.CIDRStr != "" {
+				cidrs = utils.UniqueAppend(cidrs, addr.CIDRStr)
+			}
+			if addr.ASN != 0 {
+				asns = utils.UniqueAppend(asns, strconv.Itoa(addr.ASN))
+			}
+		}
+
+		writer.Write([]string{
+			result.UUID,
+			result.Timestamp.Format(time.RFC3339),
+			result.Name,
+			result.Domain,
+			strings.Join(result.RecordTypes, " "),
+			strings.Join(result.CNAMETargets, " "),
+			strings.Join(addrs, " "),
+			strings.Join(cidrs, " "),
+			strings.Join(asns, " "),
+			result.Tag,
+			result.Source,
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// annotateAssets stores the annotation provided by the flags, or the annotations in the CSV file.
+func annotateAssets(args *dbArgs, set map[string]bool, db handlers.DataHandler) error {
+	var annotations []*handlers.Annotation
+
+	if args.Filepaths.Annotations != "" {
+		f, err := os.Open(args.Filepaths.Annotations)
+		if err != nil {
+			return fmt.Errorf("Failed to open the annotations file: %v", err)
+		}
+		defer f.Close()
+
+		annotations, err = handlers.ParseAnnotations(f)
+		if err != nil {
+			return fmt.Errorf("Failed to parse the annotations file: %v", err)
+		}
+	}
+
+	if args.Annotate != "" {
+		asset := strings.ToLower(strings.TrimSpace(args.Annotate))
+
+		a := &handlers.Annotation{Asset: asset}
+		if cur, found := db.Annotations()[asset]; found {
+			a = cur
+		}
+		if set["owner"] {
+			a.Owner = args.Annotation.Owner
+		}
+		if set["criticality"] {
+			a.Criticality = strings.ToLower(args.Annotation.Criticality)
+		}
+		if set["false-positive"] {
+			a.FalsePositive = args.Annotation.FalsePositive
+		}
+		if set["decommissioned"] {
+			a.Decommissioned = args.Annotation.Decommissioned
+		}
+		if set["notes"] {
+			a.Notes = args.Annotation.Notes
+		}
+		if !handlers.ValidCriticality(a.Criticality) {
+			return fmt.Errorf("The criticality must be one of %s", strings.Join(handlers.Criticalities, ", "))
+		}
+		annotations = append(annotations, a)
+	}
+
+	now := time.Now()
+	for _, a := range annotations {
+		a.Updated = now
+		if err := db.Annotate(a); err != nil {
+			return err
+		}
+	}
+
+	if len(annotations) == 1 {
+		if a := annotations[0]; a.Empty() {
+			g.Printf("Removed the annotation of %s\n", a.Asset)
+		} else {
+			g.Printf("Annotated %s: %s\n", a.Asset, a)
+		}
+		return nil
+	}
+	g.Printf("Stored %d annotations\n", len(annotations))
+	return nil
+}
+
+// annotationTag returns the labels of the annotation for the asset, ready to be appended to a line.
+func annotationTag(annotations map[string]*handlers.Annotation, asset string) string {
+	a, found := annotations[asset]
+	if !found {
+		return ""
+	}
+
+	labels := a.Labels()
+	if len(labels) == 0 {
+		return ""
+	}
+	return " {" + strings.Join(labels, ", ") + "}"
+}
+
+func printAssetInventory(args *dbArgs, db handlers.DataHandler) error {
+	format := strings.ToLower(args.Format)
+	if format != "text" && format != "json" {
+		return errors.New("The asset output format must be text or json")
+	}
+
+	inv, ok := db.(handlers.AssetInventory)
+	if !ok {
+		return fmt.Errorf("%s does not maintain an asset inventory", db)
+	}
+	assets := handlers.FilterAssets(inv.Assets(), args.Domains)
+
+	annotations := db.Annotations()
+	for _, a := range assets {
+		a.Annotation = annotations[a.Asset]
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(color.Output)
+		for _, a := range assets {
+			enc.Encode(a)
+		}
+		return nil
+	}
+
+	if len(assets) == 0 {
+		r.Println("No assets found within the provided scope")
+		return nil
+	}
+
+	var names, addrs, netblocks int
+	for _, a := range assets {
+		switch a.Type {
+		case handlers.AssetTypeName:
+			names++
+		case handlers.AssetTypeAddress:
+			addrs++
+		case handlers.AssetTypeNetblock:
+			netblocks++
+		}
+
+		asset, history := amass.AssetLineParts(a, args.Options.DemoMode)
+		fmt.Fprintf(color.Output, "%s %s %s %s\n", green(asset), blue("["+a.Type+"]"),
+			yellow(a.FirstSeen.Local().Format(timeFormat)+" -> "+a.LastSeen.Local().Format(timeFormat)),
+			blue(fmt.Sprintf("(%d enumerations)", len(a.Enumerations))))
+		if a.Annotation != nil {
+			fmt.Fprintf(color.Output, "\t%s\n", yellow(a.Annotation.String()))
+		}
+
+		for i, addr := range history {
+			h := a.Addresses[i]
+			fmt.Fprintf(color.Output, "\t%s %s\n", green(addr),
+				yellow(h.FirstSeen.Local().Format(timeFormat)+" -> "+h.LastSeen.Local().Format(timeFormat)))
+		}
+	}
+	g.Printf("\n%d names, %d addresses and %d netblocks in the asset inventory\n", names, addrs, netblocks)
+	return nil
+}
+
+// streamEnumOutput calls fn with the output of the enumeration selected by id, or the
+// output of all the enumerations within the domains when id is zero. The names are only
+// provided once, starting with the oldest enumeration.
+func streamEnumOutput(id int, domains []string, db handlers.DataHandler, fn func(*core.Output) bool) {
 	if id > 0 {
 		enum := enumIndexToID(id, domains, db)
 		if enum == "" {
 			r.Fprintln(color.Error, "No enumerations found within the provided scope")
-			return output
+			return
 		}
-		return getUniqueDBOutput(enum, domains, db)
+		streamUniqueDBOutput(enum, domains, db, fn)
+		return
 	}
 
 	enums := enumIDs(domains, db)
 	if len(enums) == 0 {
-		return output
+		return
 	}
 
 	enums, _, _ = orderedEnumsAndDateRanges(enums, db)
 	if len(enums) == 0 {
-		return output
+		return
 	}
 
+	cancelled := false
 	filter := utils.NewStringFilter()
-	for i := len(enums) - 1; i >= 0; i-- {
-		for _, out := range db.GetOutput(enums[i], true) {
-			if !filter.Duplicate(out.Name) {
-				output = append(output, out)
+	for i := len(enums) - 1; i >= 0 && !cancelled; i-- {
+		err := db.StreamOutput(enums[i], true, func(out *core.Output) bool {
+			if !filter.Duplicate(out.Name) && !fn(out) {
+				cancelled = true
 			}
+			return !cancelled
+		})
+		if err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			return
 		}
 	}
-	return output
 }
 
-func getUniqueDBOutput(id string, domains []string, db handlers.DataHandler) []*core.Output {
-	var output []*core.Output
+// streamUniqueDBOutput calls fn with each name in the enumeration that is within the domains.
+func streamUniqueDBOutput(id string, domains []string, db handlers.DataHandler, fn func(*core.Output) bool) {
 	filter := utils.NewStringFilter()
 
-	for _, out := range db.GetOutput(id, true) {
+	err := db.StreamOutput(id, true, func(out *core.Output) bool {
 		if len(domains) > 0 && !domainNameInScope(out.Name, domains) {
-			continue
+			return true
 		}
-		if !filter.Duplicate(out.Name) {
-			output = append(output, out)
+		if filter.Duplicate(out.Name) {
+			return true
 		}
+		return fn(out)
+	})
+	if err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
 	}
-	return output
 }
 
 func enumIndexToID(e int, domains []string, db handlers.DataHandler) string {
@@ -25,6 +25,7 @@ import (
 	"github.com/root-secure/Amass/amass/core"
 	"github.com/root-secure/Amass/amass/utils"
 	"github.com/fatih/color"
+	"github.com/google/uuid"
 	homedir "github.com/mitchellh/go-homedir"
 )
 
@@ -43,6 +44,7 @@ type enumArgs struct {
 	AltWordList     []string
 	BruteWordList   []string
 	Blacklist       utils.ParseStrings
+	DKIMSelectors   []string
 	Domains         utils.ParseStrings
 	Excluded        utils.ParseStrings
 	Included        utils.ParseStrings
@@ -51,10 +53,14 @@ type enumArgs struct {
 	Names           []string
 	Ports           utils.ParseInts
 	Resolvers       utils.ParseStrings
+	Shard           string
+	UUID            string
 	Options         struct {
 		Active       bool
 		BruteForcing bool
 		DemoMode     bool
+		EmailPosture bool
+		HTTPProbe    bool
 		IPs          bool
 		IPv4         bool
 		IPv6         bool
@@ -62,7 +68,9 @@ type enumArgs struct {
 		NoAlts       bool
 		NoRecursive  bool
 		Passive      bool
+		RDAP         bool
 		Sources      bool
+		Takeovers    bool
 		Unresolved   bool
 	}
 	Filepaths struct {
@@ -73,6 +81,7 @@ type enumArgs struct {
 		ConfigFile    string
 		DataOpts      string
 		Directory     string
+		DKIMSelectors string
 		Domains       string
 		ExcludedSrcs  string
 		IncludedSrcs  string
@@ -80,6 +89,7 @@ type enumArgs struct {
 		LogFile       string
 		Names         string
 		Resolvers     string
+		TakeoverSigs  string
 		TermOut       string
 	}
 }
@@ -96,12 +106,16 @@ func defineEnumArgumentFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.IntVar(&args.MinForRecursive, "min-for-recursive", 0, "Number of subdomain discoveries before recursive brute forcing")
 	enumFlags.Var(&args.Ports, "p", "Ports separated by commas (default: 443)")
 	enumFlags.Var(&args.Resolvers, "r", "IP addresses of preferred DNS resolvers (can be used multiple times)")
+	enumFlags.StringVar(&args.Shard, "shard", "", "Only generate the slice i of n of the brute forced and altered names (format: i/n)")
+	enumFlags.StringVar(&args.UUID, "uuid", "", "Enumeration UUID to use, so the data operations of shards can be merged")
 }
 
 func defineEnumOptionFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.BoolVar(&args.Options.Active, "active", false, "Attempt zone transfers and certificate name grabs")
 	enumFlags.BoolVar(&args.Options.BruteForcing, "brute", false, "Execute brute forcing after searches")
 	enumFlags.BoolVar(&args.Options.DemoMode, "demo", false, "Censor output to make it suitable for demonstrations")
+	enumFlags.BoolVar(&args.Options.EmailPosture, "email-posture", false, "Analyze the SPF, DMARC and DKIM records of the root domains")
+	enumFlags.BoolVar(&args.Options.HTTPProbe, "http-probe", false, "Probe discovered names over HTTP/HTTPS and extract names from the responses")
 	enumFlags.BoolVar(&args.Options.IPs, "ip", false, "Show the IP addresses for discovered names")
 	enumFlags.BoolVar(&args.Options.IPv4, "ipv4", false, "Show the IPv4 addresses for discovered names")
 	enumFlags.BoolVar(&args.Options.IPv6, "ipv6", false, "Show the IPv6 addresses for discovered names")
@@ -109,7 +123,9 @@ func defineEnumOptionFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.BoolVar(&args.Options.NoAlts, "noalts", false, "Disable generation of altered names")
 	enumFlags.BoolVar(&args.Options.NoRecursive, "norecursive", false, "Turn off recursive brute forcing")
 	enumFlags.BoolVar(&args.Options.Passive, "passive", false, "Disable DNS resolution of names and dependent features")
+	enumFlags.BoolVar(&args.Options.RDAP, "rdap", false, "Store RDAP registration data for the root domains, ASNs and netblocks")
 	enumFlags.BoolVar(&args.Options.Sources, "src", false, "Print data sources for the discovered names")
+	enumFlags.BoolVar(&args.Options.Takeovers, "takeover", false, "Check CNAME records of discovered names for subdomain takeovers")
 	enumFlags.BoolVar(&args.Options.Unresolved, "include-unresolvable", false, "Output DNS names that did not resolve")
 }
 
@@ -121,6 +137,7 @@ func defineEnumFilepathFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.StringVar(&args.Filepaths.ConfigFile, "config", "", "Path to the INI configuration file. Additional details below")
 	enumFlags.StringVar(&args.Filepaths.DataOpts, "do", "", "Path to data operations JSON output file")
 	enumFlags.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the output files")
+	enumFlags.StringVar(&args.Filepaths.DKIMSelectors, "dkim-selectors", "", "Path to a file providing DKIM selectors for the email posture analysis")
 	enumFlags.StringVar(&args.Filepaths.Domains, "df", "", "Path to a file providing root domain names")
 	enumFlags.StringVar(&args.Filepaths.ExcludedSrcs, "ef", "", "Path to a file providing data sources to exclude")
 	enumFlags.StringVar(&args.Filepaths.IncludedSrcs, "if", "", "Path to a file providing data sources to include")
@@ -128,6 +145,7 @@ func defineEnumFilepathFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.StringVar(&args.Filepaths.LogFile, "log", "", "Path to the log file where errors will be written")
 	enumFlags.StringVar(&args.Filepaths.Names, "nf", "", "Path to a file providing already known subdomain names (from other tools/sources)")
 	enumFlags.StringVar(&args.Filepaths.Resolvers, "rf", "", "Path to a file providing preferred DNS resolvers")
+	enumFlags.StringVar(&args.Filepaths.TakeoverSigs, "takeover-sigs", "", "Path to a JSON file providing subdomain takeover signatures")
 	enumFlags.StringVar(&args.Filepaths.TermOut, "o", "", "Path to the text file containing terminal stdout/stderr")
 }
 
@@ -334,11 +352,36 @@ func processEnumOutput(enum *amass.Enumeration, args *enumArgs, pipe *io.PipeRea
 				enc.Encode(out)
 			}
 		}
+		// Add the web server responses to the JSON output
+		if jsonptr != nil {
+			for _, probe := range enum.HTTPProbes() {
+				enc.Encode(struct {
+					HTTPProbe *core.HTTPProbe `json:"http_probe"`
+				}{probe})
+			}
+		}
+		takeovers := enum.Takeovers()
+		postures := enum.EmailPostures()
+		// Add the subdomain takeover findings and email posture reports to the JSON output
+		if jsonptr != nil {
+			for _, finding := range takeovers {
+				enc.Encode(struct {
+					Takeover *core.TakeoverFinding `json:"takeover"`
+				}{finding})
+			}
+			for _, posture := range postures {
+				enc.Encode(struct {
+					EmailPosture *core.EmailPosture `json:"email_posture"`
+				}{posture})
+			}
+		}
 		if total == 0 {
 			r.Println("No names were discovered")
 		} else {
 			amass.PrintEnumerationSummary(total, tags, asns, args.Options.DemoMode)
 		}
+		amass.PrintTakeoverFindings(takeovers, args.Options.DemoMode)
+		amass.PrintEmailPostures(postures, args.Options.DemoMode)
 		close(finished)
 	}()
 	// Start the enumeration process
@@ -348,6 +391,8 @@ func processEnumOutput(enum *amass.Enumeration, args *enumArgs, pipe *io.PipeRea
 		os.Exit(1)
 	}
 	<-finished
+	amass.PrintFilterStats(enum.FilterStats())
+	amass.PrintInsertStats(enum.InsertStats())
 }
 
 // If the user interrupts the program, print the summary information
@@ -447,6 +492,13 @@ func processEnumInputFiles(args *enumArgs) error {
 		}
 		args.Included = utils.UniqueAppend(args.Included, list...)
 	}
+	if args.Filepaths.DKIMSelectors != "" {
+		list, err := core.GetListFromFile(args.Filepaths.DKIMSelectors)
+		if err != nil {
+			return fmt.Errorf("Failed to parse the DKIM selectors file: %v", err)
+		}
+		args.DKIMSelectors = list
+	}
 	if args.Filepaths.Names != "" {
 		list, err := core.GetListFromFile(args.Filepaths.Names)
 		if err != nil {
@@ -497,6 +549,9 @@ func updateEnumConfiguration(enum *amass.Enumeration, args *enumArgs) error {
 	if len(args.AltWordList) > 0 {
 		enum.Config.AltWordlist = args.AltWordList
 	}
+	if len(args.DKIMSelectors) > 0 {
+		enum.Config.DKIMSelectors = args.DKIMSelectors
+	}
 	if len(args.Names) > 0 {
 		enum.ProvidedNames = args.Names
 	}
@@ -512,12 +567,42 @@ func updateEnumConfiguration(enum *amass.Enumeration, args *enumArgs) error {
 	if args.MinForRecursive > 0 {
 		enum.Config.MinForRecursive = args.MinForRecursive
 	}
+	if args.Shard != "" {
+		idx, count, err := core.ParseShard(args.Shard)
+		if err != nil {
+			return err
+		}
+		enum.Config.ShardIndex = idx
+		enum.Config.ShardCount = count
+	}
+	if args.UUID != "" {
+		id, err := uuid.Parse(args.UUID)
+		if err != nil {
+			return fmt.Errorf("%s is not a valid enumeration UUID", args.UUID)
+		}
+		enum.Config.UUID = id
+	}
 	if args.Options.Active {
 		enum.Config.Active = true
 	}
 	if args.Options.Unresolved {
 		enum.Config.IncludeUnresolvable = true
 	}
+	if args.Options.HTTPProbe {
+		enum.Config.HTTPProbe = true
+	}
+	if args.Options.Takeovers {
+		enum.Config.Takeovers = true
+	}
+	if args.Filepaths.TakeoverSigs != "" {
+		enum.Config.TakeoverSignatures = args.Filepaths.TakeoverSigs
+	}
+	if args.Options.EmailPosture {
+		enum.Config.EmailPosture = true
+	}
+	if args.Options.RDAP {
+		enum.Config.RDAP = true
+	}
 	if args.Options.Passive {
 		enum.Config.Passive = true
 	}
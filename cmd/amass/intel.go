@@ -6,9 +6,11 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"os"
@@ -19,6 +21,7 @@ import (
 
 	"github.com/root-secure/Amass/amass"
 	"github.com/root-secure/Amass/amass/core"
+	"github.com/root-secure/Amass/amass/handlers"
 	"github.com/root-secure/Amass/amass/utils"
 	"github.com/fatih/color"
 	homedir "github.com/mitchellh/go-homedir"
@@ -46,15 +49,18 @@ type intelArgs struct {
 		IPv4         bool
 		IPv6         bool
 		ListSources  bool
+		Pivot        bool
 		ReverseWhois bool
 		Sources      bool
 	}
 	Filepaths struct {
 		ConfigFile   string
+		DataOpts     string
 		Directory    string
 		Domains      string
 		ExcludedSrcs string
 		IncludedSrcs string
+		JSONOutput   string
 		LogFile      string
 		Resolvers    string
 		TermOut      string
@@ -65,7 +71,7 @@ func defineIntelArgumentFlags(intelFlags *flag.FlagSet, args *intelArgs) {
 	intelFlags.Var(&args.Addresses, "addr", "IPs and ranges (192.168.1.1-254) separated by commas")
 	intelFlags.Var(&args.ASNs, "asn", "ASNs separated by commas (can be used multiple times)")
 	intelFlags.Var(&args.CIDRs, "cidr", "CIDRs separated by commas (can be used multiple times)")
-	intelFlags.StringVar(&args.OrganizationName, "org", "", "Search string provided against AS description information")
+	intelFlags.StringVar(&args.OrganizationName, "org", "", "Search string provided against AS description information and certificate subject organizations")
 	intelFlags.Var(&args.Domains, "d", "Domain names separated by commas (can be used multiple times)")
 	intelFlags.Var(&args.Excluded, "exclude", "Data source names separated by commas to be excluded")
 	intelFlags.Var(&args.Included, "include", "Data source names separated by commas to be included")
@@ -81,6 +87,7 @@ func defineIntelOptionFlags(intelFlags *flag.FlagSet, args *intelArgs) {
 	intelFlags.BoolVar(&args.Options.IPv4, "ipv4", false, "Show the IPv4 addresses for discovered names")
 	intelFlags.BoolVar(&args.Options.IPv6, "ipv6", false, "Show the IPv6 addresses for discovered names")
 	intelFlags.BoolVar(&args.Options.ListSources, "list", false, "Print the names of all available data sources")
+	intelFlags.BoolVar(&args.Options.Pivot, "pivot", false, "Find root domains sharing nameservers, mail servers and SOA contacts")
 	intelFlags.BoolVar(&args.Options.ReverseWhois, "whois", false, "All discovered domains are run through reverse whois")
 	intelFlags.BoolVar(&args.Options.Sources, "src", false, "Print data sources for the discovered names")
 }
@@ -89,8 +96,10 @@ func defineIntelFilepathFlags(intelFlags *flag.FlagSet, args *intelArgs) {
 	intelFlags.StringVar(&args.Filepaths.ConfigFile, "config", "", "Path to the INI configuration file. Additional details below")
 	intelFlags.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the output files")
 	intelFlags.StringVar(&args.Filepaths.Domains, "df", "", "Path to a file providing root domain names")
+	intelFlags.StringVar(&args.Filepaths.DataOpts, "do", "", "Path to data operations JSON output file")
 	intelFlags.StringVar(&args.Filepaths.ExcludedSrcs, "ef", "", "Path to a file providing data sources to exclude")
 	intelFlags.StringVar(&args.Filepaths.IncludedSrcs, "if", "", "Path to a file providing data sources to include")
+	intelFlags.StringVar(&args.Filepaths.JSONOutput, "json", "", "Path to the JSON output file")
 	intelFlags.StringVar(&args.Filepaths.LogFile, "log", "", "Path to the log file where errors will be written")
 	intelFlags.StringVar(&args.Filepaths.Resolvers, "rf", "", "Path to a file providing preferred DNS resolvers")
 	intelFlags.StringVar(&args.Filepaths.TermOut, "o", "", "Path to the text file containing terminal stdout/stderr")
@@ -133,7 +142,7 @@ func runIntelCommand(clArgs []string) {
 	}
 
 	// Some input validation
-	if !args.Options.ReverseWhois && args.OrganizationName == "" &&
+	if !args.Options.ReverseWhois && !args.Options.Pivot && args.OrganizationName == "" &&
 		len(args.Addresses) == 0 && len(args.CIDRs) == 0 && len(args.ASNs) == 0 {
 		commandUsage(intelUsageMsg, intelCommand, intelBuf)
 		os.Exit(1)
@@ -143,14 +152,43 @@ func runIntelCommand(clArgs []string) {
 	rand.Seed(time.Now().UTC().UnixNano())
 
 	if args.OrganizationName != "" {
+		config := &core.Config{Log: log.New(ioutil.Discard, "", 0)}
+		core.AcquireConfig(args.Filepaths.Directory, args.Filepaths.ConfigFile, config)
+		if args.Filepaths.Directory != "" {
+			config.Dir = args.Filepaths.Directory
+		}
+		// Search the offline ASN database before the online sources
+		amass.LoadOfflineASNDatabase(config)
+
 		records, err := amass.LookupASNsByName(args.OrganizationName)
 		if err == nil {
+			regs := amass.LookupASNRegistrations(config, records)
+
 			for _, a := range records {
-				fmt.Printf("%d, %s\n", a.ASN, a.Description)
+				line := fmt.Sprintf("%d, %s", a.ASN, a.Description)
+				// Include the registrant and abuse contact from RDAP when available
+				if reg, found := regs[a.ASN]; found {
+					line += fmt.Sprintf(", %s, %s", reg.Registrant, reg.AbuseEmail)
+				}
+				fmt.Println(line)
 			}
 		} else {
 			fmt.Printf("%v\n", err)
 		}
+
+		// Root domains in certificates issued to the organization are related
+		rLog, wLog := io.Pipe()
+		intel := amass.NewIntelCollection()
+		config.UUID = intel.Config.UUID
+		config.Log = log.New(wLog, "", log.Lmicroseconds)
+		intel.Config = config
+		if db := openGraphDatabase(config.Dir, config); db != nil {
+			intel.Graph = db
+			defer db.Close()
+		}
+
+		go intel.OrganizationCertificates(args.OrganizationName)
+		processIntelOutput(intel, &args, rLog)
 		return
 	}
 
@@ -194,12 +232,34 @@ func runIntelCommand(clArgs []string) {
 		args.Options.IPv4 = false
 		args.Options.IPv6 = false
 		go intel.ReverseWhois()
+	} else if args.Options.Pivot {
+		if len(intel.Config.Domains()) == 0 {
+			r.Fprintln(color.Error, "No root domain names were provided")
+			os.Exit(1)
+		}
+
+		args.Options.IPs = false
+		args.Options.IPv4 = false
+		args.Options.IPv6 = false
+		// Names in the graph sharing the infrastructure are included when available
+		if db := openGraphDatabase(intel.Config.Dir, intel.Config); db != nil {
+			intel.Graph = db
+			defer db.Close()
+		}
+		go intel.InfrastructurePivot()
 	} else {
 		go intel.HostedDomains()
 	}
 
 	go intelSignalHandler(intel)
 	processIntelOutput(intel, &args, rLog)
+
+	if args.Options.ReverseWhois {
+		amass.PrintRDAPRecords(intel.Registrations(), args.Options.DemoMode)
+	}
+	if args.Options.Pivot {
+		amass.PrintPivotCandidates(intel.Pivots(), args.Options.DemoMode)
+	}
 }
 
 func processIntelOutput(intel *amass.IntelCollection, args *intelArgs, pipe *io.PipeReader) {
@@ -231,6 +291,39 @@ func processIntelOutput(intel *amass.IntelCollection, args *intelArgs, pipe *io.
 
 	go writeLogsAndMessages(pipe, logfile)
 
+	// The data operations allow the results to be imported into the graph database
+	var dataHandler handlers.DataHandler
+	if args.Filepaths.DataOpts != "" {
+		dataptr, err := os.OpenFile(args.Filepaths.DataOpts, os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			r.Fprintf(color.Error, "Failed to open the data operations output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			dataptr.Sync()
+			dataptr.Close()
+		}()
+		dataptr.Truncate(0)
+		dataptr.Seek(0, 0)
+		dataHandler = handlers.NewDataOptsHandler(dataptr)
+	}
+
+	var enc *json.Encoder
+	if args.Filepaths.JSONOutput != "" {
+		jsonptr, err := os.OpenFile(args.Filepaths.JSONOutput, os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			r.Fprintf(color.Error, "Failed to open the JSON output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			jsonptr.Sync()
+			jsonptr.Close()
+		}()
+		jsonptr.Truncate(0)
+		jsonptr.Seek(0, 0)
+		enc = json.NewEncoder(jsonptr)
+	}
+
 	var outptr *os.File
 	if txtfile != "" {
 		outptr, err = os.OpenFile(txtfile, os.O_WRONLY|os.O_CREATE, 0644)
@@ -260,7 +353,27 @@ func processIntelOutput(intel *amass.IntelCollection, args *intelArgs, pipe *io.
 		if outptr != nil {
 			fmt.Fprintf(outptr, "%s%s%s\n", source, name, ips)
 		}
+		// Handle encoding the result as JSON, including the method and evidence
+		if enc != nil {
+			enc.Encode(out)
+		}
+		if dataHandler != nil {
+			if err := amass.InsertIntelOutput(dataHandler, intel.Config.UUID.String(), out); err != nil {
+				intel.Config.Log.Printf("Failed to write the data operations: %v", err)
+			}
+		}
+	}
+
+	evidence := intel.CertEvidence()
+	// Add the certificate evidence for the related root domains to the JSON output
+	if enc != nil {
+		for _, ev := range evidence {
+			enc.Encode(struct {
+				CertEvidence *core.CertEvidence `json:"cert_evidence"`
+			}{ev})
+		}
 	}
+	amass.PrintCertEvidence(evidence, args.Options.DemoMode)
 }
 
 // If the user interrupts the program, print the summary information
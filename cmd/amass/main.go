@@ -60,7 +60,8 @@ func main() {
 		g.Fprintf(color.Error, "\t%-11s - Perform enumerations and network mapping\n", "amass enum")
 		g.Fprintf(color.Error, "\t%-11s - Visualize enumeration results\n", "amass viz")
 		g.Fprintf(color.Error, "\t%-11s - Track differences between enumerations\n", "amass track")
-		g.Fprintf(color.Error, "\t%-11s - Manipulate the Amass graph database\n\n", "amass db")
+		g.Fprintf(color.Error, "\t%-11s - Manipulate the Amass graph database\n", "amass db")
+		g.Fprintf(color.Error, "\t%-11s - Import and query the offline ASN database\n\n", "amass asn")
 
 		g.Fprintf(color.Error, "The user guide can be found here: \n%s\n\n", userGuideURL)
 		g.Fprintf(color.Error, "An example configuration file can be found here: \n%s\n\n", exampleConfigFileURL)
@@ -86,6 +87,8 @@ func main() {
 	}
 
 	switch os.Args[1] {
+	case "asn":
+		runASNCommand(os.Args[2:])
 	case "db":
 		runDBCommand(os.Args[2:])
 	case "enum":
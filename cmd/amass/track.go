@@ -5,12 +5,16 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"math/rand"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/root-secure/Amass/amass"
 	"github.com/root-secure/Amass/amass/core"
 	"github.com/root-secure/Amass/amass/handlers"
 	"github.com/root-secure/Amass/amass/utils"
@@ -24,15 +28,19 @@ const (
 
 type trackArgs struct {
 	Domains utils.ParseStrings
+	Format  string
+	From    string
+	To      string
 	Last    int
 	Since   string
 	Options struct {
 		History bool
 	}
 	Filepaths struct {
-		ConfigFile string
-		Directory  string
-		Domains    string
+		ConfigFile   string
+		Directory    string
+		Domains      string
+		TakeoverSigs string
 	}
 }
 
@@ -47,12 +55,16 @@ func runTrackCommand(clArgs []string) {
 	trackCommand.BoolVar(&help1, "h", false, "Show the program usage message")
 	trackCommand.BoolVar(&help2, "help", false, "Show the program usage message")
 	trackCommand.Var(&args.Domains, "d", "Domain names separated by commas (can be used multiple times)")
+	trackCommand.StringVar(&args.Format, "format", "text", "Output format: text, json or ndjson")
+	trackCommand.StringVar(&args.From, "from", "", "The earlier enumeration to compare, selected by UUID, list index or date")
+	trackCommand.StringVar(&args.To, "to", "", "The later enumeration to compare, selected by UUID, list index or date")
 	trackCommand.IntVar(&args.Last, "last", 0, "The number of recent enumerations to include in the tracking")
 	trackCommand.StringVar(&args.Since, "since", "", "Exclude all enumerations before (format: "+timeFormat+")")
 	trackCommand.BoolVar(&args.Options.History, "history", false, "Show the difference between all enumeration pairs")
 	trackCommand.StringVar(&args.Filepaths.ConfigFile, "config", "", "Path to the INI configuration file. Additional details below")
 	trackCommand.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the graph database")
 	trackCommand.StringVar(&args.Filepaths.Domains, "df", "", "Path to a file providing root domain names")
+	trackCommand.StringVar(&args.Filepaths.TakeoverSigs, "takeover-sigs", "", "Path to a JSON file providing the signatures that identify CNAME providers")
 
 	if len(clArgs) < 1 {
 		commandUsage(trackUsageMsg, trackCommand, trackBuf)
@@ -69,10 +81,23 @@ func runTrackCommand(clArgs []string) {
 	}
 
 	// Some input validation
+	args.Format = strings.ToLower(args.Format)
+	if args.Format != "text" && args.Format != "json" && args.Format != "ndjson" {
+		r.Fprintln(color.Error, "The output format must be text, json or ndjson")
+		os.Exit(1)
+	}
 	if args.Since != "" && args.Last != 0 {
 		r.Fprintln(color.Error, "The since flag cannot be used with the last or all flags")
 		os.Exit(1)
 	}
+	if (args.From == "") != (args.To == "") {
+		r.Fprintln(color.Error, "The from and to flags must be used together")
+		os.Exit(1)
+	}
+	if args.From != "" && (args.Since != "" || args.Last != 0 || args.Options.History) {
+		r.Fprintln(color.Error, "The from and to flags cannot be used with the since, last or history flags")
+		os.Exit(1)
+	}
 	if args.Last > 0 && args.Last < 2 {
 		r.Fprintln(color.Error, "Tracking requires more than one enumeration")
 		os.Exit(1)
@@ -112,6 +137,19 @@ func runTrackCommand(clArgs []string) {
 			args.Domains = utils.UniqueAppend(args.Domains, config.Domains()...)
 		}
 	}
+	if args.Filepaths.TakeoverSigs != "" {
+		config.TakeoverSignatures = args.Filepaths.TakeoverSigs
+	}
+
+	// The signatures file adds to the built-in signatures identifying the CNAME providers
+	var sigs []*amass.TakeoverSignature
+	if config.TakeoverSignatures != "" {
+		sigs, err = amass.GetTakeoverSignatures(config.TakeoverSignatures)
+		if err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	// Connect with the graph database containing the enumeration data
 	db := openGraphDatabase(args.Filepaths.Directory, config)
@@ -136,6 +174,30 @@ func runTrackCommand(clArgs []string) {
 
 	var end int
 	enums, earliest, latest := orderedEnumsAndDateRanges(enums, db)
+	// Compare the two selected enumerations, regardless of the enumerations between them
+	if args.From != "" {
+		from, err := selectEnumeration(args.From, enums, earliest)
+		if err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		to, err := selectEnumeration(args.To, enums, earliest)
+		if err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		if from == to {
+			r.Fprintln(color.Error, "The from and to flags selected the same enumeration")
+			os.Exit(1)
+		}
+
+		out := newTrackOutput(args.Format, args.Domains, enums, latest, db)
+		comparisonOutput(args.Domains, enums, earliest, latest, to, from, sigs, db, out)
+		out.close()
+		notifyTrackReport(out.report, config)
+		return
+	}
+
 	// Filter out enumerations that begin before the start date/time
 	if args.Since != "" {
 		for i := len(enums) - 1; i >= 0; i-- {
@@ -156,150 +218,381 @@ func runTrackCommand(clArgs []string) {
 	earliest = earliest[:end]
 	latest = latest[:end]
 
+	out := newTrackOutput(args.Format, args.Domains, enums, latest, db)
 	if args.Options.History {
-		completeHistoryOutput(args.Domains, enums, earliest, latest, db)
+		completeHistoryOutput(args.Domains, enums, earliest, latest, sigs, db, out)
+	} else {
+		cumulativeOutput(args.Domains, enums, earliest, latest, sigs, db, out)
+	}
+	out.close()
+	notifyTrackReport(out.report, config)
+}
+
+// notifyTrackReport delivers the report to the configured notifiers when changes were found.
+func notifyTrackReport(report *core.TrackReport, config *core.Config) {
+	if len(report.Events) == 0 {
 		return
 	}
-	cumulativeOutput(args.Domains, enums, earliest, latest, db)
+	for _, n := range amass.NewNotifiers(config) {
+		if err := n.Notify(report); err != nil {
+			r.Fprintf(color.Error, "Failed to notify %s: %v\n", n, err)
+		}
+	}
 }
 
-func cumulativeOutput(domains []string, enums []string, ea, la []time.Time, db handlers.DataHandler) {
-	idx := len(enums) - 1
-	filter := utils.NewStringFilter()
+// selectEnumeration returns the index of the enumeration identified by the UUID, the index
+// shown by the db subcommand list, or a date. A date selects the most recent enumeration
+// that started before the end of the date. The enums are ordered from the most recent.
+func selectEnumeration(sel string, enums []string, earliest []time.Time) (int, error) {
+	sel = strings.TrimSpace(sel)
 
-	var cum []*core.Output
-	for i := idx - 1; i >= 0; i-- {
-		for _, out := range getUniqueDBOutput(enums[i], domains, db) {
-			if domainNameInScope(out.Name, domains) && !filter.Duplicate(out.Name) {
-				cum = append(cum, out)
-			}
+	for i, enum := range enums {
+		if strings.EqualFold(enum, sel) {
+			return i, nil
 		}
 	}
 
+	if idx, err := strconv.Atoi(sel); err == nil {
+		if idx < 1 || idx > len(enums) {
+			return 0, fmt.Errorf("Enumeration %d is not available", idx)
+		}
+		return idx - 1, nil
+	}
+
+	var end time.Time
+	if t, err := time.Parse("2006-01-02", sel); err == nil {
+		end = t.AddDate(0, 0, 1)
+	} else if t, err := time.Parse(timeFormat, sel); err == nil {
+		end = t.Add(time.Second)
+	} else if t, err := time.Parse(time.RFC3339, sel); err == nil {
+		end = t.Add(time.Second)
+	} else {
+		return 0, fmt.Errorf("%s is not an enumeration UUID, index or date (format: 2006-01-02 or %s)", sel, timeFormat)
+	}
+
+	for i := range enums {
+		if earliest[i].Before(end) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("No enumerations started before %s", sel)
+}
+
+// trackOutput writes the changes in the requested format as they are discovered,
+// and keeps them for the notifiers.
+type trackOutput struct {
+	format      string
+	enc         *json.Encoder
+	times       map[string]time.Time
+	annotations map[string]*handlers.Annotation
+	movements   map[string]int
+	report      *core.TrackReport
+}
+
+func newTrackOutput(format string, domains, enums []string, la []time.Time, db handlers.DataHandler) *trackOutput {
+	t := &trackOutput{
+		format:      format,
+		enc:         json.NewEncoder(color.Output),
+		times:       make(map[string]time.Time),
+		annotations: db.Annotations(),
+		movements:   make(map[string]int),
+		report: &core.TrackReport{
+			Timestamp: time.Now(),
+			Domains:   domains,
+			Events:    []*core.TrackEvent{},
+		},
+	}
+
+	for i, enum := range enums {
+		t.times[enum] = la[i]
+	}
+	return t
+}
+
+// header prints the date ranges of the enumerations compared, when the output is text.
+func (t *trackOutput) header(ea1, la1, ea2, la2 time.Time) {
+	if t.format != "text" {
+		return
+	}
+
 	blueLine()
 	fmt.Fprintf(color.Output, "%s\t%s%s%s\n%s\t%s%s%s\n", blue("Between"),
-		yellow(ea[0].Format(timeFormat)), blue(" -> "), yellow(la[0].Format(timeFormat)),
-		blue("and"), yellow(ea[idx].Format(timeFormat)), blue(" -> "), yellow(la[idx].Format(timeFormat)))
+		yellow(ea1.Format(timeFormat)), blue(" -> "), yellow(la1.Format(timeFormat)),
+		blue("and"), yellow(ea2.Format(timeFormat)), blue(" -> "), yellow(la2.Format(timeFormat)))
 	blueLine()
+}
 
-	var updates bool
-	out := getUniqueDBOutput(enums[idx], domains, db)
-	for _, d := range diffEnumOutput(cum, out) {
-		updates = true
-		fmt.Fprintln(color.Output, d)
-	}
-	if !updates {
+// noChanges reports that the enumerations compared did not differ, when the output is text.
+func (t *trackOutput) noChanges() {
+	if t.format == "text" {
 		g.Println("No differences discovered")
 	}
 }
 
-func completeHistoryOutput(domains []string, enums []string, ea, la []time.Time, db handlers.DataHandler) {
-	var prev string
+func (t *trackOutput) add(e *core.TrackEvent) {
+	e.Timestamp = t.times[e.Enum]
+	e.PreviousTimestamp = t.times[e.PreviousEnum]
+	if a, found := t.annotations[e.Name]; found {
+		e.Annotation = a.String()
+	}
+	for _, c := range e.Categories {
+		t.movements[c]++
+	}
+	t.report.Events = append(t.report.Events, e)
+
+	switch t.format {
+	case "ndjson":
+		t.enc.Encode(e)
+	case "text":
+		t.printEvent(e)
+	}
+}
 
-	for i, enum := range enums {
-		if prev == "" {
-			prev = enum
-			continue
-		}
-		if i != 1 {
-			fmt.Println()
+func (t *trackOutput) printEvent(e *core.TrackEvent) {
+	tag := annotationTag(t.annotations, e.Name)
+
+	switch e.Type {
+	case core.TrackAdded:
+		fmt.Fprintf(color.Output, "%s%s %s%s\n", blue("Found: "), green(e.Name),
+			yellow(strings.Join(e.Addresses, ",")), blue(tag))
+	case core.TrackRemoved:
+		fmt.Fprintf(color.Output, "%s%s %s%s\n", blue("Removed: "), green(e.Name),
+			yellow(strings.Join(e.PreviousAddresses, ",")), blue(tag))
+	case core.TrackChanged:
+		var categories string
+		if len(e.Categories) > 0 {
+			categories = " [" + strings.Join(amass.MovementLabels(e), ", ") + "]"
 		}
 
-		blueLine()
-		fmt.Fprintf(color.Output, "%s\t%s%s%s\n%s\t%s%s%s\n", blue("Between"),
-			yellow(ea[i-1].Format(timeFormat)), blue(" -> "), yellow(la[i-1].Format(timeFormat)),
-			blue("and"), yellow(ea[i].Format(timeFormat)), blue(" -> "), yellow(la[i].Format(timeFormat)))
-		blueLine()
-
-		var updates bool
-		out1 := getUniqueDBOutput(prev, domains, db)
-		out2 := getUniqueDBOutput(enum, domains, db)
-		for _, d := range diffEnumOutput(out1, out2) {
-			updates = true
-			fmt.Fprintln(color.Output, d)
+		fmt.Fprintf(color.Output, "%s%s%s%s\n\t%s\t%s\n\t%s\t%s\n", blue("Moved: "), green(e.Name),
+			r.Sprint(categories), blue(tag), blue(" from "), yellow(strings.Join(e.PreviousAddresses, ",")),
+			blue(" to "), yellow(strings.Join(e.Addresses, ",")))
+		if len(e.Providers) > 0 || len(e.PreviousProviders) > 0 {
+			fmt.Fprintf(color.Output, "\t%s\t%s\n\t%s\t%s\n", blue(" provider from "),
+				yellow(strings.Join(e.PreviousProviders, ", ")), blue(" provider to "),
+				yellow(strings.Join(e.Providers, ", ")))
 		}
-		if !updates {
-			g.Println("No differences discovered")
+
+		prev, cur := strings.Join(e.PreviousCNAMEs, ","), strings.Join(e.CNAMETargets, ",")
+		if prev != cur {
+			fmt.Fprintf(color.Output, "\t%s\t%s\n\t%s\t%s\n", blue(" CNAME from "), yellow(prev),
+				blue(" CNAME to "), yellow(cur))
 		}
-		prev = enum
 	}
 }
 
-func blueLine() {
-	for i := 0; i < 8; i++ {
-		b.Fprint(color.Output, "----------")
+// summary writes the complete differences and statistics of the enumerations compared.
+func (t *trackOutput) summary(comp *core.TrackComparison) {
+	comp.Timestamp = t.times[comp.Enum]
+	comp.PreviousTimestamp = t.times[comp.PreviousEnum]
+	t.report.Comparison = comp
+
+	switch t.format {
+	case "ndjson":
+		t.enc.Encode(comp)
+	case "text":
+		t.printSummary(comp)
 	}
-	fmt.Println()
 }
 
-func diffEnumOutput(out1, out2 []*core.Output) []string {
-	omap1 := make(map[string]*core.Output)
-	omap2 := make(map[string]*core.Output)
+func (t *trackOutput) printSummary(comp *core.TrackComparison) {
+	fmt.Println()
+	blueLine()
+	fmt.Fprintf(color.Output, "%s\n", blue(fmt.Sprintf("%-16s%10s%10s%10s%10s",
+		"", "Previous", "Current", "Added", "Removed")))
 
-	for _, o := range out1 {
-		omap1[o.Name] = o
+	row := func(label string, prev, cur int, diff core.TrackSetDiff) {
+		fmt.Fprintf(color.Output, "%s%s%s%s%s\n", blue(fmt.Sprintf("%-16s", label)),
+			yellow(fmt.Sprintf("%10d", prev)), yellow(fmt.Sprintf("%10d", cur)),
+			green(fmt.Sprintf("%10d", len(diff.Added))), r.Sprintf("%10d", len(diff.Removed)))
 	}
-	for _, o := range out2 {
-		omap2[o.Name] = o
+	row("Names", comp.PreviousStats.Names, comp.Stats.Names, comp.Names)
+	row("Addresses", comp.PreviousStats.Addresses, comp.Stats.Addresses, comp.Addresses)
+	row("Netblocks", comp.PreviousStats.Netblocks, comp.Stats.Netblocks, comp.Netblocks)
+	row("ASNs", comp.PreviousStats.ASNs, comp.Stats.ASNs, comp.ASNs)
+	row("CNAME targets", comp.PreviousStats.CNAMETargets, comp.Stats.CNAMETargets, comp.CNAMETargets)
+	fmt.Fprintf(color.Output, "\n%s%s%s%s\n", blue("Names changed: "), yellow(strconv.Itoa(comp.NamesChanged)),
+		blue(", unchanged: "), yellow(strconv.Itoa(comp.NamesUnchanged)))
+
+	list := func(label string, values []string) {
+		if len(values) > 0 {
+			fmt.Fprintf(color.Output, "%s%s\n", blue(label+": "), yellow(strings.Join(values, ", ")))
+		}
 	}
+	list("Netblocks added", comp.Netblocks.Added)
+	list("Netblocks removed", comp.Netblocks.Removed)
+	list("ASNs added", comp.ASNs.Added)
+	list("ASNs removed", comp.ASNs.Removed)
+	list("CNAME targets added", comp.CNAMETargets.Added)
+	list("CNAME targets removed", comp.CNAMETargets.Removed)
+}
 
-	handled := make(map[string]struct{})
-	var diff []string
-	for _, o := range out1 {
-		handled[o.Name] = struct{}{}
+// printMovements prints the number of changed names in each movement category.
+func (t *trackOutput) printMovements() {
+	fmt.Println()
+	blueLine()
+	b.Fprintln(color.Output, "Infrastructure movement")
+	for _, c := range core.TrackCategories {
+		fmt.Fprintf(color.Output, "%s%s\n", blue(fmt.Sprintf("%-24s", core.TrackCategoryLabels[c]+":")),
+			yellow(strconv.Itoa(t.movements[c])))
+	}
+}
 
-		if _, found := omap2[o.Name]; !found {
-			diff = append(diff, fmt.Sprintf("%s%s %s", blue("Found: "),
-				green(o.Name), yellow(lineOfAddresses(o.Addresses))))
-			continue
+// close writes the movement summary, and the report when the output is a JSON document.
+func (t *trackOutput) close() {
+	if len(t.report.Events) > 0 {
+		t.report.Movements = &core.TrackMovements{
+			Type:       core.TrackMovementSummary,
+			Categories: make(map[string]int),
+		}
+		for _, c := range core.TrackCategories {
+			t.report.Movements.Categories[c] = t.movements[c]
 		}
 
-		o2 := omap2[o.Name]
-		if !compareAddresses(o.Addresses, o2.Addresses) {
-			diff = append(diff, fmt.Sprintf("%s%s\n\t%s\t%s\n\t%s\t%s", blue("Moved: "),
-				green(o.Name), blue(" from "), yellow(lineOfAddresses(o2.Addresses)),
-				blue(" to "), yellow(lineOfAddresses(o.Addresses))))
+		switch t.format {
+		case "ndjson":
+			t.enc.Encode(t.report.Movements)
+		case "text":
+			t.printMovements()
 		}
 	}
 
-	for _, o := range out2 {
-		if _, found := handled[o.Name]; found {
-			continue
+	if t.format == "json" {
+		t.enc.SetIndent("", "  ")
+		t.enc.Encode(t.report)
+	}
+}
+
+func cumulativeOutput(domains []string, enums []string, ea, la []time.Time,
+	sigs []*amass.TakeoverSignature, db handlers.DataHandler, out *trackOutput) {
+	idx := len(enums) - 1
+	out.header(ea[0], la[0], ea[idx], la[idx])
+
+	earliest, err := queryDBOutput(enums[idx], domains, db)
+	if err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+
+	// The names from the later enumerations are streamed starting with the most recent, so
+	// each name is compared with the earliest enumeration using the latest data available
+	var enum string
+	cum := func(fn func(*handlers.QueryResult) bool) {
+		for i := 0; i < idx && err == nil; i++ {
+			enum = enums[i]
+			err = streamQueryResults(enum, domains, db, fn)
 		}
+	}
 
-		if _, found := omap1[o.Name]; !found {
-			diff = append(diff, fmt.Sprintf("%s%s %s", blue("Removed: "),
-				green(o.Name), yellow(lineOfAddresses(o.Addresses))))
+	comp := amass.CompareEnumerationStream(cum, earliest, enums[0], enums[idx], sigs, func(e *core.TrackEvent) {
+		// Added and changed names are reported with the enumeration that provided them
+		if e.Type != core.TrackRemoved {
+			e.Enum = enum
 		}
+		out.add(e)
+	})
+	if err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if !comparisonChanged(comp) {
+		out.noChanges()
 	}
-	return diff
 }
 
-func lineOfAddresses(addrs []core.AddressInfo) string {
-	var line string
+func completeHistoryOutput(domains []string, enums []string, ea, la []time.Time,
+	sigs []*amass.TakeoverSignature, db handlers.DataHandler, out *trackOutput) {
+	for i := 1; i < len(enums); i++ {
+		if i != 1 && out.format == "text" {
+			fmt.Println()
+		}
+
+		out.header(ea[i-1], la[i-1], ea[i], la[i])
+
+		later, earlier := enums[i-1], enums[i]
+		prev, err := queryDBOutput(earlier, domains, db)
+		if err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+
+		cur := func(fn func(*handlers.QueryResult) bool) {
+			err = streamQueryResults(later, domains, db, fn)
+		}
 
-	for i, addr := range addrs {
-		if i != 0 {
-			line = line + ","
+		comp := amass.CompareEnumerationStream(cur, prev, later, earlier, sigs, out.add)
+		if err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		if !comparisonChanged(comp) {
+			out.noChanges()
 		}
-		line = line + addr.Address.String()
 	}
-	return line
 }
 
-func compareAddresses(addr1, addr2 []core.AddressInfo) bool {
-	for _, a1 := range addr1 {
-		var found bool
+// comparisonOutput provides the complete differences between the later and earlier enumerations.
+func comparisonOutput(domains []string, enums []string, ea, la []time.Time, later, earlier int,
+	sigs []*amass.TakeoverSignature, db handlers.DataHandler, out *trackOutput) {
+	// The enumerations are ordered from the most recent
+	if later > earlier {
+		later, earlier = earlier, later
+	}
+	out.header(ea[later], la[later], ea[earlier], la[earlier])
 
-		for _, a2 := range addr2 {
-			if a1.Address.Equal(a2.Address) {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
+	prev, err := queryDBOutput(enums[earlier], domains, db)
+	if err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+
+	// The names of the later enumeration are compared as they are streamed
+	cur := func(fn func(*handlers.QueryResult) bool) {
+		err = streamQueryResults(enums[later], domains, db, fn)
+	}
+
+	comp := amass.CompareEnumerationStream(cur, prev, enums[later], enums[earlier], sigs, out.add)
+	if err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if !comparisonChanged(comp) {
+		out.noChanges()
+	}
+	out.summary(comp)
+}
+
+// comparisonChanged returns true when names were added, removed or changed.
+func comparisonChanged(comp *core.TrackComparison) bool {
+	return comp.NamesChanged > 0 || len(comp.Names.Added) > 0 || len(comp.Names.Removed) > 0
+}
+
+// streamQueryResults calls fn with the names in the enumeration that are within the domains,
+// along with the CNAME targets of each name.
+func streamQueryResults(id string, domains []string, db handlers.DataHandler, fn func(*handlers.QueryResult) bool) error {
+	return db.StreamQuery(id, nil, func(result *handlers.QueryResult) bool {
+		if len(domains) == 0 || domainNameInScope(result.Name, domains) {
+			return fn(result)
 		}
+		return true
+	})
+}
+
+// queryDBOutput returns the names in the enumeration that are within the domains, along with
+// the CNAME targets of each name.
+func queryDBOutput(id string, domains []string, db handlers.DataHandler) ([]*handlers.QueryResult, error) {
+	var results []*handlers.QueryResult
+
+	err := streamQueryResults(id, domains, db, func(result *handlers.QueryResult) bool {
+		results = append(results, result)
+		return true
+	})
+	return results, err
+}
+
+func blueLine() {
+	for i := 0; i < 8; i++ {
+		b.Fprint(color.Output, "----------")
 	}
-	return true
+	fmt.Println()
 }
@@ -12,6 +12,7 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/root-secure/Amass/amass/core"
@@ -22,7 +23,7 @@ import (
 )
 
 const (
-	vizUsageMsg = "viz -d3|-gexf|-graphistry|-maltego|-visjs [options]"
+	vizUsageMsg = "viz -d3|-gexf|-graphistry|-maltego|-stix|-visjs [options]"
 )
 
 type vizArgs struct {
@@ -33,6 +34,7 @@ type vizArgs struct {
 		GEXF       bool
 		Graphistry bool
 		Maltego    bool
+		STIX       bool
 		VisJS      bool
 	}
 	Filepaths struct {
@@ -65,6 +67,7 @@ func runVizCommand(clArgs []string) {
 	vizCommand.BoolVar(&args.Options.GEXF, "gexf", false, "Generate the Gephi Graph Exchange XML Format (GEXF) file")
 	vizCommand.BoolVar(&args.Options.Graphistry, "graphistry", false, "Generate the Graphistry JSON file")
 	vizCommand.BoolVar(&args.Options.Maltego, "maltego", false, "Generate the Maltego csv file")
+	vizCommand.BoolVar(&args.Options.STIX, "stix", false, "Generate the STIX 2.1 JSON bundle")
 	vizCommand.BoolVar(&args.Options.VisJS, "visjs", false, "Generate the Visjs output HTML file")
 
 	if len(clArgs) < 1 {
@@ -83,7 +86,7 @@ func runVizCommand(clArgs []string) {
 
 	// Make sure at least one graph file format has been identified on the command-line
 	if !args.Options.D3 && !args.Options.GEXF &&
-		!args.Options.Graphistry && !args.Options.Maltego && !args.Options.VisJS {
+		!args.Options.Graphistry && !args.Options.Maltego && !args.Options.STIX && !args.Options.VisJS {
 		r.Fprintln(color.Error, "At least one file format must be selected")
 		os.Exit(1)
 	}
@@ -154,26 +157,82 @@ func runVizCommand(clArgs []string) {
 		os.Exit(1)
 	}
 
-	nodes, edges := db.VizData(uuid)
-	if args.Options.D3 {
-		dir := filepath.Join(args.Filepaths.Output, "amass_d3.html")
-		writeD3File(dir, nodes, edges)
-	}
+	// The GEXF, Graphistry, Maltego and Visjs files are written while the graph is streamed.
+	// The D3 and STIX files need the complete graph, since D3 sizes the nodes by the largest
+	// number of edges and STIX groups every object, so they are built from the nodes and
+	// edges collected during the stream
+	var writers []viz.StreamWriter
 	if args.Options.GEXF {
-		dir := filepath.Join(args.Filepaths.Output, "amass.gexf")
-		writeGEXFFile(dir, nodes, edges)
+		if f := createVizFile(filepath.Join(args.Filepaths.Output, "amass.gexf")); f != nil {
+			w := &vizFileWriter{StreamWriter: viz.NewGEXFWriter(f), f: f}
+			defer w.Close()
+			writers = append(writers, w)
+		}
 	}
 	if args.Options.Graphistry {
-		dir := filepath.Join(args.Filepaths.Output, "amass_graphistry.gexf")
-		writeGraphistryFile(dir, nodes, edges)
+		if f := createVizFile(filepath.Join(args.Filepaths.Output, "amass_graphistry.gexf")); f != nil {
+			w := &vizFileWriter{StreamWriter: viz.NewGraphistryWriter(f), f: f}
+			defer w.Close()
+			writers = append(writers, w)
+		}
 	}
 	if args.Options.Maltego {
-		dir := filepath.Join(args.Filepaths.Output, "amass_maltego.csv")
-		writeMaltegoFile(dir, nodes, edges)
+		if f := createVizFile(filepath.Join(args.Filepaths.Output, "amass_maltego.csv")); f != nil {
+			w := &vizFileWriter{StreamWriter: viz.NewMaltegoWriter(f), f: f}
+			defer w.Close()
+			writers = append(writers, w)
+		}
 	}
 	if args.Options.VisJS {
-		dir := filepath.Join(args.Filepaths.Output, "amass_visjs.html")
-		writeVisjsFile(dir, nodes, edges)
+		if f := createVizFile(filepath.Join(args.Filepaths.Output, "amass_visjs.html")); f != nil {
+			w := &vizFileWriter{StreamWriter: viz.NewVisjsWriter(f), f: f}
+			defer w.Close()
+			writers = append(writers, w)
+		}
+	}
+	collect := args.Options.D3 || args.Options.STIX
+
+	var nodes []viz.Node
+	var edges []viz.Edge
+	annotations := db.Annotations()
+	err = db.StreamVizData(uuid, func(n viz.Node) bool {
+		annotateVizNode(&n, annotations)
+		for _, w := range writers {
+			w.WriteNode(n)
+		}
+		if collect {
+			nodes = append(nodes, n)
+		}
+		return true
+	}, func(e viz.Edge) bool {
+		for _, w := range writers {
+			w.WriteEdge(e)
+		}
+		if collect {
+			edges = append(edges, e)
+		}
+		return true
+	})
+	if err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		return
+	}
+
+	if args.Options.D3 {
+		dir := filepath.Join(args.Filepaths.Output, "amass_d3.html")
+		writeD3File(dir, nodes, edges)
+	}
+	if args.Options.STIX {
+		dir := filepath.Join(args.Filepaths.Output, "amass_stix.json")
+		first, last := db.EnumerationDateRange(uuid)
+		writeSTIXFile(dir, uuid, first, last, nodes, edges)
+	}
+}
+
+// annotateVizNode adds the annotation of the asset to the title of the node.
+func annotateVizNode(node *viz.Node, annotations map[string]*handlers.Annotation) {
+	if a, found := annotations[strings.ToLower(node.Label)]; found && !a.Empty() {
+		node.Title = node.Title + ", Annotation: " + a.String()
 	}
 }
 
@@ -194,7 +253,14 @@ func inputFileToDB(args *vizArgs) (string, handlers.DataHandler, error) {
 	if err != nil {
 		return "", nil, fmt.Errorf("Failed to parse the provided data operations: %v", err)
 	}
-	uuid := opts[0].UUID
+	// Annotations in the file are not part of the enumeration
+	var uuid string
+	for _, opt := range opts {
+		if opt.UUID != "" {
+			uuid = opt.UUID
+			break
+		}
+	}
 
 	graph := handlers.NewGraph(args.Filepaths.Directory)
 	if graph == nil {
@@ -208,47 +274,36 @@ func inputFileToDB(args *vizArgs) (string, handlers.DataHandler, error) {
 	return uuid, graph, nil
 }
 
-func writeMaltegoFile(path string, nodes []viz.Node, edges []viz.Edge) {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-
-	viz.WriteMaltegoData(f, nodes, edges)
-	f.Sync()
+// vizFileWriter closes the file after the stream writer has completed the output.
+type vizFileWriter struct {
+	viz.StreamWriter
+	f *os.File
 }
 
-func writeVisjsFile(path string, nodes []viz.Node, edges []viz.Edge) {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		return
-	}
-	defer f.Close()
+func (w *vizFileWriter) Close() error {
+	err := w.StreamWriter.Close()
 
-	viz.WriteVisjsData(f, nodes, edges)
-	f.Sync()
+	w.f.Sync()
+	w.f.Close()
+	return err
 }
 
-func writeGraphistryFile(path string, nodes []viz.Node, edges []viz.Edge) {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+func createVizFile(path string) *os.File {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
-		return
+		return nil
 	}
-	defer f.Close()
-
-	viz.WriteGraphistryData(f, nodes, edges)
-	f.Sync()
+	return f
 }
 
-func writeGEXFFile(path string, nodes []viz.Node, edges []viz.Edge) {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+func writeSTIXFile(path, uuid string, first, last time.Time, nodes []viz.Node, edges []viz.Edge) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return
 	}
 	defer f.Close()
 
-	viz.WriteGEXFData(f, nodes, edges)
+	viz.WriteSTIXData(f, uuid, first, last, nodes, edges)
 	f.Sync()
 }
 
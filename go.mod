@@ -4,7 +4,7 @@ go 1.12
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.0
-	github.com/boltdb/bolt v1.3.1 // indirect
+	github.com/boltdb/bolt v1.3.1
 	github.com/caffix/cloudflare-roundtripper v0.0.0-20181218223503-4c29d231c9cb
 	github.com/cayleygraph/cayley v0.7.5
 	github.com/cenkalti/backoff v2.1.1+incompatible // indirect
@@ -21,6 +21,7 @@ require (
 	github.com/johnnadratowski/golang-neo4j-bolt-driver v0.0.0-20181101021923-6b24c0085aae
 	github.com/lib/pq v1.1.1
 	github.com/mattn/go-colorable v0.1.2 // indirect
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/miekg/dns v1.1.15
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/qasaur/gremgo v0.0.0-20180719101618-fa23ada7c5da